@@ -14,9 +14,14 @@ import (
 	"github.com/loangraph/backend/internal/db"
 	admindomain "github.com/loangraph/backend/internal/domain/admin"
 	investordomain "github.com/loangraph/backend/internal/domain/investor"
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
 	passportdomain "github.com/loangraph/backend/internal/domain/passport"
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
 	"github.com/loangraph/backend/internal/http/handlers"
+	"github.com/loangraph/backend/internal/indexer"
 	"github.com/loangraph/backend/internal/observability"
 	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
 	"github.com/loangraph/backend/internal/server"
@@ -47,6 +52,7 @@ func main() {
 		postgresrepo.NewLoanRepository(pool),
 		postgresrepo.NewOutboxRepository(pool),
 	)
+	loanService.SetRealtimePublisher(postgresrepo.NewWSRepository(pool))
 	loanHandler := handlers.NewLoanHandler(loanService)
 	passportService := passportdomain.NewService(
 		postgresrepo.NewBorrowerRepository(pool),
@@ -60,13 +66,43 @@ func main() {
 		postgresrepo.NewLoanRepository(pool),
 	)
 	investorHandler := handlers.NewInvestorHandler(investorService)
+	poolService := pooldomain.NewService(
+		postgresrepo.NewPoolRepository(pool),
+		postgresrepo.NewLenderRepository(pool),
+		postgresrepo.NewLoanRepository(pool),
+		postgresrepo.NewAdminAuditRepository(pool),
+	)
+	poolHandler := handlers.NewPoolHandler(poolService)
+	positionService := positiondomain.NewService(
+		postgresrepo.NewPositionRepository(pool),
+		postgresrepo.NewPoolRepository(pool),
+	)
+	positionHandler := handlers.NewPositionHandler(positionService)
 	adminService := admindomain.NewService(
 		postgresrepo.NewLenderRepository(pool),
 		postgresrepo.NewAdminAuditRepository(pool),
 	)
-	adminHandler := handlers.NewAdminHandler(adminService)
+	outboxService := outboxdomain.NewService(
+		postgresrepo.NewOutboxRepository(pool),
+		postgresrepo.NewAdminAuditRepository(pool),
+	)
+	adminHandler := handlers.NewAdminHandler(adminService, outboxService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerdomain.NewService(postgresrepo.NewLedgerRepository(pool)))
+	indexerRepo := postgresrepo.NewIndexerRepository(pool)
+	chainHandler := handlers.NewChainHandler(
+		indexer.NewQuarantineService(indexerRepo, postgresrepo.NewAdminAuditRepository(pool)),
+		indexer.NewReconciliationService(indexerRepo, nil),
+	)
 	hub := ws.NewHub()
-	wsHandler := ws.NewHandler(hub)
+	wsRepo := postgresrepo.NewWSRepository(pool)
+	wsHandler := ws.NewHandler(hub, wsRepo)
+	wsHandler.SetOptions(ws.Options{
+		BufferSize:   int(cfg.WSClientBuffer),
+		DropPolicy:   cfg.WSDropPolicy,
+		PingInterval: cfg.WSPingInterval,
+		IdleTimeout:  cfg.WSIdleTimeout,
+	})
+	wsHandler.SetReplay(wsRepo)
 
 	r := server.NewRouter(cfg, logger, server.Dependencies{
 		Pinger:          pool,
@@ -74,7 +110,11 @@ func main() {
 		LoanHandler:     loanHandler,
 		PassportHandler: passportHandler,
 		InvestorHandler: investorHandler,
+		PoolHandler:     poolHandler,
+		PositionHandler: positionHandler,
 		AdminHandler:    adminHandler,
+		LedgerHandler:   ledgerHandler,
+		ChainHandler:    chainHandler,
 		WSHandler:       wsHandler,
 		JWTManager:      jwtManager,
 	})
@@ -94,8 +134,12 @@ func main() {
 
 	notifierCtx, notifierCancel := context.WithCancel(context.Background())
 	if cfg.WSEnabled {
-		wsRepo := postgresrepo.NewWSRepository(pool)
-		notifier := ws.NewNotifier(wsRepo, hub, cfg.WSPollInterval)
+		notifier := ws.NewNotifier(wsRepo, hub, cfg.WSNodeID, cfg.WSPollInterval, logger)
+		notifier.SetRetention(cfg.WSReplayRetention)
+		notifier.SetRevoker(wsHandler)
+		if cfg.WSListen {
+			notifier.SetListener(postgresrepo.NewProjectionListener(pool, logger))
+		}
 		go func() {
 			if err := notifier.Run(notifierCtx); err != nil && !errors.Is(err, context.Canceled) {
 				logger.Error("ws notifier failed", "err", err)
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/loangraph/backend/internal/blockchain"
+	"github.com/loangraph/backend/internal/config"
+	"github.com/loangraph/backend/internal/indexer"
+	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
+)
+
+const commandUsage = `usage: indexer [command] [flags]
+
+Without a command the indexer runs as a daemon. Commands:
+  backfill --from N --to M [--source a,b]
+      re-ingest a block range into chain_events without moving cursors
+  reset-cursor [--source a,b] [--block N] [--dry-run]
+      make ingestion resume from block N, or from the source's start block
+  reproject [--dry-run]
+      rebuild loans and passport_cache from chain_events and print the diff
+  reconcile [--enqueue] [--dry-run]
+      compare loans with the registry and chain_events and store the
+      discrepancies; --enqueue adds corrective outbox jobs
+`
+
+// runCommand runs a maintenance command and returns the process exit code.
+func runCommand(cfg config.Config, logger *slog.Logger, repo *postgresrepo.IndexerRepository, name string, args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch name {
+	case "backfill":
+		err = backfillCommand(ctx, cfg, logger, repo, args)
+	case "reset-cursor":
+		err = resetCursorCommand(ctx, cfg, repo, args)
+	case "reproject":
+		err = reprojectCommand(ctx, repo, args)
+	case "reconcile":
+		err = reconcileCommand(ctx, cfg, logger, repo, args)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, commandUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, commandUsage)
+		return 2
+	}
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		logger.Error("indexer command failed", "command", name, "err", err)
+		return 1
+	}
+}
+
+// errUsage reports invalid flags, which the flag set has already printed.
+var errUsage = errors.New("invalid usage")
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return errUsage
+	}
+	return nil
+}
+
+func splitNames(raw string) []string {
+	var out []string
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func backfillCommand(ctx context.Context, cfg config.Config, logger *slog.Logger, repo *postgresrepo.IndexerRepository, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	from := fs.Int64("from", -1, "first block to ingest")
+	to := fs.Int64("to", -1, "last block to ingest")
+	sources := fs.String("source", "", "comma-separated source names (default all)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *from < 0 || *to < *from {
+		fmt.Fprintln(os.Stderr, "backfill requires --from and --to with from <= to")
+		return errUsage
+	}
+
+	svc, err := newIngestionService(cfg, logger, repo)
+	if err != nil {
+		return err
+	}
+	n, err := svc.Backfill(ctx, uint64(*from), uint64(*to), splitNames(*sources))
+	fmt.Fprintf(os.Stdout, "backfilled blocks %d-%d: %d events read\n", *from, *to, n)
+	return err
+}
+
+func resetCursorCommand(ctx context.Context, cfg config.Config, repo *postgresrepo.IndexerRepository, args []string) error {
+	fs := flag.NewFlagSet("reset-cursor", flag.ContinueOnError)
+	names := fs.String("source", "", "comma-separated source names (default all)")
+	block := fs.Int64("block", -1, "block to resume from (default the source's start block)")
+	dryRun := fs.Bool("dry-run", false, "print the change without writing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	all, err := indexer.SourcesFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	sources := all
+	if selected := splitNames(*names); len(selected) > 0 {
+		sources = nil
+		for _, name := range selected {
+			found := false
+			for _, src := range all {
+				if src.Name == name {
+					sources = append(sources, src)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown indexer source %s", name)
+			}
+		}
+	}
+
+	for _, src := range sources {
+		last, ok, err := repo.GetIngestionCursor(ctx, src.CursorKey())
+		if err != nil {
+			return err
+		}
+		current := "unset"
+		if ok {
+			current = fmt.Sprintf("%d", last)
+		}
+		// The cursor holds the last ingested block; without one ingestion
+		// starts at the source's start block.
+		resumeFrom := src.StartBlock
+		if *block >= 0 {
+			resumeFrom = uint64(*block)
+		}
+		if resumeFrom < src.StartBlock {
+			return fmt.Errorf("source %s: block %d is before its start block %d", src.Name, resumeFrom, src.StartBlock)
+		}
+		next := "unset"
+		if resumeFrom > src.StartBlock {
+			next = fmt.Sprintf("%d", resumeFrom-1)
+		}
+		fmt.Fprintf(os.Stdout, "source %s: cursor %s -> %s (next ingest from block %d)\n", src.Name, current, next, resumeFrom)
+		if *dryRun {
+			continue
+		}
+		if next == "unset" {
+			err = repo.DeleteIngestionCursor(ctx, src.CursorKey())
+		} else {
+			err = repo.SetIngestionCursor(ctx, src.CursorKey(), resumeFrom-1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if *dryRun {
+		fmt.Fprintln(os.Stdout, "dry run: no cursors changed")
+	}
+	return nil
+}
+
+func reprojectCommand(ctx context.Context, repo indexer.ReprojectionRepository, args []string) error {
+	fs := flag.NewFlagSet("reproject", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the diff and roll back")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	report, err := repo.Reproject(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	printReprojectReport(os.Stdout, report, *dryRun)
+	return nil
+}
+
+func reconcileCommand(ctx context.Context, cfg config.Config, logger *slog.Logger, repo *postgresrepo.IndexerRepository, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	enqueue := fs.Bool("enqueue", false, "enqueue outbox jobs resending writes the chain is missing")
+	dryRun := fs.Bool("dry-run", false, "print the discrepancies without storing them or enqueueing jobs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.CreditcoinHTTPRPC) == "" || strings.TrimSpace(cfg.LoanRegistryProxy) == "" {
+		return fmt.Errorf("missing chain config: CREDITCOIN_HTTP_RPC and LOAN_REGISTRY_PROXY are required")
+	}
+	transport, err := blockchain.NewRPCTransportFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	transport.SetLogger(logger)
+	reader, err := blockchain.NewRPCRegistryReader(transport, cfg.LoanRegistryProxy)
+	if err != nil {
+		return err
+	}
+	// Read the registry at the block ingestion trusts, so it is compared with
+	// the same chain the events came from.
+	head, err := blockchain.NewJSONRPCLogClientWithTransport(transport).BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if head < cfg.IndexerConfirmations {
+		return fmt.Errorf("chain head %d is below the %d required confirmations", head, cfg.IndexerConfirmations)
+	}
+
+	svc := indexer.NewReconciliationService(repo, reader)
+	report, err := svc.Reconcile(ctx, indexer.ReconcileOptions{
+		BlockNumber: head - cfg.IndexerConfirmations,
+		Enqueue:     *enqueue,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+	printReconcileReport(os.Stdout, report, *dryRun)
+	return nil
+}
+
+func printReconcileReport(w io.Writer, report *indexer.ReconcileReport, dryRun bool) {
+	run := report.Run
+	fmt.Fprintf(w, "checked %d loans at block %d: %d discrepancies, %d corrective jobs\n", run.LoansChecked, run.BlockNumber, run.Discrepancies, run.JobsEnqueued)
+	for _, item := range report.Items {
+		line := fmt.Sprintf("  loan %s %s: db %s, chain %s, events %s", item.LoanID, item.Field, item.DBValue, item.ChainValue, item.EventsValue)
+		if item.InFlight {
+			line += " (outbox job pending or failed)"
+		}
+		if item.CorrectiveTopic != "" {
+			line += " -> " + item.CorrectiveTopic
+		}
+		fmt.Fprintln(w, line)
+	}
+	if dryRun {
+		fmt.Fprintln(w, "dry run: nothing stored or enqueued")
+	} else {
+		fmt.Fprintf(w, "stored as reconciliation run %d\n", run.ID)
+	}
+}
+
+func printReprojectReport(w io.Writer, report indexer.ReprojectReport, dryRun bool) {
+	fmt.Fprintf(w, "replayed %d chain events: %d loans and %d passports changed, %d ledger entries posted\n", report.Events, len(report.Loans), len(report.Passports), report.LedgerEntries)
+	for _, diff := range report.Loans {
+		b, a := diff.Before, diff.After
+		var changes []string
+		changes = appendChange(changes, "status", b.Status, a.Status)
+		changes = appendChange(changes, "amount_repaid_minor", b.AmountRepaidMinor, a.AmountRepaidMinor)
+		changes = appendChange(changes, "on_chain_tx", b.OnChainTX, a.OnChainTX)
+		changes = appendChange(changes, "on_chain_confirmed", b.OnChainConfirmed, a.OnChainConfirmed)
+		fmt.Fprintf(w, "  loan %s: %s\n", a.LoanID, strings.Join(changes, ", "))
+	}
+	for _, diff := range report.Passports {
+		a := diff.After
+		if diff.Before == nil {
+			fmt.Fprintf(w, "  passport %s: created (loans %d, repaid %d, defaulted %d, score %d)\n", a.BorrowerID, a.TotalLoans, a.TotalRepaid, a.TotalDefaulted, a.CreditScore)
+			continue
+		}
+		b := *diff.Before
+		var changes []string
+		changes = appendChange(changes, "total_loans", b.TotalLoans, a.TotalLoans)
+		changes = appendChange(changes, "total_repaid", b.TotalRepaid, a.TotalRepaid)
+		changes = appendChange(changes, "total_defaulted", b.TotalDefaulted, a.TotalDefaulted)
+		changes = appendChange(changes, "cumulative_borrowed", b.CumulativeBorrowed, a.CumulativeBorrowed)
+		changes = appendChange(changes, "cumulative_repaid", b.CumulativeRepaid, a.CumulativeRepaid)
+		changes = appendChange(changes, "credit_score", b.CreditScore, a.CreditScore)
+		fmt.Fprintf(w, "  passport %s: %s\n", a.BorrowerID, strings.Join(changes, ", "))
+	}
+	if dryRun {
+		fmt.Fprintln(w, "dry run: changes rolled back")
+	}
+}
+
+func appendChange[T comparable](changes []string, field string, before, after T) []string {
+	if before == after {
+		return changes
+	}
+	return append(changes, fmt.Sprintf("%s %v -> %v", field, before, after))
+}
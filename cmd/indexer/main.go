@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
@@ -17,6 +19,9 @@ import (
 	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
 )
 
+// catchUpRounds bounds how many block ranges one wake-up ingests.
+const catchUpRounds = 20
+
 func main() {
 	cfg := config.Load()
 	logger := observability.NewLogger(cfg.Env)
@@ -32,26 +37,33 @@ func main() {
 	defer pool.Close()
 
 	idxRepo := postgresrepo.NewIndexerRepository(pool)
-	svc := indexer.NewService(idxRepo, idxRepo)
+	if len(os.Args) > 1 {
+		code := runCommand(cfg, logger, idxRepo, os.Args[1], os.Args[2:])
+		pool.Close()
+		os.Exit(code)
+	}
+
+	svc := indexer.NewService(idxRepo)
+	svc.SetMaxAttempts(cfg.IndexerMaxAttempts)
 	var ingestSvc *indexer.IngestionService
 	if cfg.IndexerIngestEnabled {
-		if strings.TrimSpace(cfg.CreditcoinHTTPRPC) == "" || strings.TrimSpace(cfg.LoanRegistryProxy) == "" {
-			logger.Error("indexer ingestion enabled but missing chain config", "CREDITCOIN_HTTP_RPC", cfg.CreditcoinHTTPRPC != "", "LOAN_REGISTRY_PROXY", cfg.LoanRegistryProxy != "")
+		ingestSvc, err = newIngestionService(cfg, logger, idxRepo)
+		if err != nil {
+			logger.Error("failed to initialize indexer ingestion", "err", err)
 			os.Exit(1)
 		}
-		rpcClient, err := blockchain.NewJSONRPCLogClient(cfg.CreditcoinHTTPRPC)
+	}
+
+	// With a live subscription the indexer ingests as soon as a block lands
+	// and polls only while the subscription is down.
+	var subscriber *blockchain.HeadSubscriber
+	wake := make(chan struct{}, 1)
+	if ingestSvc != nil && strings.TrimSpace(cfg.CreditcoinWSRPC) != "" {
+		subscriber, err = blockchain.NewHeadSubscriber(cfg.CreditcoinWSRPC, ingestSvc.Filter(), logger)
 		if err != nil {
-			logger.Error("failed to initialize indexer rpc client", "err", err)
+			logger.Error("failed to initialize chain subscription", "err", err)
 			os.Exit(1)
 		}
-		ingestSvc = indexer.NewIngestionService(
-			idxRepo,
-			rpcClient,
-			cfg.LoanRegistryProxy,
-			cfg.IndexerStartBlock,
-			cfg.IndexerBlockBatchSize,
-			cfg.IndexerConfirmations,
-		)
 	}
 
 	interval := cfg.IndexerPollInterval
@@ -65,25 +77,69 @@ func main() {
 	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	logger.Info("indexer started", "interval", interval.String(), "batch_size", cfg.IndexerBatchSize, "ingestion_enabled", cfg.IndexerIngestEnabled)
+	if subscriber != nil {
+		go subscriber.Run(sigCtx, wake)
+	}
+
+	run := func(ingest func(context.Context) error) {
+		runCtx, runCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer runCancel()
+		if ingest != nil {
+			err := ingest(runCtx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("indexer ingestion failed", "err", err)
+			}
+		}
+		err := svc.RunOnce(runCtx, cfg.IndexerBatchSize)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("indexer run failed", "err", err)
+		}
+	}
+
+	logger.Info("indexer started", "interval", interval.String(), "batch_size", cfg.IndexerBatchSize, "ingestion_enabled", cfg.IndexerIngestEnabled, "subscription_enabled", subscriber != nil)
 	for {
 		select {
 		case <-sigCtx.Done():
 			logger.Info("indexer stopped")
 			return
+		case <-wake:
+			// Catching up from the cursor also backfills blocks missed while
+			// the subscription was down.
+			run(func(ctx context.Context) error { return ingestSvc.CatchUp(ctx, catchUpRounds) })
 		case <-ticker.C:
-			runCtx, runCancel := context.WithTimeout(context.Background(), 30*time.Second)
-			if ingestSvc != nil {
-				err := ingestSvc.RunOnce(runCtx)
-				if err != nil && !errors.Is(err, context.Canceled) {
-					logger.Error("indexer ingestion failed", "err", err)
-				}
-			}
-			err := svc.RunOnce(runCtx, cfg.IndexerBatchSize)
-			runCancel()
-			if err != nil && !errors.Is(err, context.Canceled) {
-				logger.Error("indexer run failed", "err", err)
+			if ingestSvc != nil && (subscriber == nil || !subscriber.Connected()) {
+				run(ingestSvc.RunOnce)
+			} else {
+				run(nil)
 			}
 		}
 	}
 }
+
+// newIngestionService builds ingestion for the configured sources, logging
+// each one.
+func newIngestionService(cfg config.Config, logger *slog.Logger, repo *postgresrepo.IndexerRepository) (*indexer.IngestionService, error) {
+	sources, err := indexer.SourcesFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid indexer sources: %w", err)
+	}
+	if strings.TrimSpace(cfg.CreditcoinHTTPRPC) == "" || len(sources) == 0 {
+		return nil, fmt.Errorf("missing chain config: CREDITCOIN_HTTP_RPC and LOAN_REGISTRY_PROXY or INDEXER_SOURCES_FILE are required")
+	}
+	transport, err := blockchain.NewRPCTransportFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.SetLogger(logger)
+	rpcClient := blockchain.NewJSONRPCLogClientWithTransport(transport)
+	for _, src := range sources {
+		logger.Info("indexing source", "name", src.Name, "addresses", src.Addresses, "start_block", src.StartBlock, "events", len(src.Events))
+	}
+	return indexer.NewMultiSourceIngestionService(
+		repo,
+		rpcClient,
+		sources,
+		cfg.IndexerBlockBatchSize,
+		cfg.IndexerConfirmations,
+	), nil
+}
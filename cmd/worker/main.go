@@ -36,11 +36,48 @@ func main() {
 		os.Exit(1)
 	}
 
+	if rpcWriter, ok := writer.(*blockchain.RPCWriter); ok {
+		rpcWriter.Transport().SetLogger(logger)
+	}
+	if managed, ok := writer.(blockchain.NonceManagedWriter); ok {
+		managed.SetNonceManager(blockchain.NewNonceManager(postgresrepo.NewNonceRepository(pool), cfg.ChainTxStuckAfter, cfg.ChainTxFeeBumpPercent))
+	}
+	if tracked, ok := writer.(blockchain.FeeTrackedWriter); ok {
+		tracked.SetFeeStore(postgresrepo.NewChainFeeRepository(pool), logger)
+	}
+
+	outboxRepo := postgresrepo.NewOutboxRepository(pool)
 	worker := jobs.NewWorker(
-		postgresrepo.NewOutboxRepository(pool),
+		outboxRepo,
 		postgresrepo.NewLoanRepository(pool),
 		writer,
 	)
+	worker.SetPositionRepository(postgresrepo.NewPositionRepository(pool))
+	worker.SetConcurrency(int(cfg.WorkerConcurrency))
+	worker.SetBatching(int(cfg.WorkerChainBatchSize), postgresrepo.NewChainBatchRepository(pool))
+	worker.SetLease(cfg.WorkerID, cfg.WorkerLeaseDuration)
+
+	retryPolicy := jobs.DefaultRetryPolicy()
+	if cfg.WorkerMaxAttempts > 0 {
+		retryPolicy.MaxAttempts = cfg.WorkerMaxAttempts
+	}
+	if cfg.WorkerRetryBaseDelay > 0 {
+		retryPolicy.BaseDelay = cfg.WorkerRetryBaseDelay
+	}
+	if cfg.WorkerRetryMaxDelay >= retryPolicy.BaseDelay {
+		retryPolicy.MaxDelay = cfg.WorkerRetryMaxDelay
+	}
+	worker.SetRetryPolicy(retryPolicy)
+	topicPolicies, err := jobs.ParseRetryPolicies(cfg.WorkerRetryPolicies, retryPolicy)
+	if err != nil {
+		logger.Error("invalid WORKER_RETRY_POLICIES", "err", err)
+		os.Exit(1)
+	}
+	topicMaxAttempts := make(map[string]int32, len(topicPolicies))
+	for topic, policy := range topicPolicies {
+		worker.SetTopicRetryPolicy(topic, policy)
+		topicMaxAttempts[topic] = policy.MaxAttempts
+	}
 
 	interval := cfg.WorkerPollInterval
 	if interval <= 0 {
@@ -50,17 +87,47 @@ func main() {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	reapInterval := cfg.WorkerReapInterval
+	if reapInterval <= 0 {
+		reapInterval = 30 * time.Second
+	}
+	reapTicker := time.NewTicker(reapInterval)
+	defer reapTicker.Stop()
+
 	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	logger.Info("worker started", "interval", interval.String(), "batch_size", cfg.WorkerBatchSize)
+	logger.Info("worker started", "interval", interval.String(), "batch_size", cfg.WorkerBatchSize, "concurrency", cfg.WorkerConcurrency)
 	for {
 		select {
 		case <-sigCtx.Done():
 			logger.Info("worker stopped")
 			return
+		case <-reapTicker.C:
+			// Jobs whose worker crashed keep their expired lease until reaped;
+			// any replica may reap them.
+			reapCtx, reapCancel := context.WithTimeout(sigCtx, 10*time.Second)
+			reaped, failed, err := outboxRepo.ReapExpiredLeases(reapCtx, retryPolicy.MaxAttempts, topicMaxAttempts)
+			reapCancel()
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("outbox lease reap failed", "err", err)
+			} else if reaped > 0 || failed > 0 {
+				logger.Warn("reaped expired outbox leases", "requeued", reaped, "failed", failed)
+			}
+			if replacer, ok := writer.(blockchain.StuckTxReplacer); ok {
+				replaceCtx, replaceCancel := context.WithTimeout(sigCtx, 30*time.Second)
+				replaced, err := replacer.ReplaceStuck(replaceCtx)
+				replaceCancel()
+				if err != nil && !errors.Is(err, context.Canceled) {
+					logger.Error("stuck transaction replacement failed", "err", err)
+				} else if replaced > 0 {
+					logger.Warn("replaced stuck transactions", "count", replaced)
+				}
+			}
 		case <-ticker.C:
-			runCtx, runCancel := context.WithTimeout(context.Background(), 30*time.Second)
+			// Derived from sigCtx so a shutdown stops the batch and releases
+			// its unstarted jobs instead of leaving them in processing.
+			runCtx, runCancel := context.WithTimeout(sigCtx, 30*time.Second)
 			err := worker.RunOnce(runCtx, cfg.WorkerBatchSize)
 			runCancel()
 			if err != nil && !errors.Is(err, context.Canceled) {
@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind classifies chain errors by how a caller should react to them.
+type ErrorKind string
+
+const (
+	// KindTransient covers network failures, timeouts, node overload and any
+	// error that is not known to be deterministic. Retrying may succeed.
+	KindTransient ErrorKind = "transient"
+	// KindNonceTooLow means the node rejected the transaction nonce; the next
+	// attempt picks a fresh nonce, so it is retried promptly.
+	KindNonceTooLow ErrorKind = "nonce_too_low"
+	// KindAlreadyKnown means the node already holds this exact transaction,
+	// so an earlier send whose response was lost went through. Resending it
+	// under another nonce would repeat the write.
+	KindAlreadyKnown ErrorKind = "already_known"
+	// KindReplacementUnderpriced means another transaction already holds the
+	// nonce and the fee was not bumped enough to replace it.
+	KindReplacementUnderpriced ErrorKind = "replacement_underpriced"
+	// KindReverted means the contract call reverted. Reverts can depend on
+	// chain state (e.g. a loan not yet registered), so they are retried.
+	KindReverted ErrorKind = "reverted"
+	// KindFeeBudgetExceeded means sending would take the day's fee spend over
+	// the daily budget. Nothing is sent until the budget resets at the next
+	// UTC midnight.
+	KindFeeBudgetExceeded ErrorKind = "fee_budget_exceeded"
+	// KindPermanent covers invalid arguments and other errors that will fail
+	// the same way on every attempt.
+	KindPermanent ErrorKind = "permanent"
+)
+
+// Error is a classified chain error. The message is that of the wrapped error
+// so stored last_error values stay readable.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func Transient(err error) error   { return wrapKind(KindTransient, err) }
+func NonceTooLow(err error) error { return wrapKind(KindNonceTooLow, err) }
+func Reverted(err error) error    { return wrapKind(KindReverted, err) }
+func Permanent(err error) error   { return wrapKind(KindPermanent, err) }
+
+func AlreadyKnown(err error) error {
+	return wrapKind(KindAlreadyKnown, err)
+}
+
+func ReplacementUnderpriced(err error) error {
+	return wrapKind(KindReplacementUnderpriced, err)
+}
+
+func wrapKind(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf reports how err is classified. Unclassified errors are treated as
+// transient so unknown failures are retried rather than dropped.
+func KindOf(err error) ErrorKind {
+	var chainErr *Error
+	if errors.As(err, &chainErr) {
+		return chainErr.Kind
+	}
+	return KindTransient
+}
+
+// IsPermanent reports whether retrying err cannot succeed.
+func IsPermanent(err error) bool {
+	return err != nil && KindOf(err) == KindPermanent
+}
+
+// classifyRPCError maps a JSON-RPC error object to an error kind. Node clients
+// disagree on codes, so the message is matched as well.
+func classifyRPCError(code int, message string) error {
+	err := fmt.Errorf("rpc error %d: %s", code, message)
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "already known"):
+		return AlreadyKnown(err)
+	case strings.Contains(msg, "nonce too low"):
+		return NonceTooLow(err)
+	case strings.Contains(msg, "replacement transaction underpriced"), strings.Contains(msg, "underpriced"):
+		return ReplacementUnderpriced(err)
+	case code == 3, strings.Contains(msg, "execution reverted"), strings.Contains(msg, "revert"):
+		return Reverted(err)
+	case code == -32600, code == -32601, code == -32602,
+		strings.Contains(msg, "invalid argument"), strings.Contains(msg, "intrinsic gas too low"):
+		return Permanent(err)
+	default:
+		return Transient(err)
+	}
+}
@@ -15,5 +15,30 @@ func NewWriterFromConfig(cfg config.Config) (LoanRegistryWriter, error) {
 	if mode != "real" {
 		return nil, fmt.Errorf("invalid CHAIN_WRITER_MODE: %s", cfg.ChainWriterMode)
 	}
-	return NewRPCWriter(cfg.CreditcoinHTTPRPC, cfg.ChainWriterFromAddress, cfg.LoanRegistryProxy, cfg.ChainTxGasLimit)
+	transport, err := NewRPCTransportFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewRPCWriterWithTransport(transport, cfg.ChainWriterFromAddress, cfg.LoanRegistryProxy, cfg.ChainTxGasLimit)
+	if err != nil {
+		return nil, err
+	}
+	policy := FeePolicy{GasMultiplierPercent: cfg.ChainGasPaddingPercent}
+	if policy.MaxFeePerGasWei, err = ParseWei("CHAIN_MAX_FEE_PER_GAS_WEI", cfg.ChainMaxFeePerGasWei); err != nil {
+		return nil, err
+	}
+	if policy.MaxPriorityFeeWei, err = ParseWei("CHAIN_MAX_PRIORITY_FEE_WEI", cfg.ChainMaxPriorityFeeWei); err != nil {
+		return nil, err
+	}
+	if policy.DailyBudgetWei, err = ParseWei("CHAIN_DAILY_FEE_BUDGET_WEI", cfg.ChainDailyFeeBudgetWei); err != nil {
+		return nil, err
+	}
+	writer.SetFeePolicy(policy)
+	return writer, nil
+}
+
+// NewRPCTransportFromConfig builds the JSON-RPC transport over the
+// comma-separated CREDITCOIN_HTTP_RPC endpoints.
+func NewRPCTransportFromConfig(cfg config.Config) (*RPCTransport, error) {
+	return NewRPCTransport(ParseRPCEndpoints(cfg.CreditcoinHTTPRPC), cfg.ChainRPCTimeout, int(cfg.ChainRPCReadRetries))
 }
@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	feeHistoryBlocks           = 10
+	feeHistoryRewardPercentile = 50
+)
+
+// defaultPriorityFeeWei is used when the node returns no reward samples.
+var defaultPriorityFeeWei = big.NewInt(1_000_000_000)
+
+var ErrFeeBudgetExceeded = wrapKind(KindFeeBudgetExceeded, errors.New("daily_fee_budget_exceeded"))
+
+// FeePolicy controls gas estimation and EIP-1559 fee selection. Nil caps and
+// budget mean unlimited.
+type FeePolicy struct {
+	// GasLimitCap bounds the estimated gas limit; it is also the fallback when
+	// estimation is disabled.
+	GasLimitCap uint64
+	// GasMultiplierPercent pads eth_estimateGas results, e.g. 120 for +20%.
+	GasMultiplierPercent int64
+	MaxFeePerGasWei      *big.Int
+	MaxPriorityFeeWei    *big.Int
+	// DailyBudgetWei bounds the summed worst-case cost (gas * maxFeePerGas)
+	// of transactions sent per UTC day.
+	DailyBudgetWei *big.Int
+}
+
+// FeeQuote is the gas limit and fees chosen for one transaction. Legacy
+// nodes without eth_feeHistory get GasPriceWei instead of the 1559 fields.
+type FeeQuote struct {
+	Gas               uint64
+	MaxFeePerGasWei   *big.Int
+	MaxPriorityFeeWei *big.Int
+	GasPriceWei       *big.Int
+}
+
+// MaxCostWei is the most the transaction can cost.
+func (q FeeQuote) MaxCostWei() *big.Int {
+	price := q.MaxFeePerGasWei
+	if price == nil {
+		price = q.GasPriceWei
+	}
+	if price == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(q.Gas))
+}
+
+// FeeStore keeps per-day fee totals. Spend is reserved against the daily
+// budget before a transaction is sent, and recorded per outbox topic once it
+// is, which doubles as the fee metrics.
+type FeeStore interface {
+	// ReserveSpend adds costWei to the day's reserved total unless that would
+	// take it over budgetWei, and reports whether it did. Checking and adding
+	// is one statement, so concurrent senders cannot overshoot together.
+	ReserveSpend(ctx context.Context, day time.Time, costWei, budgetWei *big.Int) (bool, error)
+	// ReleaseSpend returns a reservation whose transaction was not sent.
+	ReleaseSpend(ctx context.Context, day time.Time, costWei *big.Int) error
+	RecordSpend(ctx context.Context, day time.Time, topic string, gas uint64, maxCostWei *big.Int) error
+}
+
+// FeeTrackedWriter is implemented by writers that price their own
+// transactions and can report what they spend.
+type FeeTrackedWriter interface {
+	SetFeeStore(store FeeStore, logger *slog.Logger)
+}
+
+// ParseWei parses a decimal wei amount from config. Empty means unset.
+func ParseWei(name, value string) (*big.Int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	out, ok := new(big.Int).SetString(value, 10)
+	if !ok || out.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return out, nil
+}
+
+// PaddedGas applies the policy's multiplier to an estimate and caps it.
+func (p FeePolicy) PaddedGas(estimate uint64) uint64 {
+	percent := p.GasMultiplierPercent
+	if percent < 100 {
+		percent = 100
+	}
+	gas := new(big.Int).Mul(new(big.Int).SetUint64(estimate), big.NewInt(percent))
+	gas.Add(gas, big.NewInt(99))
+	gas.Quo(gas, big.NewInt(100))
+	if !gas.IsUint64() || (p.GasLimitCap > 0 && gas.Uint64() > p.GasLimitCap) {
+		return p.GasLimitCap
+	}
+	return gas.Uint64()
+}
+
+// DynamicFees picks EIP-1559 fees from eth_feeHistory samples: the median
+// reward as priority fee and twice the next block's base fee plus priority as
+// max fee, which survives several consecutive full blocks. Both are capped.
+func (p FeePolicy) DynamicFees(nextBaseFee *big.Int, rewards []*big.Int) (maxFee, priority *big.Int) {
+	priority = medianWei(rewards)
+	if priority == nil {
+		priority = new(big.Int).Set(defaultPriorityFeeWei)
+	}
+	if p.MaxPriorityFeeWei != nil && p.MaxPriorityFeeWei.Sign() > 0 && priority.Cmp(p.MaxPriorityFeeWei) > 0 {
+		priority = new(big.Int).Set(p.MaxPriorityFeeWei)
+	}
+	maxFee = new(big.Int).Mul(nextBaseFee, big.NewInt(2))
+	maxFee.Add(maxFee, priority)
+	if p.MaxFeePerGasWei != nil && p.MaxFeePerGasWei.Sign() > 0 && maxFee.Cmp(p.MaxFeePerGasWei) > 0 {
+		maxFee = new(big.Int).Set(p.MaxFeePerGasWei)
+		if priority.Cmp(maxFee) > 0 {
+			priority = new(big.Int).Set(maxFee)
+		}
+	}
+	return maxFee, priority
+}
+
+// HasBudget reports whether the policy limits daily spend.
+func (p FeePolicy) HasBudget() bool {
+	return p.DailyBudgetWei != nil && p.DailyBudgetWei.Sign() > 0
+}
+
+// CapFees lowers the fees of tx to the policy's caps, keeping the priority
+// fee within the max fee. A legacy gas price is capped like a max fee.
+func (p FeePolicy) CapFees(tx PendingTx) PendingTx {
+	maxFee := p.MaxFeePerGasWei
+	if maxFee != nil && maxFee.Sign() <= 0 {
+		maxFee = nil
+	}
+	if tx.MaxFeePerGasWei == nil {
+		if maxFee != nil && tx.GasPriceWei != nil && tx.GasPriceWei.Cmp(maxFee) > 0 {
+			tx.GasPriceWei = new(big.Int).Set(maxFee)
+		}
+		return tx
+	}
+	if maxFee != nil && tx.MaxFeePerGasWei.Cmp(maxFee) > 0 {
+		tx.MaxFeePerGasWei = new(big.Int).Set(maxFee)
+	}
+	if limit := p.MaxPriorityFeeWei; limit != nil && limit.Sign() > 0 && tx.MaxPriorityFeeWei != nil && tx.MaxPriorityFeeWei.Cmp(limit) > 0 {
+		tx.MaxPriorityFeeWei = new(big.Int).Set(limit)
+	}
+	if tx.MaxPriorityFeeWei != nil && tx.MaxPriorityFeeWei.Cmp(tx.MaxFeePerGasWei) > 0 {
+		tx.MaxPriorityFeeWei = new(big.Int).Set(tx.MaxFeePerGasWei)
+	}
+	return tx
+}
+
+// NextBudgetDay is when a budget exhausted at now resets: the next UTC
+// midnight.
+func NextBudgetDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func medianWei(values []*big.Int) *big.Int {
+	sorted := make([]*big.Int, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	return new(big.Int).Set(sorted[len(sorted)/2])
+}
@@ -1,20 +1,18 @@
 package blockchain
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
-	"time"
 )
 
+// LogFilter matches logs emitted by any of Addresses whose first topic is
+// any of Topics.
 type LogFilter struct {
 	FromBlock uint64
 	ToBlock   uint64
-	Address   string
+	Addresses []string
 	Topics    []string
 }
 
@@ -34,23 +32,26 @@ type LogRPCClient interface {
 }
 
 type JSONRPCLogClient struct {
-	httpURL    string
-	httpClient *http.Client
+	transport *RPCTransport
 }
 
+// NewJSONRPCLogClient reads from the comma-separated endpoints in httpURL with
+// default transport settings.
 func NewJSONRPCLogClient(httpURL string) (*JSONRPCLogClient, error) {
-	if strings.TrimSpace(httpURL) == "" {
-		return nil, fmt.Errorf("missing CREDITCOIN_HTTP_RPC")
+	transport, err := NewRPCTransport(ParseRPCEndpoints(httpURL), 0, 2)
+	if err != nil {
+		return nil, err
 	}
-	return &JSONRPCLogClient{
-		httpURL:    strings.TrimSpace(httpURL),
-		httpClient: &http.Client{Timeout: 20 * time.Second},
-	}, nil
+	return NewJSONRPCLogClientWithTransport(transport), nil
+}
+
+func NewJSONRPCLogClientWithTransport(transport *RPCTransport) *JSONRPCLogClient {
+	return &JSONRPCLogClient{transport: transport}
 }
 
 func (c *JSONRPCLogClient) BlockNumber(ctx context.Context) (uint64, error) {
 	var out string
-	if err := c.rpc(ctx, "eth_blockNumber", []any{}, &out); err != nil {
+	if err := c.transport.Call(ctx, "eth_blockNumber", []any{}, &out); err != nil {
 		return 0, err
 	}
 	return parseHexUint64(out)
@@ -60,7 +61,7 @@ func (c *JSONRPCLogClient) GetLogs(ctx context.Context, filter LogFilter) ([]Log
 	reqFilter := map[string]any{
 		"fromBlock": fmt.Sprintf("0x%x", filter.FromBlock),
 		"toBlock":   fmt.Sprintf("0x%x", filter.ToBlock),
-		"address":   filter.Address,
+		"address":   filter.Addresses,
 		"topics":    []any{filter.Topics},
 	}
 	var rawLogs []struct {
@@ -72,7 +73,7 @@ func (c *JSONRPCLogClient) GetLogs(ctx context.Context, filter LogFilter) ([]Log
 		LogIndex        string   `json:"logIndex"`
 		Removed         bool     `json:"removed"`
 	}
-	if err := c.rpc(ctx, "eth_getLogs", []any{reqFilter}, &rawLogs); err != nil {
+	if err := c.transport.Call(ctx, "eth_getLogs", []any{reqFilter}, &rawLogs); err != nil {
 		return nil, err
 	}
 
@@ -99,47 +100,6 @@ func (c *JSONRPCLogClient) GetLogs(ctx context.Context, filter LogFilter) ([]Log
 	return out, nil
 }
 
-func (c *JSONRPCLogClient) rpc(ctx context.Context, method string, params []any, out any) error {
-	reqBody, _ := json.Marshal(map[string]any{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  method,
-		"params":  params,
-	})
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.httpURL, bytes.NewReader(reqBody))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var payload struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return err
-	}
-	if payload.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", payload.Error.Code, payload.Error.Message)
-	}
-	if len(payload.Result) == 0 {
-		return fmt.Errorf("rpc empty result")
-	}
-	if err := json.Unmarshal(payload.Result, out); err != nil {
-		return err
-	}
-	return nil
-}
-
 func parseHexUint64(v string) (uint64, error) {
 	clean := strings.TrimSpace(strings.ToLower(v))
 	clean = strings.TrimPrefix(clean, "0x")
@@ -0,0 +1,212 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	NonceStatusReserved  = "reserved"
+	NonceStatusSubmitted = "submitted"
+	NonceStatusReleased  = "released"
+	NonceStatusUsed      = "used"
+)
+
+// PendingTx is the unsigned transaction a nonce was reserved for. It is kept
+// with the reservation so a stuck transaction can be resent with a higher fee.
+// A transaction with MaxFeePerGasWei set is sent as EIP-1559; otherwise
+// GasPriceWei, if any, is used.
+type PendingTx struct {
+	To                string
+	Data              string
+	Gas               uint64
+	GasPriceWei       *big.Int
+	MaxFeePerGasWei   *big.Int
+	MaxPriorityFeeWei *big.Int
+}
+
+type NonceReservation struct {
+	Address      string
+	Nonce        uint64
+	Status       string
+	TxHash       string
+	Tx           PendingTx
+	Replacements int32
+	UpdatedAt    time.Time
+}
+
+// NonceStore persists nonce reservations per sender address so concurrent
+// workers and replicas never hand out the same nonce twice.
+type NonceStore interface {
+	// Reserve returns the lowest free nonce at or above chainPending: a gap
+	// left by a released or abandoned reservation first, otherwise the next
+	// unused nonce.
+	Reserve(ctx context.Context, address string, chainPending uint64, staleBefore time.Time) (uint64, error)
+	MarkSubmitted(ctx context.Context, address string, nonce uint64, txHash string, tx PendingTx, replacement bool) error
+	// MarkUsed records that the nonce is taken by a transaction this store
+	// did not submit, so it is never handed out again.
+	MarkUsed(ctx context.Context, address string, nonce uint64) error
+	Release(ctx context.Context, address string, nonce uint64) error
+	// Confirm settles every submitted reservation below chainLatest.
+	Confirm(ctx context.Context, address string, chainLatest uint64) error
+	ListStuck(ctx context.Context, address string, submittedBefore time.Time) ([]NonceReservation, error)
+}
+
+// SendFunc submits tx with the given nonce and returns its hash.
+type SendFunc func(ctx context.Context, nonce uint64, tx PendingTx) (string, error)
+
+// ReplaceFunc resends prev, stuck under nonce, as the fee-bumped next and
+// returns the new hash.
+type ReplaceFunc func(ctx context.Context, nonce uint64, prev, next PendingTx) (string, error)
+
+// minFeeBumpPercent is the least nodes accept a replacement's fees to rise by.
+const minFeeBumpPercent = 10
+
+// NonceManagedWriter is implemented by writers that submit transactions from
+// a backend-controlled account and therefore need managed nonces.
+type NonceManagedWriter interface {
+	SetNonceManager(m *NonceManager)
+}
+
+// StuckTxReplacer is implemented by nonce-managed writers that can resend
+// stuck transactions with a bumped fee.
+type StuckTxReplacer interface {
+	ReplaceStuck(ctx context.Context) (int, error)
+}
+
+type NonceManager struct {
+	store          NonceStore
+	stuckAfter     time.Duration
+	feeBumpPercent int64
+	now            func() time.Time
+}
+
+func NewNonceManager(store NonceStore, stuckAfter time.Duration, feeBumpPercent int64) *NonceManager {
+	if stuckAfter <= 0 {
+		stuckAfter = 5 * time.Minute
+	}
+	if feeBumpPercent < minFeeBumpPercent {
+		feeBumpPercent = minFeeBumpPercent
+	}
+	return &NonceManager{
+		store:          store,
+		stuckAfter:     stuckAfter,
+		feeBumpPercent: feeBumpPercent,
+		now:            func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Send reserves a nonce for address and submits tx with it. chainPending is
+// the node's pending transaction count for address, which moves the store
+// forward past nonces used outside it. A nonce the node reports as taken is
+// marked used; a nonce whose send failed otherwise is released for reuse.
+func (m *NonceManager) Send(ctx context.Context, address string, chainPending uint64, tx PendingTx, send SendFunc) (string, error) {
+	address = strings.ToLower(address)
+	nonce, err := m.store.Reserve(ctx, address, chainPending, m.now().Add(-m.stuckAfter))
+	if err != nil {
+		return "", Transient(err)
+	}
+	txHash, sendErr := send(ctx, nonce, tx)
+	// The outcome must be recorded even if ctx was cancelled mid-send.
+	recordCtx := context.WithoutCancel(ctx)
+	if sendErr != nil {
+		switch KindOf(sendErr) {
+		case KindNonceTooLow, KindReplacementUnderpriced, KindAlreadyKnown:
+			_ = m.store.MarkUsed(recordCtx, address, nonce)
+		default:
+			_ = m.store.Release(recordCtx, address, nonce)
+		}
+		return "", sendErr
+	}
+	if err := m.store.MarkSubmitted(recordCtx, address, nonce, txHash, tx, false); err != nil {
+		return "", err
+	}
+	return txHash, nil
+}
+
+// ReplaceStuck resends transactions that have not been mined within the stuck
+// threshold using the same nonce and a fee bumped by feeBumpPercent, or the
+// current gas price if that is higher, within the caps of policy if set. A
+// transaction the capped fee cannot outbid, or whose replacement send reports
+// the fee budget exhausted, is left waiting. It returns how many were
+// replaced.
+func (m *NonceManager) ReplaceStuck(ctx context.Context, address string, chainLatest uint64, currentGasPrice *big.Int, policy *FeePolicy, send ReplaceFunc) (int, error) {
+	address = strings.ToLower(address)
+	if err := m.store.Confirm(ctx, address, chainLatest); err != nil {
+		return 0, err
+	}
+	stuck, err := m.store.ListStuck(ctx, address, m.now().Add(-m.stuckAfter))
+	if err != nil {
+		return 0, err
+	}
+	replaced := 0
+	for _, res := range stuck {
+		tx := bumpFees(res.Tx, m.feeBumpPercent, currentGasPrice)
+		if policy != nil {
+			tx = policy.CapFees(tx)
+			if !outbids(tx, res.Tx) {
+				continue
+			}
+		}
+		txHash, err := send(ctx, res.Nonce, res.Tx, tx)
+		if err != nil {
+			switch KindOf(err) {
+			case KindNonceTooLow:
+				// Mined between Confirm and the resend.
+				_ = m.store.Confirm(ctx, address, res.Nonce+1)
+				continue
+			case KindAlreadyKnown, KindFeeBudgetExceeded:
+				// Sent before and waiting in the pool, or not affordable
+				// today; either way the stuck transaction stays as it is.
+				continue
+			}
+			return replaced, err
+		}
+		if err := m.store.MarkSubmitted(ctx, address, res.Nonce, txHash, tx, true); err != nil {
+			return replaced, err
+		}
+		replaced++
+	}
+	return replaced, nil
+}
+
+// outbids reports whether next raises every fee prev uses by as much as nodes
+// require of a replacement.
+func outbids(next, prev PendingTx) bool {
+	if prev.MaxFeePerGasWei != nil {
+		return next.MaxFeePerGasWei.Cmp(BumpGasPrice(prev.MaxFeePerGasWei, minFeeBumpPercent)) >= 0 &&
+			next.MaxPriorityFeeWei.Cmp(BumpGasPrice(prev.MaxPriorityFeeWei, minFeeBumpPercent)) >= 0
+	}
+	return next.GasPriceWei.Cmp(BumpGasPrice(prev.GasPriceWei, minFeeBumpPercent)) >= 0
+}
+
+// bumpFees raises every fee field tx uses. Nodes require both EIP-1559 fields
+// to rise for a replacement to be accepted.
+func bumpFees(tx PendingTx, percent int64, currentGasPrice *big.Int) PendingTx {
+	if tx.MaxFeePerGasWei != nil {
+		tx.MaxFeePerGasWei = BumpGasPrice(tx.MaxFeePerGasWei, percent)
+		tx.MaxPriorityFeeWei = BumpGasPrice(tx.MaxPriorityFeeWei, percent)
+		if currentGasPrice != nil && currentGasPrice.Cmp(tx.MaxFeePerGasWei) > 0 {
+			tx.MaxFeePerGasWei = new(big.Int).Set(currentGasPrice)
+		}
+		return tx
+	}
+	tx.GasPriceWei = BumpGasPrice(tx.GasPriceWei, percent)
+	if currentGasPrice != nil && currentGasPrice.Cmp(tx.GasPriceWei) > 0 {
+		tx.GasPriceWei = new(big.Int).Set(currentGasPrice)
+	}
+	return tx
+}
+
+// BumpGasPrice raises price by percent, rounding up so small prices still
+// increase.
+func BumpGasPrice(price *big.Int, percent int64) *big.Int {
+	if price == nil || price.Sign() <= 0 {
+		return big.NewInt(1)
+	}
+	out := new(big.Int).Mul(price, big.NewInt(100+percent))
+	out.Add(out, big.NewInt(99))
+	return out.Quo(out, big.NewInt(100))
+}
@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/sha3"
+)
+
+// OnChainLoan is the registry's view of a loan.
+type OnChainLoan struct {
+	Registered       bool
+	PrincipalMinor   int64
+	TotalRepaidMinor int64
+	Status           string
+}
+
+// LoanRegistryReader reads loans from the registry contract as of a block.
+type LoanRegistryReader interface {
+	// GetLoans returns the loans in the order of loanIDs.
+	GetLoans(ctx context.Context, loanIDs []string, blockNumber uint64) ([]OnChainLoan, error)
+}
+
+// registryReadBatch is how many getLoan calls go in one JSON-RPC batch.
+const registryReadBatch = 100
+
+// getLoanSignature is the registry view the reader calls. It returns
+// (bool registered, uint256 principalMinor, uint256 totalRepaidMinor,
+// uint8 status) for the loan ID packed into the high bytes of the bytes32.
+const getLoanSignature = "getLoan(bytes32)"
+
+// registryStatuses maps the registry's status enum to loans.status values.
+// Unregistered loans report "none".
+var registryStatuses = []string{"none", "active", "repaid", "defaulted"}
+
+type RPCRegistryReader struct {
+	transport    *RPCTransport
+	contractAddr string
+}
+
+func NewRPCRegistryReader(transport *RPCTransport, contractAddr string) (*RPCRegistryReader, error) {
+	contractAddr = strings.TrimSpace(contractAddr)
+	if transport == nil || contractAddr == "" {
+		return nil, fmt.Errorf("missing registry reader config")
+	}
+	return &RPCRegistryReader{transport: transport, contractAddr: contractAddr}, nil
+}
+
+// GetLoans reads the loans with eth_call, registryReadBatch calls per
+// JSON-RPC batch request.
+func (r *RPCRegistryReader) GetLoans(ctx context.Context, loanIDs []string, blockNumber uint64) ([]OnChainLoan, error) {
+	block := fmt.Sprintf("0x%x", blockNumber)
+	out := make([]OnChainLoan, 0, len(loanIDs))
+	for start := 0; start < len(loanIDs); start += registryReadBatch {
+		ids := loanIDs[start:min(start+registryReadBatch, len(loanIDs))]
+		results := make([]string, len(ids))
+		calls := make([]RPCCall, len(ids))
+		for i, loanID := range ids {
+			data, err := EncodeGetLoanCall(loanID)
+			if err != nil {
+				return nil, err
+			}
+			call := map[string]string{"to": r.contractAddr, "data": data}
+			calls[i] = RPCCall{Method: "eth_call", Params: []any{call, block}, Out: &results[i]}
+		}
+		if err := r.transport.Batch(ctx, calls); err != nil {
+			return nil, err
+		}
+		for i, call := range calls {
+			if call.Err != nil {
+				return nil, fmt.Errorf("read loan %s: %w", ids[i], call.Err)
+			}
+			loan, err := DecodeGetLoanResult(results[i])
+			if err != nil {
+				return nil, fmt.Errorf("read loan %s: %w", ids[i], err)
+			}
+			out = append(out, loan)
+		}
+	}
+	return out, nil
+}
+
+// EncodeGetLoanCall returns the eth_call data for getLoan(bytes32).
+func EncodeGetLoanCall(loanID string) (string, error) {
+	id, err := uuid.Parse(strings.TrimSpace(loanID))
+	if err != nil {
+		return "", Permanent(fmt.Errorf("invalid loan id %q", loanID))
+	}
+	hash := sha3.NewLegacyKeccak256()
+	_, _ = hash.Write([]byte(getLoanSignature))
+	word := make([]byte, 32)
+	copy(word, id[:])
+	return "0x" + hex.EncodeToString(hash.Sum(nil)[:4]) + hex.EncodeToString(word), nil
+}
+
+// DecodeGetLoanResult decodes the four words getLoan returns.
+func DecodeGetLoanResult(out string) (OnChainLoan, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(out), "0x"))
+	if err != nil || len(raw) < 4*32 {
+		return OnChainLoan{}, fmt.Errorf("invalid getLoan result %q", out)
+	}
+	word := func(i int) *big.Int { return new(big.Int).SetBytes(raw[i*32 : (i+1)*32]) }
+
+	registered := word(0)
+	if registered.Cmp(big.NewInt(1)) > 0 {
+		return OnChainLoan{}, fmt.Errorf("invalid getLoan registered flag %s", registered)
+	}
+	principal, repaid := word(1), word(2)
+	if !principal.IsInt64() || !repaid.IsInt64() {
+		return OnChainLoan{}, fmt.Errorf("getLoan amount overflows int64")
+	}
+	status := word(3)
+	if !status.IsInt64() || status.Int64() >= int64(len(registryStatuses)) {
+		return OnChainLoan{}, fmt.Errorf("unknown registry loan status %s", status)
+	}
+	return OnChainLoan{
+		Registered:       registered.Sign() == 1,
+		PrincipalMinor:   principal.Int64(),
+		TotalRepaidMinor: repaid.Int64(),
+		Status:           registryStatuses[status.Int64()],
+	}, nil
+}
@@ -0,0 +1,363 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// endpointDownAfter consecutive failures take an endpoint out of rotation
+	// for endpointCooldown.
+	endpointDownAfter = 3
+	endpointCooldown  = 30 * time.Second
+	// healthDecay weighs the newest sample in the latency and error averages.
+	healthDecay = 0.2
+)
+
+// idempotentMethods may be resent to another endpoint after any failure.
+// Everything else, notably eth_sendTransaction, is sent at most once per call
+// so a slow node cannot cause a double submission.
+var idempotentMethods = map[string]bool{
+	"eth_blockNumber":           true,
+	"eth_call":                  true,
+	"eth_chainId":               true,
+	"eth_estimateGas":           true,
+	"eth_feeHistory":            true,
+	"eth_gasPrice":              true,
+	"eth_getBlockByNumber":      true,
+	"eth_getLogs":               true,
+	"eth_getTransactionCount":   true,
+	"eth_getTransactionReceipt": true,
+}
+
+// RPCCall is one request of a JSON-RPC batch. Err holds the call's own error;
+// Out is left untouched when it is set.
+type RPCCall struct {
+	Method string
+	Params []any
+	Out    any
+	Err    error
+}
+
+// EndpointHealth is a snapshot of how an endpoint has been performing.
+type EndpointHealth struct {
+	URL                 string        `json:"url"`
+	Requests            int64         `json:"requests"`
+	Failures            int64         `json:"failures"`
+	ErrorRate           float64       `json:"error_rate"`
+	Latency             time.Duration `json:"latency"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	DownUntil           *time.Time    `json:"down_until,omitempty"`
+}
+
+type rpcEndpoint struct {
+	url                 string
+	requests            int64
+	failures            int64
+	errorRate           float64
+	latency             time.Duration
+	consecutiveFailures int
+	downUntil           time.Time
+}
+
+// RPCTransport is the JSON-RPC client shared by the chain writer and the
+// indexer. It spreads calls over several endpoints, preferring the fastest
+// healthy one, fails over when an endpoint errors, and retries idempotent
+// reads.
+type RPCTransport struct {
+	httpClient  *http.Client
+	readRetries int
+	logger      *slog.Logger
+	now         func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*rpcEndpoint
+}
+
+// ParseRPCEndpoints splits a comma-separated endpoint list.
+func ParseRPCEndpoints(spec string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// NewRPCTransport builds a transport over urls. readRetries is how many extra
+// attempts an idempotent call gets after its first failure; each attempt goes
+// to the next best endpoint.
+func NewRPCTransport(urls []string, timeout time.Duration, readRetries int) (*RPCTransport, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("missing CREDITCOIN_HTTP_RPC")
+	}
+	if timeout <= 0 {
+		timeout = 20 * time.Second
+	}
+	if readRetries < 0 {
+		readRetries = 0
+	}
+	t := &RPCTransport{
+		httpClient:  &http.Client{Timeout: timeout},
+		readRetries: readRetries,
+		logger:      slog.Default(),
+		now:         time.Now,
+	}
+	for _, url := range urls {
+		t.endpoints = append(t.endpoints, &rpcEndpoint{url: url})
+	}
+	return t, nil
+}
+
+func (t *RPCTransport) SetLogger(logger *slog.Logger) {
+	if logger != nil {
+		t.logger = logger
+	}
+}
+
+// SetClock replaces the clock used for endpoint cooldowns, for tests.
+func (t *RPCTransport) SetClock(now func() time.Time) {
+	if now != nil {
+		t.now = now
+	}
+}
+
+// Call sends a single request and decodes its result into out.
+func (t *RPCTransport) Call(ctx context.Context, method string, params []any, out any) error {
+	calls := []RPCCall{{Method: method, Params: params, Out: out}}
+	if err := t.do(ctx, calls, false); err != nil {
+		return err
+	}
+	return calls[0].Err
+}
+
+// Batch sends calls as one JSON-RPC batch request. The returned error covers
+// the request as a whole; each call's own error is set on the call.
+func (t *RPCTransport) Batch(ctx context.Context, calls []RPCCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	return t.do(ctx, calls, true)
+}
+
+// Health returns the endpoints in their current order of preference.
+func (t *RPCTransport) Health() []EndpointHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	out := make([]EndpointHealth, 0, len(t.endpoints))
+	for _, e := range t.ranked(now) {
+		h := EndpointHealth{
+			URL:                 e.url,
+			Requests:            e.requests,
+			Failures:            e.failures,
+			ErrorRate:           e.errorRate,
+			Latency:             e.latency,
+			ConsecutiveFailures: e.consecutiveFailures,
+		}
+		if e.downUntil.After(now) {
+			down := e.downUntil
+			h.DownUntil = &down
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+func (t *RPCTransport) do(ctx context.Context, calls []RPCCall, batch bool) error {
+	t.mu.Lock()
+	order := t.ranked(t.now())
+	t.mu.Unlock()
+
+	reads := retriable(calls)
+	attempts := len(order)
+	if reads {
+		attempts = 1 + t.readRetries
+	}
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		endpoint := order[i%len(order)]
+		started := t.now()
+		err := t.post(ctx, endpoint.url, calls, batch)
+		if err == nil && !batch && calls[0].Err != nil && KindOf(calls[0].Err) == KindTransient {
+			// The node answered but could not serve the call, e.g. it is
+			// rate limiting or lagging; another endpoint may.
+			err = calls[0].Err
+			calls[0].Err = nil
+		}
+		t.record(endpoint, t.now().Sub(started), err)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || KindOf(err) != KindTransient {
+			return err
+		}
+		// A write moves on only if no endpoint could have received it.
+		if !reads && !notSent(err) {
+			return err
+		}
+	}
+	return lastErr
+}
+
+// notSent reports whether err happened before the request left this process.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// post sends one HTTP request. Transport, status and decoding failures are
+// returned; JSON-RPC errors are set on the calls.
+func (t *RPCTransport) post(ctx context.Context, url string, calls []RPCCall, batch bool) error {
+	type request struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      int    `json:"id"`
+		Method  string `json:"method"`
+		Params  []any  `json:"params"`
+	}
+	reqs := make([]request, 0, len(calls))
+	for i, call := range calls {
+		params := call.Params
+		if params == nil {
+			params = []any{}
+		}
+		reqs = append(reqs, request{JSONRPC: "2.0", ID: i + 1, Method: call.Method, Params: params})
+	}
+	var reqBody []byte
+	if batch {
+		reqBody, _ = json.Marshal(reqs)
+	} else {
+		reqBody, _ = json.Marshal(reqs[0])
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return Transient(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Transient(fmt.Errorf("rpc http status %d", resp.StatusCode))
+	}
+
+	type response struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	var payloads []response
+	if batch {
+		if err := json.NewDecoder(resp.Body).Decode(&payloads); err != nil {
+			return Transient(err)
+		}
+	} else {
+		var payload response
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return Transient(err)
+		}
+		payload.ID = 1
+		payloads = append(payloads, payload)
+	}
+
+	answered := make([]bool, len(calls))
+	for _, payload := range payloads {
+		if payload.ID < 1 || payload.ID > len(calls) {
+			continue
+		}
+		call := &calls[payload.ID-1]
+		answered[payload.ID-1] = true
+		switch {
+		case payload.Error != nil:
+			call.Err = classifyRPCError(payload.Error.Code, payload.Error.Message)
+		case len(payload.Result) == 0:
+			call.Err = Transient(fmt.Errorf("rpc empty result"))
+		default:
+			call.Err = nil
+			if err := json.Unmarshal(payload.Result, call.Out); err != nil {
+				call.Err = err
+			}
+		}
+	}
+	for i, ok := range answered {
+		if !ok {
+			return Transient(fmt.Errorf("rpc batch missing response for %s", calls[i].Method))
+		}
+	}
+	return nil
+}
+
+func (t *RPCTransport) record(e *rpcEndpoint, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.requests++
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration((1-healthDecay)*float64(e.latency) + healthDecay*float64(latency))
+	}
+	sample := 0.0
+	// Calls the node rejected on their merits say nothing about its health.
+	if err != nil && KindOf(err) == KindTransient && !errors.Is(err, context.Canceled) {
+		sample = 1
+		e.failures++
+		e.consecutiveFailures++
+		// An endpoint still failing after its cooldown goes straight back
+		// down rather than waiting for another endpointDownAfter failures.
+		now := t.now()
+		if e.consecutiveFailures >= endpointDownAfter && !e.downUntil.After(now) && len(t.endpoints) > 1 {
+			e.downUntil = now.Add(endpointCooldown)
+			t.logger.Warn("rpc endpoint marked down", "url", e.url, "err", err, "cooldown", endpointCooldown.String())
+		}
+	} else {
+		e.consecutiveFailures = 0
+	}
+	e.errorRate = (1-healthDecay)*e.errorRate + healthDecay*sample
+}
+
+// ranked orders endpoints by health: endpoints cooling down last, then by
+// latency inflated by recent error rate. Callers hold t.mu.
+func (t *RPCTransport) ranked(now time.Time) []*rpcEndpoint {
+	out := append([]*rpcEndpoint(nil), t.endpoints...)
+	score := func(e *rpcEndpoint) float64 {
+		return float64(e.latency+time.Millisecond) * (1 + 10*e.errorRate)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		downI, downJ := out[i].downUntil.After(now), out[j].downUntil.After(now)
+		if downI != downJ {
+			return !downI
+		}
+		return score(out[i]) < score(out[j])
+	})
+	return out
+}
+
+func retriable(calls []RPCCall) bool {
+	for _, call := range calls {
+		if !idempotentMethods[call.Method] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,12 @@
 package blockchain
 
 import (
-	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"log/slog"
+	"math/big"
 	"regexp"
 	"strings"
 	"time"
@@ -15,15 +15,29 @@ import (
 var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
 
 type RPCWriter struct {
-	httpURL      string
+	transport    *RPCTransport
 	fromAddress  string
 	contractAddr string
 	gasLimit     uint64
-	httpClient   *http.Client
+	nonces       *NonceManager
+	feePolicy    *FeePolicy
+	fees         FeeStore
+	logger       *slog.Logger
+	now          func() time.Time
 }
 
+// NewRPCWriter sends to the comma-separated endpoints in httpURL with default
+// transport settings.
 func NewRPCWriter(httpURL, fromAddress, contractAddr string, gasLimit uint64) (*RPCWriter, error) {
-	if strings.TrimSpace(httpURL) == "" {
+	transport, err := NewRPCTransport(ParseRPCEndpoints(httpURL), 0, 2)
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCWriterWithTransport(transport, fromAddress, contractAddr, gasLimit)
+}
+
+func NewRPCWriterWithTransport(transport *RPCTransport, fromAddress, contractAddr string, gasLimit uint64) (*RPCWriter, error) {
+	if transport == nil {
 		return nil, fmt.Errorf("missing CREDITCOIN_HTTP_RPC")
 	}
 	if !addressPattern.MatchString(strings.TrimSpace(fromAddress)) {
@@ -36,24 +50,55 @@ func NewRPCWriter(httpURL, fromAddress, contractAddr string, gasLimit uint64) (*
 		gasLimit = 300000
 	}
 	return &RPCWriter{
-		httpURL:      strings.TrimSpace(httpURL),
+		transport:    transport,
 		fromAddress:  strings.TrimSpace(fromAddress),
 		contractAddr: strings.TrimSpace(contractAddr),
 		gasLimit:     gasLimit,
-		httpClient:   &http.Client{Timeout: 20 * time.Second},
+		logger:       slog.Default(),
+		now:          func() time.Time { return time.Now().UTC() },
 	}, nil
 }
 
+// Transport returns the JSON-RPC transport the writer sends through.
+func (w *RPCWriter) Transport() *RPCTransport {
+	return w.transport
+}
+
+// SetNonceManager makes the writer assign nonces and gas prices itself instead
+// of leaving them to the node, so concurrent sends never collide.
+func (w *RPCWriter) SetNonceManager(m *NonceManager) {
+	w.nonces = m
+}
+
+// SetFeePolicy makes the writer estimate gas and price each transaction from
+// eth_feeHistory instead of sending the fixed gas limit and leaving fees to
+// the node. A zero GasLimitCap keeps the configured gas limit as the cap.
+func (w *RPCWriter) SetFeePolicy(p FeePolicy) {
+	if p.GasLimitCap == 0 {
+		p.GasLimitCap = w.gasLimit
+	}
+	w.feePolicy = &p
+}
+
+// SetFeeStore records the worst-case cost of every priced transaction per day
+// and topic and enforces the policy's daily budget against it.
+func (w *RPCWriter) SetFeeStore(store FeeStore, logger *slog.Logger) {
+	w.fees = store
+	if logger != nil {
+		w.logger = logger
+	}
+}
+
 func (w *RPCWriter) RegisterLoan(ctx context.Context, loanID string) (string, error) {
 	if strings.TrimSpace(loanID) == "" {
-		return "", fmt.Errorf("missing loan id")
+		return "", Permanent(fmt.Errorf("missing loan id"))
 	}
 	return w.sendMarker(ctx, "register_loan", map[string]any{"loan_id": strings.TrimSpace(loanID)})
 }
 
 func (w *RPCWriter) RecordRepayment(ctx context.Context, loanID string, amountMinor int64, currency string) (string, error) {
 	if strings.TrimSpace(loanID) == "" || amountMinor <= 0 || len(strings.TrimSpace(currency)) != 3 {
-		return "", fmt.Errorf("invalid repayment args")
+		return "", Permanent(fmt.Errorf("invalid repayment args"))
 	}
 	return w.sendMarker(ctx, "record_repayment", map[string]any{
 		"loan_id":      strings.TrimSpace(loanID),
@@ -64,27 +109,304 @@ func (w *RPCWriter) RecordRepayment(ctx context.Context, loanID string, amountMi
 
 func (w *RPCWriter) MarkDefault(ctx context.Context, loanID string, reason string) (string, error) {
 	if strings.TrimSpace(loanID) == "" {
-		return "", fmt.Errorf("invalid default args")
+		return "", Permanent(fmt.Errorf("invalid default args"))
 	}
 	return w.sendMarker(ctx, "mark_default", map[string]any{"loan_id": strings.TrimSpace(loanID), "reason": strings.TrimSpace(reason)})
 }
 
+// SubmitBatch sends the calls as one multicall marker transaction to the loan
+// registry.
+func (w *RPCWriter) SubmitBatch(ctx context.Context, lenderID string, calls []BatchCall) (string, error) {
+	lenderID = strings.TrimSpace(lenderID)
+	if err := ValidateBatch(lenderID, calls); err != nil {
+		return "", err
+	}
+	items := make([]map[string]any, 0, len(calls))
+	for _, call := range calls {
+		item := map[string]any{"action": call.Action, "loan_id": strings.TrimSpace(call.LoanID)}
+		if call.Action == BatchActionRecordRepayment {
+			item["amount_minor"] = call.AmountMinor
+			item["currency"] = strings.ToUpper(strings.TrimSpace(call.Currency))
+		}
+		items = append(items, item)
+	}
+	return w.sendMarker(ctx, "multicall", map[string]any{"lender_id": lenderID, "calls": items})
+}
+
+func (w *RPCWriter) MirrorPoolPosition(ctx context.Context, in PoolPositionAction) (string, error) {
+	if !addressPattern.MatchString(strings.TrimSpace(in.PoolTokenAddr)) {
+		return "", Permanent(fmt.Errorf("invalid pool token address"))
+	}
+	if strings.TrimSpace(in.TransactionID) == "" || in.Shares <= 0 || (in.Action != "deposit" && in.Action != "withdrawal") {
+		return "", Permanent(fmt.Errorf("invalid pool position args"))
+	}
+	return w.sendMarkerTo(ctx, strings.TrimSpace(in.PoolTokenAddr), "pool_"+in.Action, map[string]any{
+		"transaction_id":   strings.TrimSpace(in.TransactionID),
+		"investor_user_id": strings.TrimSpace(in.InvestorUserID),
+		"amount_minor":     in.AmountMinor,
+		"shares":           in.Shares,
+	})
+}
+
 func (w *RPCWriter) sendMarker(ctx context.Context, action string, payload map[string]any) (string, error) {
+	return w.sendMarkerTo(ctx, w.contractAddr, action, payload)
+}
+
+func (w *RPCWriter) sendMarkerTo(ctx context.Context, to, action string, payload map[string]any) (string, error) {
 	dataBytes, _ := json.Marshal(map[string]any{
 		"action":  action,
 		"payload": payload,
 	})
+	tx := PendingTx{
+		To:   to,
+		Data: "0x" + hex.EncodeToString(dataBytes),
+		Gas:  w.gasLimit,
+	}
+	if w.feePolicy != nil {
+		if err := w.priceTx(ctx, &tx); err != nil {
+			return "", err
+		}
+	}
+	return w.sendWithinBudget(ctx, tx, tx.Gas, quoteOf(tx).MaxCostWei(), func(ctx context.Context) (string, error) {
+		return w.send(ctx, tx)
+	})
+}
+
+func (w *RPCWriter) send(ctx context.Context, tx PendingTx) (string, error) {
+	if w.nonces == nil {
+		return w.sendTransaction(ctx, nil, tx)
+	}
+
+	pending, err := w.transactionCount(ctx, "pending")
+	if err != nil {
+		return "", err
+	}
+	if tx.MaxFeePerGasWei == nil && tx.GasPriceWei == nil {
+		tx.GasPriceWei, err = w.gasPrice(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	return w.nonces.Send(ctx, w.fromAddress, pending, tx, func(ctx context.Context, nonce uint64, tx PendingTx) (string, error) {
+		return w.sendTransaction(ctx, &nonce, tx)
+	})
+}
+
+// priceTx sets the gas limit from eth_estimateGas and the fees from
+// eth_feeHistory. A call that would revert fails here without spending gas.
+// Nodes without eth_feeHistory or a base fee get a capped legacy gas price.
+func (w *RPCWriter) priceTx(ctx context.Context, tx *PendingTx) error {
+	estimate, err := w.estimateGas(ctx, *tx)
+	if err != nil {
+		return err
+	}
+	tx.Gas = w.feePolicy.PaddedGas(estimate)
+
+	baseFee, rewards, err := w.feeHistory(ctx)
+	if err != nil && KindOf(err) != KindPermanent {
+		return err
+	}
+	if err == nil && baseFee != nil {
+		tx.MaxFeePerGasWei, tx.MaxPriorityFeeWei = w.feePolicy.DynamicFees(baseFee, rewards)
+		return nil
+	}
+
+	price, err := w.gasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	if limit := w.feePolicy.MaxFeePerGasWei; limit != nil && limit.Sign() > 0 && price.Cmp(limit) > 0 {
+		price = new(big.Int).Set(limit)
+	}
+	tx.GasPriceWei = price
+	return nil
+}
+
+// sendWithinBudget reserves cost against the daily fee budget, sends tx with
+// send and records gas and cost against the outbox topics of tx. A send that
+// failed with anything but a transient error, which may have reached the
+// node, returns its reservation.
+func (w *RPCWriter) sendWithinBudget(ctx context.Context, tx PendingTx, gas uint64, cost *big.Int, send func(context.Context) (string, error)) (string, error) {
+	if w.feePolicy == nil {
+		return send(ctx)
+	}
+	day := w.now()
+	reserved := false
+	if w.fees != nil && w.feePolicy.HasBudget() {
+		ok, err := w.fees.ReserveSpend(ctx, day, cost, w.feePolicy.DailyBudgetWei)
+		if err != nil {
+			return "", Transient(err)
+		}
+		if !ok {
+			return "", ErrFeeBudgetExceeded
+		}
+		reserved = true
+	}
+	txHash, err := send(ctx)
+	if err != nil {
+		if reserved && KindOf(err) != KindTransient {
+			if relErr := w.fees.ReleaseSpend(context.WithoutCancel(ctx), day, cost); relErr != nil {
+				w.logger.Warn("failed to release chain fee reservation", "max_cost_wei", cost.String(), "err", relErr)
+			}
+		}
+		return "", err
+	}
+	w.recordFees(ctx, day, txHash, tx, gas, cost)
+	return txHash, nil
+}
+
+// recordFees logs what tx may cost and adds gas and cost to the day's totals,
+// split evenly across the outbox topics of its calls.
+func (w *RPCWriter) recordFees(ctx context.Context, day time.Time, txHash string, tx PendingTx, gas uint64, cost *big.Int) {
+	topics := markerTopics(tx.Data)
+	attrs := []any{"topics", topics, "tx_hash", txHash, "gas", tx.Gas, "max_cost_wei", cost.String()}
+	if tx.MaxFeePerGasWei != nil {
+		attrs = append(attrs, "max_fee_per_gas_wei", tx.MaxFeePerGasWei.String(), "max_priority_fee_wei", tx.MaxPriorityFeeWei.String())
+	} else if tx.GasPriceWei != nil {
+		attrs = append(attrs, "gas_price_wei", tx.GasPriceWei.String())
+	}
+	w.logger.Info("chain transaction priced", attrs...)
+	if w.fees == nil {
+		return
+	}
+
+	counts := make(map[string]int)
+	order := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if counts[topic] == 0 {
+			order = append(order, topic)
+		}
+		counts[topic]++
+	}
+	// The transaction is already sent; its cost must count even if ctx was
+	// cancelled meanwhile. The last topic gets the rounding remainder.
+	recordCtx := context.WithoutCancel(ctx)
+	gasLeft, costLeft := gas, new(big.Int).Set(cost)
+	for i, topic := range order {
+		shareGas, shareCost := gasLeft, costLeft
+		if i < len(order)-1 {
+			shareGas = gas * uint64(counts[topic]) / uint64(len(topics))
+			shareCost = new(big.Int).Mul(cost, big.NewInt(int64(counts[topic])))
+			shareCost.Quo(shareCost, big.NewInt(int64(len(topics))))
+			gasLeft -= shareGas
+			costLeft = new(big.Int).Sub(costLeft, shareCost)
+		}
+		if err := w.fees.RecordSpend(recordCtx, day, topic, shareGas, shareCost); err != nil {
+			w.logger.Warn("failed to record chain fee spend", "topic", topic, "tx_hash", txHash, "err", err)
+		}
+	}
+}
+
+// markerTopics returns the outbox topic of every call a marker transaction
+// makes: one for a single write, one per call for a multicall.
+func markerTopics(data string) []string {
+	var marker struct {
+		Action  string `json:"action"`
+		Payload struct {
+			Calls []struct {
+				Action string `json:"action"`
+			} `json:"calls"`
+		} `json:"payload"`
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil || json.Unmarshal(raw, &marker) != nil || marker.Action == "" {
+		return []string{"unknown"}
+	}
+	switch marker.Action {
+	case "multicall":
+		topics := make([]string, 0, len(marker.Payload.Calls))
+		for _, call := range marker.Payload.Calls {
+			topics = append(topics, call.Action)
+		}
+		if len(topics) > 0 {
+			return topics
+		}
+	case "pool_deposit", "pool_withdrawal":
+		return []string{"pool_position"}
+	}
+	return []string{marker.Action}
+}
+
+func quoteOf(tx PendingTx) FeeQuote {
+	return FeeQuote{Gas: tx.Gas, MaxFeePerGasWei: tx.MaxFeePerGasWei, MaxPriorityFeeWei: tx.MaxPriorityFeeWei, GasPriceWei: tx.GasPriceWei}
+}
+
+// ReplaceStuck resends managed transactions that have not been mined in time
+// with a bumped fee, capped and budgeted like a first send.
+func (w *RPCWriter) ReplaceStuck(ctx context.Context) (int, error) {
+	if w.nonces == nil {
+		return 0, nil
+	}
+	var latestHex, priceHex string
+	calls := []RPCCall{
+		{Method: "eth_getTransactionCount", Params: []any{w.fromAddress, "latest"}, Out: &latestHex},
+		{Method: "eth_gasPrice", Out: &priceHex},
+	}
+	if err := w.transport.Batch(ctx, calls); err != nil {
+		return 0, err
+	}
+	for _, call := range calls {
+		if call.Err != nil {
+			return 0, call.Err
+		}
+	}
+	latest, err := parseHexUint64(latestHex)
+	if err != nil {
+		return 0, Transient(err)
+	}
+	current, ok := parseHexBig(priceHex)
+	if !ok {
+		return 0, Transient(fmt.Errorf("invalid gas price response"))
+	}
+	return w.nonces.ReplaceStuck(ctx, w.fromAddress, latest, current, w.feePolicy, func(ctx context.Context, nonce uint64, prev, next PendingTx) (string, error) {
+		// Only one of prev and next can be mined, so the replacement adds
+		// just the rise in worst-case cost to the day's spend.
+		extra := new(big.Int).Sub(quoteOf(next).MaxCostWei(), quoteOf(prev).MaxCostWei())
+		if extra.Sign() < 0 {
+			extra.SetInt64(0)
+		}
+		return w.sendWithinBudget(ctx, next, 0, extra, func(ctx context.Context) (string, error) {
+			return w.sendTransaction(ctx, &nonce, next)
+		})
+	})
+}
+
+func (w *RPCWriter) sendTransaction(ctx context.Context, nonce *uint64, tx PendingTx) (string, error) {
 	txObj := map[string]string{
 		"from":  w.fromAddress,
-		"to":    w.contractAddr,
-		"gas":   fmt.Sprintf("0x%x", w.gasLimit),
-		"data":  "0x" + hex.EncodeToString(dataBytes),
+		"to":    tx.To,
+		"gas":   fmt.Sprintf("0x%x", tx.Gas),
+		"data":  tx.Data,
 		"value": "0x0",
 	}
+	if nonce != nil {
+		txObj["nonce"] = fmt.Sprintf("0x%x", *nonce)
+	}
+	switch {
+	case tx.MaxFeePerGasWei != nil:
+		txObj["type"] = "0x2"
+		txObj["maxFeePerGas"] = "0x" + tx.MaxFeePerGasWei.Text(16)
+		txObj["maxPriorityFeePerGas"] = "0x" + tx.MaxPriorityFeeWei.Text(16)
+	case tx.GasPriceWei != nil:
+		txObj["gasPrice"] = "0x" + tx.GasPriceWei.Text(16)
+	}
 
 	var txHash string
-	if err := w.rpc(ctx, "eth_sendTransaction", []any{txObj}, &txHash); err != nil {
-		return "", err
+	if err := w.transport.Call(ctx, "eth_sendTransaction", []any{txObj}, &txHash); err != nil {
+		if KindOf(err) != KindAlreadyKnown || nonce == nil {
+			return "", err
+		}
+		// The node signs deterministically, so signing the same transaction
+		// again yields the hash of the one it already holds.
+		var signed struct {
+			Tx struct {
+				Hash string `json:"hash"`
+			} `json:"tx"`
+		}
+		if signErr := w.transport.Call(ctx, "eth_signTransaction", []any{txObj}, &signed); signErr != nil || signed.Tx.Hash == "" {
+			return "", err
+		}
+		txHash = signed.Tx.Hash
 	}
 	if !strings.HasPrefix(txHash, "0x") {
 		return "", fmt.Errorf("invalid tx hash response")
@@ -92,43 +414,79 @@ func (w *RPCWriter) sendMarker(ctx context.Context, action string, payload map[s
 	return txHash, nil
 }
 
-func (w *RPCWriter) rpc(ctx context.Context, method string, params []any, out any) error {
-	reqBody, _ := json.Marshal(map[string]any{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  method,
-		"params":  params,
-	})
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.httpURL, bytes.NewReader(reqBody))
+func (w *RPCWriter) transactionCount(ctx context.Context, block string) (uint64, error) {
+	var out string
+	if err := w.transport.Call(ctx, "eth_getTransactionCount", []any{w.fromAddress, block}, &out); err != nil {
+		return 0, err
+	}
+	n, err := parseHexUint64(out)
 	if err != nil {
-		return err
+		return 0, Transient(err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return n, nil
+}
 
-	resp, err := w.httpClient.Do(req)
+func (w *RPCWriter) gasPrice(ctx context.Context) (*big.Int, error) {
+	var out string
+	if err := w.transport.Call(ctx, "eth_gasPrice", []any{}, &out); err != nil {
+		return nil, err
+	}
+	price, ok := parseHexBig(out)
+	if !ok {
+		return nil, Transient(fmt.Errorf("invalid gas price response"))
+	}
+	return price, nil
+}
+
+func (w *RPCWriter) estimateGas(ctx context.Context, tx PendingTx) (uint64, error) {
+	call := map[string]string{
+		"from":  w.fromAddress,
+		"to":    tx.To,
+		"data":  tx.Data,
+		"value": "0x0",
+	}
+	var out string
+	if err := w.transport.Call(ctx, "eth_estimateGas", []any{call}, &out); err != nil {
+		return 0, err
+	}
+	gas, err := parseHexUint64(out)
 	if err != nil {
-		return err
+		return 0, Transient(err)
 	}
-	defer resp.Body.Close()
+	return gas, nil
+}
 
-	var payload struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
+// feeHistory returns the next block's base fee and recent median priority
+// fees. A nil base fee means the chain does not use EIP-1559.
+func (w *RPCWriter) feeHistory(ctx context.Context) (*big.Int, []*big.Int, error) {
+	var out struct {
+		BaseFeePerGas []string   `json:"baseFeePerGas"`
+		Reward        [][]string `json:"reward"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return err
+	params := []any{fmt.Sprintf("0x%x", feeHistoryBlocks), "latest", []int{feeHistoryRewardPercentile}}
+	if err := w.transport.Call(ctx, "eth_feeHistory", params, &out); err != nil {
+		return nil, nil, err
 	}
-	if payload.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", payload.Error.Code, payload.Error.Message)
+	if len(out.BaseFeePerGas) == 0 {
+		return nil, nil, nil
 	}
-	if len(payload.Result) == 0 {
-		return fmt.Errorf("rpc empty result")
+	// The last entry is the base fee of the block after the newest one.
+	baseFee, ok := parseHexBig(out.BaseFeePerGas[len(out.BaseFeePerGas)-1])
+	if !ok || baseFee.Sign() == 0 {
+		return nil, nil, nil
 	}
-	if err := json.Unmarshal(payload.Result, out); err != nil {
-		return err
+	rewards := make([]*big.Int, 0, len(out.Reward))
+	for _, block := range out.Reward {
+		if len(block) == 0 {
+			continue
+		}
+		if reward, ok := parseHexBig(block[0]); ok {
+			rewards = append(rewards, reward)
+		}
 	}
-	return nil
+	return baseFee, rewards, nil
+}
+
+func parseHexBig(v string) (*big.Int, bool) {
+	return new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "0x"), 16)
 }
@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	subscriptionMinBackoff = time.Second
+	subscriptionMaxBackoff = time.Minute
+	// subscriptionIdleTimeout drops a connection that has gone quiet; a live
+	// chain produces heads far more often.
+	subscriptionIdleTimeout = 2 * time.Minute
+)
+
+// HeadSubscriber listens for new heads, and for logs matching a filter, over
+// an eth_subscribe WebSocket. It carries no data itself: each notification is
+// a wake-up telling the caller to read the chain from its own cursor, so
+// nothing is lost across reconnects.
+type HeadSubscriber struct {
+	wsURL     string
+	filter    LogFilter
+	logger    *slog.Logger
+	connected atomic.Bool
+}
+
+func NewHeadSubscriber(wsURL string, filter LogFilter, logger *slog.Logger) (*HeadSubscriber, error) {
+	wsURL = strings.TrimSpace(wsURL)
+	if !strings.HasPrefix(wsURL, "ws://") && !strings.HasPrefix(wsURL, "wss://") {
+		return nil, fmt.Errorf("invalid CREDITCOIN_WS_RPC")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &HeadSubscriber{wsURL: wsURL, filter: filter, logger: logger}, nil
+}
+
+// Connected reports whether the newHeads subscription is currently live.
+// Callers poll while it is not.
+func (s *HeadSubscriber) Connected() bool {
+	return s.connected.Load()
+}
+
+// Run keeps the subscription open until ctx is done, reconnecting with
+// exponential backoff. It signals wake on every notification and right after
+// each (re)subscription so the caller backfills blocks missed while
+// disconnected. wake should be buffered; signals are dropped while one is
+// pending.
+func (s *HeadSubscriber) Run(ctx context.Context, wake chan<- struct{}) {
+	backoff := subscriptionMinBackoff
+	for ctx.Err() == nil {
+		subscribed, err := s.session(ctx, wake)
+		s.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			backoff = subscriptionMinBackoff
+		}
+		s.logger.Warn("chain subscription lost, polling until reconnected", "err", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > subscriptionMaxBackoff {
+			backoff = subscriptionMaxBackoff
+		}
+	}
+}
+
+type subscriptionMessage struct {
+	ID     *int            `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Method string `json:"method"`
+}
+
+// session runs one connection. It reports whether newHeads was subscribed
+// before the connection ended.
+func (s *HeadSubscriber) session(ctx context.Context, wake chan<- struct{}) (bool, error) {
+	cfg, err := websocket.NewConfig(s.wsURL, "http://localhost/")
+	if err != nil {
+		return false, err
+	}
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	const headsID, logsID = 1, 2
+	requests := []map[string]any{
+		{"jsonrpc": "2.0", "id": headsID, "method": "eth_subscribe", "params": []any{"newHeads"}},
+	}
+	if len(s.filter.Addresses) > 0 {
+		logFilter := map[string]any{"address": s.filter.Addresses}
+		if len(s.filter.Topics) > 0 {
+			logFilter["topics"] = []any{s.filter.Topics}
+		}
+		requests = append(requests, map[string]any{"jsonrpc": "2.0", "id": logsID, "method": "eth_subscribe", "params": []any{"logs", logFilter}})
+	}
+	for _, req := range requests {
+		if err := websocket.JSON.Send(conn, req); err != nil {
+			return false, err
+		}
+	}
+
+	subscribed := false
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(subscriptionIdleTimeout))
+		var msg subscriptionMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return subscribed, err
+		}
+		switch {
+		case msg.Method == "eth_subscription":
+			notify(wake)
+		case msg.ID != nil && *msg.ID == headsID:
+			if msg.Error != nil {
+				return false, classifyRPCError(msg.Error.Code, msg.Error.Message)
+			}
+			subscribed = true
+			s.connected.Store(true)
+			s.logger.Info("chain subscription established", "url", s.wsURL)
+			notify(wake)
+		case msg.ID != nil && *msg.ID == logsID && msg.Error != nil:
+			// newHeads alone still wakes the caller every block.
+			s.logger.Warn("chain log subscription rejected", "err", msg.Error.Message)
+		}
+	}
+}
+
+func notify(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
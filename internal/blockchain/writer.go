@@ -12,6 +12,66 @@ type LoanRegistryWriter interface {
 	MarkDefault(ctx context.Context, loanID string, reason string) (string, error)
 }
 
+// PoolPositionAction mirrors an investor deposit or withdrawal to the pool's
+// share token contract.
+type PoolPositionAction struct {
+	PoolTokenAddr  string
+	Action         string
+	TransactionID  string
+	InvestorUserID string
+	AmountMinor    int64
+	Shares         int64
+}
+
+// PoolTokenWriter is implemented by writers that can mint and burn pool
+// shares. It is optional; callers type-assert a LoanRegistryWriter for it.
+type PoolTokenWriter interface {
+	MirrorPoolPosition(ctx context.Context, in PoolPositionAction) (string, error)
+}
+
+const (
+	BatchActionRegisterLoan    = "register_loan"
+	BatchActionRecordRepayment = "record_repayment"
+)
+
+// BatchCall is one register or repayment call inside a multicall transaction.
+type BatchCall struct {
+	Action      string
+	LoanID      string
+	AmountMinor int64
+	Currency    string
+}
+
+// BatchWriter is implemented by writers that can submit several calls for one
+// lender in a single multicall transaction, executed in order. It is
+// optional; callers type-assert a LoanRegistryWriter for it. A revert of any
+// call reverts the whole transaction.
+type BatchWriter interface {
+	SubmitBatch(ctx context.Context, lenderID string, calls []BatchCall) (string, error)
+}
+
+// ValidateBatch checks every call in a batch before anything is sent.
+func ValidateBatch(lenderID string, calls []BatchCall) error {
+	if lenderID == "" || len(calls) == 0 {
+		return Permanent(fmt.Errorf("invalid batch args"))
+	}
+	for _, call := range calls {
+		switch call.Action {
+		case BatchActionRegisterLoan:
+			if call.LoanID == "" {
+				return Permanent(fmt.Errorf("missing loan id"))
+			}
+		case BatchActionRecordRepayment:
+			if call.LoanID == "" || call.AmountMinor <= 0 || len(call.Currency) != 3 {
+				return Permanent(fmt.Errorf("invalid repayment args"))
+			}
+		default:
+			return Permanent(fmt.Errorf("unsupported batch action %s", call.Action))
+		}
+	}
+	return nil
+}
+
 type StubWriter struct{}
 
 func NewStubWriter() *StubWriter {
@@ -20,7 +80,7 @@ func NewStubWriter() *StubWriter {
 
 func (w *StubWriter) RegisterLoan(_ context.Context, loanID string) (string, error) {
 	if loanID == "" {
-		return "", fmt.Errorf("missing loan id")
+		return "", Permanent(fmt.Errorf("missing loan id"))
 	}
 	prefix := loanID
 	if len(prefix) > 8 {
@@ -31,18 +91,32 @@ func (w *StubWriter) RegisterLoan(_ context.Context, loanID string) (string, err
 
 func (w *StubWriter) RecordRepayment(_ context.Context, loanID string, amountMinor int64, currency string) (string, error) {
 	if loanID == "" || amountMinor <= 0 || len(currency) != 3 {
-		return "", fmt.Errorf("invalid repayment args")
+		return "", Permanent(fmt.Errorf("invalid repayment args"))
 	}
 	return fmt.Sprintf("0xrepay%s%x", loanID[:min(8, len(loanID))], time.Now().UTC().UnixNano()), nil
 }
 
 func (w *StubWriter) MarkDefault(_ context.Context, loanID string, reason string) (string, error) {
 	if loanID == "" {
-		return "", fmt.Errorf("invalid default args")
+		return "", Permanent(fmt.Errorf("invalid default args"))
 	}
 	return fmt.Sprintf("0xdef%s%x", loanID[:min(8, len(loanID))], time.Now().UTC().UnixNano()), nil
 }
 
+func (w *StubWriter) MirrorPoolPosition(_ context.Context, in PoolPositionAction) (string, error) {
+	if in.PoolTokenAddr == "" || in.TransactionID == "" || in.Shares <= 0 {
+		return "", Permanent(fmt.Errorf("invalid pool position args"))
+	}
+	return fmt.Sprintf("0xpool%s%x", in.TransactionID[:min(8, len(in.TransactionID))], time.Now().UTC().UnixNano()), nil
+}
+
+func (w *StubWriter) SubmitBatch(_ context.Context, lenderID string, calls []BatchCall) (string, error) {
+	if err := ValidateBatch(lenderID, calls); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("0xbatch%s%x", lenderID[:min(8, len(lenderID))], time.Now().UTC().UnixNano()), nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
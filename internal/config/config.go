@@ -36,21 +36,48 @@ type Config struct {
 
 	WorkerPollInterval     time.Duration
 	WorkerBatchSize        int32
+	WorkerConcurrency      int32
+	WorkerChainBatchSize   int32
+	WorkerID               string
+	WorkerLeaseDuration    time.Duration
+	WorkerReapInterval     time.Duration
+	WorkerMaxAttempts      int32
+	WorkerRetryBaseDelay   time.Duration
+	WorkerRetryMaxDelay    time.Duration
+	WorkerRetryPolicies    string
 	ChainWriterMode        string
 	CreditcoinHTTPRPC      string
+	CreditcoinWSRPC        string
+	ChainRPCTimeout        time.Duration
+	ChainRPCReadRetries    int32
 	CreditcoinChainID      int64
 	LoanRegistryProxy      string
 	ChainWriterFromAddress string
 	LenderSignerPrivateKey string
 	ChainTxGasLimit        uint64
+	ChainTxStuckAfter      time.Duration
+	ChainTxFeeBumpPercent  int64
+	ChainGasPaddingPercent int64
+	ChainMaxFeePerGasWei   string
+	ChainMaxPriorityFeeWei string
+	ChainDailyFeeBudgetWei string
 	IndexerPollInterval    time.Duration
 	IndexerBatchSize       int32
 	IndexerIngestEnabled   bool
 	IndexerStartBlock      uint64
+	IndexerSourcesFile     string
 	IndexerBlockBatchSize  uint64
 	IndexerConfirmations   uint64
+	IndexerMaxAttempts     int32
 	WSEnabled              bool
 	WSPollInterval         time.Duration
+	WSListen               bool
+	WSNodeID               string
+	WSClientBuffer         int32
+	WSDropPolicy           string
+	WSPingInterval         time.Duration
+	WSIdleTimeout          time.Duration
+	WSReplayRetention      time.Duration
 	MaxRequestBodyBytes    int64
 }
 
@@ -84,21 +111,48 @@ func Load() Config {
 
 		WorkerPollInterval:     getEnvDuration("WORKER_POLL_INTERVAL", 2*time.Second),
 		WorkerBatchSize:        getEnvInt32("WORKER_BATCH_SIZE", 20),
+		WorkerConcurrency:      getEnvInt32("WORKER_CONCURRENCY", 4),
+		WorkerChainBatchSize:   getEnvInt32("WORKER_CHAIN_BATCH_SIZE", 50),
+		WorkerID:               getEnv("WORKER_ID", ""),
+		WorkerLeaseDuration:    getEnvDuration("WORKER_LEASE_DURATION", 2*time.Minute),
+		WorkerReapInterval:     getEnvDuration("WORKER_REAP_INTERVAL", 30*time.Second),
+		WorkerMaxAttempts:      getEnvInt32("WORKER_MAX_ATTEMPTS", 5),
+		WorkerRetryBaseDelay:   getEnvDuration("WORKER_RETRY_BASE_DELAY", 15*time.Second),
+		WorkerRetryMaxDelay:    getEnvDuration("WORKER_RETRY_MAX_DELAY", 15*time.Minute),
+		WorkerRetryPolicies:    getEnv("WORKER_RETRY_POLICIES", ""),
 		ChainWriterMode:        getEnv("CHAIN_WRITER_MODE", "stub"),
 		CreditcoinHTTPRPC:      getEnv("CREDITCOIN_HTTP_RPC", ""),
+		CreditcoinWSRPC:        getEnv("CREDITCOIN_WS_RPC", ""),
+		ChainRPCTimeout:        getEnvDuration("CHAIN_RPC_TIMEOUT", 20*time.Second),
+		ChainRPCReadRetries:    getEnvInt32("CHAIN_RPC_READ_RETRIES", 2),
 		CreditcoinChainID:      getEnvInt64("CREDITCOIN_CHAIN_ID", 102031),
 		LoanRegistryProxy:      getEnv("LOAN_REGISTRY_PROXY", ""),
 		ChainWriterFromAddress: getEnv("CHAIN_WRITER_FROM_ADDRESS", ""),
 		LenderSignerPrivateKey: getEnv("LENDER_SIGNER_PRIVATE_KEY", ""),
 		ChainTxGasLimit:        getEnvUint64("CHAIN_TX_GAS_LIMIT", 300000),
+		ChainTxStuckAfter:      getEnvDuration("CHAIN_TX_STUCK_AFTER", 5*time.Minute),
+		ChainTxFeeBumpPercent:  getEnvInt64("CHAIN_TX_FEE_BUMP_PERCENT", 20),
+		ChainGasPaddingPercent: getEnvInt64("CHAIN_GAS_ESTIMATE_MULTIPLIER_PERCENT", 120),
+		ChainMaxFeePerGasWei:   getEnv("CHAIN_MAX_FEE_PER_GAS_WEI", ""),
+		ChainMaxPriorityFeeWei: getEnv("CHAIN_MAX_PRIORITY_FEE_WEI", ""),
+		ChainDailyFeeBudgetWei: getEnv("CHAIN_DAILY_FEE_BUDGET_WEI", ""),
 		IndexerPollInterval:    getEnvDuration("INDEXER_POLL_INTERVAL", 2*time.Second),
 		IndexerBatchSize:       getEnvInt32("INDEXER_BATCH_SIZE", 100),
 		IndexerIngestEnabled:   getEnvBool("INDEXER_INGEST_ENABLED", false),
 		IndexerStartBlock:      getEnvUint64("INDEXER_START_BLOCK", 0),
+		IndexerSourcesFile:     getEnv("INDEXER_SOURCES_FILE", ""),
 		IndexerBlockBatchSize:  getEnvUint64("INDEXER_BLOCK_BATCH_SIZE", 500),
 		IndexerConfirmations:   getEnvUint64("INDEXER_CONFIRMATIONS", 2),
+		IndexerMaxAttempts:     getEnvInt32("INDEXER_MAX_EVENT_ATTEMPTS", 5),
 		WSEnabled:              getEnvBool("WS_ENABLED", true),
 		WSPollInterval:         getEnvDuration("WS_POLL_INTERVAL", 2*time.Second),
+		WSListen:               getEnvBool("WS_LISTEN", true),
+		WSNodeID:               getEnv("WS_NODE_ID", ""),
+		WSClientBuffer:         getEnvInt32("WS_CLIENT_BUFFER", 64),
+		WSDropPolicy:           getEnv("WS_DROP_POLICY", "disconnect"),
+		WSPingInterval:         getEnvDuration("WS_PING_INTERVAL", 25*time.Second),
+		WSIdleTimeout:          getEnvDuration("WS_IDLE_TIMEOUT", 60*time.Second),
+		WSReplayRetention:      getEnvDuration("WS_REPLAY_RETENTION", time.Hour),
 		MaxRequestBodyBytes:    getEnvInt64("MAX_REQUEST_BODY_BYTES", 62914560), // 60 MiB
 	}
 }
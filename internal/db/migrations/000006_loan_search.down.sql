@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_loans_loan_reference;
+DROP INDEX IF EXISTS idx_loans_currency;
+DROP INDEX IF EXISTS idx_loans_lender_outstanding_id;
+DROP INDEX IF EXISTS idx_loans_lender_principal_id;
+DROP INDEX IF EXISTS idx_loans_lender_maturity_id;
+DROP INDEX IF EXISTS idx_loans_lender_created_id;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_loans_lender_created_id ON loans(lender_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_loans_lender_maturity_id ON loans(lender_id, maturity_date, id);
+CREATE INDEX IF NOT EXISTS idx_loans_lender_principal_id ON loans(lender_id, principal_minor, id);
+CREATE INDEX IF NOT EXISTS idx_loans_lender_outstanding_id ON loans(lender_id, (principal_minor - amount_repaid_minor), id);
+CREATE INDEX IF NOT EXISTS idx_loans_currency ON loans(currency_code);
+CREATE INDEX IF NOT EXISTS idx_loans_loan_reference ON loans((metadata->>'loan_reference'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_repayments_loan_recorded;
+DROP INDEX IF EXISTS idx_pool_allocations_pool;
+DROP INDEX IF EXISTS idx_pool_allocations_active_loan;
+DROP TABLE IF EXISTS pool_allocations;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS pool_allocations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pool_id UUID NOT NULL REFERENCES pools(id),
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    allocated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    released_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pool_allocations_active_loan ON pool_allocations(loan_id) WHERE released_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_pool_allocations_pool ON pool_allocations(pool_id, allocated_at);
+CREATE INDEX IF NOT EXISTS idx_repayments_loan_recorded ON repayments(loan_id, recorded_at);
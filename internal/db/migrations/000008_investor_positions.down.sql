@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_investor_positions_investor;
+DROP TABLE IF EXISTS investor_positions;
+DROP INDEX IF EXISTS idx_investor_transactions_pool;
+DROP INDEX IF EXISTS idx_investor_transactions_investor_pool;
+DROP TABLE IF EXISTS investor_transactions;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS investor_transactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    pool_id UUID NOT NULL REFERENCES pools(id),
+    investor_user_id UUID NOT NULL REFERENCES users(id),
+    kind TEXT NOT NULL CHECK (kind IN ('deposit','withdrawal')),
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    shares BIGINT NOT NULL CHECK (shares > 0),
+    nav_per_share_micros BIGINT NOT NULL CHECK (nav_per_share_micros > 0),
+    on_chain_tx CHAR(66),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_investor_transactions_investor_pool ON investor_transactions(investor_user_id, pool_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_investor_transactions_pool ON investor_transactions(pool_id);
+
+CREATE TABLE IF NOT EXISTS investor_positions (
+    pool_id UUID NOT NULL REFERENCES pools(id),
+    investor_user_id UUID NOT NULL REFERENCES users(id),
+    shares BIGINT NOT NULL DEFAULT 0 CHECK (shares >= 0),
+    deposited_minor BIGINT NOT NULL DEFAULT 0,
+    withdrawn_minor BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pool_id, investor_user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_investor_positions_investor ON investor_positions(investor_user_id);
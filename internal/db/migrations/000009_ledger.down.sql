@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS ledger_postings_immutable ON ledger_postings;
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+DROP FUNCTION IF EXISTS ledger_reject_mutation();
+DROP INDEX IF EXISTS idx_ledger_postings_account;
+DROP INDEX IF EXISTS idx_ledger_postings_entry;
+DROP TABLE IF EXISTS ledger_postings;
+DROP INDEX IF EXISTS idx_ledger_entries_reference;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
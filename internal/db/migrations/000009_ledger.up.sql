@@ -0,0 +1,127 @@
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('borrower_receivable','lender_loan_book','interest_income','write_off','pool_cash','investor_equity')),
+    owner_id TEXT NOT NULL,
+    currency_code CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, owner_id, currency_code)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('disbursement','repayment','write_off','pool_allocation','pool_release','investor_deposit','investor_withdrawal')),
+    idempotency_key TEXT NOT NULL UNIQUE,
+    reference_type TEXT NOT NULL,
+    reference_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference ON ledger_entries(reference_type, reference_id);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    debit_minor BIGINT NOT NULL DEFAULT 0 CHECK (debit_minor >= 0),
+    credit_minor BIGINT NOT NULL DEFAULT 0 CHECK (credit_minor >= 0),
+    CHECK ((debit_minor = 0) <> (credit_minor = 0))
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry ON ledger_postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account ON ledger_postings(account_id, entry_id);
+
+CREATE OR REPLACE FUNCTION ledger_reject_mutation() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger rows are immutable (% on %)', TG_OP, TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+DROP TRIGGER IF EXISTS ledger_postings_immutable ON ledger_postings;
+CREATE TRIGGER ledger_postings_immutable BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+-- Backfill opening entries from existing rows so ledger balances match the
+-- counters at the time the ledger is introduced. Repayments recorded before
+-- this point are posted as one opening repayment per loan.
+INSERT INTO ledger_accounts (kind, owner_id, currency_code)
+SELECT 'borrower_receivable', id::text, currency_code FROM loans
+UNION SELECT 'lender_loan_book', lender_id::text, currency_code FROM loans
+UNION SELECT 'interest_income', lender_id::text, currency_code FROM loans
+UNION SELECT 'write_off', lender_id::text, currency_code FROM loans
+UNION SELECT 'lender_loan_book', lender_id::text, currency_code FROM pools
+UNION SELECT 'pool_cash', id::text, currency_code FROM pools
+UNION SELECT 'investor_equity', t.pool_id::text || ':' || t.investor_user_id::text, p.currency_code
+      FROM investor_transactions t JOIN pools p ON p.id = t.pool_id
+ON CONFLICT (kind, owner_id, currency_code) DO NOTHING;
+
+INSERT INTO ledger_entries (kind, idempotency_key, reference_type, reference_id, created_at)
+SELECT 'disbursement', 'loan:' || id::text || ':disbursement', 'loan', id::text, created_at
+FROM loans WHERE principal_minor > 0
+UNION ALL
+SELECT 'repayment', 'loan:' || id::text || ':opening_repayment', 'loan', id::text, updated_at
+FROM loans WHERE amount_repaid_minor > 0
+UNION ALL
+SELECT 'write_off', 'loan:' || id::text || ':write_off', 'loan', id::text, updated_at
+FROM loans WHERE status = 'defaulted' AND principal_minor > amount_repaid_minor
+UNION ALL
+SELECT 'pool_allocation', 'allocation:' || id::text, 'pool_allocation', id::text, allocated_at
+FROM pool_allocations
+UNION ALL
+SELECT 'pool_release', 'allocation:' || id::text || ':release', 'pool_allocation', id::text, released_at
+FROM pool_allocations WHERE released_at IS NOT NULL
+UNION ALL
+SELECT 'investor_' || kind, 'investor_transaction:' || id::text, 'investor_transaction', id::text, created_at
+FROM investor_transactions
+ON CONFLICT (idempotency_key) DO NOTHING;
+
+INSERT INTO ledger_postings (entry_id, account_id, debit_minor, credit_minor)
+SELECT e.id, a.id, x.debit_minor, x.credit_minor
+FROM (
+    SELECT 'loan:' || l.id::text || ':disbursement' AS idempotency_key, 'borrower_receivable' AS kind, l.id::text AS owner_id, l.currency_code, l.principal_minor AS debit_minor, 0::bigint AS credit_minor
+    FROM loans l WHERE l.principal_minor > 0
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':disbursement', 'lender_loan_book', l.lender_id::text, l.currency_code, 0, l.principal_minor
+    FROM loans l WHERE l.principal_minor > 0
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':opening_repayment', 'lender_loan_book', l.lender_id::text, l.currency_code, l.amount_repaid_minor, 0
+    FROM loans l WHERE l.amount_repaid_minor > 0
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':opening_repayment', 'borrower_receivable', l.id::text, l.currency_code, 0, LEAST(l.amount_repaid_minor, l.principal_minor)
+    FROM loans l WHERE l.amount_repaid_minor > 0 AND l.principal_minor > 0
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':opening_repayment', 'interest_income', l.lender_id::text, l.currency_code, 0, l.amount_repaid_minor - GREATEST(l.principal_minor, 0)
+    FROM loans l WHERE l.amount_repaid_minor > GREATEST(l.principal_minor, 0)
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':write_off', 'write_off', l.lender_id::text, l.currency_code, l.principal_minor - l.amount_repaid_minor, 0
+    FROM loans l WHERE l.status = 'defaulted' AND l.principal_minor > l.amount_repaid_minor
+    UNION ALL
+    SELECT 'loan:' || l.id::text || ':write_off', 'borrower_receivable', l.id::text, l.currency_code, 0, l.principal_minor - l.amount_repaid_minor
+    FROM loans l WHERE l.status = 'defaulted' AND l.principal_minor > l.amount_repaid_minor
+    UNION ALL
+    SELECT 'allocation:' || pa.id::text, 'lender_loan_book', p.lender_id::text, p.currency_code, pa.amount_minor, 0
+    FROM pool_allocations pa JOIN pools p ON p.id = pa.pool_id
+    UNION ALL
+    SELECT 'allocation:' || pa.id::text, 'pool_cash', p.id::text, p.currency_code, 0, pa.amount_minor
+    FROM pool_allocations pa JOIN pools p ON p.id = pa.pool_id
+    UNION ALL
+    SELECT 'allocation:' || pa.id::text || ':release', 'pool_cash', p.id::text, p.currency_code, pa.amount_minor, 0
+    FROM pool_allocations pa JOIN pools p ON p.id = pa.pool_id WHERE pa.released_at IS NOT NULL
+    UNION ALL
+    SELECT 'allocation:' || pa.id::text || ':release', 'lender_loan_book', p.lender_id::text, p.currency_code, 0, pa.amount_minor
+    FROM pool_allocations pa JOIN pools p ON p.id = pa.pool_id WHERE pa.released_at IS NOT NULL
+    UNION ALL
+    SELECT 'investor_transaction:' || t.id::text, 'pool_cash', t.pool_id::text, p.currency_code,
+           CASE WHEN t.kind = 'deposit' THEN t.amount_minor ELSE 0 END,
+           CASE WHEN t.kind = 'withdrawal' THEN t.amount_minor ELSE 0 END
+    FROM investor_transactions t JOIN pools p ON p.id = t.pool_id
+    UNION ALL
+    SELECT 'investor_transaction:' || t.id::text, 'investor_equity', t.pool_id::text || ':' || t.investor_user_id::text, p.currency_code,
+           CASE WHEN t.kind = 'withdrawal' THEN t.amount_minor ELSE 0 END,
+           CASE WHEN t.kind = 'deposit' THEN t.amount_minor ELSE 0 END
+    FROM investor_transactions t JOIN pools p ON p.id = t.pool_id
+) x
+JOIN ledger_entries e ON e.idempotency_key = x.idempotency_key
+JOIN ledger_accounts a ON a.kind = x.kind AND a.owner_id = x.owner_id AND a.currency_code = x.currency_code
+WHERE NOT EXISTS (SELECT 1 FROM ledger_postings lp WHERE lp.entry_id = e.id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_jobs_topic_status;
+UPDATE outbox_jobs SET status = 'failed' WHERE status = 'cancelled';
+ALTER TABLE outbox_jobs DROP CONSTRAINT IF EXISTS outbox_jobs_status_check;
+ALTER TABLE outbox_jobs ADD CONSTRAINT outbox_jobs_status_check CHECK (status IN ('pending','processing','done','failed'));
@@ -0,0 +1,3 @@
+ALTER TABLE outbox_jobs DROP CONSTRAINT IF EXISTS outbox_jobs_status_check;
+ALTER TABLE outbox_jobs ADD CONSTRAINT outbox_jobs_status_check CHECK (status IN ('pending','processing','done','failed','cancelled'));
+CREATE INDEX IF NOT EXISTS idx_outbox_jobs_topic_status ON outbox_jobs(topic, status, id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_outbox_jobs_loan_open;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_outbox_jobs_loan_open ON outbox_jobs ((payload->>'loan_id'), id) WHERE status IN ('pending','processing');
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_jobs_lease;
+ALTER TABLE outbox_jobs DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE outbox_jobs DROP COLUMN IF EXISTS locked_by;
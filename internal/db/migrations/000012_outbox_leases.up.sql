@@ -0,0 +1,5 @@
+ALTER TABLE outbox_jobs ADD COLUMN IF NOT EXISTS locked_by TEXT;
+ALTER TABLE outbox_jobs ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_outbox_jobs_lease ON outbox_jobs(locked_until) WHERE status = 'processing';
+-- Jobs stranded in processing before leases existed become reapable.
+UPDATE outbox_jobs SET locked_until = NOW() WHERE status = 'processing' AND locked_until IS NULL;
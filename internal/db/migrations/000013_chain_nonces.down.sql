@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chain_nonce_reservations;
+DROP TABLE IF EXISTS chain_nonces;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS chain_nonces (
+    address TEXT PRIMARY KEY,
+    next_nonce BIGINT NOT NULL CHECK (next_nonce >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS chain_nonce_reservations (
+    address TEXT NOT NULL REFERENCES chain_nonces(address),
+    nonce BIGINT NOT NULL CHECK (nonce >= 0),
+    status TEXT NOT NULL CHECK (status IN ('reserved','submitted','released','used')),
+    tx_hash TEXT,
+    to_address TEXT,
+    data TEXT,
+    gas BIGINT,
+    gas_price_wei NUMERIC(78,0),
+    replacements INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (address, nonce)
+);
+CREATE INDEX IF NOT EXISTS idx_chain_nonce_reservations_open ON chain_nonce_reservations(address, status, nonce) WHERE status IN ('reserved','submitted','released');
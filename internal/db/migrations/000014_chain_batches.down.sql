@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chain_batch_items;
+DROP TABLE IF EXISTS chain_batches;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS chain_batches (
+    id BIGSERIAL PRIMARY KEY,
+    lender_id TEXT NOT NULL,
+    tx_hash TEXT NOT NULL,
+    call_count INTEGER NOT NULL CHECK (call_count > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_chain_batches_tx_hash ON chain_batches(tx_hash);
+
+CREATE TABLE IF NOT EXISTS chain_batch_items (
+    batch_id BIGINT NOT NULL REFERENCES chain_batches(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    outbox_job_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    loan_id TEXT NOT NULL,
+    PRIMARY KEY (batch_id, position)
+);
+CREATE INDEX IF NOT EXISTS idx_chain_batch_items_loan ON chain_batch_items(loan_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS chain_fee_spend;
+ALTER TABLE chain_nonce_reservations DROP COLUMN IF EXISTS max_priority_fee_wei;
+ALTER TABLE chain_nonce_reservations DROP COLUMN IF EXISTS max_fee_per_gas_wei;
@@ -0,0 +1,12 @@
+ALTER TABLE chain_nonce_reservations ADD COLUMN IF NOT EXISTS max_fee_per_gas_wei NUMERIC(78,0);
+ALTER TABLE chain_nonce_reservations ADD COLUMN IF NOT EXISTS max_priority_fee_wei NUMERIC(78,0);
+
+CREATE TABLE IF NOT EXISTS chain_fee_spend (
+    day DATE NOT NULL,
+    topic TEXT NOT NULL,
+    tx_count BIGINT NOT NULL DEFAULT 0,
+    gas_total NUMERIC(78,0) NOT NULL DEFAULT 0,
+    max_cost_wei NUMERIC(78,0) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (day, topic)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_events_quarantined;
+ALTER TABLE chain_events DROP COLUMN IF EXISTS skipped_at;
+ALTER TABLE chain_events DROP COLUMN IF EXISTS quarantined_at;
+ALTER TABLE chain_events DROP COLUMN IF EXISTS last_error;
+ALTER TABLE chain_events DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,5 @@
+ALTER TABLE chain_events ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE chain_events ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE chain_events ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ;
+ALTER TABLE chain_events ADD COLUMN IF NOT EXISTS skipped_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_events_quarantined ON chain_events(quarantined_at, id) WHERE quarantined_at IS NOT NULL AND processed = FALSE;
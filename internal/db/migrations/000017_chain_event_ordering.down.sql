@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chain_repayment_applications;
+DROP INDEX IF EXISTS idx_events_unprocessed_order;
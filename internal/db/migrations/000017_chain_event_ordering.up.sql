@@ -0,0 +1,11 @@
+CREATE INDEX IF NOT EXISTS idx_events_unprocessed_order ON chain_events(block_number, log_index, id) WHERE processed = FALSE AND quarantined_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS chain_repayment_applications (
+    tx_hash CHAR(66) NOT NULL,
+    log_index INT NOT NULL,
+    loan_id UUID NOT NULL REFERENCES loans(id),
+    amount_minor BIGINT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_chain_repayment_applications_loan ON chain_repayment_applications(loan_id);
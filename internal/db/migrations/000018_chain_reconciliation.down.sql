@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chain_reconciliation_discrepancies;
+DROP TABLE IF EXISTS chain_reconciliation_runs;
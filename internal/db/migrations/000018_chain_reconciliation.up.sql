@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS chain_reconciliation_runs (
+    id BIGSERIAL PRIMARY KEY,
+    block_number BIGINT NOT NULL,
+    loans_checked INT NOT NULL,
+    discrepancies INT NOT NULL,
+    jobs_enqueued INT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS chain_reconciliation_discrepancies (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES chain_reconciliation_runs(id) ON DELETE CASCADE,
+    loan_id UUID NOT NULL REFERENCES loans(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    db_value TEXT NOT NULL,
+    chain_value TEXT NOT NULL,
+    events_value TEXT NOT NULL,
+    in_flight BOOLEAN NOT NULL DEFAULT FALSE,
+    corrective_topic TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_chain_reconciliation_discrepancies_run ON chain_reconciliation_discrepancies(run_id, id);
+CREATE INDEX IF NOT EXISTS idx_chain_reconciliation_discrepancies_loan ON chain_reconciliation_discrepancies(loan_id);
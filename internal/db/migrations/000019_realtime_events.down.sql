@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS realtime_cursors;
+DROP TRIGGER IF EXISTS realtime_events_notify ON realtime_events;
+DROP FUNCTION IF EXISTS realtime_events_notify();
+DROP TABLE IF EXISTS realtime_events;
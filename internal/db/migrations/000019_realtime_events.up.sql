@@ -0,0 +1,30 @@
+-- Realtime events are written by the process making the change, in the
+-- change's own transaction, and delivered by every API node following its
+-- cursor over the ids. Writers serialize on an advisory lock before
+-- inserting, so ids become visible in order.
+CREATE TABLE IF NOT EXISTS realtime_events (
+    id BIGSERIAL PRIMARY KEY,
+    channel TEXT NOT NULL,
+    event TEXT NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_realtime_events_channel ON realtime_events(channel, id);
+
+CREATE OR REPLACE FUNCTION realtime_events_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('realtime_events', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS realtime_events_notify ON realtime_events;
+CREATE TRIGGER realtime_events_notify AFTER INSERT ON realtime_events
+    FOR EACH STATEMENT EXECUTE FUNCTION realtime_events_notify();
+
+CREATE TABLE IF NOT EXISTS realtime_cursors (
+    node_id TEXT PRIMARY KEY,
+    last_seq BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,10 @@
+-- Users acting for a lender. Realtime channels of a lender are only open to
+-- its members (and admins).
+CREATE TABLE IF NOT EXISTS lender_members (
+    lender_id UUID NOT NULL REFERENCES lenders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (lender_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_lender_members_user ON lender_members(user_id);
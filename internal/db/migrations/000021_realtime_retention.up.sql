@@ -0,0 +1,2 @@
+-- Events older than WS_REPLAY_RETENTION are pruned by created_at.
+CREATE INDEX IF NOT EXISTS idx_realtime_events_created ON realtime_events(created_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS chain_fee_budget (
+    day DATE PRIMARY KEY,
+    reserved_wei NUMERIC(78,0) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO chain_fee_budget (day, reserved_wei)
+SELECT day, SUM(max_cost_wei)
+FROM chain_fee_spend
+GROUP BY day
+ON CONFLICT (day) DO NOTHING;
@@ -0,0 +1,3 @@
+-- Backfilled memberships cannot be told apart from ones added later, so they
+-- are kept.
+SELECT 1;
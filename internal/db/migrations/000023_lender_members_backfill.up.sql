@@ -0,0 +1,8 @@
+-- Lender users whose wallet is their lender's wallet were acting for it before
+-- memberships existed. Anyone else needs an admin to add them.
+INSERT INTO lender_members (lender_id, user_id)
+SELECT l.id, u.id
+FROM users u
+JOIN lenders l ON LOWER(l.wallet_address) = LOWER(TRIM(u.wallet_address))
+WHERE u.role = 'lender'
+ON CONFLICT (lender_id, user_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Failed jobs hold their loan's later jobs too, so the ordering check needs
+-- them in the index.
+DROP INDEX IF EXISTS idx_outbox_jobs_loan_open;
+CREATE INDEX IF NOT EXISTS idx_outbox_jobs_loan_open ON outbox_jobs ((payload->>'loan_id'), id) WHERE status IN ('pending','processing','failed');
@@ -13,6 +13,8 @@ type LenderRepository interface {
 	Create(ctx context.Context, in lenderdomain.CreateInput) (*lenderdomain.Entity, error)
 	GetByID(ctx context.Context, id string) (*lenderdomain.Entity, error)
 	UpdateKYCStatus(ctx context.Context, lenderID, kycStatus string) error
+	AddMember(ctx context.Context, lenderID, userID string) error
+	RemoveMember(ctx context.Context, lenderID, userID string) error
 }
 
 type AuditRepository interface {
@@ -86,3 +88,37 @@ func (s *Service) UpdateLenderStatus(ctx context.Context, adminUserID, lenderID,
 	})
 	return nil
 }
+
+// AddLenderMember lets a user act for a lender, e.g. subscribe to its
+// realtime channels.
+func (s *Service) AddLenderMember(ctx context.Context, adminUserID, lenderID, userID string) error {
+	return s.changeLenderMember(ctx, adminUserID, lenderID, userID, "lender_member_added", s.lenderRepo.AddMember)
+}
+
+func (s *Service) RemoveLenderMember(ctx context.Context, adminUserID, lenderID, userID string) error {
+	return s.changeLenderMember(ctx, adminUserID, lenderID, userID, "lender_member_removed", s.lenderRepo.RemoveMember)
+}
+
+func (s *Service) changeLenderMember(ctx context.Context, adminUserID, lenderID, userID, action string, apply func(ctx context.Context, lenderID, userID string) error) error {
+	if strings.TrimSpace(lenderID) == "" {
+		return fmt.Errorf("missing_lender_id")
+	}
+	if strings.TrimSpace(userID) == "" {
+		return fmt.Errorf("missing_user_id")
+	}
+	if _, err := s.lenderRepo.GetByID(ctx, lenderID); err != nil {
+		return err
+	}
+	if err := apply(ctx, lenderID, userID); err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(map[string]any{"user_id": userID})
+	_ = s.auditRepo.Log(ctx, AuditLogInput{
+		AdminUserID: adminUserID,
+		Action:      action,
+		TargetType:  "lender",
+		TargetID:    lenderID,
+		Payload:     payload,
+	})
+	return nil
+}
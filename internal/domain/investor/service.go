@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
@@ -24,9 +25,16 @@ type LenderProfile struct {
 	Health    *loandomain.PortfolioHealth    `json:"health"`
 }
 
+type PoolPerformance struct {
+	Summary *pooldomain.PerformanceSummary `json:"summary"`
+	Items   []loandomain.PerformancePoint  `json:"items"`
+}
+
 type PoolRepository interface {
 	List(ctx context.Context, lenderID, currencyCode, status string, limit, offset int32) ([]pooldomain.Entity, error)
 	GetByID(ctx context.Context, id string) (*pooldomain.Entity, error)
+	GetPerformanceSummary(ctx context.Context, poolID string) (*pooldomain.PerformanceSummary, error)
+	GetRepaymentTimeSeries(ctx context.Context, poolID string, days int32) ([]loandomain.PerformancePoint, error)
 }
 
 type LenderRepository interface {
@@ -34,7 +42,6 @@ type LenderRepository interface {
 }
 
 type LoanRepository interface {
-	GetRepaymentTimeSeriesByLender(ctx context.Context, lenderID string, days int32) ([]loandomain.PerformancePoint, error)
 	GetPortfolioAnalytics(ctx context.Context, lenderID string) (*loandomain.PortfolioAnalytics, error)
 	GetPortfolioHealth(ctx context.Context, lenderID string) (*loandomain.PortfolioHealth, error)
 }
@@ -43,10 +50,16 @@ type Service struct {
 	poolRepo   PoolRepository
 	lenderRepo LenderRepository
 	loanRepo   LoanRepository
+	now        func() time.Time
 }
 
 func NewService(poolRepo PoolRepository, lenderRepo LenderRepository, loanRepo LoanRepository) *Service {
-	return &Service{poolRepo: poolRepo, lenderRepo: lenderRepo, loanRepo: loanRepo}
+	return &Service{
+		poolRepo:   poolRepo,
+		lenderRepo: lenderRepo,
+		loanRepo:   loanRepo,
+		now:        func() time.Time { return time.Now().UTC() },
+	}
 }
 
 func (s *Service) ListPools(ctx context.Context, f PoolFilter) ([]pooldomain.Entity, error) {
@@ -60,12 +73,39 @@ func (s *Service) GetPool(ctx context.Context, poolID string) (*pooldomain.Entit
 	return s.poolRepo.GetByID(ctx, poolID)
 }
 
-func (s *Service) GetPoolPerformance(ctx context.Context, poolID string, days int32) ([]loandomain.PerformancePoint, error) {
+func (s *Service) GetPoolPerformance(ctx context.Context, poolID string, days int32) (*PoolPerformance, error) {
 	pool, err := s.GetPool(ctx, poolID)
 	if err != nil {
 		return nil, err
 	}
-	return s.loanRepo.GetRepaymentTimeSeriesByLender(ctx, pool.LenderID, days)
+	summary, err := s.poolRepo.GetPerformanceSummary(ctx, pool.ID)
+	if err != nil {
+		return nil, err
+	}
+	applyPoolYield(summary, s.now())
+	points, err := s.poolRepo.GetRepaymentTimeSeries(ctx, pool.ID, days)
+	if err != nil {
+		return nil, err
+	}
+	return &PoolPerformance{Summary: summary, Items: points}, nil
+}
+
+// applyPoolYield derives default rate and realized yield from the raw pool
+// totals. Realized yield is interest collected net of default losses, relative
+// to deployed capital and annualized over the time since the first allocation.
+func applyPoolYield(summary *pooldomain.PerformanceSummary, now time.Time) {
+	if summary.AllocatedLoans > 0 {
+		summary.DefaultRatePercent = (float64(summary.DefaultedLoans) / float64(summary.AllocatedLoans)) * 100
+	}
+	if summary.DeployedMinor > 0 && summary.FirstAllocatedAt != nil {
+		days := now.Sub(*summary.FirstAllocatedAt).Hours() / 24
+		if days < 1 {
+			days = 1
+		}
+		net := float64(summary.InterestCollectedMinor - summary.DefaultLossMinor)
+		summary.RealizedYieldBPS = (net / float64(summary.DeployedMinor)) * 10000 * (365 / days)
+	}
+	summary.YieldVsTargetBPS = summary.RealizedYieldBPS - float64(summary.TargetAPYBPS)
 }
 
 func (s *Service) GetLenderProfile(ctx context.Context, lenderID string) (*LenderProfile, error) {
@@ -0,0 +1,181 @@
+package ledger
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrInvalidEntry    = errors.New("invalid_ledger_entry")
+	ErrUnbalancedEntry = errors.New("unbalanced_ledger_entry")
+)
+
+// debitNormal lists the account kinds whose balance grows with debits.
+var debitNormal = map[string]bool{
+	AccountBorrowerReceivable: true,
+	AccountWriteOff:           true,
+	AccountPoolCash:           true,
+	AccountLenderLoanBook:     false,
+	AccountInterestIncome:     false,
+	AccountInvestorEquity:     false,
+}
+
+func IsAccountKind(kind string) bool {
+	_, ok := debitNormal[kind]
+	return ok
+}
+
+// NormalBalance signs debits minus credits towards the account's normal side.
+func NormalBalance(kind string, debitMinor, creditMinor int64) int64 {
+	if debitNormal[kind] {
+		return debitMinor - creditMinor
+	}
+	return creditMinor - debitMinor
+}
+
+func InvestorEquityOwner(poolID, investorUserID string) string {
+	return poolID + ":" + investorUserID
+}
+
+// Validate checks the entry is identifiable and that debits equal credits in
+// every currency it touches.
+func (in EntryInput) Validate() error {
+	if in.Kind == "" || in.IdempotencyKey == "" || len(in.Postings) < 2 {
+		return ErrInvalidEntry
+	}
+	net := map[string]int64{}
+	for _, p := range in.Postings {
+		if !IsAccountKind(p.Account.Kind) || p.Account.OwnerID == "" || len(p.Account.CurrencyCode) != 3 {
+			return ErrInvalidEntry
+		}
+		if p.DebitMinor < 0 || p.CreditMinor < 0 || (p.DebitMinor == 0) == (p.CreditMinor == 0) {
+			return ErrInvalidEntry
+		}
+		net[p.Account.CurrencyCode] += p.DebitMinor - p.CreditMinor
+	}
+	for _, v := range net {
+		if v != 0 {
+			return ErrUnbalancedEntry
+		}
+	}
+	return nil
+}
+
+// Disbursement moves a loan's principal from the lender's loan book to the
+// borrower's receivable.
+func Disbursement(loanID, lenderID, currency string, principalMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryDisbursement,
+		IdempotencyKey: "loan:" + loanID + ":disbursement",
+		ReferenceType:  "loan",
+		ReferenceID:    loanID,
+		Postings: []Posting{
+			{Account: Account{AccountBorrowerReceivable, loanID, currency}, DebitMinor: principalMinor},
+			{Account: Account{AccountLenderLoanBook, lenderID, currency}, CreditMinor: principalMinor},
+		},
+	}
+}
+
+// Repayment returns cash to the lender's loan book. The part that clears the
+// outstanding receivable is principal; anything above it is interest income.
+func Repayment(repaymentID, loanID, lenderID, currency string, amountMinor, outstandingMinor int64) EntryInput {
+	principal := amountMinor
+	if outstandingMinor < principal {
+		principal = max(outstandingMinor, 0)
+	}
+	interest := amountMinor - principal
+	postings := []Posting{{Account: Account{AccountLenderLoanBook, lenderID, currency}, DebitMinor: amountMinor}}
+	if principal > 0 {
+		postings = append(postings, Posting{Account: Account{AccountBorrowerReceivable, loanID, currency}, CreditMinor: principal})
+	}
+	if interest > 0 {
+		postings = append(postings, Posting{Account: Account{AccountInterestIncome, lenderID, currency}, CreditMinor: interest})
+	}
+	return EntryInput{
+		Kind:           EntryRepayment,
+		IdempotencyKey: "repayment:" + repaymentID,
+		ReferenceType:  "repayment",
+		ReferenceID:    repaymentID,
+		Postings:       postings,
+	}
+}
+
+// ChainRepayment is a Repayment observed on chain, keyed by the log that
+// recorded it so replaying the log posts nothing new.
+func ChainRepayment(txHash string, logIndex uint64, loanID, lenderID, currency string, amountMinor, outstandingMinor int64) EntryInput {
+	ref := txHash + ":" + strconv.FormatUint(logIndex, 10)
+	in := Repayment(ref, loanID, lenderID, currency, amountMinor, outstandingMinor)
+	in.IdempotencyKey = "chain_repayment:" + ref
+	in.ReferenceType = "chain_repayment"
+	in.ReferenceID = ref
+	return in
+}
+
+// WriteOff clears the outstanding receivable of a defaulted loan into the
+// lender's loss account.
+func WriteOff(loanID, lenderID, currency string, outstandingMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryWriteOff,
+		IdempotencyKey: "loan:" + loanID + ":write_off",
+		ReferenceType:  "loan",
+		ReferenceID:    loanID,
+		Postings: []Posting{
+			{Account: Account{AccountWriteOff, lenderID, currency}, DebitMinor: outstandingMinor},
+			{Account: Account{AccountBorrowerReceivable, loanID, currency}, CreditMinor: outstandingMinor},
+		},
+	}
+}
+
+// PoolAllocation funds a lender's loan from pool cash.
+func PoolAllocation(allocationID, poolID, lenderID, currency string, amountMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryPoolAllocation,
+		IdempotencyKey: "allocation:" + allocationID,
+		ReferenceType:  "pool_allocation",
+		ReferenceID:    allocationID,
+		Postings: []Posting{
+			{Account: Account{AccountLenderLoanBook, lenderID, currency}, DebitMinor: amountMinor},
+			{Account: Account{AccountPoolCash, poolID, currency}, CreditMinor: amountMinor},
+		},
+	}
+}
+
+// PoolRelease reverses a pool allocation.
+func PoolRelease(allocationID, poolID, lenderID, currency string, amountMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryPoolRelease,
+		IdempotencyKey: "allocation:" + allocationID + ":release",
+		ReferenceType:  "pool_allocation",
+		ReferenceID:    allocationID,
+		Postings: []Posting{
+			{Account: Account{AccountPoolCash, poolID, currency}, DebitMinor: amountMinor},
+			{Account: Account{AccountLenderLoanBook, lenderID, currency}, CreditMinor: amountMinor},
+		},
+	}
+}
+
+func InvestorDeposit(transactionID, poolID, investorUserID, currency string, amountMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryInvestorDeposit,
+		IdempotencyKey: "investor_transaction:" + transactionID,
+		ReferenceType:  "investor_transaction",
+		ReferenceID:    transactionID,
+		Postings: []Posting{
+			{Account: Account{AccountPoolCash, poolID, currency}, DebitMinor: amountMinor},
+			{Account: Account{AccountInvestorEquity, InvestorEquityOwner(poolID, investorUserID), currency}, CreditMinor: amountMinor},
+		},
+	}
+}
+
+func InvestorWithdrawal(transactionID, poolID, investorUserID, currency string, amountMinor int64) EntryInput {
+	return EntryInput{
+		Kind:           EntryInvestorWithdrawal,
+		IdempotencyKey: "investor_transaction:" + transactionID,
+		ReferenceType:  "investor_transaction",
+		ReferenceID:    transactionID,
+		Postings: []Posting{
+			{Account: Account{AccountInvestorEquity, InvestorEquityOwner(poolID, investorUserID), currency}, DebitMinor: amountMinor},
+			{Account: Account{AccountPoolCash, poolID, currency}, CreditMinor: amountMinor},
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidAccount  = errors.New("invalid_ledger_account")
+	ErrMissingLenderID = errors.New("missing_lender_id")
+	ErrMissingPoolID   = errors.New("missing_pool_id")
+)
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func (s *Service) GetAccountStatement(ctx context.Context, kind, ownerID, currency string, limit, offset int32) (*AccountStatement, error) {
+	account := Account{
+		Kind:         strings.TrimSpace(kind),
+		OwnerID:      strings.TrimSpace(ownerID),
+		CurrencyCode: strings.ToUpper(strings.TrimSpace(currency)),
+	}
+	if !IsAccountKind(account.Kind) || account.OwnerID == "" || len(account.CurrencyCode) != 3 {
+		return nil, ErrInvalidAccount
+	}
+	balance, err := s.repo.GetBalance(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.ListAccountEntries(ctx, account, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountStatement{Balance: balance, Entries: entries}, nil
+}
+
+func (s *Service) GetLenderBalances(ctx context.Context, lenderID string) ([]LenderBalances, error) {
+	if strings.TrimSpace(lenderID) == "" {
+		return nil, ErrMissingLenderID
+	}
+	return s.repo.GetLenderBalances(ctx, strings.TrimSpace(lenderID))
+}
+
+func (s *Service) GetPoolBalances(ctx context.Context, poolID string) ([]PoolBalances, error) {
+	if strings.TrimSpace(poolID) == "" {
+		return nil, ErrMissingPoolID
+	}
+	return s.repo.GetPoolBalances(ctx, strings.TrimSpace(poolID))
+}
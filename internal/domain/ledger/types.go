@@ -0,0 +1,107 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// Account kinds. Borrower receivables are owned by a loan, the lender kinds by
+// a lender, pool cash by a pool and investor equity by a pool/investor pair
+// (see InvestorEquityOwner).
+const (
+	AccountBorrowerReceivable = "borrower_receivable"
+	AccountLenderLoanBook     = "lender_loan_book"
+	AccountInterestIncome     = "interest_income"
+	AccountWriteOff           = "write_off"
+	AccountPoolCash           = "pool_cash"
+	AccountInvestorEquity     = "investor_equity"
+)
+
+const (
+	EntryDisbursement       = "disbursement"
+	EntryRepayment          = "repayment"
+	EntryWriteOff           = "write_off"
+	EntryPoolAllocation     = "pool_allocation"
+	EntryPoolRelease        = "pool_release"
+	EntryInvestorDeposit    = "investor_deposit"
+	EntryInvestorWithdrawal = "investor_withdrawal"
+)
+
+type Account struct {
+	Kind         string
+	OwnerID      string
+	CurrencyCode string
+}
+
+// Posting moves money into (debit) or out of (credit) one account. Exactly one
+// side is non-zero.
+type Posting struct {
+	Account     Account
+	DebitMinor  int64
+	CreditMinor int64
+}
+
+// EntryInput is a balanced journal entry. IdempotencyKey identifies the
+// business event so posting it twice is a no-op.
+type EntryInput struct {
+	Kind           string
+	IdempotencyKey string
+	ReferenceType  string
+	ReferenceID    string
+	Postings       []Posting
+}
+
+type Balance struct {
+	Kind         string `json:"kind"`
+	OwnerID      string `json:"owner_id"`
+	CurrencyCode string `json:"currency_code"`
+	DebitMinor   int64  `json:"debit_minor"`
+	CreditMinor  int64  `json:"credit_minor"`
+	// BalanceMinor is signed towards the account's normal side, so a
+	// receivable with principal outstanding is positive.
+	BalanceMinor int64 `json:"balance_minor"`
+}
+
+type EntryLine struct {
+	EntryID       string    `json:"entry_id"`
+	Kind          string    `json:"kind"`
+	ReferenceType string    `json:"reference_type"`
+	ReferenceID   string    `json:"reference_id"`
+	DebitMinor    int64     `json:"debit_minor"`
+	CreditMinor   int64     `json:"credit_minor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AccountStatement struct {
+	Balance *Balance    `json:"balance"`
+	Entries []EntryLine `json:"entries"`
+}
+
+// LenderBalances are the ledger totals behind a lender's portfolio analytics,
+// per currency.
+type LenderBalances struct {
+	LenderID                string `json:"lender_id"`
+	CurrencyCode            string `json:"currency_code"`
+	PrincipalDisbursedMinor int64  `json:"principal_disbursed_minor"`
+	PrincipalRepaidMinor    int64  `json:"principal_repaid_minor"`
+	InterestCollectedMinor  int64  `json:"interest_collected_minor"`
+	WrittenOffMinor         int64  `json:"written_off_minor"`
+	OutstandingMinor        int64  `json:"outstanding_minor"`
+	PoolFundedMinor         int64  `json:"pool_funded_minor"`
+}
+
+// PoolBalances are the ledger totals behind a pool's deployment and NAV.
+type PoolBalances struct {
+	PoolID              string `json:"pool_id"`
+	CurrencyCode        string `json:"currency_code"`
+	CashMinor           int64  `json:"cash_minor"`
+	DeployedMinor       int64  `json:"deployed_minor"`
+	InvestorEquityMinor int64  `json:"investor_equity_minor"`
+}
+
+type Repository interface {
+	GetBalance(ctx context.Context, account Account) (*Balance, error)
+	ListAccountEntries(ctx context.Context, account Account, limit, offset int32) ([]EntryLine, error)
+	GetLenderBalances(ctx context.Context, lenderID string) ([]LenderBalances, error)
+	GetPoolBalances(ctx context.Context, poolID string) ([]PoolBalances, error)
+}
@@ -2,9 +2,13 @@ package lender
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned by Repository.GetByID for an unknown lender.
+var ErrNotFound = errors.New("lender_not_found")
+
 type Entity struct {
 	ID            string
 	Name          string
@@ -29,4 +33,7 @@ type Repository interface {
 	GetByID(ctx context.Context, id string) (*Entity, error)
 	GetByWallet(ctx context.Context, walletAddress string) (*Entity, error)
 	UpdateKYCStatus(ctx context.Context, lenderID, kycStatus string) error
+	AddMember(ctx context.Context, lenderID, userID string) error
+	RemoveMember(ctx context.Context, lenderID, userID string) error
+	IsMember(ctx context.Context, lenderID, userID string) (bool, error)
 }
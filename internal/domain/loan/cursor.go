@@ -0,0 +1,94 @@
+package loan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid_cursor")
+	ErrInvalidSort   = errors.New("invalid_sort")
+)
+
+// Cursor is the keyset position of the last row on a page: the value of the
+// sort column plus the loan id as a tie-breaker.
+type Cursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        string `json:"id"`
+}
+
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.ID == "" || c.Value == "" {
+		return nil, ErrInvalidCursor
+	}
+	switch c.SortBy {
+	case SortCreatedAt, SortMaturity:
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	case SortPrincipal, SortOutstanding:
+		if _, err := strconv.ParseInt(c.Value, 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	default:
+		return nil, ErrInvalidCursor
+	}
+	if c.SortOrder != SortAsc && c.SortOrder != SortDesc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func cursorFor(item Entity, sortBy, sortOrder string) Cursor {
+	c := Cursor{SortBy: sortBy, SortOrder: sortOrder, ID: item.ID}
+	switch sortBy {
+	case SortMaturity:
+		c.Value = item.MaturityDate.UTC().Format(time.RFC3339Nano)
+	case SortPrincipal:
+		c.Value = strconv.FormatInt(item.PrincipalMinor, 10)
+	case SortOutstanding:
+		c.Value = strconv.FormatInt(item.PrincipalMinor-item.AmountRepaid, 10)
+	default:
+		c.Value = item.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+func normalizeSort(sortBy, sortOrder string) (string, string, error) {
+	sortBy = strings.ToLower(strings.TrimSpace(sortBy))
+	sortOrder = strings.ToLower(strings.TrimSpace(sortOrder))
+	switch sortBy {
+	case "":
+		sortBy = SortCreatedAt
+	case SortCreatedAt, SortMaturity, SortPrincipal, SortOutstanding:
+	default:
+		return "", "", ErrInvalidSort
+	}
+	switch sortOrder {
+	case "":
+		sortOrder = SortDesc
+	case SortAsc, SortDesc:
+	default:
+		return "", "", ErrInvalidSort
+	}
+	return sortBy, sortOrder, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
+	"github.com/loangraph/backend/internal/realtime"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -20,6 +22,9 @@ const (
 	outboxTopicDefault      = "mark_default"
 )
 
+// ErrNotFound is returned by Repository.GetByID for an unknown loan.
+var ErrNotFound = errors.New("loan_not_found")
+
 var expectedHeaders = []string{
 	"borrower_kyc_id",
 	"gov_id_hash",
@@ -67,6 +72,7 @@ type Service struct {
 	borrowerRepo BorrowerRepository
 	loanRepo     Repository
 	outboxRepo   OutboxRepository
+	publisher    realtime.Publisher
 	now          func() time.Time
 }
 
@@ -79,6 +85,12 @@ func NewService(borrowerRepo BorrowerRepository, loanRepo Repository, outboxRepo
 	}
 }
 
+// SetRealtimePublisher announces completed uploads on the lender's uploads
+// channel.
+func (s *Service) SetRealtimePublisher(p realtime.Publisher) {
+	s.publisher = p
+}
+
 func HashBorrowerID(kycProviderID, govIDHash string) []byte {
 	input := fmt.Sprintf("%s:%s", strings.TrimSpace(kycProviderID), strings.TrimSpace(govIDHash))
 	h := sha3.NewLegacyKeccak256()
@@ -145,7 +157,7 @@ func (s *Service) ProcessCSVUpload(ctx context.Context, lenderID string, csvRead
 			return nil, err
 		}
 
-		payload, _ := json.Marshal(map[string]any{"loan_id": created.ID})
+		payload, _ := json.Marshal(map[string]any{"loan_id": created.ID, "lender_id": lenderID})
 		if err := s.outboxRepo.Enqueue(ctx, outboxTopicRegisterLoan, payload); err != nil {
 			return nil, err
 		}
@@ -154,11 +166,64 @@ func (s *Service) ProcessCSVUpload(ctx context.Context, lenderID string, csvRead
 		result.Processed++
 	}
 
+	if s.publisher != nil {
+		// The loans are already stored; a lost notification only delays the
+		// dashboard until its next refresh, so it does not fail the upload.
+		_ = s.publisher.Publish(ctx, realtime.LenderUploadsChannel(lenderID), realtime.EventUploadCompleted, realtime.UploadCompleted{
+			LenderID:  lenderID,
+			Processed: result.Processed,
+			Failed:    len(result.Errors),
+			LoanIDs:   result.LoanIDs,
+		})
+	}
 	return result, nil
 }
 
-func (s *Service) ListLoans(ctx context.Context, filter ListFilter) ([]Entity, error) {
-	return s.loanRepo.List(ctx, filter)
+const maxListLimit = 200
+
+func (s *Service) ListLoans(ctx context.Context, filter ListFilter) (*ListPage, error) {
+	sortBy, sortOrder, err := normalizeSort(filter.SortBy, filter.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	filter.SortBy = sortBy
+	filter.SortOrder = sortOrder
+	filter.After = nil
+	if strings.TrimSpace(filter.Cursor) != "" {
+		after, err := DecodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after.SortBy != sortBy || after.SortOrder != sortOrder {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = after
+		filter.Offset = 0
+	}
+	if filter.CurrencyCode != "" {
+		filter.CurrencyCode = strings.ToUpper(strings.TrimSpace(filter.CurrencyCode))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	// Fetch one extra row to learn whether another page exists.
+	filter.Limit = limit + 1
+	items, err := s.loanRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{Items: items}
+	if int32(len(items)) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = EncodeCursor(cursorFor(page.Items[limit-1], sortBy, sortOrder))
+	}
+	return page, nil
 }
 
 func (s *Service) GetLoan(ctx context.Context, loanID string) (*Entity, error) {
@@ -172,11 +237,17 @@ func (s *Service) RecordRepayment(ctx context.Context, in RepaymentInput) error
 	if err := s.loanRepo.RecordRepayment(ctx, in.LoanID, in.AmountMinor); err != nil {
 		return err
 	}
-	payload, _ := json.Marshal(map[string]any{
+	body := map[string]any{
 		"loan_id":      in.LoanID,
 		"amount_minor": in.AmountMinor,
 		"currency":     strings.ToUpper(strings.TrimSpace(in.Currency)),
-	})
+	}
+	// The lender lets the worker batch this repayment with the lender's other
+	// chain calls; without it the job is sent on its own.
+	if l, err := s.loanRepo.GetByID(ctx, in.LoanID); err == nil {
+		body["lender_id"] = l.LenderID
+	}
+	payload, _ := json.Marshal(body)
 	return s.outboxRepo.Enqueue(ctx, outboxTopicRepayment, payload)
 }
 
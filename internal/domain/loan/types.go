@@ -38,12 +38,40 @@ type CreateInput struct {
 	Metadata        []byte
 }
 
+const (
+	SortCreatedAt   = "created_at"
+	SortMaturity    = "maturity"
+	SortPrincipal   = "principal"
+	SortOutstanding = "outstanding"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
 type ListFilter struct {
-	LenderID  string
-	Status    string
-	RiskGrade string
-	Limit     int32
-	Offset    int32
+	LenderID      string
+	BorrowerID    string
+	Status        string
+	RiskGrade     string
+	CurrencyCode  string
+	LoanReference string
+	MaturityFrom  *time.Time
+	MaturityTo    *time.Time
+	PrincipalMin  int64
+	PrincipalMax  int64
+	SortBy        string
+	SortOrder     string
+	// Cursor is the opaque next_cursor from a previous page; the service
+	// decodes it into After before the repository sees the filter.
+	Cursor string
+	After  *Cursor
+	Limit  int32
+	Offset int32
+}
+
+type ListPage struct {
+	Items      []Entity `json:"items"`
+	NextCursor string   `json:"next_cursor"`
 }
 
 type PortfolioAnalytics struct {
@@ -0,0 +1,137 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	admindomain "github.com/loangraph/backend/internal/domain/admin"
+)
+
+var (
+	// ErrNotFound is returned by Repository.GetByID for an unknown job.
+	ErrNotFound          = errors.New("outbox_job_not_found")
+	ErrInvalidTransition = errors.New("invalid_outbox_transition")
+	ErrInvalidStatus     = errors.New("invalid_outbox_status")
+	ErrMissingTopic      = errors.New("missing_topic")
+)
+
+var knownStatuses = map[string]bool{
+	StatusPending:    true,
+	StatusProcessing: true,
+	StatusDone:       true,
+	StatusFailed:     true,
+	StatusCancelled:  true,
+}
+
+type Service struct {
+	repo      Repository
+	auditRepo admindomain.AuditRepository
+}
+
+func NewService(repo Repository, auditRepo admindomain.AuditRepository) *Service {
+	return &Service{repo: repo, auditRepo: auditRepo}
+}
+
+func (s *Service) ListJobs(ctx context.Context, f ListFilter) ([]Job, error) {
+	f.Status = strings.ToLower(strings.TrimSpace(f.Status))
+	f.Topic = strings.TrimSpace(f.Topic)
+	if f.Status != "" && !knownStatuses[f.Status] {
+		return nil, ErrInvalidStatus
+	}
+	return s.repo.List(ctx, f)
+}
+
+func (s *Service) GetJob(ctx context.Context, id int64) (*Job, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// RetryJob puts a failed or cancelled job back in the queue with a fresh
+// attempt budget.
+func (s *Service) RetryJob(ctx context.Context, adminUserID string, id int64) (*Job, error) {
+	current, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != StatusFailed && current.Status != StatusCancelled {
+		return nil, ErrInvalidTransition
+	}
+	updated, err := s.repo.Retry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, adminUserID, "outbox_job_retried", "outbox_job", strconv.FormatInt(id, 10), map[string]any{
+		"topic":       updated.Topic,
+		"prev_status": current.Status,
+		"attempts":    current.Attempts,
+		"last_error":  current.LastError,
+	})
+	return updated, nil
+}
+
+// CancelJob stops a pending or failed job from being processed. Jobs a worker
+// has already claimed cannot be cancelled.
+func (s *Service) CancelJob(ctx context.Context, adminUserID string, id int64) (*Job, error) {
+	current, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != StatusPending && current.Status != StatusFailed {
+		return nil, ErrInvalidTransition
+	}
+	updated, err := s.repo.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, adminUserID, "outbox_job_cancelled", "outbox_job", strconv.FormatInt(id, 10), map[string]any{
+		"topic":       updated.Topic,
+		"prev_status": current.Status,
+	})
+	return updated, nil
+}
+
+// RequeueTopic resets every failed job of a topic to pending and returns how
+// many were requeued.
+func (s *Service) RequeueTopic(ctx context.Context, adminUserID, topic string) (int64, error) {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return 0, ErrMissingTopic
+	}
+	count, err := s.repo.RequeueFailed(ctx, topic)
+	if err != nil {
+		return 0, err
+	}
+	s.audit(ctx, adminUserID, "outbox_topic_requeued", "outbox_topic", topic, map[string]any{"requeued": count})
+	return count, nil
+}
+
+func (s *Service) Summary(ctx context.Context) (*Summary, error) {
+	counts, err := s.repo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := &Summary{ByStatus: map[string]int64{}, FailedByTopic: map[string]int64{}}
+	for status := range knownStatuses {
+		out.ByStatus[status] = 0
+	}
+	for _, c := range counts {
+		out.ByStatus[c.Status] += c.Count
+		if c.Status == StatusFailed {
+			out.FailedByTopic[c.Topic] += c.Count
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) audit(ctx context.Context, adminUserID, action, targetType, targetID string, fields map[string]any) {
+	payload, _ := json.Marshal(fields)
+	_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+		AdminUserID: adminUserID,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Payload:     payload,
+	})
+}
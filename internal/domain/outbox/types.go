@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+	StatusCancelled  = "cancelled"
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Topic       string          `json:"topic"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	AvailableAt time.Time       `json:"available_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type ListFilter struct {
+	Status string
+	Topic  string
+	Limit  int32
+	Offset int32
+}
+
+type StatusCount struct {
+	Topic  string
+	Status string
+	Count  int64
+}
+
+// Summary is the outbox backlog shown on the admin health endpoint.
+type Summary struct {
+	ByStatus      map[string]int64 `json:"by_status"`
+	FailedByTopic map[string]int64 `json:"failed_by_topic"`
+}
+
+type Repository interface {
+	List(ctx context.Context, f ListFilter) ([]Job, error)
+	GetByID(ctx context.Context, id int64) (*Job, error)
+	// Retry and Cancel return ErrInvalidTransition when the job is no longer
+	// in a status the action applies to.
+	Retry(ctx context.Context, id int64) (*Job, error)
+	Cancel(ctx context.Context, id int64) (*Job, error)
+	RequeueFailed(ctx context.Context, topic string) (int64, error)
+	CountByStatus(ctx context.Context) ([]StatusCount, error)
+}
@@ -0,0 +1,324 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	admindomain "github.com/loangraph/backend/internal/domain/admin"
+	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
+	loandomain "github.com/loangraph/backend/internal/domain/loan"
+)
+
+const maxTargetAPYBPS = 10000
+
+var (
+	// ErrNotFound is returned by Repository.GetByID for an unknown pool.
+	ErrNotFound                = errors.New("pool_not_found")
+	ErrInvalidStatusTransition = errors.New("invalid_status_transition")
+	ErrCurrencyMismatch        = errors.New("currency_mismatch")
+	ErrPoolNotOpen             = errors.New("pool_not_open")
+	ErrInvalidInput            = errors.New("invalid_pool_input")
+	ErrInvalidTargetAPY        = errors.New("invalid_target_apybps")
+	ErrInvalidPoolTokenAddr    = errors.New("invalid_pool_token_addr")
+	ErrInvalidStatus           = errors.New("invalid_pool_status")
+	ErrLenderNotFound          = errors.New("lender_not_found")
+	ErrLoanAlreadyAllocated    = errors.New("loan_already_allocated")
+	ErrAllocationNotFound      = errors.New("allocation_not_found")
+	ErrLoanNotEligible         = errors.New("loan_not_eligible")
+	ErrPoolSettled             = errors.New("pool_settled")
+	ErrForbidden               = errors.New("forbidden")
+)
+
+var poolTokenAddrPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// statusTransitions lists the statuses each pool status may move to. A closed
+// pool can be reopened until it is settled; settled is terminal.
+var statusTransitions = map[string][]string{
+	StatusOpen:    {StatusClosed},
+	StatusClosed:  {StatusOpen, StatusSettled},
+	StatusSettled: {},
+}
+
+type LenderRepository interface {
+	GetByID(ctx context.Context, id string) (*lenderdomain.Entity, error)
+	IsMember(ctx context.Context, lenderID, userID string) (bool, error)
+}
+
+type LoanRepository interface {
+	GetByID(ctx context.Context, id string) (*loandomain.Entity, error)
+	List(ctx context.Context, f loandomain.ListFilter) ([]loandomain.Entity, error)
+}
+
+// UpdateRequest is a partial update; nil fields keep their current value.
+type UpdateRequest struct {
+	Name         *string
+	TargetAPYBPS *int32
+	Status       *string
+}
+
+type Service struct {
+	poolRepo   Repository
+	lenderRepo LenderRepository
+	loanRepo   LoanRepository
+	auditRepo  admindomain.AuditRepository
+}
+
+func NewService(poolRepo Repository, lenderRepo LenderRepository, loanRepo LoanRepository, auditRepo admindomain.AuditRepository) *Service {
+	return &Service{poolRepo: poolRepo, lenderRepo: lenderRepo, loanRepo: loanRepo, auditRepo: auditRepo}
+}
+
+func (s *Service) CreatePool(ctx context.Context, actor Actor, in CreateInput) (*Entity, error) {
+	in.LenderID = strings.TrimSpace(in.LenderID)
+	in.Name = strings.TrimSpace(in.Name)
+	in.PoolTokenAddr = strings.TrimSpace(in.PoolTokenAddr)
+	in.CurrencyCode = strings.ToUpper(strings.TrimSpace(in.CurrencyCode))
+	if in.LenderID == "" {
+		return nil, fmt.Errorf("missing_lender_id")
+	}
+	if in.Name == "" || len(in.CurrencyCode) != 3 {
+		return nil, ErrInvalidInput
+	}
+	if in.PoolTokenAddr != "" && !poolTokenAddrPattern.MatchString(in.PoolTokenAddr) {
+		return nil, ErrInvalidPoolTokenAddr
+	}
+	if err := validateTargetAPY(in.TargetAPYBPS); err != nil {
+		return nil, err
+	}
+	in.Status = StatusOpen
+
+	if err := s.authorize(ctx, actor, in.LenderID); err != nil {
+		return nil, err
+	}
+	if _, err := s.lenderRepo.GetByID(ctx, in.LenderID); err != nil {
+		if errors.Is(err, lenderdomain.ErrNotFound) {
+			return nil, ErrLenderNotFound
+		}
+		return nil, err
+	}
+	// A pool can only be funded by loans in its own currency, so refuse pools
+	// the lender has no loans to back.
+	loans, err := s.loanRepo.List(ctx, loandomain.ListFilter{LenderID: in.LenderID, CurrencyCode: in.CurrencyCode, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(loans) == 0 {
+		return nil, ErrCurrencyMismatch
+	}
+
+	created, err := s.poolRepo.Create(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(map[string]any{"lender_id": created.LenderID, "name": created.Name, "currency_code": created.CurrencyCode, "target_apybps": created.TargetAPYBPS, "pool_token_addr": created.PoolTokenAddr})
+	_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+		AdminUserID: actor.UserID,
+		Action:      "pool_created",
+		TargetType:  "pool",
+		TargetID:    created.ID,
+		Payload:     payload,
+	})
+	return created, nil
+}
+
+func (s *Service) UpdatePool(ctx context.Context, actor Actor, poolID string, req UpdateRequest) (*Entity, error) {
+	current, err := s.getPool(ctx, actor, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := UpdateInput{Name: current.Name, TargetAPYBPS: current.TargetAPYBPS, Status: current.Status}
+	if req.Name != nil {
+		next.Name = strings.TrimSpace(*req.Name)
+		if next.Name == "" {
+			return nil, ErrInvalidInput
+		}
+	}
+	if req.TargetAPYBPS != nil {
+		if err := validateTargetAPY(*req.TargetAPYBPS); err != nil {
+			return nil, err
+		}
+		if current.Status != StatusOpen && *req.TargetAPYBPS != current.TargetAPYBPS {
+			return nil, ErrPoolNotOpen
+		}
+		next.TargetAPYBPS = *req.TargetAPYBPS
+	}
+	if req.Status != nil {
+		next.Status = strings.ToLower(strings.TrimSpace(*req.Status))
+		if err := validateTransition(current.Status, next.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.poolRepo.Update(ctx, current.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	action := "pool_updated"
+	if updated.Status != current.Status {
+		action = "pool_status_updated"
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"name":          updated.Name,
+		"target_apybps": updated.TargetAPYBPS,
+		"status":        updated.Status,
+		"prev_status":   current.Status,
+	})
+	_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+		AdminUserID: actor.UserID,
+		Action:      action,
+		TargetType:  "pool",
+		TargetID:    updated.ID,
+		Payload:     payload,
+	})
+	return updated, nil
+}
+
+func (s *Service) ClosePool(ctx context.Context, actor Actor, poolID string) (*Entity, error) {
+	status := StatusClosed
+	return s.UpdatePool(ctx, actor, poolID, UpdateRequest{Status: &status})
+}
+
+// AllocateLoans attaches each loan to the pool at its principal amount. Loans
+// that cannot be allocated are reported per loan rather than failing the batch.
+func (s *Service) AllocateLoans(ctx context.Context, actor Actor, poolID string, loanIDs []string) (*AllocationResult, error) {
+	p, err := s.getPool(ctx, actor, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != StatusOpen {
+		return nil, ErrPoolNotOpen
+	}
+	if len(loanIDs) == 0 {
+		return nil, ErrInvalidInput
+	}
+
+	result := &AllocationResult{Allocated: []Allocation{}, Errors: []AllocationError{}}
+	for _, loanID := range loanIDs {
+		loanID = strings.TrimSpace(loanID)
+		allocation, err := s.allocateLoan(ctx, p, loanID)
+		if err != nil {
+			if errors.Is(err, ErrLoanAlreadyAllocated) || errors.Is(err, ErrLoanNotEligible) || errors.Is(err, ErrCurrencyMismatch) {
+				result.Errors = append(result.Errors, AllocationError{LoanID: loanID, Error: err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		result.Allocated = append(result.Allocated, *allocation)
+		payload, _ := json.Marshal(map[string]any{"loan_id": allocation.LoanID, "amount_minor": allocation.AmountMinor})
+		_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+			AdminUserID: actor.UserID,
+			Action:      "pool_loan_allocated",
+			TargetType:  "pool",
+			TargetID:    p.ID,
+			Payload:     payload,
+		})
+	}
+	return result, nil
+}
+
+func (s *Service) allocateLoan(ctx context.Context, p *Entity, loanID string) (*Allocation, error) {
+	if loanID == "" {
+		return nil, ErrLoanNotEligible
+	}
+	l, err := s.loanRepo.GetByID(ctx, loanID)
+	if err != nil {
+		if errors.Is(err, loandomain.ErrNotFound) {
+			return nil, ErrLoanNotEligible
+		}
+		return nil, err
+	}
+	if l.LenderID != p.LenderID || l.Status != "active" {
+		return nil, ErrLoanNotEligible
+	}
+	if !strings.EqualFold(strings.TrimSpace(l.CurrencyCode), p.CurrencyCode) {
+		return nil, ErrCurrencyMismatch
+	}
+	return s.poolRepo.AllocateLoan(ctx, p.ID, l.ID, l.PrincipalMinor)
+}
+
+func (s *Service) ReleaseLoan(ctx context.Context, actor Actor, poolID, loanID string) error {
+	p, err := s.getPool(ctx, actor, poolID)
+	if err != nil {
+		return err
+	}
+	if p.Status == StatusSettled {
+		return ErrPoolSettled
+	}
+	allocation, err := s.poolRepo.ReleaseLoan(ctx, p.ID, strings.TrimSpace(loanID))
+	if err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(map[string]any{"loan_id": allocation.LoanID, "amount_minor": allocation.AmountMinor})
+	_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+		AdminUserID: actor.UserID,
+		Action:      "pool_loan_released",
+		TargetType:  "pool",
+		TargetID:    p.ID,
+		Payload:     payload,
+	})
+	return nil
+}
+
+func (s *Service) ListAllocations(ctx context.Context, actor Actor, poolID string, limit, offset int32) ([]Allocation, error) {
+	p, err := s.getPool(ctx, actor, poolID)
+	if err != nil {
+		return nil, err
+	}
+	return s.poolRepo.ListAllocations(ctx, p.ID, limit, offset)
+}
+
+// getPool loads a pool the actor may act on.
+func (s *Service) getPool(ctx context.Context, actor Actor, poolID string) (*Entity, error) {
+	if strings.TrimSpace(poolID) == "" {
+		return nil, fmt.Errorf("missing_pool_id")
+	}
+	item, err := s.poolRepo.GetByID(ctx, strings.TrimSpace(poolID))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, actor, item.LenderID); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// authorize refuses actors who are neither admins nor members of the lender.
+func (s *Service) authorize(ctx context.Context, actor Actor, lenderID string) error {
+	if actor.Admin {
+		return nil
+	}
+	ok, err := s.lenderRepo.IsMember(ctx, lenderID, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func validateTargetAPY(bps int32) error {
+	if bps <= 0 || bps > maxTargetAPYBPS {
+		return ErrInvalidTargetAPY
+	}
+	return nil
+}
+
+func validateTransition(from, to string) error {
+	if from == to {
+		return nil
+	}
+	if _, ok := statusTransitions[to]; !ok {
+		return ErrInvalidStatus
+	}
+	for _, candidate := range statusTransitions[from] {
+		if candidate == to {
+			return nil
+		}
+	}
+	return ErrInvalidStatusTransition
+}
@@ -3,6 +3,8 @@ package pool
 import (
 	"context"
 	"time"
+
+	loandomain "github.com/loangraph/backend/internal/domain/loan"
 )
 
 type Entity struct {
@@ -17,6 +19,13 @@ type Entity struct {
 	CreatedAt     time.Time
 }
 
+// Actor is the user calling a pool operation. Admins act for every lender;
+// other users only for the lenders they are members of.
+type Actor struct {
+	UserID string
+	Admin  bool
+}
+
 type CreateInput struct {
 	LenderID      string
 	Name          string
@@ -31,4 +40,65 @@ type Repository interface {
 	GetByID(ctx context.Context, id string) (*Entity, error)
 	ListByLender(ctx context.Context, lenderID string) ([]Entity, error)
 	List(ctx context.Context, lenderID, currencyCode, status string, limit, offset int32) ([]Entity, error)
+	Update(ctx context.Context, id string, in UpdateInput) (*Entity, error)
+	AllocateLoan(ctx context.Context, poolID, loanID string, amountMinor int64) (*Allocation, error)
+	ReleaseLoan(ctx context.Context, poolID, loanID string) (*Allocation, error)
+	ListAllocations(ctx context.Context, poolID string, limit, offset int32) ([]Allocation, error)
+	GetPerformanceSummary(ctx context.Context, poolID string) (*PerformanceSummary, error)
+	GetRepaymentTimeSeries(ctx context.Context, poolID string, days int32) ([]loandomain.PerformancePoint, error)
+}
+
+const (
+	StatusOpen    = "open"
+	StatusClosed  = "closed"
+	StatusSettled = "settled"
+)
+
+// UpdateInput carries the full mutable state of a pool; the service merges
+// partial requests onto the current entity before calling Repository.Update.
+type UpdateInput struct {
+	Name         string
+	TargetAPYBPS int32
+	Status       string
+}
+
+// Allocation links a loan to the pool funding it. A loan has at most one
+// active (unreleased) allocation at a time.
+type Allocation struct {
+	ID          string
+	PoolID      string
+	LoanID      string
+	AmountMinor int64
+	AllocatedAt time.Time
+	ReleasedAt  *time.Time
+}
+
+type AllocationError struct {
+	LoanID string `json:"loan_id"`
+	Error  string `json:"error"`
+}
+
+type AllocationResult struct {
+	Allocated []Allocation      `json:"allocated"`
+	Errors    []AllocationError `json:"errors"`
+}
+
+// PerformanceSummary aggregates the loans currently allocated to a pool. The
+// repository fills the raw totals; rates and yields are derived by the caller.
+type PerformanceSummary struct {
+	PoolID                 string     `json:"pool_id"`
+	TargetAPYBPS           int32      `json:"target_apybps"`
+	AllocatedLoans         int64      `json:"allocated_loans"`
+	ActiveLoans            int64      `json:"active_loans"`
+	RepaidLoans            int64      `json:"repaid_loans"`
+	DefaultedLoans         int64      `json:"defaulted_loans"`
+	DeployedMinor          int64      `json:"deployed_minor"`
+	RepaidMinor            int64      `json:"repaid_minor"`
+	InterestCollectedMinor int64      `json:"interest_collected_minor"`
+	DefaultLossMinor       int64      `json:"default_loss_minor"`
+	WeightedAvgInterestBPS float64    `json:"weighted_avg_interest_bps"`
+	DefaultRatePercent     float64    `json:"default_rate_percent"`
+	RealizedYieldBPS       float64    `json:"realized_yield_bps"`
+	YieldVsTargetBPS       float64    `json:"yield_vs_target_bps"`
+	FirstAllocatedAt       *time.Time `json:"first_allocated_at"`
 }
@@ -0,0 +1,202 @@
+package position
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+)
+
+const (
+	// OutboxTopicPoolPosition mints or burns pool share tokens for a
+	// transaction.
+	OutboxTopicPoolPosition = "pool_position"
+	maxPricingAttempts      = 3
+)
+
+var (
+	ErrInvalidAmount      = errors.New("invalid_amount")
+	ErrPoolNotFound       = errors.New("pool_not_found")
+	ErrPoolNotOpen        = errors.New("pool_not_open")
+	ErrInsufficientShares = errors.New("insufficient_shares")
+	ErrConcurrentUpdate   = errors.New("concurrent_pool_update")
+)
+
+type PoolRepository interface {
+	GetByID(ctx context.Context, id string) (*pooldomain.Entity, error)
+}
+
+type Service struct {
+	positionRepo Repository
+	poolRepo     PoolRepository
+}
+
+func NewService(positionRepo Repository, poolRepo PoolRepository) *Service {
+	return &Service{positionRepo: positionRepo, poolRepo: poolRepo}
+}
+
+func (s *Service) Deposit(ctx context.Context, investorUserID, poolID string, amountMinor int64) (*Transaction, error) {
+	return s.record(ctx, investorUserID, poolID, KindDeposit, amountMinor)
+}
+
+func (s *Service) Withdraw(ctx context.Context, investorUserID, poolID string, amountMinor int64) (*Transaction, error) {
+	return s.record(ctx, investorUserID, poolID, KindWithdrawal, amountMinor)
+}
+
+func (s *Service) record(ctx context.Context, investorUserID, poolID, kind string, amountMinor int64) (*Transaction, error) {
+	if strings.TrimSpace(investorUserID) == "" {
+		return nil, fmt.Errorf("missing_investor_id")
+	}
+	if amountMinor <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	pool, err := s.poolRepo.GetByID(ctx, strings.TrimSpace(poolID))
+	if err != nil {
+		if errors.Is(err, pooldomain.ErrNotFound) {
+			return nil, ErrPoolNotFound
+		}
+		return nil, err
+	}
+	if kind == KindDeposit && pool.Status != pooldomain.StatusOpen {
+		return nil, ErrPoolNotOpen
+	}
+
+	var tx *Transaction
+	for attempt := 1; ; attempt++ {
+		state, err := s.positionRepo.GetPoolState(ctx, pool.ID)
+		if err != nil {
+			return nil, err
+		}
+		nav := NAVPerShare(*state)
+		shares := SharesForAmount(amountMinor, nav, kind == KindWithdrawal)
+		if shares <= 0 {
+			return nil, ErrInvalidAmount
+		}
+		tx, err = s.positionRepo.RecordTransaction(ctx, TransactionInput{
+			PoolID:              pool.ID,
+			InvestorUserID:      investorUserID,
+			Kind:                kind,
+			AmountMinor:         amountMinor,
+			Shares:              shares,
+			NAVPerShareMicros:   nav,
+			ExpectedTotalShares: state.TotalShares,
+			// Pools without a share token are tracked off-chain only.
+			PoolTokenAddr: strings.TrimSpace(pool.PoolTokenAddr),
+		})
+		if errors.Is(err, ErrConcurrentUpdate) && attempt < maxPricingAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return tx, nil
+}
+
+// PoolPositionPayload is the pool_position job payload mirroring tx on the
+// pool's share token.
+func PoolPositionPayload(tx *Transaction, poolTokenAddr string) []byte {
+	payload, _ := json.Marshal(map[string]any{
+		"transaction_id":   tx.ID,
+		"pool_id":          tx.PoolID,
+		"pool_token_addr":  poolTokenAddr,
+		"investor_user_id": tx.InvestorUserID,
+		"action":           tx.Kind,
+		"amount_minor":     tx.AmountMinor,
+		"shares":           tx.Shares,
+	})
+	return payload
+}
+
+func (s *Service) ListPositions(ctx context.Context, investorUserID string) ([]PositionView, error) {
+	if strings.TrimSpace(investorUserID) == "" {
+		return nil, fmt.Errorf("missing_investor_id")
+	}
+	positions, err := s.positionRepo.ListPositions(ctx, investorUserID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PositionView, 0, len(positions))
+	for _, p := range positions {
+		pool, err := s.poolRepo.GetByID(ctx, p.PoolID)
+		if err != nil {
+			return nil, err
+		}
+		state, err := s.positionRepo.GetPoolState(ctx, p.PoolID)
+		if err != nil {
+			return nil, err
+		}
+		nav := NAVPerShare(*state)
+		value := mulDiv(p.Shares, nav, NAVScale, false)
+		out = append(out, PositionView{
+			PoolID:            p.PoolID,
+			PoolName:          pool.Name,
+			CurrencyCode:      pool.CurrencyCode,
+			Shares:            p.Shares,
+			DepositedMinor:    p.DepositedMinor,
+			WithdrawnMinor:    p.WithdrawnMinor,
+			NAVPerShareMicros: nav,
+			ValueMinor:        value,
+			ReturnMinor:       value + p.WithdrawnMinor - p.DepositedMinor,
+			UpdatedAt:         p.UpdatedAt,
+		})
+	}
+	return out, nil
+}
+
+func (s *Service) ListHistory(ctx context.Context, investorUserID, poolID string, limit, offset int32) ([]Transaction, error) {
+	if strings.TrimSpace(investorUserID) == "" {
+		return nil, fmt.Errorf("missing_investor_id")
+	}
+	if strings.TrimSpace(poolID) == "" {
+		return nil, fmt.Errorf("missing_pool_id")
+	}
+	return s.positionRepo.ListTransactions(ctx, investorUserID, strings.TrimSpace(poolID), limit, offset)
+}
+
+// NAVPerShare values the pool at its net investor capital plus interest
+// collected minus default losses, divided across outstanding shares. An
+// empty pool prices shares at one minor unit each.
+func NAVPerShare(state PoolState) int64 {
+	if state.TotalShares <= 0 {
+		return NAVScale
+	}
+	value := state.DepositedMinor - state.WithdrawnMinor + state.InterestCollectedMinor - state.DefaultLossMinor
+	nav := mulDiv(value, NAVScale, state.TotalShares, false)
+	if nav < 1 {
+		return 1
+	}
+	return nav
+}
+
+// SharesForAmount converts a currency amount to shares at the given NAV.
+// Deposits round down and withdrawals round up so rounding never favours the
+// investor at the expense of the pool.
+func SharesForAmount(amountMinor, navPerShareMicros int64, roundUp bool) int64 {
+	if navPerShareMicros <= 0 {
+		return 0
+	}
+	return mulDiv(amountMinor, NAVScale, navPerShareMicros, roundUp)
+}
+
+func mulDiv(a, b, c int64, roundUp bool) int64 {
+	if c == 0 {
+		return 0
+	}
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(c)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if roundUp && r.Sign() > 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if !q.IsInt64() {
+		return 0
+	}
+	return q.Int64()
+}
@@ -0,0 +1,83 @@
+package position
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	KindDeposit    = "deposit"
+	KindWithdrawal = "withdrawal"
+
+	// NAVScale is the fixed-point scale of NAV values: a NAV of NAVScale means
+	// one share is worth one minor unit of the pool currency.
+	NAVScale int64 = 1_000_000
+)
+
+type Transaction struct {
+	ID                string
+	PoolID            string
+	InvestorUserID    string
+	Kind              string
+	AmountMinor       int64
+	Shares            int64
+	NAVPerShareMicros int64
+	OnChainTX         string
+	CreatedAt         time.Time
+}
+
+type TransactionInput struct {
+	PoolID            string
+	InvestorUserID    string
+	Kind              string
+	AmountMinor       int64
+	Shares            int64
+	NAVPerShareMicros int64
+	// ExpectedTotalShares is the pool share count the NAV was priced from; the
+	// repository rejects the write with ErrConcurrentUpdate if it has moved.
+	ExpectedTotalShares int64
+	// PoolTokenAddr is the pool's share token. When set, the repository
+	// enqueues the pool_position job mirroring the transaction on chain in
+	// the same database transaction.
+	PoolTokenAddr string
+}
+
+type Position struct {
+	PoolID         string
+	InvestorUserID string
+	Shares         int64
+	DepositedMinor int64
+	WithdrawnMinor int64
+	UpdatedAt      time.Time
+}
+
+// PoolState holds the pool totals NAV is derived from. Interest and losses
+// come from the loans currently allocated to the pool.
+type PoolState struct {
+	TotalShares            int64
+	DepositedMinor         int64
+	WithdrawnMinor         int64
+	InterestCollectedMinor int64
+	DefaultLossMinor       int64
+}
+
+type PositionView struct {
+	PoolID            string    `json:"pool_id"`
+	PoolName          string    `json:"pool_name"`
+	CurrencyCode      string    `json:"currency_code"`
+	Shares            int64     `json:"shares"`
+	DepositedMinor    int64     `json:"deposited_minor"`
+	WithdrawnMinor    int64     `json:"withdrawn_minor"`
+	NAVPerShareMicros int64     `json:"nav_per_share_micros"`
+	ValueMinor        int64     `json:"value_minor"`
+	ReturnMinor       int64     `json:"return_minor"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type Repository interface {
+	RecordTransaction(ctx context.Context, in TransactionInput) (*Transaction, error)
+	GetPoolState(ctx context.Context, poolID string) (*PoolState, error)
+	ListPositions(ctx context.Context, investorUserID string) ([]Position, error)
+	ListTransactions(ctx context.Context, investorUserID, poolID string, limit, offset int32) ([]Transaction, error)
+	SetTransactionSubmission(ctx context.Context, transactionID, txHash string) error
+}
@@ -2,31 +2,56 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
 )
 
 type AdminService interface {
 	OnboardLender(ctx context.Context, adminUserID string, in lenderdomain.CreateInput) (*lenderdomain.Entity, error)
 	UpdateLenderStatus(ctx context.Context, adminUserID, lenderID, status string) error
+	AddLenderMember(ctx context.Context, adminUserID, lenderID, userID string) error
+	RemoveLenderMember(ctx context.Context, adminUserID, lenderID, userID string) error
+}
+
+type OutboxService interface {
+	ListJobs(ctx context.Context, f outboxdomain.ListFilter) ([]outboxdomain.Job, error)
+	GetJob(ctx context.Context, id int64) (*outboxdomain.Job, error)
+	RetryJob(ctx context.Context, adminUserID string, id int64) (*outboxdomain.Job, error)
+	CancelJob(ctx context.Context, adminUserID string, id int64) (*outboxdomain.Job, error)
+	RequeueTopic(ctx context.Context, adminUserID, topic string) (int64, error)
+	Summary(ctx context.Context) (*outboxdomain.Summary, error)
 }
 
 type AdminHandler struct {
-	adminService AdminService
+	adminService  AdminService
+	outboxService OutboxService
 }
 
 var evmAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
 
-func NewAdminHandler(adminService AdminService) *AdminHandler {
-	return &AdminHandler{adminService: adminService}
+func NewAdminHandler(adminService AdminService, outboxService OutboxService) *AdminHandler {
+	return &AdminHandler{adminService: adminService, outboxService: outboxService}
 }
 
 func (h *AdminHandler) SystemHealth(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	resp := gin.H{"status": "ok"}
+	if h.outboxService != nil {
+		summary, err := h.outboxService.Summary(c.Request.Context())
+		if err != nil {
+			resp["status"] = "degraded"
+			resp["outbox_error"] = "outbox_summary_failed"
+		} else {
+			resp["outbox"] = summary
+		}
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *AdminHandler) OnboardLender(c *gin.Context) {
@@ -89,6 +114,141 @@ func (h *AdminHandler) UpdateLenderStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+func (h *AdminHandler) AddLenderMember(c *gin.Context) {
+	if h.adminService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "admin_service_unavailable"})
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	if err := h.adminService.AddLenderMember(c.Request.Context(), toString(adminUserID), c.Param("lenderId"), c.Param("userId")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "add_lender_member_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *AdminHandler) RemoveLenderMember(c *gin.Context) {
+	if h.adminService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "admin_service_unavailable"})
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	if err := h.adminService.RemoveLenderMember(c.Request.Context(), toString(adminUserID), c.Param("lenderId"), c.Param("userId")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "remove_lender_member_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *AdminHandler) ListOutboxJobs(c *gin.Context) {
+	if h.outboxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "outbox_service_unavailable"})
+		return
+	}
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	items, err := h.outboxService.ListJobs(c.Request.Context(), outboxdomain.ListFilter{
+		Status: c.Query("status"),
+		Topic:  c.Query("topic"),
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		writeOutboxError(c, err, "list_outbox_jobs_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *AdminHandler) GetOutboxJob(c *gin.Context) {
+	if h.outboxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "outbox_service_unavailable"})
+		return
+	}
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+	job, err := h.outboxService.GetJob(c.Request.Context(), jobID)
+	if err != nil {
+		writeOutboxError(c, err, "get_outbox_job_failed")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *AdminHandler) RetryOutboxJob(c *gin.Context) {
+	if h.outboxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "outbox_service_unavailable"})
+		return
+	}
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	job, err := h.outboxService.RetryJob(c.Request.Context(), toString(adminUserID), jobID)
+	if err != nil {
+		writeOutboxError(c, err, "retry_outbox_job_failed")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *AdminHandler) CancelOutboxJob(c *gin.Context) {
+	if h.outboxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "outbox_service_unavailable"})
+		return
+	}
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	job, err := h.outboxService.CancelJob(c.Request.Context(), toString(adminUserID), jobID)
+	if err != nil {
+		writeOutboxError(c, err, "cancel_outbox_job_failed")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *AdminHandler) RequeueOutboxTopic(c *gin.Context) {
+	if h.outboxService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "outbox_service_unavailable"})
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	count, err := h.outboxService.RequeueTopic(c.Request.Context(), toString(adminUserID), c.Param("topic"))
+	if err != nil {
+		writeOutboxError(c, err, "requeue_outbox_topic_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": count})
+}
+
+func parseJobID(c *gin.Context) (int64, bool) {
+	jobID, err := strconv.ParseInt(strings.TrimSpace(c.Param("jobId")), 10, 64)
+	if err != nil || jobID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_job_id"})
+		return 0, false
+	}
+	return jobID, true
+}
+
+func writeOutboxError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, outboxdomain.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, outboxdomain.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, outboxdomain.ErrInvalidStatus), errors.Is(err, outboxdomain.ErrMissingTopic):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
 func toString(v any) string {
 	if s, ok := v.(string); ok {
 		return s
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loangraph/backend/internal/indexer"
+)
+
+type ChainEventService interface {
+	ListQuarantined(ctx context.Context, limit, offset int32) ([]indexer.QuarantinedEvent, error)
+	RetryEvent(ctx context.Context, adminUserID string, id int64, rawData json.RawMessage) (*indexer.QuarantinedEvent, error)
+	SkipEvent(ctx context.Context, adminUserID string, id int64) (*indexer.QuarantinedEvent, error)
+}
+
+type ReconciliationService interface {
+	ListDiscrepancies(ctx context.Context, runID int64, limit, offset int32) (*indexer.ReconciliationRun, []indexer.Discrepancy, error)
+}
+
+type ChainHandler struct {
+	eventService          ChainEventService
+	reconciliationService ReconciliationService
+}
+
+func NewChainHandler(eventService ChainEventService, reconciliationService ReconciliationService) *ChainHandler {
+	return &ChainHandler{eventService: eventService, reconciliationService: reconciliationService}
+}
+
+func (h *ChainHandler) ListQuarantinedEvents(c *gin.Context) {
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	items, err := h.eventService.ListQuarantined(c.Request.Context(), int32(limit), int32(offset))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list_quarantined_events_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// RetryEvent requeues a quarantined event, optionally replacing its payload
+// with a corrected raw_data object.
+func (h *ChainHandler) RetryEvent(c *gin.Context) {
+	eventID, ok := parseEventID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		RawData json.RawMessage `json:"raw_data"`
+	}
+	// The body is optional.
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	ev, err := h.eventService.RetryEvent(c.Request.Context(), toString(adminUserID), eventID, req.RawData)
+	if err != nil {
+		writeChainEventError(c, err, "retry_chain_event_failed")
+		return
+	}
+	c.JSON(http.StatusOK, ev)
+}
+
+func (h *ChainHandler) SkipEvent(c *gin.Context) {
+	eventID, ok := parseEventID(c)
+	if !ok {
+		return
+	}
+	adminUserID, _ := c.Get("user_id")
+	ev, err := h.eventService.SkipEvent(c.Request.Context(), toString(adminUserID), eventID)
+	if err != nil {
+		writeChainEventError(c, err, "skip_chain_event_failed")
+		return
+	}
+	c.JSON(http.StatusOK, ev)
+}
+
+// ListDiscrepancies returns a reconciliation run, the latest unless run_id is
+// given, with a page of its discrepancies.
+func (h *ChainHandler) ListDiscrepancies(c *gin.Context) {
+	if h.reconciliationService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "reconciliation_service_unavailable"})
+		return
+	}
+	var runID int64
+	if raw := strings.TrimSpace(c.Query("run_id")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_run_id"})
+			return
+		}
+		runID = id
+	}
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	run, items, err := h.reconciliationService.ListDiscrepancies(c.Request.Context(), runID, int32(limit), int32(offset))
+	if err != nil {
+		if errors.Is(err, indexer.ErrReconciliationRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list_discrepancies_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run, "items": items})
+}
+
+func parseEventID(c *gin.Context) (int64, bool) {
+	eventID, err := strconv.ParseInt(strings.TrimSpace(c.Param("eventId")), 10, 64)
+	if err != nil || eventID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_event_id"})
+		return 0, false
+	}
+	return eventID, true
+}
+
+func writeChainEventError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, indexer.ErrEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, indexer.ErrEventNotQuarantined):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, indexer.ErrInvalidEventPayload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -8,14 +8,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	investordomain "github.com/loangraph/backend/internal/domain/investor"
-	loandomain "github.com/loangraph/backend/internal/domain/loan"
 	pooldomain "github.com/loangraph/backend/internal/domain/pool"
 )
 
 type InvestorService interface {
 	ListPools(ctx context.Context, f investordomain.PoolFilter) ([]pooldomain.Entity, error)
 	GetPool(ctx context.Context, poolID string) (*pooldomain.Entity, error)
-	GetPoolPerformance(ctx context.Context, poolID string, days int32) ([]loandomain.PerformancePoint, error)
+	GetPoolPerformance(ctx context.Context, poolID string, days int32) (*investordomain.PoolPerformance, error)
 	GetLenderProfile(ctx context.Context, lenderID string) (*investordomain.LenderProfile, error)
 }
 
@@ -57,12 +56,12 @@ func (h *InvestorHandler) GetPool(c *gin.Context) {
 func (h *InvestorHandler) GetPoolPerformance(c *gin.Context) {
 	poolID := strings.TrimSpace(c.Param("poolId"))
 	days64, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("days", "30")), 10, 32)
-	points, err := h.investorService.GetPoolPerformance(c.Request.Context(), poolID, int32(days64))
+	perf, err := h.investorService.GetPoolPerformance(c.Request.Context(), poolID, int32(days64))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "pool_performance_failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": points})
+	c.JSON(http.StatusOK, perf)
 }
 
 func (h *InvestorHandler) GetLenderProfile(c *gin.Context) {
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
+)
+
+type LedgerService interface {
+	GetAccountStatement(ctx context.Context, kind, ownerID, currency string, limit, offset int32) (*ledgerdomain.AccountStatement, error)
+	GetLenderBalances(ctx context.Context, lenderID string) ([]ledgerdomain.LenderBalances, error)
+	GetPoolBalances(ctx context.Context, poolID string) ([]ledgerdomain.PoolBalances, error)
+}
+
+type LedgerHandler struct {
+	ledgerService LedgerService
+}
+
+func NewLedgerHandler(ledgerService LedgerService) *LedgerHandler {
+	return &LedgerHandler{ledgerService: ledgerService}
+}
+
+func (h *LedgerHandler) GetAccount(c *gin.Context) {
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	statement, err := h.ledgerService.GetAccountStatement(c.Request.Context(), c.Param("kind"), c.Param("ownerId"), c.Query("currency"), int32(limit), int32(offset))
+	if err != nil {
+		if errors.Is(err, ledgerdomain.ErrInvalidAccount) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ledger_account_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
+
+func (h *LedgerHandler) GetLenderBalances(c *gin.Context) {
+	items, err := h.ledgerService.GetLenderBalances(c.Request.Context(), c.Param("lenderId"))
+	if err != nil {
+		if errors.Is(err, ledgerdomain.ErrMissingLenderID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ledger_balances_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *LedgerHandler) GetPoolBalances(c *gin.Context) {
+	items, err := h.ledgerService.GetPoolBalances(c.Request.Context(), c.Param("poolId"))
+	if err != nil {
+		if errors.Is(err, ledgerdomain.ErrMissingPoolID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ledger_balances_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
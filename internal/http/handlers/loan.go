@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
@@ -15,7 +17,7 @@ const maxUploadSizeBytes = 50 << 20
 
 type LoanService interface {
 	ProcessCSVUpload(ctx context.Context, lenderID string, csvReader io.Reader) (*loandomain.UploadResult, error)
-	ListLoans(ctx context.Context, filter loandomain.ListFilter) ([]loandomain.Entity, error)
+	ListLoans(ctx context.Context, filter loandomain.ListFilter) (*loandomain.ListPage, error)
 	GetLoan(ctx context.Context, loanID string) (*loandomain.Entity, error)
 	RecordRepayment(ctx context.Context, in loandomain.RepaymentInput) error
 	MarkDefault(ctx context.Context, in loandomain.DefaultInput) error
@@ -71,18 +73,71 @@ func (h *LoanHandler) UploadLoanBook(c *gin.Context) {
 func (h *LoanHandler) ListLoans(c *gin.Context) {
 	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
 	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
-	items, err := h.loanService.ListLoans(c.Request.Context(), loandomain.ListFilter{
-		LenderID:  strings.TrimSpace(c.Query("lender_id")),
-		Status:    strings.TrimSpace(c.Query("status")),
-		RiskGrade: strings.TrimSpace(c.Query("risk_grade")),
-		Limit:     int32(limit),
-		Offset:    int32(offset),
-	})
+	filter := loandomain.ListFilter{
+		LenderID:      strings.TrimSpace(c.Query("lender_id")),
+		BorrowerID:    strings.TrimSpace(c.Query("borrower_id")),
+		Status:        strings.TrimSpace(c.Query("status")),
+		RiskGrade:     strings.TrimSpace(c.Query("risk_grade")),
+		CurrencyCode:  strings.TrimSpace(c.Query("currency")),
+		LoanReference: strings.TrimSpace(c.Query("loan_reference")),
+		SortBy:        strings.TrimSpace(c.Query("sort")),
+		SortOrder:     strings.TrimSpace(c.Query("order")),
+		Cursor:        strings.TrimSpace(c.Query("cursor")),
+		Limit:         int32(limit),
+		Offset:        int32(offset),
+	}
+	var ok bool
+	if filter.MaturityFrom, ok = parseTimeQuery(c, "maturity_from"); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_maturity_from"})
+		return
+	}
+	if filter.MaturityTo, ok = parseTimeQuery(c, "maturity_to"); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_maturity_to"})
+		return
+	}
+	if filter.PrincipalMin, ok = parseInt64Query(c, "principal_min"); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_principal_min"})
+		return
+	}
+	if filter.PrincipalMax, ok = parseInt64Query(c, "principal_max"); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_principal_max"})
+		return
+	}
+
+	page, err := h.loanService.ListLoans(c.Request.Context(), filter)
 	if err != nil {
+		if errors.Is(err, loandomain.ErrInvalidCursor) || errors.Is(err, loandomain.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "list_loans_failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	c.JSON(http.StatusOK, gin.H{"items": page.Items, "next_cursor": page.NextCursor})
+}
+
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, bool) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+func parseInt64Query(c *gin.Context, key string) (int64, bool) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
 }
 
 func (h *LoanHandler) GetLoan(c *gin.Context) {
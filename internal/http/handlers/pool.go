@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loangraph/backend/internal/auth"
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+)
+
+type PoolService interface {
+	CreatePool(ctx context.Context, actor pooldomain.Actor, in pooldomain.CreateInput) (*pooldomain.Entity, error)
+	UpdatePool(ctx context.Context, actor pooldomain.Actor, poolID string, req pooldomain.UpdateRequest) (*pooldomain.Entity, error)
+	ClosePool(ctx context.Context, actor pooldomain.Actor, poolID string) (*pooldomain.Entity, error)
+	AllocateLoans(ctx context.Context, actor pooldomain.Actor, poolID string, loanIDs []string) (*pooldomain.AllocationResult, error)
+	ReleaseLoan(ctx context.Context, actor pooldomain.Actor, poolID, loanID string) error
+	ListAllocations(ctx context.Context, actor pooldomain.Actor, poolID string, limit, offset int32) ([]pooldomain.Allocation, error)
+}
+
+type PoolHandler struct {
+	poolService PoolService
+}
+
+func NewPoolHandler(poolService PoolService) *PoolHandler {
+	return &PoolHandler{poolService: poolService}
+}
+
+func (h *PoolHandler) CreatePool(c *gin.Context) {
+	var req struct {
+		LenderID      string `json:"lender_id"`
+		Name          string `json:"name"`
+		PoolTokenAddr string `json:"pool_token_addr"`
+		TargetAPYBPS  int32  `json:"target_apybps"`
+		CurrencyCode  string `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	if strings.TrimSpace(req.LenderID) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing_lender_id"})
+		return
+	}
+	created, err := h.poolService.CreatePool(c.Request.Context(), poolActor(c), pooldomain.CreateInput{
+		LenderID:      req.LenderID,
+		Name:          req.Name,
+		PoolTokenAddr: req.PoolTokenAddr,
+		TargetAPYBPS:  req.TargetAPYBPS,
+		CurrencyCode:  req.CurrencyCode,
+	})
+	if err != nil {
+		writePoolError(c, err, "create_pool_failed")
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *PoolHandler) UpdatePool(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	var req struct {
+		Name         *string `json:"name"`
+		TargetAPYBPS *int32  `json:"target_apybps"`
+		Status       *string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	if req.Name == nil && req.TargetAPYBPS == nil && req.Status == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	updated, err := h.poolService.UpdatePool(c.Request.Context(), poolActor(c), poolID, pooldomain.UpdateRequest{
+		Name:         req.Name,
+		TargetAPYBPS: req.TargetAPYBPS,
+		Status:       req.Status,
+	})
+	if err != nil {
+		writePoolError(c, err, "update_pool_failed")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *PoolHandler) ClosePool(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	updated, err := h.poolService.ClosePool(c.Request.Context(), poolActor(c), poolID)
+	if err != nil {
+		writePoolError(c, err, "close_pool_failed")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *PoolHandler) AllocateLoans(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	var req struct {
+		LoanIDs []string `json:"loan_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.LoanIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	result, err := h.poolService.AllocateLoans(c.Request.Context(), poolActor(c), poolID, req.LoanIDs)
+	if err != nil {
+		writePoolError(c, err, "allocate_loans_failed")
+		return
+	}
+	if len(result.Allocated) == 0 && len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *PoolHandler) ReleaseLoan(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	loanID := strings.TrimSpace(c.Param("loanId"))
+	if err := h.poolService.ReleaseLoan(c.Request.Context(), poolActor(c), poolID, loanID); err != nil {
+		writePoolError(c, err, "release_loan_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *PoolHandler) ListAllocations(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	items, err := h.poolService.ListAllocations(c.Request.Context(), poolActor(c), poolID, int32(limit), int32(offset))
+	if err != nil {
+		writePoolError(c, err, "list_allocations_failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// poolActor is the authenticated caller; pool routes only admit lenders and
+// admins.
+func poolActor(c *gin.Context) pooldomain.Actor {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("user_role")
+	return pooldomain.Actor{UserID: toString(userID), Admin: toString(role) == auth.RoleAdmin}
+}
+
+func writePoolError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, pooldomain.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, pooldomain.ErrNotFound), errors.Is(err, pooldomain.ErrAllocationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, pooldomain.ErrInvalidStatusTransition), errors.Is(err, pooldomain.ErrPoolNotOpen), errors.Is(err, pooldomain.ErrPoolSettled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, pooldomain.ErrInvalidInput),
+		errors.Is(err, pooldomain.ErrInvalidTargetAPY),
+		errors.Is(err, pooldomain.ErrInvalidPoolTokenAddr),
+		errors.Is(err, pooldomain.ErrInvalidStatus),
+		errors.Is(err, pooldomain.ErrCurrencyMismatch),
+		errors.Is(err, pooldomain.ErrLenderNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
+)
+
+type PositionService interface {
+	Deposit(ctx context.Context, investorUserID, poolID string, amountMinor int64) (*positiondomain.Transaction, error)
+	Withdraw(ctx context.Context, investorUserID, poolID string, amountMinor int64) (*positiondomain.Transaction, error)
+	ListPositions(ctx context.Context, investorUserID string) ([]positiondomain.PositionView, error)
+	ListHistory(ctx context.Context, investorUserID, poolID string, limit, offset int32) ([]positiondomain.Transaction, error)
+}
+
+type PositionHandler struct {
+	positionService PositionService
+}
+
+func NewPositionHandler(positionService PositionService) *PositionHandler {
+	return &PositionHandler{positionService: positionService}
+}
+
+func (h *PositionHandler) Deposit(c *gin.Context) {
+	h.recordTransaction(c, h.positionService.Deposit, "deposit_failed")
+}
+
+func (h *PositionHandler) Withdraw(c *gin.Context) {
+	h.recordTransaction(c, h.positionService.Withdraw, "withdrawal_failed")
+}
+
+func (h *PositionHandler) recordTransaction(c *gin.Context, fn func(ctx context.Context, investorUserID, poolID string, amountMinor int64) (*positiondomain.Transaction, error), fallback string) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	var req struct {
+		AmountMinor int64 `json:"amount_minor"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.AmountMinor <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	tx, err := fn(c.Request.Context(), toString(userID), poolID, req.AmountMinor)
+	if err != nil {
+		switch {
+		case errors.Is(err, positiondomain.ErrPoolNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, positiondomain.ErrPoolNotOpen), errors.Is(err, positiondomain.ErrConcurrentUpdate):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, positiondomain.ErrInvalidAmount), errors.Is(err, positiondomain.ErrInsufficientShares):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, tx)
+}
+
+func (h *PositionHandler) ListPositions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	items, err := h.positionService.ListPositions(c.Request.Context(), toString(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "list_positions_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *PositionHandler) ListHistory(c *gin.Context) {
+	poolID := strings.TrimSpace(c.Param("poolId"))
+	limit, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("limit", "50")), 10, 32)
+	offset, _ := strconv.ParseInt(strings.TrimSpace(c.DefaultQuery("offset", "0")), 10, 32)
+	userID, _ := c.Get("user_id")
+	items, err := h.positionService.ListHistory(c.Request.Context(), toString(userID), poolID, int32(limit), int32(offset))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position_history_failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
@@ -0,0 +1,370 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+// ABIParam is an event input as it appears in contract ABI JSON.
+type ABIParam struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed"`
+}
+
+// ABIEvent decodes logs of one event into the raw_data stored on
+// chain_events. Values are keyed by input name. bytes32 values are stored as
+// hex under "<name>_bytes32" and, since the registry packs UUIDs into them,
+// under "<name>" as the UUID when they hold one.
+type ABIEvent struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Anonymous bool       `json:"anonymous"`
+	Inputs    []ABIParam `json:"inputs"`
+}
+
+// ParseABIEvents returns the non-anonymous events in an ABI JSON document;
+// functions, errors and anonymous events, which have no topic to match, are
+// skipped.
+func ParseABIEvents(raw []byte) ([]ABIEvent, error) {
+	var entries []ABIEvent
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("invalid abi json: %w", err)
+	}
+	out := make([]ABIEvent, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type != "event" || entry.Anonymous {
+			continue
+		}
+		if strings.TrimSpace(entry.Name) == "" {
+			return nil, fmt.Errorf("abi event without name")
+		}
+		for i, in := range entry.Inputs {
+			if strings.TrimSpace(in.Name) == "" {
+				return nil, fmt.Errorf("abi event %s input %d has no name", entry.Name, i)
+			}
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// Signature is the canonical event signature, e.g. "Transfer(address,address,uint256)".
+func (e ABIEvent) Signature() string {
+	types := make([]string, 0, len(e.Inputs))
+	for _, in := range e.Inputs {
+		types = append(types, in.Type)
+	}
+	return e.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Topic is the event's first log topic.
+func (e ABIEvent) Topic() string {
+	return eventTopic(e.Signature())
+}
+
+// Decode decodes a log of this event. Integers are *big.Int, so they are
+// stored as exact JSON numbers; consumers needing int64 get an error rather
+// than a silently wrong value when one does not fit. Indexed dynamic values
+// are only available as their keccak hash, stored under "<name>_hash".
+func (e ABIEvent) Decode(log blockchain.LogEntry) (map[string]any, error) {
+	var dataInputs []ABIParam
+	indexed := 0
+	for _, in := range e.Inputs {
+		if in.Indexed {
+			indexed++
+		} else {
+			dataInputs = append(dataInputs, in)
+		}
+	}
+	if len(log.Topics) < indexed+1 {
+		return nil, fmt.Errorf("%s missing indexed topics", e.Name)
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(log.Data)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("%s data is not hex: %w", e.Name, err)
+	}
+
+	out := make(map[string]any, len(e.Inputs)+2)
+	topic := 1
+	for _, in := range e.Inputs {
+		if !in.Indexed {
+			continue
+		}
+		word, err := hex.DecodeString(strings.TrimPrefix(normalizeBytes32Hex(log.Topics[topic]), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: invalid topic: %w", e.Name, in.Name, err)
+		}
+		topic++
+		typ, err := parseABIType(in.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+		}
+		if typ.dynamic() || typ.isArray() {
+			out[in.Name+"_hash"] = "0x" + hex.EncodeToString(word)
+			continue
+		}
+		value, err := decodeABIValue(typ, word, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+		}
+		setDecoded(out, in.Name, typ, value)
+	}
+
+	head := 0
+	for _, in := range dataInputs {
+		typ, err := parseABIType(in.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+		}
+		var value any
+		if typ.dynamic() {
+			offset, err := readOffset(data, head)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+			}
+			value, err = decodeABIValue(typ, data, offset)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+			}
+		} else {
+			value, err = decodeABIValue(typ, data, head)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+			}
+		}
+		setDecoded(out, in.Name, typ, value)
+		head += typ.headSize()
+	}
+	return out, nil
+}
+
+// setDecoded stores a decoded value. A bytes32 holding a packed UUID is also
+// exposed as the UUID.
+func setDecoded(out map[string]any, name string, typ abiType, value any) {
+	if typ.base == "bytes" && typ.size == 32 && !typ.isArray() {
+		hexValue := value.(string)
+		out[name+"_bytes32"] = hexValue
+		out[name] = projectedLoanID(hexValue)
+		return
+	}
+	out[name] = value
+}
+
+// abiType is a parsed ABI type such as uint256, bytes, string or address[2][].
+type abiType struct {
+	base string
+	// size is the bit size of intN/uintN, the byte size of bytesN, and 0 for
+	// dynamic bytes, string, address and bool.
+	size int
+	// dims are array dimensions innermost first; -1 marks a dynamic array.
+	dims []int
+}
+
+func parseABIType(raw string) (abiType, error) {
+	raw = strings.TrimSpace(raw)
+	// Dimensions are written outermost last: uint256[2][] is a dynamic array
+	// of uint256[2].
+	var dims []int
+	for strings.HasSuffix(raw, "]") {
+		open := strings.LastIndex(raw, "[")
+		if open < 0 {
+			return abiType{}, fmt.Errorf("invalid abi type %s", raw)
+		}
+		inner := raw[open+1 : len(raw)-1]
+		n := -1
+		if inner != "" {
+			v, err := strconv.Atoi(inner)
+			if err != nil || v <= 0 {
+				return abiType{}, fmt.Errorf("invalid array size in %s", raw)
+			}
+			n = v
+		}
+		dims = append([]int{n}, dims...)
+		raw = raw[:open]
+	}
+
+	t := abiType{base: raw, dims: dims}
+	switch {
+	case raw == "address", raw == "bool", raw == "string", raw == "bytes":
+	case strings.HasPrefix(raw, "uint"), strings.HasPrefix(raw, "int"):
+		bits := strings.TrimPrefix(strings.TrimPrefix(raw, "u"), "int")
+		t.base = strings.TrimSuffix(raw, bits)
+		t.size = 256
+		if bits != "" {
+			v, err := strconv.Atoi(bits)
+			if err != nil || v < 8 || v > 256 || v%8 != 0 {
+				return abiType{}, fmt.Errorf("invalid integer type %s", raw)
+			}
+			t.size = v
+		}
+	case strings.HasPrefix(raw, "bytes"):
+		v, err := strconv.Atoi(strings.TrimPrefix(raw, "bytes"))
+		if err != nil || v < 1 || v > 32 {
+			return abiType{}, fmt.Errorf("invalid bytes type %s", raw)
+		}
+		t.base, t.size = "bytes", v
+	default:
+		return abiType{}, fmt.Errorf("unsupported abi type %s", raw)
+	}
+	return t, nil
+}
+
+func (t abiType) isArray() bool {
+	return len(t.dims) > 0
+}
+
+// elem is the type of the outermost array's elements.
+func (t abiType) elem() abiType {
+	return abiType{base: t.base, size: t.size, dims: t.dims[:len(t.dims)-1]}
+}
+
+func (t abiType) dynamic() bool {
+	if t.isArray() {
+		return t.dims[len(t.dims)-1] < 0 || t.elem().dynamic()
+	}
+	return t.base == "string" || (t.base == "bytes" && t.size == 0)
+}
+
+// headSize is how many bytes the type takes in the head of its enclosing
+// tuple: one offset word if dynamic, its full encoding otherwise.
+func (t abiType) headSize() int {
+	if t.dynamic() {
+		return 32
+	}
+	if t.isArray() {
+		return t.dims[len(t.dims)-1] * t.elem().headSize()
+	}
+	return 32
+}
+
+// decodeABIValue decodes a value of type t encoded at pos in data. For
+// dynamic types pos is where the tail encoding starts.
+func decodeABIValue(t abiType, data []byte, pos int) (any, error) {
+	if t.isArray() {
+		elem := t.elem()
+		n := t.dims[len(t.dims)-1]
+		start := pos
+		if n < 0 {
+			length, err := readLength(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			n, start = length, pos+32
+		}
+		// Elements are encoded as a tuple starting at start.
+		out := make([]any, 0, n)
+		head := start
+		for i := 0; i < n; i++ {
+			at := head
+			if elem.dynamic() {
+				offset, err := readOffset(data, head)
+				if err != nil {
+					return nil, err
+				}
+				at = start + offset
+			}
+			v, err := decodeABIValue(elem, data, at)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out = append(out, v)
+			head += elem.headSize()
+		}
+		return out, nil
+	}
+
+	switch {
+	case t.base == "string" || (t.base == "bytes" && t.size == 0):
+		length, err := readLength(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		if pos+32+length > len(data) {
+			return nil, fmt.Errorf("%s length %d exceeds data", t.base, length)
+		}
+		raw := data[pos+32 : pos+32+length]
+		if t.base == "string" {
+			if !utf8.Valid(raw) {
+				return nil, fmt.Errorf("string is not valid utf-8")
+			}
+			return string(raw), nil
+		}
+		return "0x" + hex.EncodeToString(raw), nil
+	}
+
+	word, err := readWord(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	switch t.base {
+	case "address":
+		if !allZero(word[:12]) {
+			return nil, fmt.Errorf("address has dirty high bytes")
+		}
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case "bool":
+		if !allZero(word[:31]) || word[31] > 1 {
+			return nil, fmt.Errorf("invalid bool")
+		}
+		return word[31] == 1, nil
+	case "bytes":
+		if !allZero(word[t.size:]) {
+			return nil, fmt.Errorf("bytes%d has dirty padding", t.size)
+		}
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	case "uint":
+		n := new(big.Int).SetBytes(word)
+		if n.BitLen() > t.size {
+			return nil, fmt.Errorf("value overflows uint%d", t.size)
+		}
+		return n, nil
+	case "int":
+		n := new(big.Int).SetBytes(word)
+		if n.Bit(255) == 1 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("value overflows int%d", t.size)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unsupported abi type %s", t.base)
+}
+
+func readWord(data []byte, pos int) ([]byte, error) {
+	if pos < 0 || pos+32 > len(data) {
+		return nil, fmt.Errorf("data too short at offset %d", pos)
+	}
+	return data[pos : pos+32], nil
+}
+
+// readOffset and readLength read a word that must fit in an int and point
+// inside data.
+func readOffset(data []byte, pos int) (int, error) {
+	return readSize(data, pos, "offset")
+}
+
+func readLength(data []byte, pos int) (int, error) {
+	return readSize(data, pos, "length")
+}
+
+func readSize(data []byte, pos int, what string) (int, error) {
+	word, err := readWord(data, pos)
+	if err != nil {
+		return 0, err
+	}
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("%s %s out of range", what, n)
+	}
+	return int(n.Int64()), nil
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
 	"strings"
 
 	"github.com/google/uuid"
@@ -13,10 +13,6 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
-const (
-	ingestionCursorKey = "indexer.loan_registry.last_block"
-)
-
 type IngestedEvent struct {
 	ContractAddr string
 	EventName    string
@@ -24,6 +20,10 @@ type IngestedEvent struct {
 	BlockNumber  uint64
 	LogIndex     uint64
 	RawData      json.RawMessage
+	// DecodeError is set for a log the source's ABI could not decode. RawData
+	// then holds the log's topics and data, and the event is stored
+	// quarantined for an admin to fix or skip.
+	DecodeError string
 }
 
 type IngestionRepository interface {
@@ -32,208 +32,235 @@ type IngestionRepository interface {
 	InsertChainEvent(ctx context.Context, ev IngestedEvent) error
 }
 
+// IngestionService copies the logs of each configured source into
+// chain_events, advancing every source's cursor independently so a source
+// added later backfills from its own start block.
 type IngestionService struct {
 	repo          IngestionRepository
 	rpc           blockchain.LogRPCClient
-	contractAddr  string
-	startBlock    uint64
+	sources       []Source
 	blockBatch    uint64
 	confirmations uint64
 }
 
+// NewIngestionService ingests the loan registry only.
 func NewIngestionService(repo IngestionRepository, rpc blockchain.LogRPCClient, contractAddr string, startBlock, blockBatch, confirmations uint64) *IngestionService {
+	return NewMultiSourceIngestionService(repo, rpc, []Source{LoanRegistrySource(contractAddr, startBlock)}, blockBatch, confirmations)
+}
+
+func NewMultiSourceIngestionService(repo IngestionRepository, rpc blockchain.LogRPCClient, sources []Source, blockBatch, confirmations uint64) *IngestionService {
 	if blockBatch == 0 {
 		blockBatch = 500
 	}
 	return &IngestionService{
 		repo:          repo,
 		rpc:           rpc,
-		contractAddr:  strings.TrimSpace(contractAddr),
-		startBlock:    startBlock,
+		sources:       sources,
 		blockBatch:    blockBatch,
 		confirmations: confirmations,
 	}
 }
 
+// Filter is the addresses and event topics the service ingests, for callers
+// that subscribe to matching logs.
+func (s *IngestionService) Filter() blockchain.LogFilter {
+	return sourcesFilter(s.sources)
+}
+
 func (s *IngestionService) RunOnce(ctx context.Context) error {
+	_, err := s.ingestRange(ctx)
+	return err
+}
+
+// CatchUp ingests block ranges from the cursors until they reach the safe head
+// or maxRounds ranges were read, e.g. to backfill blocks missed while a
+// subscription was down.
+func (s *IngestionService) CatchUp(ctx context.Context, maxRounds int) error {
+	for i := 0; i < maxRounds; i++ {
+		done, err := s.ingestRange(ctx)
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingestRange ingests the next block range of every source and reports
+// whether all cursors have reached the safe head. A failing source does not
+// hold back the others.
+func (s *IngestionService) ingestRange(ctx context.Context) (bool, error) {
 	latest, err := s.rpc.BlockNumber(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if latest < s.confirmations {
-		return nil
+		return true, nil
 	}
 	safeHead := latest - s.confirmations
 
-	last, ok, err := s.repo.GetIngestionCursor(ctx, ingestionCursorKey)
+	done := true
+	var errs []error
+	for _, src := range s.sources {
+		srcDone, err := s.ingestSource(ctx, src, safeHead)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", src.Name, err))
+		}
+		done = done && srcDone && err == nil
+	}
+	return done, errors.Join(errs...)
+}
+
+func (s *IngestionService) ingestSource(ctx context.Context, src Source, safeHead uint64) (bool, error) {
+	last, ok, err := s.repo.GetIngestionCursor(ctx, src.CursorKey())
 	if err != nil {
-		return err
+		return false, err
 	}
 	var fromBlock uint64
 	if ok {
 		fromBlock = last + 1
 	} else {
-		fromBlock = s.startBlock
+		fromBlock = src.StartBlock
 	}
 	if fromBlock > safeHead {
-		return nil
+		return true, nil
 	}
 
 	toBlock := minUint64(safeHead, fromBlock+s.blockBatch-1)
+	if _, err := s.ingestLogs(ctx, src, fromBlock, toBlock); err != nil {
+		return false, err
+	}
+
+	if err := s.repo.SetIngestionCursor(ctx, src.CursorKey(), toBlock); err != nil {
+		return false, err
+	}
+	return toBlock == safeHead, nil
+}
+
+// Backfill re-reads blocks from..to of the named sources, or of all sources
+// when names is empty, without moving their cursors. Events already stored
+// are left as they are, so a range can be backfilled repeatedly. It returns
+// how many events were read.
+func (s *IngestionService) Backfill(ctx context.Context, from, to uint64, names []string) (int, error) {
+	if from > to {
+		return 0, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	sources, err := s.Sources(names)
+	if err != nil {
+		return 0, err
+	}
+	latest, err := s.rpc.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if latest < s.confirmations || to > latest-s.confirmations {
+		return 0, fmt.Errorf("block %d is not yet %d blocks deep", to, s.confirmations)
+	}
+
+	total := 0
+	for _, src := range sources {
+		for start := from; start <= to; {
+			end := minUint64(to, start+s.blockBatch-1)
+			n, err := s.ingestLogs(ctx, src, start, end)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("source %s blocks %d-%d: %w", src.Name, start, end, err)
+			}
+			if end == to {
+				break
+			}
+			start = end + 1
+		}
+	}
+	return total, nil
+}
+
+// Sources returns the named sources, or all sources when names is empty.
+func (s *IngestionService) Sources(names []string) ([]Source, error) {
+	if len(names) == 0 {
+		return s.sources, nil
+	}
+	out := make([]Source, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, src := range s.sources {
+			if src.Name == strings.TrimSpace(name) {
+				out = append(out, src)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown indexer source %s", name)
+		}
+	}
+	return out, nil
+}
+
+// ingestLogs stores the source's events in blocks from..to and returns how
+// many it read. Logs that do not decode are stored quarantined, so one bad
+// log cannot hold the cursor back.
+func (s *IngestionService) ingestLogs(ctx context.Context, src Source, from, to uint64) (int, error) {
 	logs, err := s.rpc.GetLogs(ctx, blockchain.LogFilter{
-		FromBlock: fromBlock,
-		ToBlock:   toBlock,
-		Address:   s.contractAddr,
-		Topics:    []string{topicLoanRegistered, topicRepaymentRecorded, topicLoanDefaulted},
+		FromBlock: from,
+		ToBlock:   to,
+		Addresses: src.Addresses,
+		Topics:    src.Topics(),
 	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	n := 0
 	for _, lg := range logs {
 		if lg.Removed {
 			continue
 		}
-		ev, ok, err := decodeLogToEvent(lg)
+		ev, ok, err := decodeLogToEvent(src, lg)
 		if err != nil {
-			return err
+			return n, err
 		}
 		if !ok {
 			continue
 		}
 		if err := s.repo.InsertChainEvent(ctx, ev); err != nil {
-			return err
+			return n, err
 		}
+		n++
 	}
-
-	return s.repo.SetIngestionCursor(ctx, ingestionCursorKey, toBlock)
+	return n, nil
 }
 
-var (
-	topicLoanRegistered    = eventTopic("LoanRegistered(bytes32,bytes32,address,uint256,uint256,string)")
-	topicRepaymentRecorded = eventTopic("RepaymentRecorded(bytes32,bytes32,uint256,uint256,uint256)")
-	topicLoanDefaulted     = eventTopic("LoanDefaulted(bytes32,bytes32,uint256)")
-)
-
-func decodeLogToEvent(log blockchain.LogEntry) (IngestedEvent, bool, error) {
+func decodeLogToEvent(src Source, log blockchain.LogEntry) (IngestedEvent, bool, error) {
 	if len(log.Topics) == 0 {
 		return IngestedEvent{}, false, nil
 	}
-	name := ""
-	raw := map[string]any{}
-	switch strings.ToLower(log.Topics[0]) {
-	case strings.ToLower(topicLoanRegistered):
-		if len(log.Topics) < 3 {
-			return IngestedEvent{}, false, fmt.Errorf("LoanRegistered missing indexed topics")
-		}
-		loanIDBytes32 := normalizeBytes32Hex(log.Topics[1])
-		borrowerIDBytes32 := normalizeBytes32Hex(log.Topics[2])
-		loanID := projectedLoanID(loanIDBytes32)
-		principal, maturity, currency := parseLoanRegisteredData(log.Data)
-		name = "LoanRegistered"
-		raw = map[string]any{
-			"loan_id":             loanID,
-			"loan_id_bytes32":     loanIDBytes32,
-			"borrower_id_bytes32": borrowerIDBytes32,
-			"principal_minor":     principal,
-			"maturity_ts":         maturity,
-			"currency_code":       currency,
-		}
-
-	case strings.ToLower(topicRepaymentRecorded):
-		if len(log.Topics) < 3 {
-			return IngestedEvent{}, false, fmt.Errorf("RepaymentRecorded missing indexed topics")
-		}
-		loanIDBytes32 := normalizeBytes32Hex(log.Topics[1])
-		borrowerIDBytes32 := normalizeBytes32Hex(log.Topics[2])
-		loanID := projectedLoanID(loanIDBytes32)
-		amountMinor, totalRepaid, ts := parseRepaymentData(log.Data)
-		name = "RepaymentRecorded"
-		raw = map[string]any{
-			"loan_id":             loanID,
-			"loan_id_bytes32":     loanIDBytes32,
-			"borrower_id_bytes32": borrowerIDBytes32,
-			"amount_minor":        amountMinor,
-			"total_repaid_minor":  totalRepaid,
-			"timestamp":           ts,
-		}
-
-	case strings.ToLower(topicLoanDefaulted):
-		if len(log.Topics) < 3 {
-			return IngestedEvent{}, false, fmt.Errorf("LoanDefaulted missing indexed topics")
-		}
-		loanIDBytes32 := normalizeBytes32Hex(log.Topics[1])
-		borrowerIDBytes32 := normalizeBytes32Hex(log.Topics[2])
-		loanID := projectedLoanID(loanIDBytes32)
-		ts := parseDefaultData(log.Data)
-		name = "LoanDefaulted"
-		raw = map[string]any{
-			"loan_id":             loanID,
-			"loan_id_bytes32":     loanIDBytes32,
-			"borrower_id_bytes32": borrowerIDBytes32,
-			"timestamp":           ts,
-		}
-	default:
+	abiEvent, ok := src.event(log.Topics[0])
+	if !ok {
 		return IngestedEvent{}, false, nil
 	}
-
-	rawJSON, err := json.Marshal(raw)
-	if err != nil {
-		return IngestedEvent{}, false, err
-	}
-	return IngestedEvent{
+	ev := IngestedEvent{
 		ContractAddr: strings.ToLower(log.Address),
-		EventName:    name,
+		EventName:    abiEvent.Name,
 		TXHash:       strings.ToLower(log.TransactionHash),
 		BlockNumber:  log.BlockNumber,
 		LogIndex:     log.LogIndex,
-		RawData:      rawJSON,
-	}, true, nil
-}
-
-func parseLoanRegisteredData(dataHex string) (int64, int64, string) {
-	words := abiWords(dataHex)
-	if len(words) < 2 {
-		return 0, 0, ""
-	}
-	return toInt64(words[0]), toInt64(words[1]), ""
-}
-
-func parseRepaymentData(dataHex string) (int64, int64, int64) {
-	words := abiWords(dataHex)
-	if len(words) < 3 {
-		return 0, 0, 0
-	}
-	return toInt64(words[0]), toInt64(words[1]), toInt64(words[2])
-}
-
-func parseDefaultData(dataHex string) int64 {
-	words := abiWords(dataHex)
-	if len(words) < 1 {
-		return 0
 	}
-	return toInt64(words[0])
-}
-
-func abiWords(dataHex string) []string {
-	clean := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(dataHex)), "0x")
-	if len(clean)%64 != 0 {
-		return nil
-	}
-	words := make([]string, 0, len(clean)/64)
-	for i := 0; i+64 <= len(clean); i += 64 {
-		words = append(words, clean[i:i+64])
+	var raw any
+	decoded, err := abiEvent.Decode(log)
+	if err != nil {
+		ev.DecodeError = "decode: " + err.Error()
+		raw = map[string]any{"topics": log.Topics, "data": log.Data}
+	} else {
+		raw = decoded
 	}
-	return words
-}
 
-func toInt64(word string) int64 {
-	n, ok := new(big.Int).SetString(word, 16)
-	if !ok || !n.IsInt64() {
-		return 0
+	rawJSON, err := json.Marshal(raw)
+	if err != nil {
+		return IngestedEvent{}, false, err
 	}
-	return n.Int64()
+	ev.RawData = rawJSON
+	return ev, true, nil
 }
 
 func normalizeBytes32Hex(topic string) string {
@@ -0,0 +1,133 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	admindomain "github.com/loangraph/backend/internal/domain/admin"
+)
+
+var (
+	ErrEventNotFound       = errors.New("chain_event_not_found")
+	ErrEventNotQuarantined = errors.New("chain_event_not_quarantined")
+	ErrInvalidEventPayload = errors.New("invalid_event_payload")
+)
+
+// QuarantinedEvent is a chain event the projection gave up on.
+type QuarantinedEvent struct {
+	ID            int64           `json:"id"`
+	ContractAddr  string          `json:"contract_addr"`
+	EventName     string          `json:"event_name"`
+	TXHash        string          `json:"tx_hash"`
+	BlockNumber   uint64          `json:"block_number"`
+	LogIndex      uint64          `json:"log_index"`
+	RawData       json.RawMessage `json:"raw_data"`
+	Attempts      int32           `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	Processed     bool            `json:"processed"`
+	QuarantinedAt *time.Time      `json:"quarantined_at,omitempty"`
+	SkippedAt     *time.Time      `json:"skipped_at,omitempty"`
+}
+
+type QuarantineRepository interface {
+	ListQuarantined(ctx context.Context, limit, offset int32) ([]QuarantinedEvent, error)
+	// GetEvent returns ErrEventNotFound for unknown IDs.
+	GetEvent(ctx context.Context, id int64) (*QuarantinedEvent, error)
+	// Requeue and Skip return ErrEventNotQuarantined when the event is no
+	// longer quarantined. Requeue replaces raw_data when rawData is set.
+	Requeue(ctx context.Context, id int64, rawData json.RawMessage) (*QuarantinedEvent, error)
+	Skip(ctx context.Context, id int64) (*QuarantinedEvent, error)
+}
+
+// QuarantineService lets admins inspect quarantined events and either fix
+// and retry them or skip them for good.
+type QuarantineService struct {
+	repo      QuarantineRepository
+	auditRepo admindomain.AuditRepository
+}
+
+func NewQuarantineService(repo QuarantineRepository, auditRepo admindomain.AuditRepository) *QuarantineService {
+	return &QuarantineService{repo: repo, auditRepo: auditRepo}
+}
+
+func (s *QuarantineService) ListQuarantined(ctx context.Context, limit, offset int32) ([]QuarantinedEvent, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListQuarantined(ctx, limit, offset)
+}
+
+// RetryEvent puts a quarantined event back in the projection queue with a
+// fresh attempt budget. A non-empty rawData replaces the stored payload and
+// must parse as the event's payload.
+func (s *QuarantineService) RetryEvent(ctx context.Context, adminUserID string, id int64, rawData json.RawMessage) (*QuarantinedEvent, error) {
+	current, err := s.repo.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.QuarantinedAt == nil || current.Processed {
+		return nil, ErrEventNotQuarantined
+	}
+	if len(rawData) > 0 {
+		var obj map[string]any
+		if err := json.Unmarshal(rawData, &obj); err != nil || obj == nil {
+			return nil, ErrInvalidEventPayload
+		}
+		if _, err := parseLoanEvent(ChainEvent{ID: id, EventName: current.EventName, RawData: rawData}); err != nil {
+			return nil, ErrInvalidEventPayload
+		}
+	}
+	updated, err := s.repo.Requeue(ctx, id, rawData)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{
+		"event_name": current.EventName,
+		"attempts":   current.Attempts,
+		"last_error": current.LastError,
+	}
+	if len(rawData) > 0 {
+		fields["prev_raw_data"] = current.RawData
+		fields["raw_data"] = rawData
+	}
+	s.audit(ctx, adminUserID, "chain_event_retried", id, fields)
+	return updated, nil
+}
+
+// SkipEvent marks a quarantined event processed without projecting it.
+func (s *QuarantineService) SkipEvent(ctx context.Context, adminUserID string, id int64) (*QuarantinedEvent, error) {
+	current, err := s.repo.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.QuarantinedAt == nil || current.Processed {
+		return nil, ErrEventNotQuarantined
+	}
+	updated, err := s.repo.Skip(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, adminUserID, "chain_event_skipped", id, map[string]any{
+		"event_name": current.EventName,
+		"attempts":   current.Attempts,
+		"last_error": current.LastError,
+	})
+	return updated, nil
+}
+
+func (s *QuarantineService) audit(ctx context.Context, adminUserID, action string, id int64, fields map[string]any) {
+	payload, _ := json.Marshal(fields)
+	_ = s.auditRepo.Log(ctx, admindomain.AuditLogInput{
+		AdminUserID: adminUserID,
+		Action:      action,
+		TargetType:  "chain_event",
+		TargetID:    strconv.FormatInt(id, 10),
+		Payload:     payload,
+	})
+}
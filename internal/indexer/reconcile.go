@@ -0,0 +1,309 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+var ErrReconciliationRunNotFound = errors.New("reconciliation_run_not_found")
+
+// Outbox topics of the corrective jobs, as enqueued by the loan service.
+const (
+	correctiveRegisterLoan = "register_loan"
+	correctiveRepayment    = "record_repayment"
+	correctiveDefault      = "mark_default"
+)
+
+// ReconcileLoan is a loans row with what reconciliation needs to correct it.
+type ReconcileLoan struct {
+	LoanState
+	LenderID     string
+	CurrencyCode string
+	// OpenJobs counts the loan's pending, processing or failed outbox jobs.
+	// While there are any, a difference with the chain may be a write in
+	// flight or one an admin can still retry.
+	OpenJobs int
+}
+
+// Discrepancy is a field on which the loans row, the registry and the
+// ingested chain events disagree.
+type Discrepancy struct {
+	ID              int64     `json:"id"`
+	RunID           int64     `json:"run_id"`
+	LoanID          string    `json:"loan_id"`
+	Field           string    `json:"field"`
+	DBValue         string    `json:"db_value"`
+	ChainValue      string    `json:"chain_value"`
+	EventsValue     string    `json:"events_value"`
+	InFlight        bool      `json:"in_flight"`
+	CorrectiveTopic string    `json:"corrective_topic,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ReconciliationRun struct {
+	ID            int64     `json:"id"`
+	BlockNumber   uint64    `json:"block_number"`
+	LoansChecked  int       `json:"loans_checked"`
+	Discrepancies int       `json:"discrepancies"`
+	JobsEnqueued  int       `json:"jobs_enqueued"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+}
+
+// CorrectiveJob is an outbox job that resends a write the chain is missing.
+type CorrectiveJob struct {
+	LoanID  string
+	Topic   string
+	Payload json.RawMessage
+}
+
+type ReconciliationRepository interface {
+	// ReconciliationSnapshot reads every loan and the projectable chain
+	// events, in chain order, from one snapshot.
+	ReconciliationSnapshot(ctx context.Context) ([]ReconcileLoan, []ChainEvent, error)
+	// SaveReconciliation stores the run and its discrepancies and enqueues
+	// the jobs in one transaction.
+	SaveReconciliation(ctx context.Context, run ReconciliationRun, items []Discrepancy, jobs []CorrectiveJob) (*ReconciliationRun, error)
+	// GetReconciliationRun returns the latest run when id is 0, and
+	// ErrReconciliationRunNotFound when there is no such run.
+	GetReconciliationRun(ctx context.Context, id int64) (*ReconciliationRun, error)
+	ListDiscrepancies(ctx context.Context, runID int64, limit, offset int32) ([]Discrepancy, error)
+}
+
+// ReconcileOptions controls a reconciliation run. The registry is read as of
+// BlockNumber. Enqueue adds corrective outbox jobs; DryRun stores nothing.
+type ReconcileOptions struct {
+	BlockNumber uint64
+	Enqueue     bool
+	DryRun      bool
+}
+
+type ReconcileReport struct {
+	Run   ReconciliationRun
+	Items []Discrepancy
+	Jobs  []CorrectiveJob
+}
+
+// ReconciliationService compares the loans table with the registry and with
+// what the ingested events say, and reports where they disagree.
+type ReconciliationService struct {
+	repo   ReconciliationRepository
+	reader blockchain.LoanRegistryReader
+	now    func() time.Time
+}
+
+// NewReconciliationService takes a nil reader when the service only serves
+// stored reports.
+func NewReconciliationService(repo ReconciliationRepository, reader blockchain.LoanRegistryReader) *ReconciliationService {
+	return &ReconciliationService{repo: repo, reader: reader, now: time.Now}
+}
+
+// Reconcile checks every loan, reading the registry in JSON-RPC batches.
+// Corrective jobs are only built for loans the DB is ahead of the chain on
+// and that have no outbox job open: registering a loan the DB has
+// confirmed or whose registration was never sent, the missing repaid amount,
+// and a default. Where the chain is ahead, the fix is on the DB side
+// (backfill or reproject) and the discrepancy is only reported.
+func (s *ReconciliationService) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	if s.reader == nil {
+		return nil, fmt.Errorf("reconciliation needs a registry reader")
+	}
+	started := s.now().UTC()
+	loans, events, err := s.repo.ReconciliationSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replaying the events from unregistered loans gives what the events
+	// alone say about each one.
+	initial := make(map[string]LoanState, len(loans))
+	for _, loan := range loans {
+		state := loan.LoanState
+		state.OnChainTX, state.OnChainConfirmed = "", false
+		initial[loan.LoanID] = state
+	}
+	replayed, err := ReplayLoans(events, initial)
+	if err != nil {
+		return nil, err
+	}
+	fromEvents := make(map[string]LoanState, len(replayed))
+	for _, state := range replayed {
+		fromEvents[state.LoanID] = state
+	}
+
+	loanIDs := make([]string, len(loans))
+	for i, loan := range loans {
+		loanIDs[i] = loan.LoanID
+	}
+	onChain, err := s.reader.GetLoans(ctx, loanIDs, opts.BlockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("read loans from registry: %w", err)
+	}
+
+	report := &ReconcileReport{Items: make([]Discrepancy, 0), Jobs: make([]CorrectiveJob, 0)}
+	for i, loan := range loans {
+		chain := onChain[i]
+		items := compareLoan(loan, chain, fromEvents[loan.LoanID])
+		if opts.Enqueue && loan.OpenJobs == 0 {
+			for _, job := range correctiveJobs(loan, chain) {
+				for i := range items {
+					if items[i].Field == correctedField(job.Topic) {
+						items[i].CorrectiveTopic = job.Topic
+					}
+				}
+				report.Jobs = append(report.Jobs, job)
+			}
+		}
+		report.Items = append(report.Items, items...)
+	}
+
+	report.Run = ReconciliationRun{
+		BlockNumber:   opts.BlockNumber,
+		LoansChecked:  len(loans),
+		Discrepancies: len(report.Items),
+		JobsEnqueued:  len(report.Jobs),
+		StartedAt:     started,
+		FinishedAt:    s.now().UTC(),
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	saved, err := s.repo.SaveReconciliation(ctx, report.Run, report.Items, report.Jobs)
+	if err != nil {
+		return nil, err
+	}
+	report.Run = *saved
+	return report, nil
+}
+
+// ListDiscrepancies returns a run, the latest when runID is 0, and a page of
+// its discrepancies.
+func (s *ReconciliationService) ListDiscrepancies(ctx context.Context, runID int64, limit, offset int32) (*ReconciliationRun, []Discrepancy, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	run, err := s.repo.GetReconciliationRun(ctx, runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.repo.ListDiscrepancies(ctx, run.ID, limit, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return run, items, nil
+}
+
+// registrationUnsent reports whether the loan's registration never produced a
+// transaction and no job is left to send it, as when its register_loan job
+// was cancelled. A dead-lettered job is left to an admin retry, and a
+// registration that was sent but has not landed is not resent, as either may
+// still reach the chain.
+func registrationUnsent(loan ReconcileLoan) bool {
+	return !loan.OnChainConfirmed && loan.OnChainTX == "" && loan.OpenJobs == 0
+}
+
+// compareLoan reports the registered flag, the repaid amount and the status
+// where the three sources disagree. Each source reports status "none" for a
+// loan it does not consider registered; the DB considers a loan whose
+// registration was never sent registered, and reports its flag as "unsent".
+func compareLoan(loan ReconcileLoan, chain blockchain.OnChainLoan, events LoanState) []Discrepancy {
+	registryStatus := func(registered bool, status string) string {
+		if !registered {
+			return "none"
+		}
+		return status
+	}
+	dbRegistered := strconv.FormatBool(loan.OnChainConfirmed)
+	if registrationUnsent(loan) {
+		dbRegistered = "unsent"
+	}
+	fields := []struct {
+		name                string
+		db, chain, fromLogs string
+	}{
+		{
+			"registered",
+			dbRegistered,
+			strconv.FormatBool(chain.Registered),
+			strconv.FormatBool(events.OnChainConfirmed),
+		},
+		{
+			"amount_repaid_minor",
+			strconv.FormatInt(loan.AmountRepaidMinor, 10),
+			strconv.FormatInt(chain.TotalRepaidMinor, 10),
+			strconv.FormatInt(events.AmountRepaidMinor, 10),
+		},
+		{
+			"status",
+			registryStatus(loan.OnChainConfirmed || registrationUnsent(loan), loan.Status),
+			registryStatus(chain.Registered, chain.Status),
+			registryStatus(events.OnChainConfirmed, events.Status),
+		},
+	}
+
+	var out []Discrepancy
+	for _, f := range fields {
+		if f.db == f.chain && f.chain == f.fromLogs {
+			continue
+		}
+		out = append(out, Discrepancy{
+			LoanID:      loan.LoanID,
+			Field:       f.name,
+			DBValue:     f.db,
+			ChainValue:  f.chain,
+			EventsValue: f.fromLogs,
+			InFlight:    loan.OpenJobs > 0,
+		})
+	}
+	return out
+}
+
+// correctiveJobs resends, in the order the loan service enqueues them, the
+// writes the DB has and the registry lacks. A loan whose registration was
+// never sent gets it along with every write that follows it.
+func correctiveJobs(loan ReconcileLoan, chain blockchain.OnChainLoan) []CorrectiveJob {
+	registered := loan.OnChainConfirmed || registrationUnsent(loan)
+	if !registered && !chain.Registered {
+		// The registration is in flight; there is nothing to correct yet.
+		return nil
+	}
+	var out []CorrectiveJob
+	add := func(topic string, body map[string]any) {
+		body["loan_id"] = loan.LoanID
+		if loan.LenderID != "" {
+			body["lender_id"] = loan.LenderID
+		}
+		payload, _ := json.Marshal(body)
+		out = append(out, CorrectiveJob{LoanID: loan.LoanID, Topic: topic, Payload: payload})
+	}
+	if registered && !chain.Registered {
+		add(correctiveRegisterLoan, map[string]any{})
+	}
+	if missing := loan.AmountRepaidMinor - chain.TotalRepaidMinor; missing > 0 {
+		add(correctiveRepayment, map[string]any{"amount_minor": missing, "currency": loan.CurrencyCode})
+	}
+	if loan.Status == "defaulted" && chain.Status != "defaulted" {
+		add(correctiveDefault, map[string]any{"reason": "reconciliation"})
+	}
+	return out
+}
+
+func correctedField(topic string) string {
+	switch topic {
+	case correctiveRegisterLoan:
+		return "registered"
+	case correctiveRepayment:
+		return "amount_repaid_minor"
+	default:
+		return "status"
+	}
+}
@@ -0,0 +1,151 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// LoanState is the part of a loans row the projection writes, plus what it
+// reads to decide the status.
+type LoanState struct {
+	LoanID            string
+	BorrowerID        string
+	PrincipalMinor    int64
+	AmountRepaidMinor int64
+	Status            string
+	OnChainTX         string
+	OnChainConfirmed  bool
+}
+
+// PassportState is a passport_cache row.
+type PassportState struct {
+	BorrowerID         string
+	TotalLoans         int32
+	TotalRepaid        int32
+	TotalDefaulted     int32
+	CumulativeBorrowed int64
+	CumulativeRepaid   int64
+	CreditScore        int32
+}
+
+type LoanDiff struct {
+	Before LoanState
+	After  LoanState
+}
+
+// PassportDiff is a changed passport_cache row; Before is nil when the row
+// did not exist.
+type PassportDiff struct {
+	Before *PassportState
+	After  PassportState
+}
+
+// ReprojectReport lists what a reprojection changed, or would change on a dry
+// run.
+type ReprojectReport struct {
+	Events    int
+	Loans     []LoanDiff
+	Passports []PassportDiff
+	// LedgerEntries counts the entries posted for replayed events the
+	// ledger was missing.
+	LedgerEntries int
+}
+
+// ReprojectionRepository rebuilds the projections from chain_events in one
+// transaction, rolled back when dryRun is set.
+type ReprojectionRepository interface {
+	Reproject(ctx context.Context, dryRun bool) (ReprojectReport, error)
+}
+
+// ReplayLoans rebuilds loans from events, which must be in chain order. Each
+// loan is reset to its unprojected state (nothing repaid, active, keeping the
+// on-chain fields the worker set) and then gets the rules the Service applies
+// incrementally. Events for loans missing from loans are skipped, as the
+// Service's updates would match no row. The result is sorted by loan ID.
+func ReplayLoans(events []ChainEvent, loans map[string]LoanState) ([]LoanState, error) {
+	state := make(map[string]LoanState, len(loans))
+	for id, loan := range loans {
+		loan.AmountRepaidMinor = 0
+		loan.Status = "active"
+		state[id] = loan
+	}
+
+	for _, ev := range events {
+		le, err := parseLoanEvent(ev)
+		if err != nil {
+			return nil, fmt.Errorf("chain event %d: %w", ev.ID, err)
+		}
+		loan, ok := state[le.loanID]
+		if le.skip || !ok {
+			continue
+		}
+		switch le.name {
+		case "LoanRegistered":
+			loan.OnChainTX = ev.TXHash
+			loan.OnChainConfirmed = true
+		case "RepaymentRecorded":
+			if loan.Status == "defaulted" {
+				continue
+			}
+			loan.AmountRepaidMinor += le.amountMinor
+			if loan.AmountRepaidMinor >= loan.PrincipalMinor {
+				loan.Status = "repaid"
+			}
+		default:
+			loan.Status = "defaulted"
+		}
+		state[le.loanID] = loan
+	}
+
+	out := make([]LoanState, 0, len(state))
+	for _, loan := range state {
+		out = append(out, loan)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LoanID < out[j].LoanID })
+	return out, nil
+}
+
+// LedgerEvent is a replayed event that posts a ledger entry: a repayment of
+// AmountMinor, or a default when AmountMinor is zero.
+type LedgerEvent struct {
+	ChainEvent
+	LoanID      string
+	AmountMinor int64
+}
+
+// ReplayLedger returns the events ReplayLoans applies that move ledger
+// balances, in chain order: repayments on loans not yet defaulted and each
+// loan's first default.
+func ReplayLedger(events []ChainEvent, loans map[string]LoanState) ([]LedgerEvent, error) {
+	defaulted := make(map[string]bool)
+	out := make([]LedgerEvent, 0)
+	for _, ev := range events {
+		le, err := parseLoanEvent(ev)
+		if err != nil {
+			return nil, fmt.Errorf("chain event %d: %w", ev.ID, err)
+		}
+		if _, ok := loans[le.loanID]; le.skip || !ok || defaulted[le.loanID] {
+			continue
+		}
+		switch le.name {
+		case "RepaymentRecorded":
+			out = append(out, LedgerEvent{ChainEvent: ev, LoanID: le.loanID, AmountMinor: le.amountMinor})
+		case "LoanDefaulted":
+			defaulted[le.loanID] = true
+			out = append(out, LedgerEvent{ChainEvent: ev, LoanID: le.loanID})
+		}
+	}
+	return out, nil
+}
+
+// DiffLoans returns the rebuilt loans that differ from before.
+func DiffLoans(before map[string]LoanState, after []LoanState) []LoanDiff {
+	out := make([]LoanDiff, 0)
+	for _, loan := range after {
+		if prev := before[loan.LoanID]; prev != loan {
+			out = append(out, LoanDiff{Before: prev, After: loan})
+		}
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -10,67 +11,134 @@ import (
 )
 
 type ChainEvent struct {
-	ID        int64
-	EventName string
-	TXHash    string
-	RawData   []byte
+	ID          int64
+	EventName   string
+	TXHash      string
+	BlockNumber uint64
+	LogIndex    uint64
+	RawData     []byte
 }
 
+// DefaultMaxEventAttempts is how often an event may fail before it is
+// quarantined.
+const DefaultMaxEventAttempts = 5
+
 type EventRepository interface {
+	// ListUnprocessed returns events in chain order, (block_number,
+	// log_index), skipping quarantined ones.
 	ListUnprocessed(ctx context.Context, limit int32) ([]ChainEvent, error)
-	MarkProcessed(ctx context.Context, eventID int64) error
+	// Project runs apply against projections bound to one transaction and
+	// marks the event processed in the same transaction, so an event is
+	// either fully applied or not at all.
+	Project(ctx context.Context, eventID int64, apply func(ProjectionRepository) error) error
+	// RecordFailure counts a failed attempt and stores its error, quarantining
+	// the event once it has failed maxAttempts times. It reports whether the
+	// event is now quarantined.
+	RecordFailure(ctx context.Context, eventID int64, errMsg string, maxAttempts int32) (bool, error)
 }
 
 type ProjectionRepository interface {
 	ApplyLoanRegistered(ctx context.Context, loanID, txHash string) error
-	ApplyRepayment(ctx context.Context, loanID string, amountMinor int64) error
+	// ApplyRepayment applies a repayment once per (txHash, logIndex); a
+	// repeat is a no-op.
+	ApplyRepayment(ctx context.Context, loanID string, amountMinor int64, txHash string, logIndex uint64) error
 	ApplyDefault(ctx context.Context, loanID string) error
 	RefreshPassportCacheByLoan(ctx context.Context, loanID string) error
 }
 
 type Service struct {
-	eventRepo EventRepository
-	projRepo  ProjectionRepository
+	eventRepo   EventRepository
+	maxAttempts int32
+}
+
+func NewService(eventRepo EventRepository) *Service {
+	return &Service{eventRepo: eventRepo, maxAttempts: DefaultMaxEventAttempts}
 }
 
-func NewService(eventRepo EventRepository, projRepo ProjectionRepository) *Service {
-	return &Service{eventRepo: eventRepo, projRepo: projRepo}
+// SetMaxAttempts sets how often an event may fail before it is quarantined.
+func (s *Service) SetMaxAttempts(n int32) {
+	if n > 0 {
+		s.maxAttempts = n
+	}
 }
 
+// RunOnce projects a batch of events. A failing event stops the batch, so
+// later events keep their order while the failure may be transient, and is
+// retried on the next run. Once it has failed maxAttempts times it is
+// quarantined and the batch moves past it; RunOnce then still reports it.
 func (s *Service) RunOnce(ctx context.Context, batchSize int32) error {
 	events, err := s.eventRepo.ListUnprocessed(ctx, batchSize)
 	if err != nil {
 		return err
 	}
 
+	var quarantined []error
 	for _, ev := range events {
-		if err := s.processEvent(ctx, ev); err != nil {
+		err := s.eventRepo.Project(ctx, ev.ID, func(proj ProjectionRepository) error {
+			return processEvent(ctx, proj, ev)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			isQuarantined, recordErr := s.eventRepo.RecordFailure(ctx, ev.ID, err.Error(), s.maxAttempts)
+			if recordErr != nil {
+				return errors.Join(fmt.Errorf("chain event %d: %w", ev.ID, err), recordErr)
+			}
+			if !isQuarantined {
+				return fmt.Errorf("chain event %d: %w", ev.ID, err)
+			}
+			quarantined = append(quarantined, fmt.Errorf("chain event %d quarantined after %d attempts: %w", ev.ID, s.maxAttempts, err))
+		}
+	}
+	return errors.Join(quarantined...)
+}
+
+func processEvent(ctx context.Context, proj ProjectionRepository, ev ChainEvent) error {
+	le, err := parseLoanEvent(ev)
+	if err != nil || le.skip {
+		return err
+	}
+	switch le.name {
+	case "LoanRegistered":
+		return proj.ApplyLoanRegistered(ctx, le.loanID, ev.TXHash)
+	case "RepaymentRecorded":
+		if err := proj.ApplyRepayment(ctx, le.loanID, le.amountMinor, ev.TXHash, ev.LogIndex); err != nil {
 			return err
 		}
-		if err := s.eventRepo.MarkProcessed(ctx, ev.ID); err != nil {
+		return proj.RefreshPassportCacheByLoan(ctx, le.loanID)
+	default:
+		if err := proj.ApplyDefault(ctx, le.loanID); err != nil {
 			return err
 		}
+		return proj.RefreshPassportCacheByLoan(ctx, le.loanID)
 	}
-	return nil
 }
 
-func (s *Service) processEvent(ctx context.Context, ev ChainEvent) error {
+// loanEvent is the payload of an event the loan projection applies. skip is
+// set for other events and for loan IDs that are not UUIDs.
+type loanEvent struct {
+	name        string
+	loanID      string
+	amountMinor int64
+	skip        bool
+}
+
+func parseLoanEvent(ev ChainEvent) (loanEvent, error) {
 	name := strings.TrimSpace(ev.EventName)
+	out := loanEvent{name: name}
 	switch name {
-	case "LoanRegistered":
+	case "LoanRegistered", "LoanDefaulted":
 		var payload struct {
 			LoanID string `json:"loan_id"`
 		}
 		if err := json.Unmarshal(ev.RawData, &payload); err != nil {
-			return fmt.Errorf("invalid LoanRegistered payload: %w", err)
+			return out, fmt.Errorf("invalid %s payload: %w", name, err)
 		}
 		if strings.TrimSpace(payload.LoanID) == "" {
-			return fmt.Errorf("missing loan_id in LoanRegistered")
+			return out, fmt.Errorf("missing loan_id in %s", name)
 		}
-		if !isUUID(payload.LoanID) {
-			return nil
-		}
-		return s.projRepo.ApplyLoanRegistered(ctx, payload.LoanID, ev.TXHash)
+		out.loanID = payload.LoanID
 
 	case "RepaymentRecorded":
 		var payload struct {
@@ -78,40 +146,19 @@ func (s *Service) processEvent(ctx context.Context, ev ChainEvent) error {
 			AmountMinor int64  `json:"amount_minor"`
 		}
 		if err := json.Unmarshal(ev.RawData, &payload); err != nil {
-			return fmt.Errorf("invalid RepaymentRecorded payload: %w", err)
+			return out, fmt.Errorf("invalid RepaymentRecorded payload: %w", err)
 		}
 		if strings.TrimSpace(payload.LoanID) == "" || payload.AmountMinor <= 0 {
-			return fmt.Errorf("invalid RepaymentRecorded payload values")
-		}
-		if !isUUID(payload.LoanID) {
-			return nil
-		}
-		if err := s.projRepo.ApplyRepayment(ctx, payload.LoanID, payload.AmountMinor); err != nil {
-			return err
-		}
-		return s.projRepo.RefreshPassportCacheByLoan(ctx, payload.LoanID)
-
-	case "LoanDefaulted":
-		var payload struct {
-			LoanID string `json:"loan_id"`
-		}
-		if err := json.Unmarshal(ev.RawData, &payload); err != nil {
-			return fmt.Errorf("invalid LoanDefaulted payload: %w", err)
-		}
-		if strings.TrimSpace(payload.LoanID) == "" {
-			return fmt.Errorf("missing loan_id in LoanDefaulted")
-		}
-		if !isUUID(payload.LoanID) {
-			return nil
-		}
-		if err := s.projRepo.ApplyDefault(ctx, payload.LoanID); err != nil {
-			return err
+			return out, fmt.Errorf("invalid RepaymentRecorded payload values")
 		}
-		return s.projRepo.RefreshPassportCacheByLoan(ctx, payload.LoanID)
+		out.loanID, out.amountMinor = payload.LoanID, payload.AmountMinor
 
 	default:
-		return nil
+		out.skip = true
+		return out, nil
 	}
+	out.skip = !isUUID(out.loanID)
+	return out, nil
 }
 
 func isUUID(raw string) bool {
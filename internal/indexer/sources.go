@@ -0,0 +1,240 @@
+package indexer
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/loangraph/backend/internal/blockchain"
+	"github.com/loangraph/backend/internal/config"
+)
+
+const LoanRegistrySourceName = "loan_registry"
+
+//go:embed abi/*.json
+var builtinABIs embed.FS
+
+var (
+	sourceNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	addressPattern    = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+)
+
+// Source is a set of contracts sharing an ABI that the indexer ingests from
+// StartBlock on, with its own cursor.
+type Source struct {
+	Name       string
+	Addresses  []string
+	StartBlock uint64
+	Events     []ABIEvent
+}
+
+// CursorKey is the app_metadata key holding the last ingested block.
+func (s Source) CursorKey() string {
+	return "indexer." + s.Name + ".last_block"
+}
+
+// Topics are the first topics of the source's events.
+func (s Source) Topics() []string {
+	out := make([]string, 0, len(s.Events))
+	for _, ev := range s.Events {
+		out = append(out, ev.Topic())
+	}
+	return out
+}
+
+func (s Source) event(topic string) (ABIEvent, bool) {
+	for _, ev := range s.Events {
+		if strings.EqualFold(ev.Topic(), topic) {
+			return ev, true
+		}
+	}
+	return ABIEvent{}, false
+}
+
+// LoanRegistrySource is the built-in source for the loan registry proxy.
+func LoanRegistrySource(contractAddr string, startBlock uint64) Source {
+	events, err := builtinEvents("loan_registry")
+	if err != nil {
+		panic(err)
+	}
+	return Source{
+		Name:       LoanRegistrySourceName,
+		Addresses:  []string{strings.TrimSpace(contractAddr)},
+		StartBlock: startBlock,
+		Events:     events,
+	}
+}
+
+// SourcesFromConfig returns the loan registry source for LOAN_REGISTRY_PROXY
+// plus the sources in INDEXER_SOURCES_FILE, which may also redefine
+// loan_registry.
+func SourcesFromConfig(cfg config.Config) ([]Source, error) {
+	var sources []Source
+	if addr := strings.TrimSpace(cfg.LoanRegistryProxy); addr != "" {
+		sources = append(sources, LoanRegistrySource(addr, cfg.IndexerStartBlock))
+	}
+	if path := strings.TrimSpace(cfg.IndexerSourcesFile); path != "" {
+		extra, err := LoadSources(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = MergeSources(sources, extra)
+	}
+	return sources, nil
+}
+
+// sourceConfig is one entry of the INDEXER_SOURCES_FILE JSON array. The ABI
+// is given inline (abi), as a file path relative to the sources file
+// (abi_file), or by built-in name (abi_builtin: loan_registry, erc20,
+// erc721). events optionally restricts which ABI events are ingested.
+type sourceConfig struct {
+	Name       string          `json:"name"`
+	Address    string          `json:"address"`
+	Addresses  []string        `json:"addresses"`
+	StartBlock uint64          `json:"start_block"`
+	ABI        json.RawMessage `json:"abi"`
+	ABIFile    string          `json:"abi_file"`
+	ABIBuiltin string          `json:"abi_builtin"`
+	Events     []string        `json:"events"`
+}
+
+// LoadSources reads source definitions from a JSON file.
+func LoadSources(path string) ([]Source, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read indexer sources: %w", err)
+	}
+	var configs []sourceConfig
+	if err := json.Unmarshal(raw, &configs); err != nil {
+		return nil, fmt.Errorf("invalid indexer sources: %w", err)
+	}
+	out := make([]Source, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, cfg := range configs {
+		src, err := cfg.source(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		if seen[src.Name] {
+			return nil, fmt.Errorf("duplicate indexer source %s", src.Name)
+		}
+		seen[src.Name] = true
+		out = append(out, src)
+	}
+	return out, nil
+}
+
+// MergeSources adds extra to base, replacing base sources of the same name.
+func MergeSources(base []Source, extra []Source) []Source {
+	out := make([]Source, 0, len(base)+len(extra))
+	byName := make(map[string]bool)
+	for _, src := range extra {
+		byName[src.Name] = true
+	}
+	for _, src := range base {
+		if !byName[src.Name] {
+			out = append(out, src)
+		}
+	}
+	return append(out, extra...)
+}
+
+func (c sourceConfig) source(baseDir string) (Source, error) {
+	name := strings.TrimSpace(c.Name)
+	if !sourceNamePattern.MatchString(name) {
+		return Source{}, fmt.Errorf("invalid indexer source name %q", c.Name)
+	}
+	addresses := append([]string(nil), c.Addresses...)
+	if strings.TrimSpace(c.Address) != "" {
+		addresses = append(addresses, c.Address)
+	}
+	if len(addresses) == 0 {
+		return Source{}, fmt.Errorf("indexer source %s has no address", name)
+	}
+	for i, addr := range addresses {
+		addresses[i] = strings.TrimSpace(addr)
+		if !addressPattern.MatchString(addresses[i]) {
+			return Source{}, fmt.Errorf("indexer source %s has invalid address %q", name, addr)
+		}
+	}
+
+	var (
+		events []ABIEvent
+		err    error
+	)
+	switch {
+	case len(c.ABI) > 0:
+		events, err = ParseABIEvents(c.ABI)
+	case c.ABIFile != "":
+		path := c.ABIFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		var raw []byte
+		if raw, err = os.ReadFile(path); err == nil {
+			events, err = ParseABIEvents(raw)
+		}
+	case c.ABIBuiltin != "":
+		events, err = builtinEvents(c.ABIBuiltin)
+	default:
+		err = fmt.Errorf("no abi")
+	}
+	if err != nil {
+		return Source{}, fmt.Errorf("indexer source %s: %w", name, err)
+	}
+	if len(c.Events) > 0 {
+		if events, err = selectEvents(events, c.Events); err != nil {
+			return Source{}, fmt.Errorf("indexer source %s: %w", name, err)
+		}
+	}
+	if len(events) == 0 {
+		return Source{}, fmt.Errorf("indexer source %s has no events", name)
+	}
+	return Source{Name: name, Addresses: addresses, StartBlock: c.StartBlock, Events: events}, nil
+}
+
+func builtinEvents(name string) ([]ABIEvent, error) {
+	raw, err := builtinABIs.ReadFile("abi/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown builtin abi %q", name)
+	}
+	return ParseABIEvents(raw)
+}
+
+func selectEvents(events []ABIEvent, names []string) ([]ABIEvent, error) {
+	out := make([]ABIEvent, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, ev := range events {
+			if ev.Name == strings.TrimSpace(name) {
+				out = append(out, ev)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("abi has no event %s", name)
+		}
+	}
+	return out, nil
+}
+
+// sourcesFilter is the union of the sources' addresses and topics.
+func sourcesFilter(sources []Source) blockchain.LogFilter {
+	var filter blockchain.LogFilter
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		filter.Addresses = append(filter.Addresses, src.Addresses...)
+		for _, topic := range src.Topics() {
+			if !seen[topic] {
+				seen[topic] = true
+				filter.Topics = append(filter.Topics, topic)
+			}
+		}
+	}
+	return filter
+}
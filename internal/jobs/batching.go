@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+// ChainBatch records which outbox jobs, and so which loans, a multicall
+// transaction covers.
+type ChainBatch struct {
+	LenderID string
+	TxHash   string
+	Items    []ChainBatchItem
+}
+
+type ChainBatchItem struct {
+	JobID  int64
+	Action string
+	LoanID string
+}
+
+type BatchRepository interface {
+	RecordBatch(ctx context.Context, batch ChainBatch) error
+}
+
+// workUnit is the slice of a claimed batch one goroutine processes: either a
+// lane of jobs for one loan run one by one, or a multicall batch for one
+// lender.
+type workUnit struct {
+	jobs     []OutboxJob
+	lenderID string
+	calls    []blockchain.BatchCall
+}
+
+func (u workUnit) batched() bool {
+	return len(u.calls) > 0
+}
+
+// SetBatching groups register and repayment jobs per lender into multicall
+// transactions of up to maxSize calls when the writer supports it. A maxSize
+// below 2 disables batching. repo may be nil.
+func (w *Worker) SetBatching(maxSize int, repo BatchRepository) {
+	w.maxChainBatch = maxSize
+	w.batchRepo = repo
+}
+
+// planUnits splits claimed jobs into multicall batches and ordered lanes.
+// Only jobs whose loan appears once in the claim are batched, so a loan's
+// jobs never straddle a batch and a lane.
+func (w *Worker) planUnits(jobs []OutboxJob) []workUnit {
+	if _, ok := w.writer.(blockchain.BatchWriter); !ok || w.maxChainBatch < 2 {
+		return laneUnits(jobs)
+	}
+
+	perLoan := make(map[string]int)
+	for _, job := range jobs {
+		perLoan[orderingKey(job)]++
+	}
+	var (
+		lenders  []string
+		byLender = make(map[string]*workUnit)
+		rest     []OutboxJob
+	)
+	for _, job := range jobs {
+		lenderID, call, ok := batchCall(job)
+		if !ok || perLoan[call.LoanID] != 1 {
+			rest = append(rest, job)
+			continue
+		}
+		unit, seen := byLender[lenderID]
+		if !seen {
+			unit = &workUnit{lenderID: lenderID}
+			byLender[lenderID] = unit
+			lenders = append(lenders, lenderID)
+		}
+		unit.jobs = append(unit.jobs, job)
+		unit.calls = append(unit.calls, call)
+	}
+
+	units := make([]workUnit, 0, len(jobs))
+	for _, lenderID := range lenders {
+		unit := byLender[lenderID]
+		for start := 0; start < len(unit.jobs); start += w.maxChainBatch {
+			end := min(start+w.maxChainBatch, len(unit.jobs))
+			if end-start == 1 {
+				rest = append(rest, unit.jobs[start])
+				continue
+			}
+			units = append(units, workUnit{lenderID: lenderID, jobs: unit.jobs[start:end], calls: unit.calls[start:end]})
+		}
+	}
+	return append(units, laneUnits(rest)...)
+}
+
+func laneUnits(jobs []OutboxJob) []workUnit {
+	lanes := orderedLanes(jobs)
+	out := make([]workUnit, 0, len(lanes))
+	for _, lane := range lanes {
+		out = append(out, workUnit{jobs: lane})
+	}
+	return out
+}
+
+// batchCall returns the multicall call for a register or repayment job that
+// names its lender. Anything else, including invalid payloads, is processed
+// on its own so it fails or retries independently.
+func batchCall(job OutboxJob) (string, blockchain.BatchCall, bool) {
+	var payload struct {
+		LoanID      string `json:"loan_id"`
+		LenderID    string `json:"lender_id"`
+		AmountMinor int64  `json:"amount_minor"`
+		Currency    string `json:"currency"`
+	}
+	if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.LenderID == "" || payload.LoanID == "" {
+		return "", blockchain.BatchCall{}, false
+	}
+	switch job.Topic {
+	case registerLoanTopic:
+		return payload.LenderID, blockchain.BatchCall{Action: blockchain.BatchActionRegisterLoan, LoanID: payload.LoanID}, true
+	case repaymentTopic:
+		if payload.AmountMinor <= 0 || len(payload.Currency) != 3 {
+			return "", blockchain.BatchCall{}, false
+		}
+		return payload.LenderID, blockchain.BatchCall{
+			Action:      blockchain.BatchActionRecordRepayment,
+			LoanID:      payload.LoanID,
+			AmountMinor: payload.AmountMinor,
+			Currency:    payload.Currency,
+		}, true
+	default:
+		return "", blockchain.BatchCall{}, false
+	}
+}
+
+// processBatch submits the unit as one multicall. When it reverts the batch
+// is split in half and each half retried, isolating the offending call, which
+// then fails or retries on its own under the usual policy.
+func (w *Worker) processBatch(ctx context.Context, unit workUnit) error {
+	txHash, err := w.writer.(blockchain.BatchWriter).SubmitBatch(ctx, unit.lenderID, unit.calls)
+	if err != nil {
+		if blockchain.KindOf(err) == blockchain.KindReverted && len(unit.jobs) > 1 {
+			mid := len(unit.jobs) / 2
+			first := workUnit{lenderID: unit.lenderID, jobs: unit.jobs[:mid], calls: unit.calls[:mid]}
+			second := workUnit{lenderID: unit.lenderID, jobs: unit.jobs[mid:], calls: unit.calls[mid:]}
+			if err := w.processBatch(ctx, first); err != nil {
+				return err
+			}
+			return w.processBatch(ctx, second)
+		}
+		return w.failBatch(ctx, unit, err)
+	}
+
+	// The batch is on chain now, so nothing below may send it again: its jobs
+	// are finished even if recording the batch or a loan's submission fails,
+	// and those failures are returned for the caller to log. The indexer
+	// still confirms the loans when it sees their events.
+	recordCtx := context.WithoutCancel(ctx)
+	var recordErrs []error
+	if w.batchRepo != nil {
+		batch := ChainBatch{LenderID: unit.lenderID, TxHash: txHash, Items: make([]ChainBatchItem, 0, len(unit.jobs))}
+		for i, job := range unit.jobs {
+			batch.Items = append(batch.Items, ChainBatchItem{JobID: job.ID, Action: unit.calls[i].Action, LoanID: unit.calls[i].LoanID})
+		}
+		if err := w.batchRepo.RecordBatch(recordCtx, batch); err != nil {
+			recordErrs = append(recordErrs, fmt.Errorf("record batch %s: %w", txHash, err))
+		}
+	}
+	for i, job := range unit.jobs {
+		if unit.calls[i].Action == blockchain.BatchActionRegisterLoan {
+			if err := w.loanRepo.SetOnChainSubmission(recordCtx, unit.calls[i].LoanID, txHash, false); err != nil {
+				recordErrs = append(recordErrs, fmt.Errorf("record submission of loan %s in batch %s: %w", unit.calls[i].LoanID, txHash, err))
+			}
+		}
+		if err := w.outboxRepo.MarkDone(recordCtx, job.ID); err != nil {
+			return errors.Join(append(recordErrs, err)...)
+		}
+	}
+	return errors.Join(recordErrs...)
+}
+
+func (w *Worker) failBatch(ctx context.Context, unit workUnit, cause error) error {
+	for _, job := range unit.jobs {
+		if err := w.handleJobError(ctx, job, cause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
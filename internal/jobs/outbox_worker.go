@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/loangraph/backend/internal/blockchain"
@@ -14,6 +17,7 @@ const (
 	registerLoanTopic = "register_loan"
 	repaymentTopic    = "record_repayment"
 	defaultTopic      = "mark_default"
+	poolPositionTopic = "pool_position"
 )
 
 type OutboxJob struct {
@@ -27,54 +31,277 @@ type OutboxJob struct {
 }
 
 type OutboxRepository interface {
-	ClaimPending(ctx context.Context, limit int32) ([]OutboxJob, error)
+	ClaimPending(ctx context.Context, workerID string, lease time.Duration, limit int32) ([]OutboxJob, error)
+	RenewLease(ctx context.Context, workerID string, jobIDs []int64, lease time.Duration) error
 	MarkDone(ctx context.Context, jobID int64) error
 	MarkRetry(ctx context.Context, jobID int64, nextAvailableAt time.Time, lastError string) error
 	MarkFailed(ctx context.Context, jobID int64, lastError string) error
+	Defer(ctx context.Context, jobID int64, until time.Time, lastError string) error
+	Release(ctx context.Context, workerID string, jobIDs []int64) error
 }
 
 type LoanRepository interface {
 	SetOnChainSubmission(ctx context.Context, loanID, txHash string, confirmed bool) error
 }
 
+type PositionRepository interface {
+	SetTransactionSubmission(ctx context.Context, transactionID, txHash string) error
+}
+
+const (
+	defaultConcurrency = 4
+	defaultLease       = 2 * time.Minute
+	releaseTimeout     = 10 * time.Second
+)
+
+var (
+	errInvalidPayload = blockchain.Permanent(errors.New("invalid_payload"))
+	errUnsupported    = blockchain.Permanent(errors.New("unsupported_topic"))
+)
+
 type Worker struct {
-	outboxRepo   OutboxRepository
-	loanRepo     LoanRepository
-	writer       blockchain.LoanRegistryWriter
-	maxAttempts  int32
-	now          func() time.Time
-	retryBackoff func(attempt int32) time.Duration
+	outboxRepo    OutboxRepository
+	loanRepo      LoanRepository
+	positionRepo  PositionRepository
+	writer        blockchain.LoanRegistryWriter
+	retryPolicy   RetryPolicy
+	topicPolicies map[string]RetryPolicy
+	concurrency   int
+	maxChainBatch int
+	batchRepo     BatchRepository
+	workerID      string
+	lease         time.Duration
+	now           func() time.Time
+	jitter        func() float64
 }
 
 func NewWorker(outboxRepo OutboxRepository, loanRepo LoanRepository, writer blockchain.LoanRegistryWriter) *Worker {
 	return &Worker{
-		outboxRepo:  outboxRepo,
-		loanRepo:    loanRepo,
-		writer:      writer,
-		maxAttempts: 5,
-		now:         func() time.Time { return time.Now().UTC() },
-		retryBackoff: func(attempt int32) time.Duration {
-			if attempt < 1 {
-				attempt = 1
-			}
-			return time.Duration(attempt*15) * time.Second
-		},
+		outboxRepo:    outboxRepo,
+		loanRepo:      loanRepo,
+		writer:        writer,
+		retryPolicy:   DefaultRetryPolicy(),
+		topicPolicies: make(map[string]RetryPolicy),
+		concurrency:   defaultConcurrency,
+		workerID:      defaultWorkerID(),
+		lease:         defaultLease,
+		now:           func() time.Time { return time.Now().UTC() },
+		jitter:        rand.Float64,
 	}
 }
 
+// SetConcurrency bounds how many jobs run at once within a batch.
+func (w *Worker) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	w.concurrency = n
+}
+
+// SetLease sets the identity recorded on claimed jobs and how long a claim
+// lasts without renewal. An empty workerID keeps the hostname-pid default.
+func (w *Worker) SetLease(workerID string, lease time.Duration) {
+	if workerID != "" {
+		w.workerID = workerID
+	}
+	if lease > 0 {
+		w.lease = lease
+	}
+}
+
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// SetRetryPolicy replaces the policy used for topics without an override.
+func (w *Worker) SetRetryPolicy(policy RetryPolicy) {
+	w.retryPolicy = policy
+}
+
+// SetTopicRetryPolicy overrides the retry policy for a single topic.
+func (w *Worker) SetTopicRetryPolicy(topic string, policy RetryPolicy) {
+	w.topicPolicies[topic] = policy
+}
+
+// SetClock replaces the time and jitter sources. Tests use it to make retry
+// schedules deterministic.
+func (w *Worker) SetClock(now func() time.Time, jitter func() float64) {
+	if now != nil {
+		w.now = now
+	}
+	if jitter != nil {
+		w.jitter = jitter
+	}
+}
+
+func (w *Worker) policyFor(topic string) RetryPolicy {
+	if policy, ok := w.topicPolicies[topic]; ok {
+		return policy
+	}
+	return w.retryPolicy
+}
+
+// SetPositionRepository lets the worker record pool token transaction hashes
+// against investor transactions. Without it pool_position jobs still run.
+func (w *Worker) SetPositionRepository(repo PositionRepository) {
+	w.positionRepo = repo
+}
+
+// RunOnce claims a batch and processes it on up to concurrency goroutines.
+// Jobs sharing an ordering key (the loan they touch) run sequentially in claim
+// order on one goroutine; with batching enabled, register and repayment jobs
+// are grouped per lender into multicall transactions first. A job whose
+// outcome could not be recorded, and every later job in its lane, is released
+// back to the queue, as are jobs left unstarted when ctx is cancelled. The
+// batch's leases are renewed until every lane has finished.
 func (w *Worker) RunOnce(ctx context.Context, batchSize int32) error {
-	jobs, err := w.outboxRepo.ClaimPending(ctx, batchSize)
+	jobs, err := w.outboxRepo.ClaimPending(ctx, w.workerID, w.lease, batchSize)
 	if err != nil {
 		return err
 	}
+	if len(jobs) == 0 {
+		return nil
+	}
+	stopRenewal := w.renewLeases(ctx, jobIDs(jobs))
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		errs       []error
+		unfinished []int64
+	)
+	sem := make(chan struct{}, w.concurrency)
+	for _, unit := range w.planUnits(jobs) {
+		wg.Add(1)
+		go func(unit workUnit) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, ctx.Err())
+				unfinished = append(unfinished, jobIDs(unit.jobs)...)
+				mu.Unlock()
+				return
+			}
+			fail := func(err error, remaining []OutboxJob) {
+				mu.Lock()
+				errs = append(errs, err)
+				unfinished = append(unfinished, jobIDs(remaining)...)
+				mu.Unlock()
+			}
+			if unit.batched() {
+				err := ctx.Err()
+				if err == nil {
+					err = w.processBatch(ctx, unit)
+				}
+				if err != nil {
+					// Jobs of the batch that were already finished are no
+					// longer processing, so releasing them all is safe.
+					fail(err, unit.jobs)
+				}
+				return
+			}
+			for i, job := range unit.jobs {
+				err := ctx.Err()
+				if err == nil {
+					err = w.processJob(ctx, job)
+				}
+				if err != nil {
+					fail(err, unit.jobs[i:])
+					return
+				}
+			}
+		}(unit)
+	}
+	wg.Wait()
+	stopRenewal()
+
+	if len(unfinished) > 0 {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+		defer cancel()
+		if err := w.outboxRepo.Release(releaseCtx, w.workerID, unfinished); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
+// renewLeases extends the batch's leases every third of the lease period until
+// the returned stop func is called. Finished jobs drop out of the renewal
+// query on their own since they are no longer processing.
+func (w *Worker) renewLeases(ctx context.Context, ids []int64) func() {
+	interval := w.lease / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// A failed renewal is retried on the next tick; the lease only
+				// lapses if renewals keep failing for the whole period.
+				_ = w.outboxRepo.RenewLease(ctx, w.workerID, ids, w.lease)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// orderedLanes groups jobs by ordering key, keeping claim order within each
+// lane. Jobs without a key get a lane of their own.
+func orderedLanes(jobs []OutboxJob) [][]OutboxJob {
+	lanes := make([][]OutboxJob, 0, len(jobs))
+	byKey := make(map[string]int)
 	for _, job := range jobs {
-		if err := w.processJob(ctx, job); err != nil {
-			return err
+		key := orderingKey(job)
+		if key == "" {
+			lanes = append(lanes, []OutboxJob{job})
+			continue
+		}
+		if idx, ok := byKey[key]; ok {
+			lanes[idx] = append(lanes[idx], job)
+			continue
 		}
+		byKey[key] = len(lanes)
+		lanes = append(lanes, []OutboxJob{job})
 	}
+	return lanes
+}
 
-	return nil
+func orderingKey(job OutboxJob) string {
+	var payload struct {
+		LoanID string `json:"loan_id"`
+	}
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return ""
+	}
+	return payload.LoanID
+}
+
+func jobIDs(jobs []OutboxJob) []int64 {
+	out := make([]int64, 0, len(jobs))
+	for _, job := range jobs {
+		out = append(out, job.ID)
+	}
+	return out
 }
 
 func (w *Worker) processJob(ctx context.Context, job OutboxJob) error {
@@ -85,12 +312,10 @@ func (w *Worker) processJob(ctx context.Context, job OutboxJob) error {
 		return w.processRepayment(ctx, job)
 	case defaultTopic:
 		return w.processDefault(ctx, job)
+	case poolPositionTopic:
+		return w.processPoolPosition(ctx, job)
 	default:
-		if job.Attempts >= w.maxAttempts {
-			return w.outboxRepo.MarkFailed(ctx, job.ID, "unsupported_topic")
-		}
-		next := w.now().Add(w.retryBackoff(job.Attempts))
-		return w.outboxRepo.MarkRetry(ctx, job.ID, next, "unsupported_topic")
+		return w.handleJobError(ctx, job, errUnsupported)
 	}
 }
 
@@ -103,10 +328,10 @@ type repaymentPayload struct {
 func (w *Worker) processRepayment(ctx context.Context, job OutboxJob) error {
 	var payload repaymentPayload
 	if err := json.Unmarshal(job.Payload, &payload); err != nil {
-		return w.handleJobError(ctx, job, fmt.Errorf("invalid_payload"))
+		return w.handleJobError(ctx, job, errInvalidPayload)
 	}
 	if payload.LoanID == "" || payload.AmountMinor <= 0 || len(payload.Currency) != 3 {
-		return w.handleJobError(ctx, job, errors.New("invalid_repayment_payload"))
+		return w.handleJobError(ctx, job, blockchain.Permanent(errors.New("invalid_repayment_payload")))
 	}
 	if _, err := w.writer.RecordRepayment(ctx, payload.LoanID, payload.AmountMinor, payload.Currency); err != nil {
 		return w.handleJobError(ctx, job, err)
@@ -122,10 +347,10 @@ type defaultPayload struct {
 func (w *Worker) processDefault(ctx context.Context, job OutboxJob) error {
 	var payload defaultPayload
 	if err := json.Unmarshal(job.Payload, &payload); err != nil {
-		return w.handleJobError(ctx, job, fmt.Errorf("invalid_payload"))
+		return w.handleJobError(ctx, job, errInvalidPayload)
 	}
 	if payload.LoanID == "" {
-		return w.handleJobError(ctx, job, errors.New("invalid_default_payload"))
+		return w.handleJobError(ctx, job, blockchain.Permanent(errors.New("invalid_default_payload")))
 	}
 	if _, err := w.writer.MarkDefault(ctx, payload.LoanID, payload.Reason); err != nil {
 		return w.handleJobError(ctx, job, err)
@@ -133,6 +358,47 @@ func (w *Worker) processDefault(ctx context.Context, job OutboxJob) error {
 	return w.outboxRepo.MarkDone(ctx, job.ID)
 }
 
+type poolPositionPayload struct {
+	TransactionID  string `json:"transaction_id"`
+	PoolID         string `json:"pool_id"`
+	PoolTokenAddr  string `json:"pool_token_addr"`
+	InvestorUserID string `json:"investor_user_id"`
+	Action         string `json:"action"`
+	AmountMinor    int64  `json:"amount_minor"`
+	Shares         int64  `json:"shares"`
+}
+
+func (w *Worker) processPoolPosition(ctx context.Context, job OutboxJob) error {
+	var payload poolPositionPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return w.handleJobError(ctx, job, errInvalidPayload)
+	}
+	if payload.TransactionID == "" || payload.PoolTokenAddr == "" || payload.Shares <= 0 {
+		return w.handleJobError(ctx, job, blockchain.Permanent(errors.New("invalid_pool_position_payload")))
+	}
+	tokenWriter, ok := w.writer.(blockchain.PoolTokenWriter)
+	if !ok {
+		return w.outboxRepo.MarkFailed(ctx, job.ID, "pool_token_writer_unsupported")
+	}
+	txHash, err := tokenWriter.MirrorPoolPosition(ctx, blockchain.PoolPositionAction{
+		PoolTokenAddr:  payload.PoolTokenAddr,
+		Action:         payload.Action,
+		TransactionID:  payload.TransactionID,
+		InvestorUserID: payload.InvestorUserID,
+		AmountMinor:    payload.AmountMinor,
+		Shares:         payload.Shares,
+	})
+	if err != nil {
+		return w.handleJobError(ctx, job, err)
+	}
+	if w.positionRepo != nil {
+		if err := w.positionRepo.SetTransactionSubmission(ctx, payload.TransactionID, txHash); err != nil {
+			return w.handleJobError(ctx, job, err)
+		}
+	}
+	return w.outboxRepo.MarkDone(ctx, job.ID)
+}
+
 type registerLoanPayload struct {
 	LoanID string `json:"loan_id"`
 }
@@ -140,10 +406,10 @@ type registerLoanPayload struct {
 func (w *Worker) processRegisterLoan(ctx context.Context, job OutboxJob) error {
 	var payload registerLoanPayload
 	if err := json.Unmarshal(job.Payload, &payload); err != nil {
-		return w.handleJobError(ctx, job, fmt.Errorf("invalid_payload"))
+		return w.handleJobError(ctx, job, errInvalidPayload)
 	}
 	if payload.LoanID == "" {
-		return w.handleJobError(ctx, job, errors.New("missing_loan_id"))
+		return w.handleJobError(ctx, job, blockchain.Permanent(errors.New("missing_loan_id")))
 	}
 
 	txHash, err := w.writer.RegisterLoan(ctx, payload.LoanID)
@@ -158,11 +424,25 @@ func (w *Worker) processRegisterLoan(ctx context.Context, job OutboxJob) error {
 	return w.outboxRepo.MarkDone(ctx, job.ID)
 }
 
+// handleJobError fails permanent errors immediately and schedules everything
+// else for retry under the topic's policy until its attempts run out. A
+// transaction the node already holds under a hash it did not return is failed
+// too, as a retry would send the write again; reconciliation reports the loan
+// if that transaction never lands. A write over the daily fee budget waits
+// for the next UTC day without using up an attempt.
 func (w *Worker) handleJobError(ctx context.Context, job OutboxJob, err error) error {
 	msg := err.Error()
-	if job.Attempts >= w.maxAttempts {
+	kind := blockchain.KindOf(err)
+	if kind == blockchain.KindFeeBudgetExceeded {
+		return w.outboxRepo.Defer(ctx, job.ID, blockchain.NextBudgetDay(w.now()), msg)
+	}
+	policy := w.policyFor(job.Topic)
+	if kind == blockchain.KindPermanent || kind == blockchain.KindAlreadyKnown || job.Attempts >= policy.MaxAttempts {
 		return w.outboxRepo.MarkFailed(ctx, job.ID, msg)
 	}
-	next := w.now().Add(w.retryBackoff(job.Attempts))
-	return w.outboxRepo.MarkRetry(ctx, job.ID, next, msg)
+	delay := policy.Backoff(job.Attempts, w.jitter())
+	if kind == blockchain.KindNonceTooLow || kind == blockchain.KindReplacementUnderpriced {
+		delay = nonceRetryDelay
+	}
+	return w.outboxRepo.MarkRetry(ctx, job.ID, w.now().Add(delay), msg)
 }
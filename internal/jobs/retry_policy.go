@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nonceRetryDelay is how long a job waits after its nonce was rejected as
+// taken. The next send reserves a fresh nonce, so there is no need to back off.
+const nonceRetryDelay = 2 * time.Second
+
+// RetryPolicy controls how often and how far apart a topic's jobs are retried.
+// Delays grow exponentially from BaseDelay, are capped at MaxDelay and are
+// spread by +/- Jitter (a fraction of the delay) so failed jobs do not retry
+// in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int32
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   15 * time.Second,
+		MaxDelay:    15 * time.Minute,
+		Jitter:      0.2,
+	}
+}
+
+// Backoff returns the delay before the next attempt after attempt failures.
+// r must be in [0, 1); 0.5 yields the un-jittered delay.
+func (p RetryPolicy) Backoff(attempt int32, r float64) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := p.BaseDelay
+	for i := int32(1); i < attempt && i < 32 && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration((r*2 - 1) * spread)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// ParseRetryPolicies parses per-topic overrides in the form
+// "topic=max_attempts/base_delay/max_delay,..." e.g.
+// "register_loan=8/30s/30m,mark_default=3/10s/5m". Omitted trailing fields
+// keep the fallback's values.
+func ParseRetryPolicies(spec string, fallback RetryPolicy) (map[string]RetryPolicy, error) {
+	out := make(map[string]RetryPolicy)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		topic, rest, ok := strings.Cut(item, "=")
+		topic = strings.TrimSpace(topic)
+		if !ok || topic == "" {
+			return nil, fmt.Errorf("invalid retry policy %q", item)
+		}
+		policy := fallback
+		fields := strings.Split(rest, "/")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("invalid retry policy %q", item)
+		}
+		if v := strings.TrimSpace(fields[0]); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid max attempts for %s", topic)
+			}
+			policy.MaxAttempts = int32(n)
+		}
+		if len(fields) > 1 {
+			d, err := time.ParseDuration(strings.TrimSpace(fields[1]))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid base delay for %s", topic)
+			}
+			policy.BaseDelay = d
+		}
+		if len(fields) > 2 {
+			d, err := time.ParseDuration(strings.TrimSpace(fields[2]))
+			if err != nil || d < policy.BaseDelay {
+				return nil, fmt.Errorf("invalid max delay for %s", topic)
+			}
+			policy.MaxDelay = d
+		}
+		out[topic] = policy
+	}
+	return out, nil
+}
@@ -0,0 +1,131 @@
+// Package realtime defines the channels and typed events streamed to
+// websocket clients. Producers in any process write events to the
+// realtime_events table in the transaction of the change they announce; API
+// nodes read them from there and fan them out to subscribers.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event types. The envelope sent to clients is
+// {"event": <type>, "channel": <channel>, "data": <payload>}.
+const (
+	EventRepaymentRecorded    = "repayment_recorded"
+	EventPortfolioUpdated     = "portfolio_updated"
+	EventLoanStatusChanged    = "loan_status_changed"
+	EventLoanDefaulted        = "loan_defaulted"
+	EventUploadCompleted      = "upload_completed"
+	EventPassportScoreChanged = "passport_score_changed"
+	EventOutboxJobFailed      = "outbox_job_failed"
+	EventLenderMemberRemoved  = "lender_member_removed"
+)
+
+// Loan statuses reported by EventLoanStatusChanged. A loan is registered when
+// the worker submits it to the registry and confirmed when the indexer sees
+// the registry's LoanRegistered event.
+const (
+	StatusRegistered = "registered"
+	StatusConfirmed  = "confirmed"
+	StatusRepaid     = "repaid"
+	StatusDefaulted  = "defaulted"
+)
+
+// OutboxFailuresChannel carries jobs that failed for good, for admins.
+const OutboxFailuresChannel = "admin:outbox"
+
+// LenderMembersChannel carries revoked lender memberships to the API nodes,
+// which recheck the subscriptions of the user; clients cannot subscribe to
+// it.
+const LenderMembersChannel = "internal:lender_members"
+
+func PoolRepaymentsChannel(poolID string) string    { return "pool:repayments:" + poolID }
+func LenderPortfolioChannel(lenderID string) string { return "lender:portfolio:" + lenderID }
+func LenderDefaultsChannel(lenderID string) string  { return "lender:defaults:" + lenderID }
+func LenderUploadsChannel(lenderID string) string   { return "lender:uploads:" + lenderID }
+func LoanChannel(loanID string) string              { return "loan:" + loanID }
+func PassportChannel(borrowerID string) string      { return "passport:" + borrowerID }
+
+// Event is a stored realtime event. ID orders events across all channels.
+type Event struct {
+	ID        int64
+	Channel   string
+	Type      string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+// EventPage is a batch of stored events in id order together with the
+// transaction ids bounding the snapshot it was read in: every transaction
+// below Xmin had finished, and Xmax is the next one to start.
+type EventPage struct {
+	Events []Event
+	Xmin   int64
+	Xmax   int64
+}
+
+// Publisher stores an event for every API node to deliver. data is one of
+// the payload types below.
+type Publisher interface {
+	Publish(ctx context.Context, channel, eventType string, data any) error
+}
+
+type RepaymentRecorded struct {
+	LoanID      string    `json:"loan_id"`
+	LenderID    string    `json:"lender_id"`
+	AmountMinor int64     `json:"amount_minor"`
+	Currency    string    `json:"currency"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// PortfolioUpdated tells a lender to refresh its portfolio; Source is the
+// event type that changed it.
+type PortfolioUpdated struct {
+	LenderID string `json:"lender_id"`
+	Source   string `json:"source"`
+}
+
+type LoanStatusChanged struct {
+	LoanID   string `json:"loan_id"`
+	LenderID string `json:"lender_id"`
+	Status   string `json:"status"`
+	TXHash   string `json:"tx_hash,omitempty"`
+}
+
+type LoanDefaulted struct {
+	LoanID            string `json:"loan_id"`
+	LenderID          string `json:"lender_id"`
+	BorrowerID        string `json:"borrower_id"`
+	PrincipalMinor    int64  `json:"principal_minor"`
+	AmountRepaidMinor int64  `json:"amount_repaid_minor"`
+	Currency          string `json:"currency"`
+}
+
+type UploadCompleted struct {
+	LenderID  string   `json:"lender_id"`
+	Processed int      `json:"processed"`
+	Failed    int      `json:"failed"`
+	LoanIDs   []string `json:"loan_ids"`
+}
+
+type PassportScoreChanged struct {
+	BorrowerID    string `json:"borrower_id"`
+	PreviousScore int32  `json:"previous_score"`
+	CreditScore   int32  `json:"credit_score"`
+}
+
+type OutboxJobFailed struct {
+	JobID    int64  `json:"job_id"`
+	Topic    string `json:"topic"`
+	LoanID   string `json:"loan_id,omitempty"`
+	LenderID string `json:"lender_id,omitempty"`
+	Attempts int32  `json:"attempts"`
+	Error    string `json:"error"`
+}
+
+type LenderMemberRemoved struct {
+	LenderID string `json:"lender_id"`
+	UserID   string `json:"user_id"`
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/jobs"
+)
+
+type ChainBatchRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewChainBatchRepository(pool *pgxpool.Pool) *ChainBatchRepository {
+	return &ChainBatchRepository{pool: pool}
+}
+
+func (r *ChainBatchRepository) RecordBatch(ctx context.Context, batch jobs.ChainBatch) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var batchID int64
+	q := `INSERT INTO chain_batches (lender_id, tx_hash, call_count) VALUES ($1, $2, $3) RETURNING id`
+	if err := tx.QueryRow(ctx, q, batch.LenderID, batch.TxHash, len(batch.Items)).Scan(&batchID); err != nil {
+		return err
+	}
+	q = `INSERT INTO chain_batch_items (batch_id, position, outbox_job_id, action, loan_id) VALUES ($1, $2, $3, $4, $5)`
+	for i, item := range batch.Items {
+		if _, err := tx.Exec(ctx, q, batchID, i, item.JobID, item.Action, item.LoanID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ChainFeeRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewChainFeeRepository(pool *pgxpool.Pool) *ChainFeeRepository {
+	return &ChainFeeRepository{pool: pool}
+}
+
+// ReserveSpend adds costWei to the day's reserved spend unless that would
+// exceed budgetWei, in one statement so concurrent senders cannot both pass
+// the check. A nil budget always reserves.
+func (r *ChainFeeRepository) ReserveSpend(ctx context.Context, day time.Time, costWei, budgetWei *big.Int) (bool, error) {
+	q := `
+INSERT INTO chain_fee_budget AS b (day, reserved_wei)
+SELECT $1::date, $2::numeric
+WHERE $3::numeric IS NULL OR $2::numeric <= $3::numeric
+ON CONFLICT (day)
+DO UPDATE SET reserved_wei = b.reserved_wei + EXCLUDED.reserved_wei,
+              updated_at = NOW()
+WHERE $3::numeric IS NULL OR b.reserved_wei + EXCLUDED.reserved_wei <= $3::numeric
+`
+	var budget *string
+	if budgetWei != nil {
+		s := budgetWei.String()
+		budget = &s
+	}
+	tag, err := r.pool.Exec(ctx, q, day.UTC().Format(time.DateOnly), costWei.String(), budget)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseSpend returns a reservation whose transaction was never sent.
+func (r *ChainFeeRepository) ReleaseSpend(ctx context.Context, day time.Time, costWei *big.Int) error {
+	q := `
+UPDATE chain_fee_budget
+SET reserved_wei = GREATEST(reserved_wei - $2::numeric, 0),
+    updated_at = NOW()
+WHERE day = $1::date
+`
+	_, err := r.pool.Exec(ctx, q, day.UTC().Format(time.DateOnly), costWei.String())
+	return err
+}
+
+func (r *ChainFeeRepository) RecordSpend(ctx context.Context, day time.Time, topic string, gas uint64, maxCostWei *big.Int) error {
+	q := `
+INSERT INTO chain_fee_spend (day, topic, tx_count, gas_total, max_cost_wei)
+VALUES ($1::date, $2, 1, $3::numeric, $4::numeric)
+ON CONFLICT (day, topic)
+DO UPDATE SET tx_count = chain_fee_spend.tx_count + 1,
+              gas_total = chain_fee_spend.gas_total + EXCLUDED.gas_total,
+              max_cost_wei = chain_fee_spend.max_cost_wei + EXCLUDED.max_cost_wei,
+              updated_at = NOW()
+`
+	_, err := r.pool.Exec(ctx, q, day.UTC().Format(time.DateOnly), topic, new(big.Int).SetUint64(gas).String(), maxCostWei.String())
+	return err
+}
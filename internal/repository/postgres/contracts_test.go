@@ -1,11 +1,18 @@
 package postgres
 
 import (
+	"github.com/loangraph/backend/internal/blockchain"
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
 	passportdomain "github.com/loangraph/backend/internal/domain/passport"
 	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
+	"github.com/loangraph/backend/internal/indexer"
+	"github.com/loangraph/backend/internal/realtime"
+	"github.com/loangraph/backend/internal/ws"
 )
 
 var (
@@ -14,4 +21,25 @@ var (
 	_ loandomain.Repository     = (*LoanRepository)(nil)
 	_ pooldomain.Repository     = (*PoolRepository)(nil)
 	_ passportdomain.Repository = (*PassportRepository)(nil)
+	_ positiondomain.Repository = (*PositionRepository)(nil)
+	_ ledgerdomain.Repository   = (*LedgerRepository)(nil)
+	_ outboxdomain.Repository   = (*OutboxRepository)(nil)
+	_ blockchain.NonceStore     = (*NonceRepository)(nil)
+	_ blockchain.FeeStore       = (*ChainFeeRepository)(nil)
+)
+
+var (
+	_ indexer.EventRepository          = (*IndexerRepository)(nil)
+	_ indexer.ReprojectionRepository   = (*IndexerRepository)(nil)
+	_ indexer.QuarantineRepository     = (*IndexerRepository)(nil)
+	_ indexer.ReconciliationRepository = (*IndexerRepository)(nil)
+	_ indexer.ProjectionRepository     = projectionTx{}
+)
+
+var (
+	_ ws.RealtimeRepository = (*WSRepository)(nil)
+	_ ws.ProjectionListener = (*ProjectionListener)(nil)
+	_ ws.AccessRepository   = (*WSRepository)(nil)
+	_ ws.ReplayRepository   = (*WSRepository)(nil)
+	_ realtime.Publisher    = (*WSRepository)(nil)
 )
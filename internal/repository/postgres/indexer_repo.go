@@ -2,14 +2,17 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/loangraph/backend/internal/indexer"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type IndexerRepository struct {
@@ -25,10 +28,10 @@ func (r *IndexerRepository) ListUnprocessed(ctx context.Context, limit int32) ([
 		limit = 100
 	}
 	q := `
-SELECT id, event_name, tx_hash, raw_data::text
+SELECT id, event_name, tx_hash, block_number, log_index, raw_data::text
 FROM chain_events
-WHERE processed = FALSE
-ORDER BY id
+WHERE processed = FALSE AND quarantined_at IS NULL
+ORDER BY block_number, log_index, id
 LIMIT $1
 `
 	rows, err := r.pool.Query(ctx, q, limit)
@@ -40,10 +43,13 @@ LIMIT $1
 	out := make([]indexer.ChainEvent, 0)
 	for rows.Next() {
 		var ev indexer.ChainEvent
+		var blockNumber int64
+		var logIndex int32
 		var rawText string
-		if err := rows.Scan(&ev.ID, &ev.EventName, &ev.TXHash, &rawText); err != nil {
+		if err := rows.Scan(&ev.ID, &ev.EventName, &ev.TXHash, &blockNumber, &logIndex, &rawText); err != nil {
 			return nil, err
 		}
+		ev.BlockNumber, ev.LogIndex = uint64(blockNumber), uint64(logIndex)
 		ev.RawData = []byte(rawText)
 		out = append(out, ev)
 	}
@@ -53,9 +59,119 @@ LIMIT $1
 	return out, nil
 }
 
-func (r *IndexerRepository) MarkProcessed(ctx context.Context, eventID int64) error {
-	_, err := r.pool.Exec(ctx, `UPDATE chain_events SET processed = TRUE WHERE id = $1`, eventID)
-	return err
+func (r *IndexerRepository) Project(ctx context.Context, eventID int64, apply func(indexer.ProjectionRepository) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := apply(projectionTx{tx: tx}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE chain_events SET processed = TRUE WHERE id = $1`, eventID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *IndexerRepository) RecordFailure(ctx context.Context, eventID int64, errMsg string, maxAttempts int32) (bool, error) {
+	q := `
+UPDATE chain_events
+SET attempts = attempts + 1,
+    last_error = $2,
+    quarantined_at = CASE WHEN attempts + 1 >= $3 THEN NOW() ELSE quarantined_at END
+WHERE id = $1
+RETURNING quarantined_at IS NOT NULL
+`
+	var quarantined bool
+	if err := r.pool.QueryRow(ctx, q, eventID, errMsg, maxAttempts).Scan(&quarantined); err != nil {
+		return false, err
+	}
+	return quarantined, nil
+}
+
+const chainEventColumns = `id, contract_addr::text, event_name, tx_hash::text, block_number, log_index, raw_data::text, attempts, COALESCE(last_error, ''), processed, quarantined_at, skipped_at`
+
+func scanQuarantinedEvent(row pgx.Row) (*indexer.QuarantinedEvent, error) {
+	out := &indexer.QuarantinedEvent{}
+	var blockNumber int64
+	var logIndex int32
+	var rawText string
+	if err := row.Scan(&out.ID, &out.ContractAddr, &out.EventName, &out.TXHash, &blockNumber, &logIndex, &rawText, &out.Attempts, &out.LastError, &out.Processed, &out.QuarantinedAt, &out.SkippedAt); err != nil {
+		return nil, err
+	}
+	out.BlockNumber, out.LogIndex = uint64(blockNumber), uint64(logIndex)
+	out.RawData = []byte(rawText)
+	return out, nil
+}
+
+func (r *IndexerRepository) ListQuarantined(ctx context.Context, limit, offset int32) ([]indexer.QuarantinedEvent, error) {
+	q := `SELECT ` + chainEventColumns + `
+FROM chain_events
+WHERE quarantined_at IS NOT NULL AND processed = FALSE
+ORDER BY quarantined_at, id
+LIMIT $1 OFFSET $2`
+	rows, err := r.pool.Query(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]indexer.QuarantinedEvent, 0)
+	for rows.Next() {
+		ev, err := scanQuarantinedEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *ev)
+	}
+	return out, rows.Err()
+}
+
+func (r *IndexerRepository) GetEvent(ctx context.Context, id int64) (*indexer.QuarantinedEvent, error) {
+	out, err := scanQuarantinedEvent(r.pool.QueryRow(ctx, `SELECT `+chainEventColumns+` FROM chain_events WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, indexer.ErrEventNotFound
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *IndexerRepository) Requeue(ctx context.Context, id int64, rawData json.RawMessage) (*indexer.QuarantinedEvent, error) {
+	q := `
+UPDATE chain_events
+SET raw_data = COALESCE($2::jsonb, raw_data), attempts = 0, quarantined_at = NULL
+WHERE id = $1 AND quarantined_at IS NOT NULL AND processed = FALSE
+RETURNING ` + chainEventColumns
+	var raw *string
+	if len(rawData) > 0 {
+		text := string(rawData)
+		raw = &text
+	}
+	return r.quarantineTransition(r.pool.QueryRow(ctx, q, id, raw))
+}
+
+func (r *IndexerRepository) Skip(ctx context.Context, id int64) (*indexer.QuarantinedEvent, error) {
+	q := `
+UPDATE chain_events
+SET processed = TRUE, skipped_at = NOW()
+WHERE id = $1 AND quarantined_at IS NOT NULL AND processed = FALSE
+RETURNING ` + chainEventColumns
+	return r.quarantineTransition(r.pool.QueryRow(ctx, q, id))
+}
+
+func (r *IndexerRepository) quarantineTransition(row pgx.Row) (*indexer.QuarantinedEvent, error) {
+	out, err := scanQuarantinedEvent(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, indexer.ErrEventNotQuarantined
+		}
+		return nil, err
+	}
+	return out, nil
 }
 
 func (r *IndexerRepository) GetIngestionCursor(ctx context.Context, key string) (uint64, bool, error) {
@@ -84,10 +200,19 @@ DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
 	return err
 }
 
+// DeleteIngestionCursor makes the next ingestion start from the source's
+// start block.
+func (r *IndexerRepository) DeleteIngestionCursor(ctx context.Context, key string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM app_metadata WHERE key = $1`, key)
+	return err
+}
+
+// InsertChainEvent stores an ingested event, quarantined with its decode
+// error if it did not decode.
 func (r *IndexerRepository) InsertChainEvent(ctx context.Context, ev indexer.IngestedEvent) error {
 	_, err := r.pool.Exec(ctx, `
-INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
-VALUES ($1, $2, $3, $4, $5, $6::jsonb, FALSE)
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed, last_error, quarantined_at)
+VALUES ($1, $2, $3, $4, $5, $6::jsonb, FALSE, NULLIF($7::text, ''), CASE WHEN $7::text = '' THEN NULL ELSE NOW() END)
 ON CONFLICT (tx_hash, log_index) DO NOTHING
 `,
 		ev.ContractAddr,
@@ -96,38 +221,509 @@ ON CONFLICT (tx_hash, log_index) DO NOTHING
 		int64(ev.BlockNumber),
 		int32(ev.LogIndex),
 		string(ev.RawData),
+		ev.DecodeError,
 	)
 	return err
 }
 
-func (r *IndexerRepository) ApplyLoanRegistered(ctx context.Context, loanID, txHash string) error {
-	_, err := r.pool.Exec(ctx, `UPDATE loans SET on_chain_tx = $2, on_chain_confirmed = TRUE, updated_at = NOW() WHERE id = $1`, loanID, txHash)
-	return err
+// projectionTx applies projections within one event's transaction, along
+// with the realtime events announcing them.
+type projectionTx struct {
+	tx pgx.Tx
+}
+
+func (p projectionTx) ApplyLoanRegistered(ctx context.Context, loanID, txHash string) error {
+	var lenderID string
+	q := `UPDATE loans SET on_chain_tx = $2, on_chain_confirmed = TRUE, updated_at = NOW() WHERE id = $1 RETURNING lender_id::text`
+	if err := p.tx.QueryRow(ctx, q, loanID, txHash).Scan(&lenderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	return insertRealtimeEvent(ctx, p.tx, realtime.LoanChannel(loanID), realtime.EventLoanStatusChanged, realtime.LoanStatusChanged{
+		LoanID: loanID, LenderID: lenderID, Status: realtime.StatusConfirmed, TXHash: txHash,
+	})
 }
 
-func (r *IndexerRepository) ApplyRepayment(ctx context.Context, loanID string, amountMinor int64) error {
+func (p projectionTx) ApplyRepayment(ctx context.Context, loanID string, amountMinor int64, txHash string, logIndex uint64) error {
+	tag, err := p.tx.Exec(ctx, `
+INSERT INTO chain_repayment_applications (tx_hash, log_index, loan_id, amount_minor)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (tx_hash, log_index) DO NOTHING
+`, txHash, int32(logIndex), loanID, amountMinor)
+	if err != nil || tag.RowsAffected() == 0 {
+		return err
+	}
 	q := `
-UPDATE loans
-SET amount_repaid_minor = amount_repaid_minor + $2,
-    status = CASE WHEN (amount_repaid_minor + $2) >= principal_minor THEN 'repaid' ELSE status END,
+WITH prev AS (SELECT id, status FROM loans WHERE id = $1 FOR UPDATE)
+UPDATE loans l
+SET amount_repaid_minor = l.amount_repaid_minor + $2,
+    status = CASE WHEN (l.amount_repaid_minor + $2) >= l.principal_minor THEN 'repaid' ELSE l.status END,
     updated_at = NOW()
-WHERE id = $1 AND status != 'defaulted'
+FROM prev
+WHERE l.id = prev.id AND l.status != 'defaulted'
+RETURNING l.lender_id::text, l.currency_code, prev.status, l.status, l.updated_at
 `
-	_, err := r.pool.Exec(ctx, q, loanID, amountMinor)
-	return err
+	var ev realtime.RepaymentRecorded
+	var prevStatus, status string
+	if err := p.tx.QueryRow(ctx, q, loanID, amountMinor).Scan(&ev.LenderID, &ev.Currency, &prevStatus, &status, &ev.RecordedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	ev.LoanID, ev.AmountMinor = loanID, amountMinor
+	if err := postChainRepayment(ctx, p.tx, txHash, logIndex, loanID, ev.LenderID, ev.Currency, amountMinor); err != nil {
+		return err
+	}
+
+	poolID, err := allocatedPool(ctx, p.tx, loanID)
+	if err != nil {
+		return err
+	}
+	if poolID != "" {
+		if err := insertRealtimeEvent(ctx, p.tx, realtime.PoolRepaymentsChannel(poolID), realtime.EventRepaymentRecorded, ev); err != nil {
+			return err
+		}
+	}
+	if status == "repaid" && prevStatus != "repaid" {
+		if err := insertRealtimeEvent(ctx, p.tx, realtime.LoanChannel(loanID), realtime.EventLoanStatusChanged, realtime.LoanStatusChanged{
+			LoanID: loanID, LenderID: ev.LenderID, Status: realtime.StatusRepaid, TXHash: txHash,
+		}); err != nil {
+			return err
+		}
+	}
+	return insertRealtimeEvent(ctx, p.tx, realtime.LenderPortfolioChannel(ev.LenderID), realtime.EventPortfolioUpdated, realtime.PortfolioUpdated{
+		LenderID: ev.LenderID, Source: realtime.EventRepaymentRecorded,
+	})
 }
 
-func (r *IndexerRepository) ApplyDefault(ctx context.Context, loanID string) error {
-	_, err := r.pool.Exec(ctx, `UPDATE loans SET status = 'defaulted', updated_at = NOW() WHERE id = $1`, loanID)
-	return err
+func (p projectionTx) ApplyDefault(ctx context.Context, loanID string) error {
+	q := `
+WITH prev AS (SELECT id, status FROM loans WHERE id = $1 FOR UPDATE)
+UPDATE loans l
+SET status = 'defaulted', updated_at = NOW()
+FROM prev
+WHERE l.id = prev.id
+RETURNING prev.status, l.lender_id::text, l.borrower_id::text, l.principal_minor, l.amount_repaid_minor, l.currency_code
+`
+	var prevStatus string
+	var ev realtime.LoanDefaulted
+	if err := p.tx.QueryRow(ctx, q, loanID).Scan(&prevStatus, &ev.LenderID, &ev.BorrowerID, &ev.PrincipalMinor, &ev.AmountRepaidMinor, &ev.Currency); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if prevStatus == "defaulted" {
+		return nil
+	}
+	ev.LoanID = loanID
+	if err := postWriteOff(ctx, p.tx, loanID, ev.LenderID, ev.Currency); err != nil {
+		return err
+	}
+	if err := insertRealtimeEvent(ctx, p.tx, realtime.LoanChannel(loanID), realtime.EventLoanStatusChanged, realtime.LoanStatusChanged{
+		LoanID: loanID, LenderID: ev.LenderID, Status: realtime.StatusDefaulted,
+	}); err != nil {
+		return err
+	}
+	if err := insertRealtimeEvent(ctx, p.tx, realtime.LenderDefaultsChannel(ev.LenderID), realtime.EventLoanDefaulted, ev); err != nil {
+		return err
+	}
+	return insertRealtimeEvent(ctx, p.tx, realtime.LenderPortfolioChannel(ev.LenderID), realtime.EventPortfolioUpdated, realtime.PortfolioUpdated{
+		LenderID: ev.LenderID, Source: realtime.EventLoanDefaulted,
+	})
 }
 
-func (r *IndexerRepository) RefreshPassportCacheByLoan(ctx context.Context, loanID string) error {
+func (p projectionTx) RefreshPassportCacheByLoan(ctx context.Context, loanID string) error {
 	var borrowerID string
-	if err := r.pool.QueryRow(ctx, `SELECT borrower_id FROM loans WHERE id = $1`, loanID).Scan(&borrowerID); err != nil {
+	if err := p.tx.QueryRow(ctx, `SELECT borrower_id FROM loans WHERE id = $1`, loanID).Scan(&borrowerID); err != nil {
+		return err
+	}
+	before, err := getPassportState(ctx, p.tx, borrowerID)
+	if err != nil {
 		return err
 	}
+	if err := refreshPassportCache(ctx, p.tx, borrowerID); err != nil {
+		return err
+	}
+	after, err := getPassportState(ctx, p.tx, borrowerID)
+	if err != nil || after == nil {
+		return err
+	}
+	var previous int32
+	if before != nil {
+		if before.CreditScore == after.CreditScore {
+			return nil
+		}
+		previous = before.CreditScore
+	}
+	return insertRealtimeEvent(ctx, p.tx, realtime.PassportChannel(borrowerID), realtime.EventPassportScoreChanged, realtime.PassportScoreChanged{
+		BorrowerID: borrowerID, PreviousScore: previous, CreditScore: after.CreditScore,
+	})
+}
+
+// allocatedPool returns the pool holding the loan's active allocation, or ""
+// for an unallocated loan. Only that pool's investors see its repayments.
+func allocatedPool(ctx context.Context, tx pgx.Tx, loanID string) (string, error) {
+	var poolID string
+	err := tx.QueryRow(ctx, `SELECT pool_id::text FROM pool_allocations WHERE loan_id = $1 AND released_at IS NULL`, loanID).Scan(&poolID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return poolID, err
+}
+
+// Reproject rebuilds loan status, repaid amounts and on-chain fields of every
+// loan referenced by chain_events, replaying the events in chain order, posts
+// the ledger entries those loans are missing for the replayed repayments and
+// defaults, then recomputes passport_cache for their borrowers. Quarantined and
+// skipped events are left out. chain_events is locked against ingestion and
+// projection for the duration, and every event read is marked processed. A
+// dry run reports the same diff and rolls back.
+func (r *IndexerRepository) Reproject(ctx context.Context, dryRun bool) (indexer.ReprojectReport, error) {
+	var report indexer.ReprojectReport
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE chain_events IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return report, err
+	}
+	events, err := listChainEvents(ctx, tx)
+	if err != nil {
+		return report, err
+	}
+	report.Events = len(events)
+
+	q := `
+SELECT id::text, borrower_id::text, principal_minor, amount_repaid_minor, status,
+       COALESCE(on_chain_tx::text, ''), on_chain_confirmed
+FROM loans
+WHERE id::text IN (
+  SELECT raw_data->>'loan_id' FROM chain_events
+  WHERE event_name IN ('LoanRegistered', 'RepaymentRecorded', 'LoanDefaulted')
+    AND quarantined_at IS NULL AND skipped_at IS NULL
+)
+ORDER BY id
+FOR UPDATE
+`
+	rows, err := tx.Query(ctx, q)
+	if err != nil {
+		return report, err
+	}
+	before := make(map[string]indexer.LoanState)
+	for rows.Next() {
+		var loan indexer.LoanState
+		if err := rows.Scan(&loan.LoanID, &loan.BorrowerID, &loan.PrincipalMinor, &loan.AmountRepaidMinor, &loan.Status, &loan.OnChainTX, &loan.OnChainConfirmed); err != nil {
+			rows.Close()
+			return report, err
+		}
+		before[loan.LoanID] = loan
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	rebuilt, err := indexer.ReplayLoans(events, before)
+	if err != nil {
+		return report, err
+	}
+	report.Loans = indexer.DiffLoans(before, rebuilt)
+	update := `
+UPDATE loans
+SET amount_repaid_minor = $2, status = $3, on_chain_tx = NULLIF($4, ''), on_chain_confirmed = $5, updated_at = NOW()
+WHERE id = $1
+`
+	for _, diff := range report.Loans {
+		loan := diff.After
+		if _, err := tx.Exec(ctx, update, loan.LoanID, loan.AmountRepaidMinor, loan.Status, loan.OnChainTX, loan.OnChainConfirmed); err != nil {
+			return report, err
+		}
+	}
+
+	// The replayed repayments are the applied ones from now on, so the
+	// projection treats any of them it sees again as a repeat.
+	if _, err := tx.Exec(ctx, `DELETE FROM chain_repayment_applications`); err != nil {
+		return report, err
+	}
+	applied := `
+INSERT INTO chain_repayment_applications (tx_hash, log_index, loan_id, amount_minor)
+SELECT ce.tx_hash, ce.log_index, l.id, (ce.raw_data->>'amount_minor')::bigint
+FROM chain_events ce
+JOIN loans l ON l.id::text = ce.raw_data->>'loan_id'
+WHERE ce.event_name = 'RepaymentRecorded' AND ce.quarantined_at IS NULL AND ce.skipped_at IS NULL
+`
+	if _, err := tx.Exec(ctx, applied); err != nil {
+		return report, err
+	}
+
+	posted, err := reconcileLedger(ctx, tx, events, before)
+	if err != nil {
+		return report, err
+	}
+	report.LedgerEntries = posted
+
+	borrowers := make(map[string]bool)
+	for _, loan := range rebuilt {
+		borrowers[loan.BorrowerID] = true
+	}
+	borrowerIDs := make([]string, 0, len(borrowers))
+	for id := range borrowers {
+		borrowerIDs = append(borrowerIDs, id)
+	}
+	sort.Strings(borrowerIDs)
+	for _, borrowerID := range borrowerIDs {
+		prev, err := getPassportState(ctx, tx, borrowerID)
+		if err != nil {
+			return report, err
+		}
+		if err := refreshPassportCache(ctx, tx, borrowerID); err != nil {
+			return report, err
+		}
+		next, err := getPassportState(ctx, tx, borrowerID)
+		if err != nil {
+			return report, err
+		}
+		if prev == nil || *prev != *next {
+			report.Passports = append(report.Passports, indexer.PassportDiff{Before: prev, After: *next})
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE chain_events SET processed = TRUE WHERE processed = FALSE AND quarantined_at IS NULL`); err != nil {
+		return report, err
+	}
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit(ctx)
+}
+
+// reconcileLedger posts the ledger entries of the replayed repayments and
+// defaults on loans that the ledger is missing, in chain order, and returns
+// how many it posted. The ledger is append-only, so entries posted for events
+// the replay no longer applies are left for an operator to reverse.
+func reconcileLedger(ctx context.Context, tx pgx.Tx, events []indexer.ChainEvent, loans map[string]indexer.LoanState) (int, error) {
+	applied, err := indexer.ReplayLedger(events, loans)
+	if err != nil {
+		return 0, err
+	}
+	count := func() (int, error) {
+		var n int
+		err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM ledger_entries`).Scan(&n)
+		return n, err
+	}
+	start, err := count()
+	if err != nil {
+		return 0, err
+	}
+	type owner struct{ lenderID, currencyCode string }
+	owners := make(map[string]owner)
+	for _, ev := range applied {
+		o, ok := owners[ev.LoanID]
+		if !ok {
+			if err := tx.QueryRow(ctx, `SELECT lender_id::text, currency_code FROM loans WHERE id = $1`, ev.LoanID).Scan(&o.lenderID, &o.currencyCode); err != nil {
+				return 0, err
+			}
+			owners[ev.LoanID] = o
+		}
+		if ev.AmountMinor > 0 {
+			err = postChainRepayment(ctx, tx, ev.TXHash, ev.LogIndex, ev.LoanID, o.lenderID, o.currencyCode, ev.AmountMinor)
+		} else {
+			err = postWriteOff(ctx, tx, ev.LoanID, o.lenderID, o.currencyCode)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	end, err := count()
+	return end - start, err
+}
+
+// ReconciliationSnapshot reads loans and chain events in one repeatable-read
+// transaction so the events match the loans they are compared with.
+func (r *IndexerRepository) ReconciliationSnapshot(ctx context.Context) ([]indexer.ReconcileLoan, []indexer.ChainEvent, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+SELECT l.id::text, l.borrower_id::text, l.lender_id::text, l.currency_code, l.principal_minor,
+       l.amount_repaid_minor, l.status, COALESCE(l.on_chain_tx::text, ''), l.on_chain_confirmed,
+       (SELECT COUNT(*) FROM outbox_jobs o
+        WHERE o.payload->>'loan_id' = l.id::text AND o.status IN ('pending', 'processing', 'failed'))
+FROM loans l
+ORDER BY l.id
+`
+	rows, err := tx.Query(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	loans := make([]indexer.ReconcileLoan, 0)
+	for rows.Next() {
+		var loan indexer.ReconcileLoan
+		if err := rows.Scan(&loan.LoanID, &loan.BorrowerID, &loan.LenderID, &loan.CurrencyCode, &loan.PrincipalMinor, &loan.AmountRepaidMinor, &loan.Status, &loan.OnChainTX, &loan.OnChainConfirmed, &loan.OpenJobs); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		loans = append(loans, loan)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	events, err := listChainEvents(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return loans, events, tx.Commit(ctx)
+}
+
+func (r *IndexerRepository) SaveReconciliation(ctx context.Context, run indexer.ReconciliationRun, items []indexer.Discrepancy, jobs []indexer.CorrectiveJob) (*indexer.ReconciliationRun, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+INSERT INTO chain_reconciliation_runs (block_number, loans_checked, discrepancies, jobs_enqueued, started_at, finished_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING ` + reconciliationRunColumns
+	out, err := scanReconciliationRun(tx.QueryRow(ctx, q, int64(run.BlockNumber), run.LoansChecked, run.Discrepancies, run.JobsEnqueued, run.StartedAt, run.FinishedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	insert := `
+INSERT INTO chain_reconciliation_discrepancies (run_id, loan_id, field, db_value, chain_value, events_value, in_flight, corrective_topic)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+`
+	for _, item := range items {
+		if _, err := tx.Exec(ctx, insert, out.ID, item.LoanID, item.Field, item.DBValue, item.ChainValue, item.EventsValue, item.InFlight, item.CorrectiveTopic); err != nil {
+			return nil, err
+		}
+	}
+	for _, job := range jobs {
+		if err := enqueueOutboxJob(ctx, tx, job.Topic, job.Payload); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+const reconciliationRunColumns = `id, block_number, loans_checked, discrepancies, jobs_enqueued, started_at, finished_at`
+
+func scanReconciliationRun(row pgx.Row) (*indexer.ReconciliationRun, error) {
+	out := &indexer.ReconciliationRun{}
+	var blockNumber int64
+	if err := row.Scan(&out.ID, &blockNumber, &out.LoansChecked, &out.Discrepancies, &out.JobsEnqueued, &out.StartedAt, &out.FinishedAt); err != nil {
+		return nil, err
+	}
+	out.BlockNumber = uint64(blockNumber)
+	return out, nil
+}
+
+func (r *IndexerRepository) GetReconciliationRun(ctx context.Context, id int64) (*indexer.ReconciliationRun, error) {
+	q := `SELECT ` + reconciliationRunColumns + ` FROM chain_reconciliation_runs WHERE id = $1`
+	args := []any{id}
+	if id == 0 {
+		q = `SELECT ` + reconciliationRunColumns + ` FROM chain_reconciliation_runs ORDER BY id DESC LIMIT 1`
+		args = nil
+	}
+	out, err := scanReconciliationRun(r.pool.QueryRow(ctx, q, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, indexer.ErrReconciliationRunNotFound
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *IndexerRepository) ListDiscrepancies(ctx context.Context, runID int64, limit, offset int32) ([]indexer.Discrepancy, error) {
+	q := `
+SELECT id, run_id, loan_id::text, field, db_value, chain_value, events_value, in_flight, COALESCE(corrective_topic, ''), created_at
+FROM chain_reconciliation_discrepancies
+WHERE run_id = $1
+ORDER BY id
+LIMIT $2 OFFSET $3
+`
+	rows, err := r.pool.Query(ctx, q, runID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]indexer.Discrepancy, 0)
+	for rows.Next() {
+		var item indexer.Discrepancy
+		if err := rows.Scan(&item.ID, &item.RunID, &item.LoanID, &item.Field, &item.DBValue, &item.ChainValue, &item.EventsValue, &item.InFlight, &item.CorrectiveTopic, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// listChainEvents returns the events the projection applies, in chain order.
+func listChainEvents(ctx context.Context, tx pgx.Tx) ([]indexer.ChainEvent, error) {
+	q := `
+SELECT id, event_name, tx_hash::text, block_number, log_index, raw_data::text
+FROM chain_events
+WHERE quarantined_at IS NULL AND skipped_at IS NULL
+ORDER BY block_number, log_index
+`
+	rows, err := tx.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]indexer.ChainEvent, 0)
+	for rows.Next() {
+		var ev indexer.ChainEvent
+		var blockNumber int64
+		var logIndex int32
+		var rawText string
+		if err := rows.Scan(&ev.ID, &ev.EventName, &ev.TXHash, &blockNumber, &logIndex, &rawText); err != nil {
+			return nil, err
+		}
+		ev.BlockNumber, ev.LogIndex = uint64(blockNumber), uint64(logIndex)
+		ev.RawData = []byte(rawText)
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+func getPassportState(ctx context.Context, tx pgx.Tx, borrowerID string) (*indexer.PassportState, error) {
+	q := `
+SELECT borrower_id::text, total_loans, total_repaid, total_defaulted, cumulative_borrowed, cumulative_repaid, credit_score
+FROM passport_cache
+WHERE borrower_id = $1
+`
+	var out indexer.PassportState
+	err := tx.QueryRow(ctx, q, borrowerID).Scan(&out.BorrowerID, &out.TotalLoans, &out.TotalRepaid, &out.TotalDefaulted, &out.CumulativeBorrowed, &out.CumulativeRepaid, &out.CreditScore)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &out, nil
+}
 
+func refreshPassportCache(ctx context.Context, tx pgx.Tx, borrowerID string) error {
 	var totalLoans, totalRepaid, totalDefaulted int32
 	var cumulativeBorrowed, cumulativeRepaid int64
 	q := `
@@ -140,7 +736,7 @@ SELECT
 FROM loans
 WHERE borrower_id = $1
 `
-	if err := r.pool.QueryRow(ctx, q, borrowerID).Scan(&totalLoans, &totalRepaid, &totalDefaulted, &cumulativeBorrowed, &cumulativeRepaid); err != nil {
+	if err := tx.QueryRow(ctx, q, borrowerID).Scan(&totalLoans, &totalRepaid, &totalDefaulted, &cumulativeBorrowed, &cumulativeRepaid); err != nil {
 		return err
 	}
 
@@ -160,7 +756,7 @@ DO UPDATE SET
   credit_score = EXCLUDED.credit_score,
   last_updated = NOW()
 `
-	_, err := r.pool.Exec(ctx, upsert, borrowerID, totalLoans, totalRepaid, totalDefaulted, cumulativeBorrowed, cumulativeRepaid, score)
+	_, err := tx.Exec(ctx, upsert, borrowerID, totalLoans, totalRepaid, totalDefaulted, cumulativeBorrowed, cumulativeRepaid, score)
 	return err
 }
 
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/domain/ledger"
+)
+
+type LedgerRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLedgerRepository(pool *pgxpool.Pool) *LedgerRepository {
+	return &LedgerRepository{pool: pool}
+}
+
+// postLedgerEntry writes a journal entry inside the caller's transaction so
+// the ledger commits or rolls back together with the state change it records.
+// Entries already posted under the same idempotency key are skipped.
+func postLedgerEntry(ctx context.Context, tx pgx.Tx, in ledger.EntryInput) error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	q := `
+INSERT INTO ledger_entries (kind, idempotency_key, reference_type, reference_id)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (idempotency_key) DO NOTHING
+RETURNING id
+`
+	var entryID string
+	if err := tx.QueryRow(ctx, q, in.Kind, in.IdempotencyKey, in.ReferenceType, in.ReferenceID).Scan(&entryID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	accountQ := `
+INSERT INTO ledger_accounts (kind, owner_id, currency_code)
+VALUES ($1, $2, $3)
+ON CONFLICT (kind, owner_id, currency_code) DO UPDATE SET kind = EXCLUDED.kind
+RETURNING id
+`
+	for _, p := range in.Postings {
+		var accountID string
+		if err := tx.QueryRow(ctx, accountQ, p.Account.Kind, p.Account.OwnerID, p.Account.CurrencyCode).Scan(&accountID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO ledger_postings (entry_id, account_id, debit_minor, credit_minor) VALUES ($1, $2, $3, $4)`,
+			entryID, accountID, p.DebitMinor, p.CreditMinor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receivableBalance is the principal still owed on a loan according to the
+// ledger.
+func receivableBalance(ctx context.Context, tx pgx.Tx, loanID string) (int64, error) {
+	q := `
+SELECT COALESCE(SUM(p.debit_minor - p.credit_minor), 0)::bigint
+FROM ledger_postings p
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE a.kind = 'borrower_receivable' AND a.owner_id = $1
+`
+	var out int64
+	err := tx.QueryRow(ctx, q, loanID).Scan(&out)
+	return out, err
+}
+
+// postWriteOff writes off what the ledger still holds as receivable on a
+// loan. Loans with nothing outstanding post no entry.
+func postWriteOff(ctx context.Context, tx pgx.Tx, loanID, lenderID, currencyCode string) error {
+	outstanding, err := receivableBalance(ctx, tx, loanID)
+	if err != nil || outstanding <= 0 {
+		return err
+	}
+	return postLedgerEntry(ctx, tx, ledger.WriteOff(loanID, lenderID, currencyCode, outstanding))
+}
+
+// postChainRepayment posts the entry of a repayment the projection applied
+// from the log at txHash and logIndex.
+func postChainRepayment(ctx context.Context, tx pgx.Tx, txHash string, logIndex uint64, loanID, lenderID, currencyCode string, amountMinor int64) error {
+	outstanding, err := receivableBalance(ctx, tx, loanID)
+	if err != nil {
+		return err
+	}
+	return postLedgerEntry(ctx, tx, ledger.ChainRepayment(txHash, logIndex, loanID, lenderID, currencyCode, amountMinor, outstanding))
+}
+
+func (r *LedgerRepository) GetBalance(ctx context.Context, account ledger.Account) (*ledger.Balance, error) {
+	q := `
+SELECT COALESCE(SUM(p.debit_minor), 0)::bigint, COALESCE(SUM(p.credit_minor), 0)::bigint
+FROM ledger_accounts a
+JOIN ledger_postings p ON p.account_id = a.id
+WHERE a.kind = $1 AND a.owner_id = $2 AND a.currency_code = $3
+`
+	out := &ledger.Balance{Kind: account.Kind, OwnerID: account.OwnerID, CurrencyCode: account.CurrencyCode}
+	if err := r.pool.QueryRow(ctx, q, account.Kind, account.OwnerID, account.CurrencyCode).Scan(&out.DebitMinor, &out.CreditMinor); err != nil {
+		return nil, err
+	}
+	out.BalanceMinor = ledger.NormalBalance(account.Kind, out.DebitMinor, out.CreditMinor)
+	return out, nil
+}
+
+func (r *LedgerRepository) ListAccountEntries(ctx context.Context, account ledger.Account, limit, offset int32) ([]ledger.EntryLine, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	q := `
+SELECT e.id, e.kind, e.reference_type, e.reference_id, p.debit_minor, p.credit_minor, e.created_at
+FROM ledger_accounts a
+JOIN ledger_postings p ON p.account_id = a.id
+JOIN ledger_entries e ON e.id = p.entry_id
+WHERE a.kind = $1 AND a.owner_id = $2 AND a.currency_code = $3
+ORDER BY e.created_at DESC, p.id DESC
+LIMIT $4 OFFSET $5
+`
+	rows, err := r.pool.Query(ctx, q, account.Kind, account.OwnerID, account.CurrencyCode, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ledger.EntryLine, 0)
+	for rows.Next() {
+		var item ledger.EntryLine
+		if err := rows.Scan(&item.EntryID, &item.Kind, &item.ReferenceType, &item.ReferenceID, &item.DebitMinor, &item.CreditMinor, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) GetLenderBalances(ctx context.Context, lenderID string) ([]ledger.LenderBalances, error) {
+	q := `
+SELECT
+  a.currency_code,
+  COALESCE(SUM(p.debit_minor) FILTER (WHERE a.kind = 'borrower_receivable' AND e.kind = 'disbursement'), 0)::bigint,
+  COALESCE(SUM(p.credit_minor) FILTER (WHERE a.kind = 'borrower_receivable' AND e.kind = 'repayment'), 0)::bigint,
+  COALESCE(SUM(p.credit_minor - p.debit_minor) FILTER (WHERE a.kind = 'interest_income'), 0)::bigint,
+  COALESCE(SUM(p.debit_minor - p.credit_minor) FILTER (WHERE a.kind = 'write_off'), 0)::bigint,
+  COALESCE(SUM(p.debit_minor - p.credit_minor) FILTER (WHERE a.kind = 'borrower_receivable'), 0)::bigint,
+  COALESCE(SUM(p.debit_minor - p.credit_minor) FILTER (WHERE a.kind = 'lender_loan_book' AND e.kind IN ('pool_allocation', 'pool_release')), 0)::bigint
+FROM ledger_postings p
+JOIN ledger_entries e ON e.id = p.entry_id
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE (a.kind IN ('lender_loan_book', 'interest_income', 'write_off') AND a.owner_id = $1)
+   OR (a.kind = 'borrower_receivable' AND a.owner_id IN (SELECT id::text FROM loans WHERE lender_id = $2))
+GROUP BY a.currency_code
+ORDER BY a.currency_code
+`
+	rows, err := r.pool.Query(ctx, q, lenderID, lenderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ledger.LenderBalances, 0)
+	for rows.Next() {
+		item := ledger.LenderBalances{LenderID: lenderID}
+		if err := rows.Scan(
+			&item.CurrencyCode,
+			&item.PrincipalDisbursedMinor,
+			&item.PrincipalRepaidMinor,
+			&item.InterestCollectedMinor,
+			&item.WrittenOffMinor,
+			&item.OutstandingMinor,
+			&item.PoolFundedMinor,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *LedgerRepository) GetPoolBalances(ctx context.Context, poolID string) ([]ledger.PoolBalances, error) {
+	q := `
+SELECT
+  a.currency_code,
+  COALESCE(SUM(p.debit_minor - p.credit_minor) FILTER (WHERE a.kind = 'pool_cash'), 0)::bigint,
+  COALESCE(SUM(p.credit_minor - p.debit_minor) FILTER (WHERE a.kind = 'pool_cash' AND e.kind IN ('pool_allocation', 'pool_release')), 0)::bigint,
+  COALESCE(SUM(p.credit_minor - p.debit_minor) FILTER (WHERE a.kind = 'investor_equity'), 0)::bigint
+FROM ledger_postings p
+JOIN ledger_entries e ON e.id = p.entry_id
+JOIN ledger_accounts a ON a.id = p.account_id
+WHERE (a.kind = 'pool_cash' AND a.owner_id = $1)
+   OR (a.kind = 'investor_equity' AND a.owner_id LIKE $1 || ':%')
+GROUP BY a.currency_code
+ORDER BY a.currency_code
+`
+	rows, err := r.pool.Query(ctx, q, poolID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ledger.PoolBalances, 0)
+	for rows.Next() {
+		item := ledger.PoolBalances{PoolID: poolID}
+		if err := rows.Scan(&item.CurrencyCode, &item.CashMinor, &item.DeployedMinor, &item.InvestorEquityMinor); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"errors"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/loangraph/backend/internal/domain/lender"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type LenderRepository struct {
@@ -31,11 +34,17 @@ RETURNING id, name, country_code, wallet_address, kyc_status, tier, created_at,
 }
 
 func (r *LenderRepository) GetByID(ctx context.Context, id string) (*lender.Entity, error) {
+	if !isUUID(id) {
+		return nil, lender.ErrNotFound
+	}
 	q := `SELECT id, name, country_code, wallet_address, kyc_status, tier, created_at, updated_at FROM lenders WHERE id = $1`
 	out := &lender.Entity{}
 	err := r.pool.QueryRow(ctx, q, id).
 		Scan(&out.ID, &out.Name, &out.CountryCode, &out.WalletAddress, &out.KYCStatus, &out.Tier, &out.CreatedAt, &out.UpdatedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, lender.ErrNotFound
+		}
 		return nil, err
 	}
 	return out, nil
@@ -57,3 +66,49 @@ func (r *LenderRepository) UpdateKYCStatus(ctx context.Context, lenderID, kycSta
 	_, err := r.pool.Exec(ctx, q, lenderID, kycStatus)
 	return err
 }
+
+// AddMember lets userID act for lenderID; adding an existing member is a
+// no-op.
+func (r *LenderRepository) AddMember(ctx context.Context, lenderID, userID string) error {
+	q := `INSERT INTO lender_members (lender_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := r.pool.Exec(ctx, q, lenderID, userID)
+	return err
+}
+
+// RemoveMember revokes userID's membership of lenderID and, in the same
+// transaction, tells the API nodes to drop the user's subscriptions to the
+// lender's channels.
+func (r *LenderRepository) RemoveMember(ctx context.Context, lenderID, userID string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM lender_members WHERE lender_id = $1 AND user_id = $2`, lenderID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	if err := insertRealtimeEvent(ctx, tx, realtime.LenderMembersChannel, realtime.EventLenderMemberRemoved, realtime.LenderMemberRemoved{
+		LenderID: lenderID,
+		UserID:   userID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *LenderRepository) IsMember(ctx context.Context, lenderID, userID string) (bool, error) {
+	if !isUUID(lenderID) || !isUUID(userID) {
+		return false, nil
+	}
+	var ok bool
+	q := `SELECT EXISTS (SELECT 1 FROM lender_members WHERE lender_id = $1 AND user_id = $2)`
+	if err := r.pool.QueryRow(ctx, q, lenderID, userID).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
@@ -2,11 +2,15 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/domain/ledger"
 	"github.com/loangraph/backend/internal/domain/loan"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type LoanRepository struct {
@@ -27,8 +31,14 @@ RETURNING id, loan_hash, lender_id, borrower_id, principal_minor, currency_code,
           interest_rate_bps, start_date, maturity_date, amount_repaid_minor,
           status, on_chain_tx, on_chain_confirmed, risk_grade, metadata, created_at, updated_at
 `
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
 	out := &loan.Entity{}
-	err := r.pool.QueryRow(ctx, q,
+	err = tx.QueryRow(ctx, q,
 		in.LoanHash, in.LenderID, in.BorrowerID, in.PrincipalMinor, in.CurrencyCode,
 		in.InterestRateBPS, in.StartDate, in.MaturityDate, in.RiskGrade, in.Metadata,
 	).Scan(
@@ -39,10 +49,21 @@ RETURNING id, loan_hash, lender_id, borrower_id, principal_minor, currency_code,
 	if err != nil {
 		return nil, err
 	}
+	if out.PrincipalMinor > 0 {
+		if err := postLedgerEntry(ctx, tx, ledger.Disbursement(out.ID, out.LenderID, out.CurrencyCode, out.PrincipalMinor)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
 func (r *LoanRepository) GetByID(ctx context.Context, id string) (*loan.Entity, error) {
+	if !isUUID(id) {
+		return nil, loan.ErrNotFound
+	}
 	q := `
 SELECT id, loan_hash, lender_id, borrower_id, principal_minor, currency_code,
        interest_rate_bps, start_date, maturity_date, amount_repaid_minor,
@@ -56,6 +77,9 @@ FROM loans WHERE id = $1
 		&out.Status, &out.OnChainTX, &out.OnChainConfirmed, &out.RiskGrade, &out.Metadata, &out.CreatedAt, &out.UpdatedAt,
 	)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, loan.ErrNotFound
+		}
 		return nil, err
 	}
 	return out, nil
@@ -80,6 +104,16 @@ FROM loans WHERE loan_hash = $1
 	return out, nil
 }
 
+var loanSortColumns = map[string]struct {
+	expr string
+	cast string
+}{
+	loan.SortCreatedAt:   {expr: "created_at", cast: "timestamptz"},
+	loan.SortMaturity:    {expr: "maturity_date", cast: "timestamptz"},
+	loan.SortPrincipal:   {expr: "principal_minor", cast: "bigint"},
+	loan.SortOutstanding: {expr: "(principal_minor - amount_repaid_minor)", cast: "bigint"},
+}
+
 func (r *LoanRepository) List(ctx context.Context, f loan.ListFilter) ([]loan.Entity, error) {
 	if f.Limit <= 0 {
 		f.Limit = 50
@@ -87,6 +121,16 @@ func (r *LoanRepository) List(ctx context.Context, f loan.ListFilter) ([]loan.En
 	if f.Offset < 0 {
 		f.Offset = 0
 	}
+	sortCol, ok := loanSortColumns[f.SortBy]
+	if !ok {
+		sortCol = loanSortColumns[loan.SortCreatedAt]
+	}
+	direction := "DESC"
+	comparator := "<"
+	if f.SortOrder == loan.SortAsc {
+		direction = "ASC"
+		comparator = ">"
+	}
 
 	builder := strings.Builder{}
 	builder.WriteString(`
@@ -98,32 +142,72 @@ WHERE 1=1`)
 
 	args := []any{}
 	argPos := 1
-	if strings.TrimSpace(f.LenderID) != "" {
-		builder.WriteString(" AND lender_id = $")
-		builder.WriteString(strconv.Itoa(argPos))
-		args = append(args, f.LenderID)
+	addCond := func(cond string, value any) {
+		builder.WriteString(" AND ")
+		builder.WriteString(strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(argPos)))
+		args = append(args, value)
 		argPos++
 	}
+	if strings.TrimSpace(f.LenderID) != "" {
+		addCond("lender_id = ?", f.LenderID)
+	}
+	if strings.TrimSpace(f.BorrowerID) != "" {
+		addCond("borrower_id = ?", f.BorrowerID)
+	}
 	if strings.TrimSpace(f.Status) != "" {
-		builder.WriteString(" AND status = $")
-		builder.WriteString(strconv.Itoa(argPos))
-		args = append(args, f.Status)
-		argPos++
+		addCond("status = ?", f.Status)
 	}
 	if strings.TrimSpace(f.RiskGrade) != "" {
-		builder.WriteString(" AND risk_grade = $")
+		addCond("risk_grade = ?", f.RiskGrade)
+	}
+	if strings.TrimSpace(f.CurrencyCode) != "" {
+		addCond("currency_code = ?", f.CurrencyCode)
+	}
+	if strings.TrimSpace(f.LoanReference) != "" {
+		addCond("metadata->>'loan_reference' = ?", strings.TrimSpace(f.LoanReference))
+	}
+	if f.MaturityFrom != nil {
+		addCond("maturity_date >= ?", *f.MaturityFrom)
+	}
+	if f.MaturityTo != nil {
+		addCond("maturity_date <= ?", *f.MaturityTo)
+	}
+	if f.PrincipalMin > 0 {
+		addCond("principal_minor >= ?", f.PrincipalMin)
+	}
+	if f.PrincipalMax > 0 {
+		addCond("principal_minor <= ?", f.PrincipalMax)
+	}
+	if f.After != nil {
+		builder.WriteString(" AND (")
+		builder.WriteString(sortCol.expr)
+		builder.WriteString(", id) ")
+		builder.WriteString(comparator)
+		builder.WriteString(" ($")
 		builder.WriteString(strconv.Itoa(argPos))
-		args = append(args, f.RiskGrade)
-		argPos++
+		builder.WriteString("::")
+		builder.WriteString(sortCol.cast)
+		builder.WriteString(", $")
+		builder.WriteString(strconv.Itoa(argPos + 1))
+		builder.WriteString("::uuid)")
+		args = append(args, f.After.Value, f.After.ID)
+		argPos += 2
 	}
-	builder.WriteString(" ORDER BY created_at DESC")
+	builder.WriteString(" ORDER BY ")
+	builder.WriteString(sortCol.expr)
+	builder.WriteString(" ")
+	builder.WriteString(direction)
+	builder.WriteString(", id ")
+	builder.WriteString(direction)
 	builder.WriteString(" LIMIT $")
 	builder.WriteString(strconv.Itoa(argPos))
 	args = append(args, f.Limit)
 	argPos++
-	builder.WriteString(" OFFSET $")
-	builder.WriteString(strconv.Itoa(argPos))
-	args = append(args, f.Offset)
+	if f.After == nil {
+		builder.WriteString(" OFFSET $")
+		builder.WriteString(strconv.Itoa(argPos))
+		args = append(args, f.Offset)
+	}
 
 	rows, err := r.pool.Query(ctx, builder.String(), args...)
 	if err != nil {
@@ -149,28 +233,92 @@ WHERE 1=1`)
 	return out, nil
 }
 
+// SetOnChainSubmission records the registry transaction of a loan and
+// announces it as registered, or confirmed when the receipt is already final.
 func (r *LoanRepository) SetOnChainSubmission(ctx context.Context, loanID, txHash string, confirmed bool) error {
-	q := `UPDATE loans SET on_chain_tx = $2, on_chain_confirmed = $3, updated_at = NOW() WHERE id = $1`
-	_, err := r.pool.Exec(ctx, q, loanID, txHash, confirmed)
-	return err
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var lenderID string
+	q := `UPDATE loans SET on_chain_tx = $2, on_chain_confirmed = $3, updated_at = NOW() WHERE id = $1 RETURNING lender_id::text`
+	if err := tx.QueryRow(ctx, q, loanID, txHash, confirmed).Scan(&lenderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	status := realtime.StatusRegistered
+	if confirmed {
+		status = realtime.StatusConfirmed
+	}
+	if err := insertRealtimeEvent(ctx, tx, realtime.LoanChannel(loanID), realtime.EventLoanStatusChanged, realtime.LoanStatusChanged{
+		LoanID: loanID, LenderID: lenderID, Status: status, TXHash: txHash,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *LoanRepository) RecordRepayment(ctx context.Context, loanID string, amountMinor int64) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	q := `
 UPDATE loans
 SET amount_repaid_minor = amount_repaid_minor + $2,
     status = CASE WHEN (amount_repaid_minor + $2) >= principal_minor THEN 'repaid' ELSE status END,
     updated_at = NOW()
 WHERE id = $1 AND status != 'defaulted'
+RETURNING lender_id, currency_code
 `
-	_, err := r.pool.Exec(ctx, q, loanID, amountMinor)
-	return err
+	var lenderID, currencyCode string
+	if err := tx.QueryRow(ctx, q, loanID, amountMinor).Scan(&lenderID, &currencyCode); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	var repaymentID string
+	if err := tx.QueryRow(ctx, `INSERT INTO repayments (loan_id, amount_minor) VALUES ($1, $2) RETURNING id`, loanID, amountMinor).Scan(&repaymentID); err != nil {
+		return err
+	}
+	// The row lock taken by the update above serializes repayments per loan,
+	// so the receivable read here already includes earlier repayments.
+	outstanding, err := receivableBalance(ctx, tx, loanID)
+	if err != nil {
+		return err
+	}
+	if err := postLedgerEntry(ctx, tx, ledger.Repayment(repaymentID, loanID, lenderID, currencyCode, amountMinor, outstanding)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *LoanRepository) MarkDefault(ctx context.Context, loanID string) error {
-	q := `UPDATE loans SET status = 'defaulted', updated_at = NOW() WHERE id = $1 AND status = 'active'`
-	_, err := r.pool.Exec(ctx, q, loanID)
-	return err
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `UPDATE loans SET status = 'defaulted', updated_at = NOW() WHERE id = $1 AND status = 'active' RETURNING lender_id, currency_code`
+	var lenderID, currencyCode string
+	if err := tx.QueryRow(ctx, q, loanID).Scan(&lenderID, &currencyCode); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if err := postWriteOff(ctx, tx, loanID, lenderID, currencyCode); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *LoanRepository) GetPortfolioAnalytics(ctx context.Context, lenderID string) (*loan.PortfolioAnalytics, error) {
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+type NonceRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewNonceRepository(pool *pgxpool.Pool) *NonceRepository {
+	return &NonceRepository{pool: pool}
+}
+
+func (r *NonceRepository) Reserve(ctx context.Context, address string, chainPending uint64, staleBefore time.Time) (uint64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	pending := int64(chainPending)
+	if _, err := tx.Exec(ctx, `INSERT INTO chain_nonces (address, next_nonce) VALUES ($1, $2) ON CONFLICT (address) DO NOTHING`, address, pending); err != nil {
+		return 0, err
+	}
+	// Serializes reservations per sender across workers and replicas.
+	var next int64
+	if err := tx.QueryRow(ctx, `SELECT next_nonce FROM chain_nonces WHERE address = $1 FOR UPDATE`, address).Scan(&next); err != nil {
+		return 0, err
+	}
+	if pending > next {
+		next = pending
+	}
+	// Gaps below the chain's pending count were filled outside this store.
+	q := `
+UPDATE chain_nonce_reservations
+SET status = 'used', updated_at = NOW()
+WHERE address = $1 AND nonce < $2 AND status IN ('reserved', 'released')
+`
+	if _, err := tx.Exec(ctx, q, address, pending); err != nil {
+		return 0, err
+	}
+
+	// Reuse the lowest gap: a released nonce, or one whose reserving worker
+	// never reported back.
+	q = `
+SELECT nonce
+FROM chain_nonce_reservations
+WHERE address = $1 AND nonce >= $2
+  AND (status = 'released' OR (status = 'reserved' AND updated_at < $3))
+ORDER BY nonce
+LIMIT 1
+`
+	var nonce int64
+	err = tx.QueryRow(ctx, q, address, pending, staleBefore).Scan(&nonce)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgx.ErrNoRows):
+		nonce = next
+		next++
+	default:
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE chain_nonces SET next_nonce = $2, updated_at = NOW() WHERE address = $1`, address, next); err != nil {
+		return 0, err
+	}
+	q = `
+INSERT INTO chain_nonce_reservations (address, nonce, status)
+VALUES ($1, $2, 'reserved')
+ON CONFLICT (address, nonce)
+DO UPDATE SET status = 'reserved', tx_hash = NULL, updated_at = NOW()
+`
+	if _, err := tx.Exec(ctx, q, address, nonce); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return uint64(nonce), nil
+}
+
+func (r *NonceRepository) MarkSubmitted(ctx context.Context, address string, nonce uint64, txHash string, in blockchain.PendingTx, replacement bool) error {
+	q := `
+UPDATE chain_nonce_reservations
+SET status = 'submitted', tx_hash = $3, to_address = $4, data = $5, gas = $6, gas_price_wei = $7::numeric,
+    max_fee_per_gas_wei = $8::numeric, max_priority_fee_wei = $9::numeric,
+    replacements = replacements + CASE WHEN $10 THEN 1 ELSE 0 END, updated_at = NOW()
+WHERE address = $1 AND nonce = $2
+`
+	_, err := r.pool.Exec(ctx, q, address, int64(nonce), txHash, in.To, in.Data, int64(in.Gas),
+		weiParam(in.GasPriceWei), weiParam(in.MaxFeePerGasWei), weiParam(in.MaxPriorityFeeWei), replacement)
+	return err
+}
+
+func (r *NonceRepository) MarkUsed(ctx context.Context, address string, nonce uint64) error {
+	q := `UPDATE chain_nonce_reservations SET status = 'used', updated_at = NOW() WHERE address = $1 AND nonce = $2`
+	_, err := r.pool.Exec(ctx, q, address, int64(nonce))
+	return err
+}
+
+func (r *NonceRepository) Release(ctx context.Context, address string, nonce uint64) error {
+	q := `UPDATE chain_nonce_reservations SET status = 'released', updated_at = NOW() WHERE address = $1 AND nonce = $2 AND status = 'reserved'`
+	_, err := r.pool.Exec(ctx, q, address, int64(nonce))
+	return err
+}
+
+func (r *NonceRepository) Confirm(ctx context.Context, address string, chainLatest uint64) error {
+	q := `
+UPDATE chain_nonce_reservations
+SET status = 'used', updated_at = NOW()
+WHERE address = $1 AND nonce < $2 AND status IN ('submitted', 'released')
+`
+	_, err := r.pool.Exec(ctx, q, address, int64(chainLatest))
+	return err
+}
+
+func (r *NonceRepository) ListStuck(ctx context.Context, address string, submittedBefore time.Time) ([]blockchain.NonceReservation, error) {
+	q := `
+SELECT address, nonce, status, COALESCE(tx_hash, ''), COALESCE(to_address, ''), COALESCE(data, ''), COALESCE(gas, 0),
+       COALESCE(gas_price_wei, 0)::text, max_fee_per_gas_wei::text, max_priority_fee_wei::text, replacements, updated_at
+FROM chain_nonce_reservations
+WHERE address = $1 AND status = 'submitted' AND updated_at < $2
+ORDER BY nonce
+`
+	rows, err := r.pool.Query(ctx, q, address, submittedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]blockchain.NonceReservation, 0)
+	for rows.Next() {
+		var item blockchain.NonceReservation
+		var nonce, gas int64
+		var gasPrice string
+		var maxFee, priorityFee *string
+		if err := rows.Scan(&item.Address, &nonce, &item.Status, &item.TxHash, &item.Tx.To, &item.Tx.Data, &gas, &gasPrice, &maxFee, &priorityFee, &item.Replacements, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		item.Nonce = uint64(nonce)
+		item.Tx.Gas = uint64(gas)
+		item.Tx.GasPriceWei, _ = new(big.Int).SetString(gasPrice, 10)
+		if maxFee != nil && priorityFee != nil {
+			item.Tx.MaxFeePerGasWei, _ = new(big.Int).SetString(*maxFee, 10)
+			item.Tx.MaxPriorityFeeWei, _ = new(big.Int).SetString(*priorityFee, 10)
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func weiParam(v *big.Int) *string {
+	if v == nil {
+		return nil
+	}
+	out := v.String()
+	return &out
+}
@@ -2,10 +2,17 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/domain/outbox"
 	"github.com/loangraph/backend/internal/jobs"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type OutboxRepository struct {
@@ -16,32 +23,53 @@ func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
 	return &OutboxRepository{pool: pool}
 }
 
+const enqueueOutboxJobSQL = `INSERT INTO outbox_jobs (topic, payload, status) VALUES ($1, $2::jsonb, 'pending')`
+
 func (r *OutboxRepository) Enqueue(ctx context.Context, topic string, payload []byte) error {
-	q := `INSERT INTO outbox_jobs (topic, payload, status) VALUES ($1, $2::jsonb, 'pending')`
-	_, err := r.pool.Exec(ctx, q, topic, payload)
+	_, err := r.pool.Exec(ctx, enqueueOutboxJobSQL, topic, payload)
+	return err
+}
+
+// enqueueOutboxJob adds a job in tx, so it is only sent if the change it
+// mirrors commits.
+func enqueueOutboxJob(ctx context.Context, tx pgx.Tx, topic string, payload []byte) error {
+	_, err := tx.Exec(ctx, enqueueOutboxJobSQL, topic, payload)
 	return err
 }
 
-func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int32) ([]jobs.OutboxJob, error) {
+// ClaimPending leases up to limit jobs to workerID until NOW() + lease. The
+// worker renews the lease while it runs; ReapExpiredLeases returns jobs whose
+// worker stopped renewing.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, workerID string, lease time.Duration, limit int32) ([]jobs.OutboxJob, error) {
 	if limit <= 0 {
 		limit = 20
 	}
+	// A job is only claimable once every earlier job for the same loan has
+	// finished, so register -> repay -> default never run out of order even
+	// when an earlier job is waiting out a retry backoff. A failed job holds
+	// its loan's later jobs until an operator retries or cancels it.
 	q := `
 WITH claimed AS (
   SELECT id
-  FROM outbox_jobs
+  FROM outbox_jobs o
   WHERE status = 'pending' AND available_at <= NOW()
+    AND NOT EXISTS (
+      SELECT 1 FROM outbox_jobs prev
+      WHERE prev.payload->>'loan_id' = o.payload->>'loan_id'
+        AND prev.id < o.id
+        AND prev.status IN ('pending', 'processing', 'failed')
+    )
   ORDER BY id
   LIMIT $1
   FOR UPDATE SKIP LOCKED
 )
 UPDATE outbox_jobs j
-SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+SET status = 'processing', attempts = attempts + 1, locked_by = $2, locked_until = NOW() + $3 * INTERVAL '1 millisecond', updated_at = NOW()
 FROM claimed
 WHERE j.id = claimed.id
 RETURNING j.id, j.topic, j.payload::text, j.status, j.attempts, COALESCE(j.last_error, ''), j.available_at
 `
-	rows, err := r.pool.Query(ctx, q, limit)
+	rows, err := r.pool.Query(ctx, q, limit, workerID, lease.Milliseconds())
 	if err != nil {
 		return nil, err
 	}
@@ -64,19 +92,271 @@ RETURNING j.id, j.topic, j.payload::text, j.status, j.attempts, COALESCE(j.last_
 }
 
 func (r *OutboxRepository) MarkDone(ctx context.Context, jobID int64) error {
-	q := `UPDATE outbox_jobs SET status = 'done', updated_at = NOW(), last_error = NULL WHERE id = $1`
+	q := `UPDATE outbox_jobs SET status = 'done', updated_at = NOW(), last_error = NULL, locked_by = NULL, locked_until = NULL WHERE id = $1`
 	_, err := r.pool.Exec(ctx, q, jobID)
 	return err
 }
 
 func (r *OutboxRepository) MarkRetry(ctx context.Context, jobID int64, nextAvailableAt time.Time, lastError string) error {
-	q := `UPDATE outbox_jobs SET status = 'pending', available_at = $2, last_error = $3, updated_at = NOW() WHERE id = $1`
+	q := `UPDATE outbox_jobs SET status = 'pending', available_at = $2, last_error = $3, locked_by = NULL, locked_until = NULL, updated_at = NOW() WHERE id = $1`
 	_, err := r.pool.Exec(ctx, q, jobID, nextAvailableAt, lastError)
 	return err
 }
 
-func (r *OutboxRepository) MarkFailed(ctx context.Context, jobID int64, lastError string) error {
-	q := `UPDATE outbox_jobs SET status = 'failed', last_error = $2, updated_at = NOW() WHERE id = $1`
-	_, err := r.pool.Exec(ctx, q, jobID, lastError)
+// Defer returns a job to the queue until the given time without counting
+// the claim as an attempt, for work held back by something other than the
+// job itself.
+func (r *OutboxRepository) Defer(ctx context.Context, jobID int64, until time.Time, lastError string) error {
+	q := `
+UPDATE outbox_jobs
+SET status = 'pending', attempts = GREATEST(attempts - 1, 0), available_at = $2, last_error = $3,
+    locked_by = NULL, locked_until = NULL, updated_at = NOW()
+WHERE id = $1
+`
+	_, err := r.pool.Exec(ctx, q, jobID, until, lastError)
+	return err
+}
+
+// Release returns claimed jobs that were never finished to the queue without
+// counting the claim as an attempt.
+func (r *OutboxRepository) Release(ctx context.Context, workerID string, jobIDs []int64) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+	q := `
+UPDATE outbox_jobs
+SET status = 'pending', attempts = GREATEST(attempts - 1, 0), locked_by = NULL, locked_until = NULL, updated_at = NOW()
+WHERE id = ANY($1) AND status = 'processing' AND locked_by = $2
+`
+	_, err := r.pool.Exec(ctx, q, jobIDs, workerID)
+	return err
+}
+
+// RenewLease extends workerID's lease on the given jobs. Jobs that finished or
+// were reaped in the meantime are left alone.
+func (r *OutboxRepository) RenewLease(ctx context.Context, workerID string, jobIDs []int64, lease time.Duration) error {
+	if len(jobIDs) == 0 {
+		return nil
+	}
+	q := `
+UPDATE outbox_jobs
+SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+WHERE id = ANY($1) AND status = 'processing' AND locked_by = $2
+`
+	_, err := r.pool.Exec(ctx, q, jobIDs, workerID, lease.Milliseconds())
 	return err
 }
+
+// ReapExpiredLeases returns processing jobs whose lease ran out to pending.
+// The crashed attempt still counts, so a job that keeps killing its worker
+// runs out of attempts: one that has used maxAttempts, or its topic's entry in
+// topicMaxAttempts, is failed instead and announced like MarkFailed.
+func (r *OutboxRepository) ReapExpiredLeases(ctx context.Context, maxAttempts int32, topicMaxAttempts map[string]int32) (requeued, failed int64, err error) {
+	limits, err := json.Marshal(topicMaxAttempts)
+	if err != nil {
+		return 0, 0, err
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+UPDATE outbox_jobs
+SET status = CASE WHEN attempts >= COALESCE(($2::jsonb ->> topic)::int, $1) THEN 'failed' ELSE 'pending' END,
+    last_error = 'lease_expired', locked_by = NULL, locked_until = NULL, available_at = NOW(), updated_at = NOW()
+WHERE status = 'processing' AND locked_until < NOW()
+RETURNING id, status, topic, attempts, COALESCE(payload->>'loan_id', ''), COALESCE(payload->>'lender_id', '')
+`
+	rows, err := tx.Query(ctx, q, maxAttempts, string(limits))
+	if err != nil {
+		return 0, 0, err
+	}
+	var dead []realtime.OutboxJobFailed
+	for rows.Next() {
+		var status string
+		ev := realtime.OutboxJobFailed{Error: "lease_expired"}
+		if err := rows.Scan(&ev.JobID, &status, &ev.Topic, &ev.Attempts, &ev.LoanID, &ev.LenderID); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if status == "failed" {
+			dead = append(dead, ev)
+		} else {
+			requeued++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	for _, ev := range dead {
+		if err := insertRealtimeEvent(ctx, tx, realtime.OutboxFailuresChannel, realtime.EventOutboxJobFailed, ev); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return requeued, int64(len(dead)), nil
+}
+
+// MarkFailed fails a job for good and announces it on the admin outbox
+// channel.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, jobID int64, lastError string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+UPDATE outbox_jobs
+SET status = 'failed', last_error = $2, locked_by = NULL, locked_until = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING topic, attempts, COALESCE(payload->>'loan_id', ''), COALESCE(payload->>'lender_id', '')
+`
+	ev := realtime.OutboxJobFailed{JobID: jobID, Error: lastError}
+	if err := tx.QueryRow(ctx, q, jobID, lastError).Scan(&ev.Topic, &ev.Attempts, &ev.LoanID, &ev.LenderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if err := insertRealtimeEvent(ctx, tx, realtime.OutboxFailuresChannel, realtime.EventOutboxJobFailed, ev); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+const outboxJobColumns = `id, topic, status, attempts, COALESCE(last_error, ''), payload::text, COALESCE(locked_by, ''), locked_until, available_at, created_at, updated_at`
+
+func scanOutboxJob(row pgx.Row) (*outbox.Job, error) {
+	out := &outbox.Job{}
+	var payloadText string
+	if err := row.Scan(&out.ID, &out.Topic, &out.Status, &out.Attempts, &out.LastError, &payloadText, &out.LockedBy, &out.LockedUntil, &out.AvailableAt, &out.CreatedAt, &out.UpdatedAt); err != nil {
+		return nil, err
+	}
+	out.Payload = []byte(payloadText)
+	return out, nil
+}
+
+func (r *OutboxRepository) List(ctx context.Context, f outbox.ListFilter) ([]outbox.Job, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	var builder strings.Builder
+	args := make([]any, 0, 4)
+	builder.WriteString(`SELECT ` + outboxJobColumns + ` FROM outbox_jobs WHERE 1=1`)
+	if f.Status != "" {
+		args = append(args, f.Status)
+		builder.WriteString(` AND status = $` + strconv.Itoa(len(args)))
+	}
+	if f.Topic != "" {
+		args = append(args, f.Topic)
+		builder.WriteString(` AND topic = $` + strconv.Itoa(len(args)))
+	}
+	args = append(args, f.Limit)
+	builder.WriteString(` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args)))
+	args = append(args, f.Offset)
+	builder.WriteString(` OFFSET $` + strconv.Itoa(len(args)))
+
+	rows, err := r.pool.Query(ctx, builder.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]outbox.Job, 0)
+	for rows.Next() {
+		item, err := scanOutboxJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Listings stay small; the payload is returned by GetByID.
+		item.Payload = nil
+		out = append(out, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) GetByID(ctx context.Context, id int64) (*outbox.Job, error) {
+	out, err := scanOutboxJob(r.pool.QueryRow(ctx, `SELECT `+outboxJobColumns+` FROM outbox_jobs WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, outbox.ErrNotFound
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) Retry(ctx context.Context, id int64) (*outbox.Job, error) {
+	q := `
+UPDATE outbox_jobs
+SET status = 'pending', attempts = 0, available_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status IN ('failed', 'cancelled')
+RETURNING ` + outboxJobColumns
+	return r.transition(ctx, q, id)
+}
+
+func (r *OutboxRepository) Cancel(ctx context.Context, id int64) (*outbox.Job, error) {
+	q := `
+UPDATE outbox_jobs
+SET status = 'cancelled', updated_at = NOW()
+WHERE id = $1 AND status IN ('pending', 'failed')
+RETURNING ` + outboxJobColumns
+	return r.transition(ctx, q, id)
+}
+
+func (r *OutboxRepository) transition(ctx context.Context, q string, id int64) (*outbox.Job, error) {
+	out, err := scanOutboxJob(r.pool.QueryRow(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, outbox.ErrInvalidTransition
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) RequeueFailed(ctx context.Context, topic string) (int64, error) {
+	q := `
+UPDATE outbox_jobs
+SET status = 'pending', attempts = 0, available_at = NOW(), updated_at = NOW()
+WHERE topic = $1 AND status = 'failed'
+`
+	tag, err := r.pool.Exec(ctx, q, topic)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *OutboxRepository) CountByStatus(ctx context.Context) ([]outbox.StatusCount, error) {
+	rows, err := r.pool.Query(ctx, `SELECT topic, status, COUNT(*)::bigint FROM outbox_jobs GROUP BY topic, status ORDER BY topic, status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]outbox.StatusCount, 0)
+	for rows.Next() {
+		var item outbox.StatusCount
+		if err := rows.Scan(&item.Topic, &item.Status, &item.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
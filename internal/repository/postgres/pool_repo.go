@@ -2,10 +2,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/domain/ledger"
+	"github.com/loangraph/backend/internal/domain/loan"
 	"github.com/loangraph/backend/internal/domain/pool"
 )
 
@@ -20,8 +24,8 @@ func NewPoolRepository(db *pgxpool.Pool) *PoolRepository {
 func (r *PoolRepository) Create(ctx context.Context, in pool.CreateInput) (*pool.Entity, error) {
 	q := `
 INSERT INTO pools (lender_id, name, pool_token_addr, target_apybps, currency_code, status)
-VALUES ($1,$2,$3,$4,$5,$6)
-RETURNING id, lender_id, name, pool_token_addr, target_apybps, currency_code, status, total_deployed, created_at
+VALUES ($1,$2,NULLIF($3,''),$4,$5,$6)
+RETURNING id, lender_id, name, COALESCE(pool_token_addr, ''), target_apybps, currency_code, status, total_deployed, created_at
 `
 	out := &pool.Entity{}
 	err := r.pool.QueryRow(ctx, q, in.LenderID, in.Name, in.PoolTokenAddr, in.TargetAPYBPS, in.CurrencyCode, in.Status).
@@ -33,18 +37,24 @@ RETURNING id, lender_id, name, pool_token_addr, target_apybps, currency_code, st
 }
 
 func (r *PoolRepository) GetByID(ctx context.Context, id string) (*pool.Entity, error) {
-	q := `SELECT id, lender_id, name, pool_token_addr, target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE id = $1`
+	if !isUUID(id) {
+		return nil, pool.ErrNotFound
+	}
+	q := `SELECT id, lender_id, name, COALESCE(pool_token_addr, ''), target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE id = $1`
 	out := &pool.Entity{}
 	err := r.pool.QueryRow(ctx, q, id).
 		Scan(&out.ID, &out.LenderID, &out.Name, &out.PoolTokenAddr, &out.TargetAPYBPS, &out.CurrencyCode, &out.Status, &out.TotalDeployed, &out.CreatedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, pool.ErrNotFound
+		}
 		return nil, err
 	}
 	return out, nil
 }
 
 func (r *PoolRepository) ListByLender(ctx context.Context, lenderID string) ([]pool.Entity, error) {
-	q := `SELECT id, lender_id, name, pool_token_addr, target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE lender_id = $1 ORDER BY created_at DESC`
+	q := `SELECT id, lender_id, name, COALESCE(pool_token_addr, ''), target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE lender_id = $1 ORDER BY created_at DESC`
 	rows, err := r.pool.Query(ctx, q, lenderID)
 	if err != nil {
 		return nil, err
@@ -74,7 +84,7 @@ func (r *PoolRepository) List(ctx context.Context, lenderID, currencyCode, statu
 	}
 
 	builder := strings.Builder{}
-	builder.WriteString(`SELECT id, lender_id, name, pool_token_addr, target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE 1=1`)
+	builder.WriteString(`SELECT id, lender_id, name, COALESCE(pool_token_addr, ''), target_apybps, currency_code, status, total_deployed, created_at FROM pools WHERE 1=1`)
 
 	args := []any{}
 	argPos := 1
@@ -123,3 +133,203 @@ func (r *PoolRepository) List(ctx context.Context, lenderID, currencyCode, statu
 	}
 	return out, nil
 }
+
+func (r *PoolRepository) Update(ctx context.Context, id string, in pool.UpdateInput) (*pool.Entity, error) {
+	q := `
+UPDATE pools
+SET name = $2, target_apybps = $3, status = $4
+WHERE id = $1
+RETURNING id, lender_id, name, COALESCE(pool_token_addr, ''), target_apybps, currency_code, status, total_deployed, created_at
+`
+	out := &pool.Entity{}
+	err := r.pool.QueryRow(ctx, q, id, in.Name, in.TargetAPYBPS, in.Status).
+		Scan(&out.ID, &out.LenderID, &out.Name, &out.PoolTokenAddr, &out.TargetAPYBPS, &out.CurrencyCode, &out.Status, &out.TotalDeployed, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PoolRepository) AllocateLoan(ctx context.Context, poolID, loanID string, amountMinor int64) (*pool.Allocation, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+INSERT INTO pool_allocations (pool_id, loan_id, amount_minor)
+VALUES ($1, $2, $3)
+ON CONFLICT (loan_id) WHERE released_at IS NULL DO NOTHING
+RETURNING id, pool_id, loan_id, amount_minor, allocated_at, released_at
+`
+	out := &pool.Allocation{}
+	err = tx.QueryRow(ctx, q, poolID, loanID, amountMinor).
+		Scan(&out.ID, &out.PoolID, &out.LoanID, &out.AmountMinor, &out.AllocatedAt, &out.ReleasedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, pool.ErrLoanAlreadyAllocated
+		}
+		return nil, err
+	}
+	var lenderID, currencyCode string
+	if err := tx.QueryRow(ctx, `UPDATE pools SET total_deployed = total_deployed + $2 WHERE id = $1 RETURNING lender_id, currency_code`, poolID, amountMinor).Scan(&lenderID, &currencyCode); err != nil {
+		return nil, err
+	}
+	if err := postLedgerEntry(ctx, tx, ledger.PoolAllocation(out.ID, poolID, lenderID, currencyCode, out.AmountMinor)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PoolRepository) ReleaseLoan(ctx context.Context, poolID, loanID string) (*pool.Allocation, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+UPDATE pool_allocations
+SET released_at = NOW()
+WHERE pool_id = $1 AND loan_id = $2 AND released_at IS NULL
+RETURNING id, pool_id, loan_id, amount_minor, allocated_at, released_at
+`
+	out := &pool.Allocation{}
+	err = tx.QueryRow(ctx, q, poolID, loanID).
+		Scan(&out.ID, &out.PoolID, &out.LoanID, &out.AmountMinor, &out.AllocatedAt, &out.ReleasedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, pool.ErrAllocationNotFound
+		}
+		return nil, err
+	}
+	var lenderID, currencyCode string
+	if err := tx.QueryRow(ctx, `UPDATE pools SET total_deployed = GREATEST(total_deployed - $2, 0) WHERE id = $1 RETURNING lender_id, currency_code`, poolID, out.AmountMinor).Scan(&lenderID, &currencyCode); err != nil {
+		return nil, err
+	}
+	if err := postLedgerEntry(ctx, tx, ledger.PoolRelease(out.ID, poolID, lenderID, currencyCode, out.AmountMinor)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PoolRepository) ListAllocations(ctx context.Context, poolID string, limit, offset int32) ([]pool.Allocation, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	q := `
+SELECT id, pool_id, loan_id, amount_minor, allocated_at, released_at
+FROM pool_allocations
+WHERE pool_id = $1 AND released_at IS NULL
+ORDER BY allocated_at DESC, id DESC
+LIMIT $2 OFFSET $3
+`
+	rows, err := r.pool.Query(ctx, q, poolID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]pool.Allocation, 0)
+	for rows.Next() {
+		var item pool.Allocation
+		if err := rows.Scan(&item.ID, &item.PoolID, &item.LoanID, &item.AmountMinor, &item.AllocatedAt, &item.ReleasedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PoolRepository) GetPerformanceSummary(ctx context.Context, poolID string) (*pool.PerformanceSummary, error) {
+	q := `
+SELECT
+  p.target_apybps,
+  COUNT(l.id)::bigint AS allocated_loans,
+  COUNT(l.id) FILTER (WHERE l.status = 'active')::bigint AS active_loans,
+  COUNT(l.id) FILTER (WHERE l.status = 'repaid')::bigint AS repaid_loans,
+  COUNT(l.id) FILTER (WHERE l.status = 'defaulted')::bigint AS defaulted_loans,
+  COALESCE(SUM(a.amount_minor), 0)::bigint AS deployed_minor,
+  COALESCE(SUM(l.amount_repaid_minor), 0)::bigint AS repaid_minor,
+  COALESCE(SUM(GREATEST(l.amount_repaid_minor - l.principal_minor, 0)), 0)::bigint AS interest_collected_minor,
+  COALESCE(SUM(GREATEST(l.principal_minor - l.amount_repaid_minor, 0)) FILTER (WHERE l.status = 'defaulted'), 0)::bigint AS default_loss_minor,
+  COALESCE(SUM(l.interest_rate_bps::float8 * l.principal_minor) / NULLIF(SUM(l.principal_minor), 0)::float8, 0)::float8 AS weighted_avg_interest_bps,
+  MIN(a.allocated_at) AS first_allocated_at
+FROM pools p
+LEFT JOIN pool_allocations a ON a.pool_id = p.id AND a.released_at IS NULL
+LEFT JOIN loans l ON l.id = a.loan_id
+WHERE p.id = $1
+GROUP BY p.id, p.target_apybps
+`
+	out := &pool.PerformanceSummary{PoolID: poolID}
+	err := r.pool.QueryRow(ctx, q, poolID).Scan(
+		&out.TargetAPYBPS,
+		&out.AllocatedLoans,
+		&out.ActiveLoans,
+		&out.RepaidLoans,
+		&out.DefaultedLoans,
+		&out.DeployedMinor,
+		&out.RepaidMinor,
+		&out.InterestCollectedMinor,
+		&out.DefaultLossMinor,
+		&out.WeightedAvgInterestBPS,
+		&out.FirstAllocatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRepaymentTimeSeries only counts repayments recorded while the loan was
+// allocated to the pool, so moving a loan between pools does not move its history.
+func (r *PoolRepository) GetRepaymentTimeSeries(ctx context.Context, poolID string, days int32) ([]loan.PerformancePoint, error) {
+	if days <= 0 {
+		days = 30
+	}
+	q := `
+SELECT
+  TO_CHAR(DATE_TRUNC('day', r.recorded_at), 'YYYY-MM-DD') AS dt,
+  COUNT(*)::bigint AS repayment_count,
+  COALESCE(SUM(r.amount_minor), 0)::bigint AS repaid_amount_minor
+FROM repayments r
+JOIN pool_allocations a ON a.loan_id = r.loan_id
+WHERE a.pool_id = $1
+  AND r.recorded_at >= a.allocated_at
+  AND (a.released_at IS NULL OR r.recorded_at < a.released_at)
+  AND r.recorded_at >= NOW() - ($2::int || ' days')::interval
+GROUP BY dt
+ORDER BY dt ASC
+`
+	rows, err := r.pool.Query(ctx, q, poolID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]loan.PerformancePoint, 0)
+	for rows.Next() {
+		var p loan.PerformancePoint
+		if err := rows.Scan(&p.Date, &p.RepaymentCount, &p.RepaidAmountMinor); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/loangraph/backend/internal/domain/ledger"
+	"github.com/loangraph/backend/internal/domain/position"
+)
+
+type PositionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPositionRepository(pool *pgxpool.Pool) *PositionRepository {
+	return &PositionRepository{pool: pool}
+}
+
+func (r *PositionRepository) RecordTransaction(ctx context.Context, in position.TransactionInput) (*position.Transaction, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize share issuance per pool so the NAV the caller priced with
+	// cannot change underneath it.
+	var currencyCode string
+	if err := tx.QueryRow(ctx, `SELECT currency_code FROM pools WHERE id = $1 FOR UPDATE`, in.PoolID).Scan(&currencyCode); err != nil {
+		return nil, err
+	}
+	var totalShares int64
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(shares), 0)::bigint FROM investor_positions WHERE pool_id = $1`, in.PoolID).Scan(&totalShares); err != nil {
+		return nil, err
+	}
+	if totalShares != in.ExpectedTotalShares {
+		return nil, position.ErrConcurrentUpdate
+	}
+
+	switch in.Kind {
+	case position.KindDeposit:
+		q := `
+INSERT INTO investor_positions (pool_id, investor_user_id, shares, deposited_minor)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (pool_id, investor_user_id)
+DO UPDATE SET shares = investor_positions.shares + EXCLUDED.shares,
+              deposited_minor = investor_positions.deposited_minor + EXCLUDED.deposited_minor,
+              updated_at = NOW()
+`
+		if _, err := tx.Exec(ctx, q, in.PoolID, in.InvestorUserID, in.Shares, in.AmountMinor); err != nil {
+			return nil, err
+		}
+	case position.KindWithdrawal:
+		q := `
+UPDATE investor_positions
+SET shares = shares - $3, withdrawn_minor = withdrawn_minor + $4, updated_at = NOW()
+WHERE pool_id = $1 AND investor_user_id = $2 AND shares >= $3
+`
+		tag, err := tx.Exec(ctx, q, in.PoolID, in.InvestorUserID, in.Shares, in.AmountMinor)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, position.ErrInsufficientShares
+		}
+	default:
+		return nil, position.ErrInvalidAmount
+	}
+
+	q := `
+INSERT INTO investor_transactions (pool_id, investor_user_id, kind, amount_minor, shares, nav_per_share_micros)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, pool_id, investor_user_id, kind, amount_minor, shares, nav_per_share_micros, COALESCE(on_chain_tx, ''), created_at
+`
+	out := &position.Transaction{}
+	err = tx.QueryRow(ctx, q, in.PoolID, in.InvestorUserID, in.Kind, in.AmountMinor, in.Shares, in.NAVPerShareMicros).
+		Scan(&out.ID, &out.PoolID, &out.InvestorUserID, &out.Kind, &out.AmountMinor, &out.Shares, &out.NAVPerShareMicros, &out.OnChainTX, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry := ledger.InvestorDeposit(out.ID, out.PoolID, out.InvestorUserID, currencyCode, out.AmountMinor)
+	if out.Kind == position.KindWithdrawal {
+		entry = ledger.InvestorWithdrawal(out.ID, out.PoolID, out.InvestorUserID, currencyCode, out.AmountMinor)
+	}
+	if err := postLedgerEntry(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if in.PoolTokenAddr != "" {
+		if err := enqueueOutboxJob(ctx, tx, position.OutboxTopicPoolPosition, position.PoolPositionPayload(out, in.PoolTokenAddr)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PositionRepository) GetPoolState(ctx context.Context, poolID string) (*position.PoolState, error) {
+	q := `
+SELECT
+  (SELECT COALESCE(SUM(shares), 0) FROM investor_positions WHERE pool_id = $1)::bigint,
+  (SELECT COALESCE(SUM(deposited_minor), 0) FROM investor_positions WHERE pool_id = $1)::bigint,
+  (SELECT COALESCE(SUM(withdrawn_minor), 0) FROM investor_positions WHERE pool_id = $1)::bigint,
+  COALESCE(SUM(GREATEST(l.amount_repaid_minor - l.principal_minor, 0)), 0)::bigint,
+  COALESCE(SUM(GREATEST(l.principal_minor - l.amount_repaid_minor, 0)) FILTER (WHERE l.status = 'defaulted'), 0)::bigint
+FROM pool_allocations a
+JOIN loans l ON l.id = a.loan_id
+WHERE a.pool_id = $1 AND a.released_at IS NULL
+`
+	out := &position.PoolState{}
+	err := r.pool.QueryRow(ctx, q, poolID).Scan(
+		&out.TotalShares,
+		&out.DepositedMinor,
+		&out.WithdrawnMinor,
+		&out.InterestCollectedMinor,
+		&out.DefaultLossMinor,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PositionRepository) ListPositions(ctx context.Context, investorUserID string) ([]position.Position, error) {
+	q := `
+SELECT pool_id, investor_user_id, shares, deposited_minor, withdrawn_minor, updated_at
+FROM investor_positions
+WHERE investor_user_id = $1
+ORDER BY created_at ASC
+`
+	rows, err := r.pool.Query(ctx, q, investorUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]position.Position, 0)
+	for rows.Next() {
+		var item position.Position
+		if err := rows.Scan(&item.PoolID, &item.InvestorUserID, &item.Shares, &item.DepositedMinor, &item.WithdrawnMinor, &item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PositionRepository) ListTransactions(ctx context.Context, investorUserID, poolID string, limit, offset int32) ([]position.Transaction, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	q := `
+SELECT id, pool_id, investor_user_id, kind, amount_minor, shares, nav_per_share_micros, COALESCE(on_chain_tx, ''), created_at
+FROM investor_transactions
+WHERE investor_user_id = $1 AND pool_id = $2
+ORDER BY created_at DESC, id DESC
+LIMIT $3 OFFSET $4
+`
+	rows, err := r.pool.Query(ctx, q, investorUserID, poolID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]position.Transaction, 0)
+	for rows.Next() {
+		var item position.Transaction
+		if err := rows.Scan(&item.ID, &item.PoolID, &item.InvestorUserID, &item.Kind, &item.AmountMinor, &item.Shares, &item.NAVPerShareMicros, &item.OnChainTX, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PositionRepository) SetTransactionSubmission(ctx context.Context, transactionID, txHash string) error {
+	_, err := r.pool.Exec(ctx, `UPDATE investor_transactions SET on_chain_tx = $2 WHERE id = $1`, transactionID, txHash)
+	return err
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// RealtimeEventsChannel is notified by the realtime_events trigger each
+	// time a producer commits events.
+	RealtimeEventsChannel = "realtime_events"
+
+	listenMinBackoff = time.Second
+	listenMaxBackoff = time.Minute
+	// listenPingInterval checks an idle LISTEN connection is still alive; a
+	// dead one would otherwise wait for notifications forever.
+	listenPingInterval = 30 * time.Second
+)
+
+// ProjectionListener holds a pool connection in LISTEN on RealtimeEventsChannel.
+type ProjectionListener struct {
+	pool      *pgxpool.Pool
+	logger    *slog.Logger
+	connected atomic.Bool
+}
+
+func NewProjectionListener(pool *pgxpool.Pool, logger *slog.Logger) *ProjectionListener {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ProjectionListener{pool: pool, logger: logger}
+}
+
+func (l *ProjectionListener) Connected() bool {
+	return l.connected.Load()
+}
+
+// Run listens until ctx is done, reconnecting with exponential backoff. It
+// signals wake on every notification and right after each LISTEN so the
+// caller catches up on events committed while it was disconnected.
+func (l *ProjectionListener) Run(ctx context.Context, wake chan<- struct{}) {
+	backoff := listenMinBackoff
+	for ctx.Err() == nil {
+		listened, err := l.session(ctx, wake)
+		l.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			backoff = listenMinBackoff
+		}
+		l.logger.Warn("realtime listener lost, polling until reconnected", "err", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > listenMaxBackoff {
+			backoff = listenMaxBackoff
+		}
+	}
+}
+
+func (l *ProjectionListener) session(ctx context.Context, wake chan<- struct{}) (bool, error) {
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	// The connection is left listening, so close it rather than hand it
+	// back to the pool; Release then discards it.
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Conn().Close(closeCtx)
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+RealtimeEventsChannel); err != nil {
+		return false, err
+	}
+	l.connected.Store(true)
+	wakeListener(wake)
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, listenPingInterval)
+		_, err := conn.Conn().WaitForNotification(waitCtx)
+		cancel()
+		switch {
+		case err == nil:
+			wakeListener(wake)
+		case ctx.Err() != nil:
+			return true, ctx.Err()
+		case errors.Is(err, context.DeadlineExceeded):
+			if err := conn.Ping(ctx); err != nil {
+				return true, err
+			}
+		default:
+			return true, err
+		}
+	}
+}
+
+func wakeListener(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
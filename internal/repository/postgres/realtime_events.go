@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// realtimeEventsXact assigns the transaction its id before its first event
+// takes one from the sequence. Writers do not wait for each other, so ids can
+// commit out of order; because every id is taken by a transaction that is
+// already running, a reader that sees a gap below a visible id knows the
+// missing id belongs to a transaction in its snapshot, and that the gap is
+// final once that snapshot's xmax falls below the oldest running
+// transaction (see ListRealtimeEventsSince).
+const realtimeEventsXact = `SELECT pg_current_xact_id()`
+
+// insertRealtimeEvent stores an event for the websocket notifier within tx,
+// so it is delivered only if the change it announces commits.
+func insertRealtimeEvent(ctx context.Context, tx pgx.Tx, channel, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, realtimeEventsXact); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO realtime_events (channel, event, data) VALUES ($1, $2, $3::jsonb)`, channel, event, string(payload))
+	return err
+}
@@ -2,10 +2,13 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/loangraph/backend/internal/ws"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type WSRepository struct {
@@ -16,49 +19,145 @@ func NewWSRepository(pool *pgxpool.Pool) *WSRepository {
 	return &WSRepository{pool: pool}
 }
 
-func (r *WSRepository) ListRepaymentEventsSince(ctx context.Context, lastID int64, limit int32) ([]ws.RealtimeEvent, error) {
+// Publish stores an event outside any other change, for producers whose
+// change is already committed.
+func (r *WSRepository) Publish(ctx context.Context, channel, event string, data any) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertRealtimeEvent(ctx, tx, channel, event, data); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListRealtimeEventsSince reads events after afterID together with the xmin
+// and xmax of the snapshot that read them; the page bounds are zero when no
+// event is found.
+func (r *WSRepository) ListRealtimeEventsSince(ctx context.Context, afterID int64, limit int32) (realtime.EventPage, error) {
 	if limit <= 0 {
 		limit = 100
 	}
 	q := `
-SELECT
-  ce.id,
-  (ce.raw_data->>'loan_id') AS loan_id,
-  l.lender_id,
-  COALESCE((ce.raw_data->>'amount_minor')::bigint, 0) AS amount_minor,
-  l.currency_code,
-  ce.indexed_at
-FROM chain_events ce
-JOIN loans l ON l.id = ((ce.raw_data->>'loan_id')::uuid)
-WHERE ce.id > $1
-  AND ce.event_name = 'RepaymentRecorded'
-ORDER BY ce.id ASC
+SELECT id, channel, event, data::text, created_at,
+       pg_snapshot_xmin(pg_current_snapshot())::text::bigint,
+       pg_snapshot_xmax(pg_current_snapshot())::text::bigint
+FROM realtime_events
+WHERE id > $1
+ORDER BY id ASC
 LIMIT $2
 `
-	rows, err := r.pool.Query(ctx, q, lastID, limit)
+	page := realtime.EventPage{Events: make([]realtime.Event, 0)}
+	rows, err := r.pool.Query(ctx, q, afterID, limit)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
 	defer rows.Close()
+	for rows.Next() {
+		var ev realtime.Event
+		var dataText string
+		if err := rows.Scan(&ev.ID, &ev.Channel, &ev.Type, &dataText, &ev.CreatedAt, &page.Xmin, &page.Xmax); err != nil {
+			return page, err
+		}
+		ev.Data = []byte(dataText)
+		page.Events = append(page.Events, ev)
+	}
+	return page, rows.Err()
+}
 
-	out := make([]ws.RealtimeEvent, 0)
+func scanRealtimeEvents(rows pgx.Rows) ([]realtime.Event, error) {
+	out := make([]realtime.Event, 0)
 	for rows.Next() {
-		var ev ws.RealtimeEvent
-		var recordedAt time.Time
-		if err := rows.Scan(&ev.ID, &ev.LoanID, &ev.LenderID, &ev.AmountMinor, &ev.Currency, &recordedAt); err != nil {
+		var ev realtime.Event
+		var dataText string
+		if err := rows.Scan(&ev.ID, &ev.Channel, &ev.Type, &dataText, &ev.CreatedAt); err != nil {
 			return nil, err
 		}
-		ev.RecordedAt = recordedAt
+		ev.Data = []byte(dataText)
 		out = append(out, ev)
 	}
-	if err := rows.Err(); err != nil {
+	return out, rows.Err()
+}
+
+func (r *WSRepository) ListChannelEventsSince(ctx context.Context, channel string, afterSeq int64, limit int32) ([]realtime.Event, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	q := `
+SELECT id, channel, event, data::text, created_at
+FROM realtime_events
+WHERE channel = $1 AND id > $2
+ORDER BY id ASC
+LIMIT $3
+`
+	rows, err := r.pool.Query(ctx, q, channel, afterSeq, limit)
+	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	defer rows.Close()
+	return scanRealtimeEvents(rows)
+}
+
+func (r *WSRepository) OldestRealtimeEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(MIN(id), 0) FROM realtime_events`).Scan(&id)
+	return id, err
+}
+
+// PruneRealtimeEvents deletes events created before before.
+func (r *WSRepository) PruneRealtimeEvents(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM realtime_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneRealtimeCursors deletes cursors last moved before before.
+func (r *WSRepository) PruneRealtimeCursors(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM realtime_cursors WHERE updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
 
-func (r *WSRepository) ListPoolsByLender(ctx context.Context, lenderID string) ([]string, error) {
-	rows, err := r.pool.Query(ctx, `SELECT id FROM pools WHERE lender_id = $1`, lenderID)
+func (r *WSRepository) GetRealtimeCursor(ctx context.Context, nodeID string) (int64, bool, error) {
+	var seq int64
+	err := r.pool.QueryRow(ctx, `SELECT last_seq FROM realtime_cursors WHERE node_id = $1`, nodeID).Scan(&seq)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return seq, true, nil
+}
+
+func (r *WSRepository) SetRealtimeCursor(ctx context.Context, nodeID string, seq int64) error {
+	q := `
+INSERT INTO realtime_cursors (node_id, last_seq, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (node_id) DO UPDATE SET last_seq = EXCLUDED.last_seq, updated_at = NOW()
+`
+	_, err := r.pool.Exec(ctx, q, nodeID, seq)
+	return err
+}
+
+func (r *WSRepository) LatestRealtimeEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM realtime_events`).Scan(&id)
+	return id, err
+}
+
+func (r *WSRepository) ListLenderIDsByUser(ctx context.Context, userID string) ([]string, error) {
+	if !isUUID(userID) {
+		return []string{}, nil
+	}
+	rows, err := r.pool.Query(ctx, `SELECT lender_id::text FROM lender_members WHERE user_id = $1 ORDER BY lender_id`, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -66,14 +165,53 @@ func (r *WSRepository) ListPoolsByLender(ctx context.Context, lenderID string) (
 
 	out := make([]string, 0)
 	for rows.Next() {
-		var poolID string
-		if err := rows.Scan(&poolID); err != nil {
+		var lenderID string
+		if err := rows.Scan(&lenderID); err != nil {
 			return nil, err
 		}
-		out = append(out, poolID)
+		out = append(out, lenderID)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return out, rows.Err()
+}
+
+func (r *WSRepository) GetPoolLenderID(ctx context.Context, poolID string) (string, error) {
+	return r.lenderOf(ctx, `SELECT lender_id::text FROM pools WHERE id = $1`, poolID)
+}
+
+func (r *WSRepository) GetLoanLenderID(ctx context.Context, loanID string) (string, error) {
+	return r.lenderOf(ctx, `SELECT lender_id::text FROM loans WHERE id = $1`, loanID)
+}
+
+func (r *WSRepository) GetBorrowerLenderID(ctx context.Context, borrowerID string) (string, error) {
+	return r.lenderOf(ctx, `SELECT COALESCE(lender_id::text, '') FROM borrowers WHERE id = $1`, borrowerID)
+}
+
+// lenderOf returns "" for ids that are not UUIDs or do not exist.
+func (r *WSRepository) lenderOf(ctx context.Context, q, id string) (string, error) {
+	if !isUUID(id) {
+		return "", nil
 	}
-	return out, nil
+	var lenderID string
+	if err := r.pool.QueryRow(ctx, q, id).Scan(&lenderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return lenderID, nil
+}
+
+func (r *WSRepository) HasPoolPosition(ctx context.Context, poolID, userID string) (bool, error) {
+	if !isUUID(poolID) || !isUUID(userID) {
+		return false, nil
+	}
+	var ok bool
+	q := `SELECT EXISTS (SELECT 1 FROM investor_positions WHERE pool_id = $1 AND investor_user_id = $2)`
+	err := r.pool.QueryRow(ctx, q, poolID, userID).Scan(&ok)
+	return ok, err
+}
+
+func isUUID(raw string) bool {
+	_, err := uuid.Parse(raw)
+	return err == nil
 }
@@ -19,7 +19,11 @@ type Dependencies struct {
 	LoanHandler     *handlers.LoanHandler
 	PassportHandler *handlers.PassportHandler
 	InvestorHandler *handlers.InvestorHandler
+	PoolHandler     *handlers.PoolHandler
+	PositionHandler *handlers.PositionHandler
 	AdminHandler    *handlers.AdminHandler
+	LedgerHandler   *handlers.LedgerHandler
+	ChainHandler    *handlers.ChainHandler
 	WSHandler       *ws.Handler
 	JWTManager      *auth.JWTManager
 }
@@ -57,6 +61,7 @@ func NewRouter(cfg config.Config, logger *slog.Logger, deps Dependencies) *gin.E
 			wsGroup := r.Group("/v1")
 			wsGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleLender, auth.RoleAdmin, auth.RoleInvestor))
 			wsGroup.GET("/ws", deps.WSHandler.HandleWebSocket)
+			wsGroup.GET("/events/stream", deps.WSHandler.HandleEventStream)
 		}
 
 		if deps.LoanHandler != nil {
@@ -85,6 +90,24 @@ func NewRouter(cfg config.Config, logger *slog.Logger, deps Dependencies) *gin.E
 			investorGroup.GET("/pools/:poolId/performance", deps.InvestorHandler.GetPoolPerformance)
 			investorGroup.GET("/lenders/:lenderId/profile", deps.InvestorHandler.GetLenderProfile)
 		}
+		if deps.PoolHandler != nil {
+			poolGroup := r.Group("/v1")
+			poolGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleLender, auth.RoleAdmin))
+			poolGroup.POST("/pools", deps.PoolHandler.CreatePool)
+			poolGroup.PATCH("/pools/:poolId", deps.PoolHandler.UpdatePool)
+			poolGroup.POST("/pools/:poolId/close", deps.PoolHandler.ClosePool)
+			poolGroup.GET("/pools/:poolId/loans", deps.PoolHandler.ListAllocations)
+			poolGroup.POST("/pools/:poolId/loans", deps.PoolHandler.AllocateLoans)
+			poolGroup.DELETE("/pools/:poolId/loans/:loanId", deps.PoolHandler.ReleaseLoan)
+		}
+		if deps.PositionHandler != nil {
+			positionGroup := r.Group("/v1")
+			positionGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleInvestor))
+			positionGroup.POST("/pools/:poolId/deposits", deps.PositionHandler.Deposit)
+			positionGroup.POST("/pools/:poolId/withdrawals", deps.PositionHandler.Withdraw)
+			positionGroup.GET("/investor/positions", deps.PositionHandler.ListPositions)
+			positionGroup.GET("/investor/positions/:poolId/history", deps.PositionHandler.ListHistory)
+		}
 
 		if deps.AdminHandler != nil {
 			adminGroup := r.Group("/admin")
@@ -92,6 +115,31 @@ func NewRouter(cfg config.Config, logger *slog.Logger, deps Dependencies) *gin.E
 			adminGroup.GET("/system/health", deps.AdminHandler.SystemHealth)
 			adminGroup.POST("/lenders", deps.AdminHandler.OnboardLender)
 			adminGroup.PATCH("/lenders/:lenderId/status", deps.AdminHandler.UpdateLenderStatus)
+			adminGroup.PUT("/lenders/:lenderId/members/:userId", deps.AdminHandler.AddLenderMember)
+			adminGroup.DELETE("/lenders/:lenderId/members/:userId", deps.AdminHandler.RemoveLenderMember)
+			adminGroup.GET("/outbox/jobs", deps.AdminHandler.ListOutboxJobs)
+			adminGroup.GET("/outbox/jobs/:jobId", deps.AdminHandler.GetOutboxJob)
+			adminGroup.POST("/outbox/jobs/:jobId/retry", deps.AdminHandler.RetryOutboxJob)
+			adminGroup.POST("/outbox/jobs/:jobId/cancel", deps.AdminHandler.CancelOutboxJob)
+			adminGroup.POST("/outbox/topics/:topic/requeue", deps.AdminHandler.RequeueOutboxTopic)
+		}
+		if deps.LedgerHandler != nil {
+			ledgerGroup := r.Group("/admin/ledger")
+			ledgerGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleAdmin))
+			ledgerGroup.GET("/accounts/:kind/:ownerId", deps.LedgerHandler.GetAccount)
+			ledgerGroup.GET("/lenders/:lenderId/balances", deps.LedgerHandler.GetLenderBalances)
+			ledgerGroup.GET("/pools/:poolId/balances", deps.LedgerHandler.GetPoolBalances)
+		}
+		if deps.ChainHandler != nil {
+			chainGroup := r.Group("/admin/chain-events")
+			chainGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleAdmin))
+			chainGroup.GET("/quarantined", deps.ChainHandler.ListQuarantinedEvents)
+			chainGroup.POST("/:eventId/retry", deps.ChainHandler.RetryEvent)
+			chainGroup.POST("/:eventId/skip", deps.ChainHandler.SkipEvent)
+
+			reconciliationGroup := r.Group("/admin/chain-reconciliation")
+			reconciliationGroup.Use(middleware.RequireAuth(deps.JWTManager), middleware.RequireRole(auth.RoleAdmin))
+			reconciliationGroup.GET("/discrepancies", deps.ChainHandler.ListDiscrepancies)
 		}
 	}
 
@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/loangraph/backend/internal/auth"
+)
+
+var errForbidden = errors.New("forbidden")
+
+// Identity is the authenticated user behind a connection.
+type Identity struct {
+	UserID string
+	Role   string
+}
+
+// AccessRepository resolves who may see a channel. Lookups of unknown
+// resources return "" or false rather than an error, so a subscription to a
+// missing resource is indistinguishable from a forbidden one.
+type AccessRepository interface {
+	ListLenderIDsByUser(ctx context.Context, userID string) ([]string, error)
+	GetPoolLenderID(ctx context.Context, poolID string) (string, error)
+	GetLoanLenderID(ctx context.Context, loanID string) (string, error)
+	GetBorrowerLenderID(ctx context.Context, borrowerID string) (string, error)
+	HasPoolPosition(ctx context.Context, poolID, userID string) (bool, error)
+}
+
+// authorize applies the per-channel rules: admins see everything; lender
+// channels, and the loan and passport channels of a lender's loans and
+// borrowers, are open to that lender's members; a pool's repayments are also
+// open to investors holding a position in it; admin:outbox is admins only.
+// Lender membership is read on every subscribe, and removing a member
+// makes every node run this again for the user's open subscriptions (see
+// Handler.RecheckUser), so a removed member loses the lender's channels at
+// once and is refused them from then on, whether or not they reconnect.
+func authorize(ctx context.Context, access AccessRepository, id Identity, sub subscription) error {
+	if id.Role == auth.RoleAdmin {
+		return nil
+	}
+	if access == nil {
+		return errForbidden
+	}
+	var lenderID string
+	var err error
+	switch sub.kind {
+	case "lender:portfolio", "lender:defaults", "lender:uploads":
+		lenderID = sub.id
+	case "loan":
+		lenderID, err = access.GetLoanLenderID(ctx, sub.id)
+	case "passport":
+		lenderID, err = access.GetBorrowerLenderID(ctx, sub.id)
+	case "pool:repayments":
+		if id.Role == auth.RoleInvestor {
+			ok, err := access.HasPoolPosition(ctx, sub.id, id.UserID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errForbidden
+			}
+			return nil
+		}
+		lenderID, err = access.GetPoolLenderID(ctx, sub.id)
+	default:
+		return errForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if id.Role != auth.RoleLender || lenderID == "" {
+		return errForbidden
+	}
+	lenderIDs, err := access.ListLenderIDsByUser(ctx, id.UserID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(lenderIDs, lenderID) {
+		return errForbidden
+	}
+	return nil
+}
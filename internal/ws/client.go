@@ -1,47 +1,223 @@
 package ws
 
 import (
+	"context"
+	"errors"
+	"sort"
 	"sync"
 
 	"golang.org/x/net/websocket"
 )
 
+// Drop policies for a client whose outgoing buffer is full.
+const (
+	// DropDisconnect closes the connection; the client reconnects and
+	// resumes from its last seq.
+	DropDisconnect = "disconnect"
+	// DropOldest discards the oldest queued message to make room.
+	DropOldest = "drop_oldest"
+	// DropNewest discards the message being sent.
+	DropNewest = "drop_newest"
+)
+
+const defaultClientBuffer = 64
+
+var errClientClosed = errors.New("client closed")
+
 type Client struct {
-	conn *websocket.Conn
-	out  chan []byte
+	conn       *websocket.Conn
+	identity   Identity
+	out        chan []byte
+	dropPolicy string
+
+	// done is closed when the connection ends; out is never closed, so a
+	// late publish cannot panic.
+	done      chan struct{}
+	closeOnce sync.Once
+	// dropMu serializes making room and sending under DropOldest.
+	dropMu sync.Mutex
+
+	mu       sync.Mutex
+	channels map[string]*channelState
+}
 
-	mu       sync.RWMutex
-	channels map[string]struct{}
+// channelState tracks the last seq delivered on a channel, so replayed and
+// live copies of an event are sent once. While a resume is replaying, live
+// events are held in pending and flushed after the replay.
+type channelState struct {
+	lastSeq   int64
+	replaying bool
+	pending   []queuedEvent
 }
 
-func NewClient(conn *websocket.Conn) *Client {
+type queuedEvent struct {
+	seq     int64
+	payload []byte
+}
+
+func NewClient(conn *websocket.Conn, identity Identity) *Client {
+	return newClient(conn, identity, defaultClientBuffer, DropDisconnect)
+}
+
+func newClient(conn *websocket.Conn, identity Identity, bufferSize int, dropPolicy string) *Client {
+	if bufferSize <= 0 {
+		bufferSize = defaultClientBuffer
+	}
 	return &Client{
-		conn:     conn,
-		out:      make(chan []byte, 64),
-		channels: map[string]struct{}{},
+		conn:       conn,
+		identity:   identity,
+		out:        make(chan []byte, bufferSize),
+		dropPolicy: dropPolicy,
+		done:       make(chan struct{}),
+		channels:   map[string]*channelState{},
 	}
 }
 
+// send queues payload, applying the drop policy when the buffer is full.
 func (c *Client) send(payload []byte) {
 	select {
+	case <-c.done:
+		return
 	case c.out <- payload:
+		return
+	default:
+	}
+	switch c.dropPolicy {
+	case DropNewest:
+	case DropOldest:
+		c.dropMu.Lock()
+		defer c.dropMu.Unlock()
+		select {
+		case <-c.out:
+		default:
+		}
+		select {
+		case c.out <- payload:
+		default:
+		}
 	default:
-		_ = c.conn.Close()
+		c.close()
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
 	}
 }
 
+// sendWait queues payload, waiting for room instead of dropping; replays
+// use it so a long replay is not cut by the drop policy.
+func (c *Client) sendWait(ctx context.Context, payload []byte) error {
+	select {
+	case c.out <- payload:
+		return nil
+	case <-c.done:
+		return errClientClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close ends the writer; sends after it are discarded.
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// deliver sends a hub event on channel unless it was already delivered or
+// the channel is replaying. seq 0 is never deduplicated.
+func (c *Client) deliver(channel string, seq int64, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.channels[channel]
+	if !ok {
+		return
+	}
+	if st.replaying {
+		st.pending = append(st.pending, queuedEvent{seq: seq, payload: payload})
+		return
+	}
+	if seq > 0 {
+		if seq <= st.lastSeq {
+			return
+		}
+		st.lastSeq = seq
+	}
+	c.send(payload)
+}
+
 func (c *Client) addChannel(channel string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.channels[channel] = struct{}{}
+	if _, ok := c.channels[channel]; !ok {
+		c.channels[channel] = &channelState{}
+	}
+}
+
+// beginReplay subscribes channel in replay mode, holding live events until
+// endReplay.
+func (c *Client) beginReplay(channel string, fromSeq int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.channels[channel]
+	if !ok {
+		st = &channelState{}
+		c.channels[channel] = st
+	}
+	st.replaying = true
+	if fromSeq > st.lastSeq {
+		st.lastSeq = fromSeq
+	}
+}
+
+// replayed sends a stored event during a replay. Only the replaying reader
+// sends on a replaying channel, so waiting outside the lock keeps the order.
+func (c *Client) replayed(ctx context.Context, channel string, seq int64, payload []byte) error {
+	c.mu.Lock()
+	st, ok := c.channels[channel]
+	if !ok || seq <= st.lastSeq {
+		c.mu.Unlock()
+		return nil
+	}
+	st.lastSeq = seq
+	c.mu.Unlock()
+	return c.sendWait(ctx, payload)
+}
+
+// endReplay flushes the live events held during the replay that it did not
+// already cover.
+func (c *Client) endReplay(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.channels[channel]
+	if !ok {
+		return
+	}
+	pending := st.pending
+	st.replaying, st.pending = false, nil
+	for _, ev := range pending {
+		if ev.seq > 0 {
+			if ev.seq <= st.lastSeq {
+				continue
+			}
+			st.lastSeq = ev.seq
+		}
+		c.send(ev.payload)
+	}
+}
+
+func (c *Client) removeChannel(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.channels[channel]
+	delete(c.channels, channel)
+	return ok
 }
 
 func (c *Client) listChannels() []string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	out := make([]string, 0, len(c.channels))
 	for ch := range c.channels {
 		out = append(out, ch)
 	}
+	sort.Strings(out)
 	return out
 }
@@ -0,0 +1,76 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func drain(client *Client) []string {
+	var out []string
+	for {
+		select {
+		case msg := <-client.out:
+			out = append(out, string(msg))
+		default:
+			return out
+		}
+	}
+}
+
+func TestClientDropPolicies(t *testing.T) {
+	oldest := newClient(nil, Identity{}, 2, DropOldest)
+	for _, msg := range []string{"1", "2", "3"} {
+		oldest.send([]byte(msg))
+	}
+	if got := strings.Join(drain(oldest), ","); got != "2,3" {
+		t.Fatalf("drop_oldest kept %s, want 2,3", got)
+	}
+
+	newest := newClient(nil, Identity{}, 2, DropNewest)
+	for _, msg := range []string{"1", "2", "3"} {
+		newest.send([]byte(msg))
+	}
+	if got := strings.Join(drain(newest), ","); got != "1,2" {
+		t.Fatalf("drop_newest kept %s, want 1,2", got)
+	}
+}
+
+func TestHandlerResumeReplaysOnceAndHoldsLiveEvents(t *testing.T) {
+	const topic = "loan:loan-1"
+	repo := &memRealtimeRepo{cursors: map[string]int64{}}
+	repo.add(3, topic, "loan_status_changed", `{"status":"registered"}`)
+	repo.add(4, "loan:loan-2", "loan_status_changed", `{"status":"registered"}`)
+	repo.add(5, topic, "loan_status_changed", `{"status":"confirmed"}`)
+
+	hub := NewHub()
+	h := NewHandler(hub, nil)
+	h.SetReplay(repo)
+	client := NewClient(nil, Identity{Role: "admin"})
+
+	hub.SubscribeFrom(topic, client, 1)
+	// Published while the replay runs: 5 is also stored, 6 is new.
+	hub.Publish(topic, 5, []byte(`live-5`))
+	hub.Publish(topic, 6, []byte(`live-6`))
+	if err := h.resume(context.Background(), client, topic, 1); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	client.endReplay(topic)
+
+	got := drain(client)
+	if len(got) != 5 {
+		t.Fatalf("expected gap, 2 replayed, resumed and 1 live message, got %v", got)
+	}
+	if !strings.Contains(got[0], `"resume_gap"`) || !strings.Contains(got[1], `"seq":3`) || !strings.Contains(got[2], `"seq":5`) {
+		t.Fatalf("unexpected replay: %v", got)
+	}
+	if !strings.Contains(got[3], `"resumed"`) || !strings.Contains(got[3], `"last_seq":5`) || got[4] != "live-6" {
+		t.Fatalf("unexpected replay tail: %v", got)
+	}
+
+	// Once replayed, a republished event is not sent again.
+	hub.Publish(topic, 6, []byte(`live-6`))
+	if extra := drain(client); len(extra) != 0 {
+		t.Fatalf("expected duplicate dropped, got %v", extra)
+	}
+}
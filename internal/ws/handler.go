@@ -1,92 +1,382 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/loangraph/backend/internal/realtime"
 	"golang.org/x/net/websocket"
 )
 
+const (
+	defaultPingInterval = 25 * time.Second
+	defaultIdleTimeout  = 60 * time.Second
+	replayBatchSize     = 100
+)
+
+// Options tune each connection. The server sends {"event":"ping"} every
+// PingInterval and closes a connection it has not heard from, pong or
+// otherwise, for IdleTimeout, which should be a few ping intervals.
+type Options struct {
+	BufferSize   int
+	DropPolicy   string
+	PingInterval time.Duration
+	IdleTimeout  time.Duration
+}
+
+// ReplayRepository serves resume_from from the retained realtime events.
+type ReplayRepository interface {
+	ListChannelEventsSince(ctx context.Context, channel string, afterSeq int64, limit int32) ([]realtime.Event, error)
+	// OldestRealtimeEventID is the oldest retained id, 0 when none.
+	OldestRealtimeEventID(ctx context.Context) (int64, error)
+}
+
 type Handler struct {
-	hub *Hub
+	hub    *Hub
+	access AccessRepository
+	replay ReplayRepository
+	opts   Options
+}
+
+// NewHandler checks subscriptions against access; without it only admins
+// may subscribe.
+func NewHandler(hub *Hub, access AccessRepository) *Handler {
+	h := &Handler{hub: hub, access: access}
+	h.SetOptions(Options{})
+	return h
+}
+
+// SetOptions applies opts, defaulting unset fields.
+func (h *Handler) SetOptions(opts Options) {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultClientBuffer
+	}
+	switch opts.DropPolicy {
+	case DropOldest, DropNewest:
+	default:
+		opts.DropPolicy = DropDisconnect
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = defaultPingInterval
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	h.opts = opts
+}
+
+// SetReplay enables resume_from; without it resuming subscriptions are
+// refused.
+func (h *Handler) SetReplay(replay ReplayRepository) {
+	h.replay = replay
 }
 
-func NewHandler(hub *Hub) *Handler {
-	return &Handler{hub: hub}
+type clientMessage struct {
+	Action     string `json:"action"`
+	Channel    string `json:"channel"`
+	PoolID     string `json:"poolId"`
+	LenderID   string `json:"lenderId"`
+	LoanID     string `json:"loanId"`
+	BorrowerID string `json:"borrowerId"`
+	// ResumeFrom is the last seq the client received on the channel.
+	ResumeFrom *int64 `json:"resume_from"`
 }
 
-type subscribeMessage struct {
-	Action   string `json:"action"`
-	Channel  string `json:"channel"`
-	PoolID   string `json:"poolId"`
-	LenderID string `json:"lenderId"`
+// subscription is a validated subscribe request: the channel kind, the id
+// it is keyed by and the hub topic.
+type subscription struct {
+	kind  string
+	id    string
+	topic string
 }
 
 func (h *Handler) HandleWebSocket(c *gin.Context) {
+	identity := h.identity(c)
 	websocket.Handler(func(conn *websocket.Conn) {
-		client := NewClient(conn)
+		client := newClient(conn, identity, h.opts.BufferSize, h.opts.DropPolicy)
 		go h.writer(client)
 		h.reader(client)
 	}).ServeHTTP(c.Writer, c.Request)
 }
 
+// identity is the caller's identity as set by the auth middleware.
+func (h *Handler) identity(c *gin.Context) Identity {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("user_role")
+	return Identity{UserID: toString(userID), Role: toString(role)}
+}
+
 func (h *Handler) reader(client *Client) {
 	defer func() {
 		h.hub.UnsubscribeAll(client)
-		close(client.out)
+		client.close()
 		_ = client.conn.Close()
 	}()
 
+	ctx := client.conn.Request().Context()
 	for {
+		_ = client.conn.SetReadDeadline(time.Now().Add(h.opts.IdleTimeout))
 		var raw string
 		if err := websocket.Message.Receive(client.conn, &raw); err != nil {
 			return
 		}
-		var msg subscribeMessage
+		var msg clientMessage
 		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
 			client.send([]byte(`{"event":"error","message":"invalid_json"}`))
 			continue
 		}
-		if strings.ToLower(strings.TrimSpace(msg.Action)) != "subscribe" {
+		switch strings.ToLower(strings.TrimSpace(msg.Action)) {
+		case "subscribe":
+			h.subscribe(ctx, client, msg)
+		case "unsubscribe":
+			sub, ok := parseSubscription(msg)
+			if !ok {
+				client.send([]byte(`{"event":"error","message":"invalid_subscription"}`))
+				continue
+			}
+			if !h.hub.Unsubscribe(sub.topic, client) {
+				client.send(event("error", "message", "not_subscribed", "topic", sub.topic))
+				continue
+			}
+			client.send(event("unsubscribed", "topic", sub.topic))
+		case "list":
+			payload, _ := json.Marshal(map[string]any{"event": "subscriptions", "topics": client.listChannels()})
+			client.send(payload)
+		case "ping":
+			client.send([]byte(`{"event":"pong"}`))
+		case "pong":
+		default:
 			client.send([]byte(`{"event":"error","message":"unsupported_action"}`))
-			continue
 		}
-		topic := subscriptionTopic(msg)
-		if topic == "" {
-			client.send([]byte(`{"event":"error","message":"invalid_subscription"}`))
-			continue
+	}
+}
+
+func (h *Handler) subscribe(ctx context.Context, client *Client, msg clientMessage) {
+	sub, ok := parseSubscription(msg)
+	if !ok || (msg.ResumeFrom != nil && *msg.ResumeFrom < 0) {
+		client.send([]byte(`{"event":"error","message":"invalid_subscription"}`))
+		return
+	}
+	if err := authorize(ctx, h.access, client.identity, sub); err != nil {
+		message := "subscription_check_failed"
+		if errors.Is(err, errForbidden) {
+			message = "forbidden"
+		}
+		client.send(event("error", "message", message, "topic", sub.topic))
+		return
+	}
+	if msg.ResumeFrom == nil {
+		h.hub.Subscribe(sub.topic, client)
+		client.send(event("subscribed", "topic", sub.topic))
+		return
+	}
+	if h.replay == nil {
+		client.send(event("error", "message", "resume_unavailable", "topic", sub.topic))
+		return
+	}
+
+	// Subscribe before reading the stored events so nothing committed in
+	// between is missed; live events wait until the replay is sent.
+	from := *msg.ResumeFrom
+	h.hub.SubscribeFrom(sub.topic, client, from)
+	defer client.endReplay(sub.topic)
+	ack, _ := json.Marshal(map[string]any{"event": "subscribed", "topic": sub.topic, "resume_from": from})
+	client.send(ack)
+	if err := h.resume(ctx, client, sub.topic, from); err != nil {
+		client.send(event("error", "message", "resume_failed", "topic", sub.topic))
+	}
+}
+
+// RecheckUser authorizes the subscriptions of userID's websocket and event
+// stream clients on this node again and drops those refused, telling the
+// client with an unsubscribed event whose reason is forbidden. A check that
+// fails drops the subscription as well, since it runs because the user just
+// lost access.
+func (h *Handler) RecheckUser(ctx context.Context, userID string) {
+	for _, client := range h.hub.clientsOf(userID) {
+		for _, topic := range client.listChannels() {
+			if sub, ok := parseTopic(topic); ok && authorize(ctx, h.access, client.identity, sub) == nil {
+				continue
+			}
+			if h.hub.Unsubscribe(topic, client) {
+				client.send(event("unsubscribed", "topic", topic, "reason", "forbidden"))
+			}
 		}
-		h.hub.Subscribe(topic, client)
-		ack, _ := json.Marshal(map[string]string{"event": "subscribed", "topic": topic})
-		client.send(ack)
+	}
+}
+
+// resume replays the retained events of topic after from. When older events
+// may already be pruned the client is told with resume_gap first, so it can
+// reload state instead of assuming it saw everything.
+func (h *Handler) resume(ctx context.Context, client *Client, topic string, from int64) error {
+	oldest, err := h.replayGap(ctx, from)
+	if err != nil {
+		return err
+	}
+	if oldest > 0 {
+		gap, _ := json.Marshal(map[string]any{"event": "resume_gap", "topic": topic, "oldest_seq": oldest})
+		if err := client.sendWait(ctx, gap); err != nil {
+			return err
+		}
+	}
+	last, replayed, err := h.replayEvents(ctx, client, []string{topic}, from)
+	if err != nil {
+		return err
+	}
+	done, _ := json.Marshal(map[string]any{"event": "resumed", "topic": topic, "replayed": replayed, "last_seq": last})
+	return client.sendWait(ctx, done)
+}
+
+// replayGap returns the oldest retained seq when events after from may
+// already be pruned, and 0 otherwise.
+func (h *Handler) replayGap(ctx context.Context, from int64) (int64, error) {
+	oldest, err := h.replay.OldestRealtimeEventID(ctx)
+	if err != nil || oldest <= from+1 {
+		return 0, err
+	}
+	return oldest, nil
+}
+
+// replayEvents sends the retained events of topics after from, merged in seq
+// order so that the last seq sent is a valid resume point for all of them.
+// It returns that seq, from when nothing was replayed. Events after the
+// hub's head are left to live delivery, since an event below them may still
+// commit; the client is subscribed before head is read, so it holds them.
+func (h *Handler) replayEvents(ctx context.Context, client *Client, topics []string, from int64) (int64, int, error) {
+	head := h.hub.head.Load()
+	type cursor struct {
+		topic string
+		after int64
+		page  []realtime.Event
+		more  bool
+	}
+	cursors := make([]*cursor, 0, len(topics))
+	for _, topic := range topics {
+		cursors = append(cursors, &cursor{topic: topic, after: from, more: true})
+	}
+	last, replayed := from, 0
+	for {
+		var next *cursor
+		for _, cur := range cursors {
+			if len(cur.page) == 0 && cur.more {
+				page, err := h.replay.ListChannelEventsSince(ctx, cur.topic, cur.after, replayBatchSize)
+				if err != nil {
+					return last, replayed, err
+				}
+				cur.page, cur.more = page, len(page) == replayBatchSize
+				for i, ev := range cur.page {
+					if ev.ID > head {
+						cur.page, cur.more = cur.page[:i], false
+						break
+					}
+				}
+			}
+			if len(cur.page) > 0 && (next == nil || cur.page[0].ID < next.page[0].ID) {
+				next = cur
+			}
+		}
+		if next == nil {
+			return last, replayed, nil
+		}
+		ev := next.page[0]
+		next.page, next.after = next.page[1:], ev.ID
+		payload, err := encodeEvent(ev)
+		if err != nil {
+			return last, replayed, err
+		}
+		if err := client.replayed(ctx, next.topic, ev.ID, payload); err != nil {
+			return last, replayed, err
+		}
+		last = ev.ID
+		replayed++
 	}
 }
 
 func (h *Handler) writer(client *Client) {
-	for payload := range client.out {
-		if err := websocket.Message.Send(client.conn, string(payload)); err != nil {
+	defer func() {
+		client.close()
+		_ = client.conn.Close()
+	}()
+	ticker := time.NewTicker(h.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-client.done:
 			return
+		case payload := <-client.out:
+			if err := websocket.Message.Send(client.conn, string(payload)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := websocket.Message.Send(client.conn, `{"event":"ping"}`); err != nil {
+				return
+			}
 		}
 	}
 }
 
-func subscriptionTopic(msg subscribeMessage) string {
-	channel := strings.ToLower(strings.TrimSpace(msg.Channel))
-	switch channel {
-	case "pool:repayments":
-		poolID := strings.TrimSpace(msg.PoolID)
-		if poolID == "" {
-			return ""
+// event encodes a protocol message from key/value pairs.
+func event(name string, kv ...string) []byte {
+	out := map[string]string{"event": name}
+	for i := 0; i+1 < len(kv); i += 2 {
+		out[kv[i]] = kv[i+1]
+	}
+	payload, _ := json.Marshal(out)
+	return payload
+}
+
+func parseSubscription(msg clientMessage) (subscription, bool) {
+	kind := strings.ToLower(strings.TrimSpace(msg.Channel))
+	withID := func(id string, topic func(string) string) (subscription, bool) {
+		if id = strings.TrimSpace(id); id == "" {
+			return subscription{}, false
 		}
-		return "pool:repayments:" + poolID
+		return subscription{kind: kind, id: id, topic: topic(id)}, true
+	}
+	switch kind {
+	case "pool:repayments":
+		return withID(msg.PoolID, realtime.PoolRepaymentsChannel)
 	case "lender:portfolio":
-		lenderID := strings.TrimSpace(msg.LenderID)
-		if lenderID == "" {
-			return ""
-		}
-		return "lender:portfolio:" + lenderID
+		return withID(msg.LenderID, realtime.LenderPortfolioChannel)
+	case "lender:defaults":
+		return withID(msg.LenderID, realtime.LenderDefaultsChannel)
+	case "lender:uploads":
+		return withID(msg.LenderID, realtime.LenderUploadsChannel)
+	case "loan":
+		return withID(msg.LoanID, realtime.LoanChannel)
+	case "passport":
+		return withID(msg.BorrowerID, realtime.PassportChannel)
+	case realtime.OutboxFailuresChannel:
+		return subscription{kind: kind, topic: realtime.OutboxFailuresChannel}, true
 	default:
-		return ""
+		return subscription{}, false
 	}
 }
+
+// parseTopic is parseSubscription for a hub topic such as "loan:<id>", as
+// named in the SSE channels parameter.
+func parseTopic(topic string) (subscription, bool) {
+	topic = strings.TrimSpace(topic)
+	if topic == realtime.OutboxFailuresChannel {
+		return parseSubscription(clientMessage{Channel: topic})
+	}
+	for _, kind := range []string{"pool:repayments", "lender:portfolio", "lender:defaults", "lender:uploads", "loan", "passport"} {
+		id, ok := strings.CutPrefix(topic, kind+":")
+		if !ok {
+			continue
+		}
+		// parseSubscription reads only the id field of kind.
+		sub, ok := parseSubscription(clientMessage{Channel: kind, PoolID: id, LenderID: id, LoanID: id, BorrowerID: id})
+		return sub, ok && sub.topic == topic
+	}
+	return subscription{}, false
+}
+
+func toString(v any) string {
+	s, _ := v.(string)
+	return s
+}
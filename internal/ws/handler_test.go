@@ -0,0 +1,124 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseSubscription(t *testing.T) {
+	cases := []struct {
+		msg  clientMessage
+		want string
+	}{
+		{clientMessage{Channel: "pool:repayments", PoolID: "pool-1"}, "pool:repayments:pool-1"},
+		{clientMessage{Channel: "lender:portfolio", LenderID: "lender-1"}, "lender:portfolio:lender-1"},
+		{clientMessage{Channel: "lender:defaults", LenderID: "lender-1"}, "lender:defaults:lender-1"},
+		{clientMessage{Channel: "lender:uploads", LenderID: " lender-1 "}, "lender:uploads:lender-1"},
+		{clientMessage{Channel: "LOAN", LoanID: "loan-1"}, "loan:loan-1"},
+		{clientMessage{Channel: "passport", BorrowerID: "borrower-1"}, "passport:borrower-1"},
+		{clientMessage{Channel: "admin:outbox"}, "admin:outbox"},
+		{clientMessage{Channel: "loan"}, ""},
+		{clientMessage{Channel: "lender:defaults", PoolID: "pool-1"}, ""},
+		{clientMessage{Channel: "unknown", LoanID: "loan-1"}, ""},
+	}
+	for _, tc := range cases {
+		sub, ok := parseSubscription(tc.msg)
+		if sub.topic != tc.want || ok != (tc.want != "") {
+			t.Fatalf("parseSubscription(%+v) = %q %v, want %q", tc.msg, sub.topic, ok, tc.want)
+		}
+	}
+}
+
+type fakeAccess struct {
+	owners    map[string]string
+	positions map[string]bool
+	members   map[string][]string
+}
+
+func (f fakeAccess) ListLenderIDsByUser(_ context.Context, userID string) ([]string, error) {
+	return f.members[userID], nil
+}
+
+func (f fakeAccess) GetPoolLenderID(_ context.Context, id string) (string, error) {
+	return f.owners[id], nil
+}
+
+func (f fakeAccess) GetLoanLenderID(_ context.Context, id string) (string, error) {
+	return f.owners[id], nil
+}
+
+func (f fakeAccess) GetBorrowerLenderID(_ context.Context, id string) (string, error) {
+	return f.owners[id], nil
+}
+
+func (f fakeAccess) HasPoolPosition(_ context.Context, poolID, userID string) (bool, error) {
+	return f.positions[poolID+"/"+userID], nil
+}
+
+func TestAuthorizeSubscriptions(t *testing.T) {
+	access := fakeAccess{
+		owners:    map[string]string{"pool-1": "lender-1", "loan-1": "lender-1", "borrower-1": "lender-1", "pool-2": "lender-2", "loan-2": "lender-2"},
+		positions: map[string]bool{"pool-2/investor-1": true},
+		members:   map[string][]string{"user-1": {"lender-1"}},
+	}
+	admin := Identity{UserID: "admin-1", Role: "admin"}
+	lender := Identity{UserID: "user-1", Role: "lender"}
+	investor := Identity{UserID: "investor-1", Role: "investor"}
+	cases := []struct {
+		id      Identity
+		msg     clientMessage
+		allowed bool
+	}{
+		{admin, clientMessage{Channel: "lender:portfolio", LenderID: "lender-2"}, true},
+		{admin, clientMessage{Channel: "admin:outbox"}, true},
+		{lender, clientMessage{Channel: "lender:portfolio", LenderID: "lender-1"}, true},
+		{lender, clientMessage{Channel: "lender:portfolio", LenderID: "lender-2"}, false},
+		{lender, clientMessage{Channel: "lender:defaults", LenderID: "lender-2"}, false},
+		{lender, clientMessage{Channel: "pool:repayments", PoolID: "pool-1"}, true},
+		{lender, clientMessage{Channel: "pool:repayments", PoolID: "pool-2"}, false},
+		{lender, clientMessage{Channel: "loan", LoanID: "loan-1"}, true},
+		{lender, clientMessage{Channel: "loan", LoanID: "loan-2"}, false},
+		{lender, clientMessage{Channel: "loan", LoanID: "loan-missing"}, false},
+		{lender, clientMessage{Channel: "passport", BorrowerID: "borrower-1"}, true},
+		{lender, clientMessage{Channel: "admin:outbox"}, false},
+		{investor, clientMessage{Channel: "pool:repayments", PoolID: "pool-2"}, true},
+		{investor, clientMessage{Channel: "pool:repayments", PoolID: "pool-1"}, false},
+		{investor, clientMessage{Channel: "lender:portfolio", LenderID: "lender-2"}, false},
+		// A membership held under another role does not count.
+		{Identity{UserID: "user-1", Role: "investor"}, clientMessage{Channel: "loan", LoanID: "loan-1"}, false},
+	}
+	for _, tc := range cases {
+		sub, _ := parseSubscription(tc.msg)
+		err := authorize(context.Background(), access, tc.id, sub)
+		if tc.allowed && err != nil || !tc.allowed && !errors.Is(err, errForbidden) {
+			t.Fatalf("authorize(%s, %s) = %v, want allowed=%v", tc.id.Role, sub.topic, err, tc.allowed)
+		}
+	}
+	if err := authorize(context.Background(), nil, lender, subscription{kind: "lender:portfolio", id: "lender-1"}); !errors.Is(err, errForbidden) {
+		t.Fatalf("expected non-admins forbidden without access repository, got %v", err)
+	}
+
+	// Membership is read per subscribe: once removed, the same identity is
+	// refused.
+	access.members["user-1"] = nil
+	sub, _ := parseSubscription(clientMessage{Channel: "lender:portfolio", LenderID: "lender-1"})
+	if err := authorize(context.Background(), access, lender, sub); !errors.Is(err, errForbidden) {
+		t.Fatalf("expected a removed member refused, got %v", err)
+	}
+}
+
+func TestParseTopics(t *testing.T) {
+	subs, ok := parseTopics("pool:repayments:pool-1, loan:loan-1,admin:outbox,loan:loan-1,")
+	if !ok || len(subs) != 3 {
+		t.Fatalf("expected 3 distinct topics, got %+v %v", subs, ok)
+	}
+	if subs[0].kind != "pool:repayments" || subs[0].id != "pool-1" || subs[1].kind != "loan" || subs[1].id != "loan-1" || subs[2].topic != "admin:outbox" {
+		t.Fatalf("unexpected subscriptions: %+v", subs)
+	}
+	for _, raw := range []string{"", "loan:", "lender:lender-1", "pool:repayments", "admin:outbox:x", "loan:loan-1,bogus"} {
+		if _, ok := parseTopics(raw); ok {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
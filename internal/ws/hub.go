@@ -1,10 +1,18 @@
 package ws
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 type Hub struct {
 	mu          sync.RWMutex
 	subscribers map[string]map[*Client]struct{}
+	// head is the highest seq published. The notifier publishes in seq order
+	// past gaps only once they are final, so every event up to head that
+	// will ever be stored already is; replays stop there and leave the rest
+	// to live delivery.
+	head atomic.Int64
 }
 
 func NewHub() *Hub {
@@ -12,34 +20,93 @@ func NewHub() *Hub {
 }
 
 func (h *Hub) Subscribe(channel string, client *Client) {
+	client.addChannel(channel)
+	h.register(channel, client)
+}
+
+// SubscribeFrom subscribes client with live events held back until
+// client.endReplay, so the caller can first replay what followed fromSeq.
+func (h *Hub) SubscribeFrom(channel string, client *Client, fromSeq int64) {
+	client.beginReplay(channel, fromSeq)
+	h.register(channel, client)
+}
+
+func (h *Hub) register(channel string, client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if _, ok := h.subscribers[channel]; !ok {
 		h.subscribers[channel] = map[*Client]struct{}{}
 	}
 	h.subscribers[channel][client] = struct{}{}
-	client.addChannel(channel)
+}
+
+// Unsubscribe reports whether client was subscribed to channel.
+func (h *Hub) Unsubscribe(channel string, client *Client) bool {
+	h.mu.Lock()
+	h.unregister(channel, client)
+	h.mu.Unlock()
+	return client.removeChannel(channel)
 }
 
 func (h *Hub) UnsubscribeAll(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	for _, channel := range client.listChannels() {
-		if subs, ok := h.subscribers[channel]; ok {
-			delete(subs, client)
-			if len(subs) == 0 {
-				delete(h.subscribers, channel)
-			}
+		h.unregister(channel, client)
+	}
+}
+
+func (h *Hub) unregister(channel string, client *Client) {
+	if subs, ok := h.subscribers[channel]; ok {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(h.subscribers, channel)
 		}
 	}
 }
 
-func (h *Hub) Publish(channel string, payload []byte) {
+// Publish delivers payload to the channel's subscribers. seq is the event's
+// position in realtime_events; clients skip seqs they already received.
+func (h *Hub) Publish(channel string, seq int64, payload []byte) {
+	// Raised before the subscribers are read, so a client subscribing after
+	// that reads a head that covers seq and replays it instead.
+	h.advance(seq)
 	h.mu.RLock()
-	subs := h.subscribers[channel]
+	subs := make([]*Client, 0, len(h.subscribers[channel]))
+	for c := range h.subscribers[channel] {
+		subs = append(subs, c)
+	}
 	h.mu.RUnlock()
 
-	for c := range subs {
-		c.send(payload)
+	for _, c := range subs {
+		c.deliver(channel, seq, payload)
+	}
+}
+
+// advance raises head to seq.
+func (h *Hub) advance(seq int64) {
+	for {
+		cur := h.head.Load()
+		if seq <= cur || h.head.CompareAndSwap(cur, seq) {
+			return
+		}
+	}
+}
+
+// clientsOf returns the clients of userID with at least one subscription.
+func (h *Hub) clientsOf(userID string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	seen := map[*Client]struct{}{}
+	var out []*Client
+	for _, subs := range h.subscribers {
+		for c := range subs {
+			if _, ok := seen[c]; ok || c.identity.UserID != userID {
+				continue
+			}
+			seen[c] = struct{}{}
+			out = append(out, c)
+		}
 	}
+	return out
 }
@@ -7,10 +7,10 @@ import (
 
 func TestHubSubscribeAndPublish(t *testing.T) {
 	hub := NewHub()
-	client := NewClient(nil)
+	client := NewClient(nil, Identity{})
 
 	hub.Subscribe("pool:repayments:pool-1", client)
-	hub.Publish("pool:repayments:pool-1", []byte(`{"event":"repayment_recorded"}`))
+	hub.Publish("pool:repayments:pool-1", 1, []byte(`{"event":"repayment_recorded"}`))
 
 	select {
 	case msg := <-client.out:
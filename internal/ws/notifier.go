@@ -3,89 +3,281 @@ package ws
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"log/slog"
+	"os"
 	"time"
+
+	"github.com/loangraph/backend/internal/realtime"
+)
+
+const (
+	notifierBatchSize = 100
+	pruneInterval     = time.Minute
 )
 
-type RealtimeEvent struct {
-	ID          int64
-	LoanID      string
-	LenderID    string
-	AmountMinor int64
-	Currency    string
-	RecordedAt  time.Time
+// Envelope is the message clients receive for every realtime event. Seq
+// orders events across all channels; a reconnecting client passes the last
+// seq it saw as resume_from.
+type Envelope struct {
+	Event   string          `json:"event"`
+	Channel string          `json:"channel"`
+	Seq     int64           `json:"seq"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func encodeEvent(ev realtime.Event) ([]byte, error) {
+	return json.Marshal(Envelope{Event: ev.Type, Channel: ev.Channel, Seq: ev.ID, Data: ev.Data})
 }
 
 type RealtimeRepository interface {
-	ListRepaymentEventsSince(ctx context.Context, lastID int64, limit int32) ([]RealtimeEvent, error)
-	ListPoolsByLender(ctx context.Context, lenderID string) ([]string, error)
+	// ListRealtimeEventsSince returns stored events after afterID in id
+	// order, with the bounds of the snapshot they were read in.
+	ListRealtimeEventsSince(ctx context.Context, afterID int64, limit int32) (realtime.EventPage, error)
+	GetRealtimeCursor(ctx context.Context, nodeID string) (int64, bool, error)
+	SetRealtimeCursor(ctx context.Context, nodeID string, seq int64) error
+	// LatestRealtimeEventID is the newest stored id, 0 when none.
+	LatestRealtimeEventID(ctx context.Context) (int64, error)
+	PruneRealtimeEvents(ctx context.Context, before time.Time) (int64, error)
+	// PruneRealtimeCursors deletes cursors last moved before before.
+	PruneRealtimeCursors(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ProjectionListener wakes the notifier when producers commit realtime
+// events, e.g. via LISTEN/NOTIFY. Like the chain head subscriber it carries no data:
+// the notifier reads from its cursor, so nothing is lost while it is down.
+type ProjectionListener interface {
+	// Run keeps listening until ctx is done, signalling wake on every
+	// notification and after each (re)connect.
+	Run(ctx context.Context, wake chan<- struct{})
+	// Connected reports whether notifications are flowing; the notifier
+	// polls while they are not.
+	Connected() bool
 }
 
+// Notifier publishes stored realtime events to the hub. Its cursor is
+// persisted per node, so a restarted node resumes where it stopped and every
+// replica publishes each event to its own clients once.
+//
+// Producers do not serialize, so an event can commit before one with a
+// lower id. The notifier publishes in id order and stops at a missing id
+// until it knows the id will never show up: producers take a transaction id
+// before an event id, so the missing id belongs to a transaction that was
+// running when the notifier saw the ids after it, and once every transaction
+// older than that snapshot's xmax has finished the gap is final.
 type Notifier struct {
 	repo         RealtimeRepository
 	hub          *Hub
+	listener     ProjectionListener
+	nodeID       string
 	pollInterval time.Duration
-	lastID       int64
+	logger       *slog.Logger
+	retention    time.Duration
+	revoker      Revoker
+	lastSeq      int64
+	loaded       bool
+	gap          heldGap
+}
+
+// Revoker drops the subscriptions a user may no longer see.
+type Revoker interface {
+	// RecheckUser authorizes every subscription of userID on this node again.
+	RecheckUser(ctx context.Context, userID string)
+}
+
+// heldGap records a gap below through, seen in a snapshot whose xmax was
+// xmax; it is final once the oldest running transaction is no older than
+// xmax. The zero value means no gap is held.
+type heldGap struct {
+	through int64
+	xmax    int64
 }
 
-func NewNotifier(repo RealtimeRepository, hub *Hub, pollInterval time.Duration) *Notifier {
+// NewNotifier follows the cursor of nodeID, which should be stable across
+// restarts of the same replica; it defaults to the hostname.
+func NewNotifier(repo RealtimeRepository, hub *Hub, nodeID string, pollInterval time.Duration, logger *slog.Logger) *Notifier {
 	if pollInterval <= 0 {
 		pollInterval = 2 * time.Second
 	}
-	return &Notifier{repo: repo, hub: hub, pollInterval: pollInterval}
+	if nodeID == "" {
+		nodeID = defaultNodeID()
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Notifier{repo: repo, hub: hub, nodeID: nodeID, pollInterval: pollInterval, logger: logger}
+}
+
+// SetListener makes the notifier react to notifications, polling every
+// pollInterval only while the listener is disconnected.
+func (n *Notifier) SetListener(l ProjectionListener) {
+	n.listener = l
 }
 
+// SetRetention makes the notifier delete events older than d, bounding how
+// far back clients can resume, and the cursors of nodes that have not moved
+// them for as long, such as those of replicas whose hostname changed. Every
+// node prunes; the deletes are idempotent.
+func (n *Notifier) SetRetention(d time.Duration) {
+	n.retention = d
+}
+
+// SetRevoker hands revoked lender memberships to r. They are handled in seq
+// order with the other events, so no event of the lender committed after the
+// revocation reaches the removed member.
+func (n *Notifier) SetRevoker(r Revoker) {
+	n.revoker = r
+}
+
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "api"
+	}
+	return host
+}
+
+// Run publishes until ctx is done. Read errors are logged and retried on the
+// next notification or poll.
 func (n *Notifier) Run(ctx context.Context) error {
+	catchUp := func() {
+		if err := n.catchUp(ctx); err != nil && ctx.Err() == nil {
+			n.logger.Error("ws notifier catch-up failed", "node_id", n.nodeID, "err", err)
+		}
+	}
+	catchUp()
+
+	// The listener wakes the loop once it is listening, which covers events
+	// committed between this first catch-up and LISTEN.
+	wake := make(chan struct{}, 1)
+	if n.listener != nil {
+		go n.listener.Run(ctx, wake)
+	}
 	ticker := time.NewTicker(n.pollInterval)
 	defer ticker.Stop()
-
+	var prune <-chan time.Time
+	if n.retention > 0 {
+		pruneTicker := time.NewTicker(pruneInterval)
+		defer pruneTicker.Stop()
+		prune = pruneTicker.C
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-wake:
+			catchUp()
 		case <-ticker.C:
-			if err := n.tick(ctx); err != nil {
-				return err
+			// A held gap closes without a notification when the
+			// transaction owning it rolls back, so it is polled too.
+			if n.listener == nil || !n.listener.Connected() || n.gap != (heldGap{}) {
+				catchUp()
+			}
+		case <-prune:
+			if err := n.prune(ctx, time.Now().Add(-n.retention)); err != nil && ctx.Err() == nil {
+				n.logger.Error("ws notifier prune failed", "node_id", n.nodeID, "err", err)
 			}
 		}
 	}
 }
 
-func (n *Notifier) tick(ctx context.Context) error {
-	events, err := n.repo.ListRepaymentEventsSince(ctx, n.lastID, 100)
-	if err != nil {
+// prune deletes events and cursors older than before. A cursor that old
+// points at events already pruned, so its node, were it still running with
+// no events to publish, loses nothing by starting at the newest event; a
+// running node recreates its cursor with its next batch.
+func (n *Notifier) prune(ctx context.Context, before time.Time) error {
+	if _, err := n.repo.PruneRealtimeEvents(ctx, before); err != nil {
 		return err
 	}
-	for _, ev := range events {
-		if ev.ID > n.lastID {
-			n.lastID = ev.ID
-		}
-		pools, err := n.repo.ListPoolsByLender(ctx, ev.LenderID)
+	_, err := n.repo.PruneRealtimeCursors(ctx, before)
+	return err
+}
+
+// catchUp publishes batches until the cursor reaches the newest stored
+// event. A node without a cursor starts at the newest event; its clients
+// connected after anything older was published.
+func (n *Notifier) catchUp(ctx context.Context) error {
+	if !n.loaded {
+		seq, ok, err := n.repo.GetRealtimeCursor(ctx, n.nodeID)
 		if err != nil {
 			return err
 		}
-		payload, _ := json.Marshal(map[string]any{
-			"event": "repayment_recorded",
-			"data": map[string]any{
-				"loan_id":      ev.LoanID,
-				"lender_id":    ev.LenderID,
-				"amount_minor": ev.AmountMinor,
-				"currency":     ev.Currency,
-				"recorded_at":  ev.RecordedAt.UTC().Format(time.RFC3339),
-			},
-		})
-		for _, poolID := range pools {
-			n.hub.Publish("pool:repayments:"+poolID, payload)
+		if !ok {
+			if seq, err = n.repo.LatestRealtimeEventID(ctx); err != nil {
+				return err
+			}
+			if err := n.repo.SetRealtimeCursor(ctx, n.nodeID, seq); err != nil {
+				return err
+			}
+		}
+		n.lastSeq, n.loaded = seq, true
+		n.hub.advance(seq)
+	}
+	for {
+		count, err := n.tick(ctx)
+		if err != nil || count < notifierBatchSize {
+			return err
+		}
+	}
+}
+
+// tick publishes the next batch up to the first gap that may still fill and
+// returns how many events it published.
+func (n *Notifier) tick(ctx context.Context) (int, error) {
+	page, err := n.repo.ListRealtimeEventsSince(ctx, n.lastSeq, notifierBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, ev := range page.Events {
+		if ev.ID > n.lastSeq+1 && !n.gapFinal(ev.ID, page) {
+			break
 		}
+		n.publish(ctx, ev)
+		n.lastSeq = ev.ID
+		published++
+	}
+	if n.lastSeq >= n.gap.through {
+		n.gap = heldGap{}
+	}
+	if published == 0 {
+		return 0, nil
+	}
+	// Publishing is at least once: a crash before this write republishes
+	// the batch on restart.
+	return published, n.repo.SetRealtimeCursor(ctx, n.nodeID, n.lastSeq)
+}
 
-		portfolioPayload, _ := json.Marshal(map[string]any{
-			"event": "portfolio_updated",
-			"data": map[string]any{
-				"lender_id": ev.LenderID,
-				"source":    "repayment_recorded",
-			},
-		})
-		n.hub.Publish(fmt.Sprintf("lender:portfolio:%s", ev.LenderID), portfolioPayload)
+// gapFinal reports whether every id between the cursor and id is either in
+// page or will never be written. A held gap is kept until it is final, so
+// that a busy database, whose xmax keeps moving, cannot postpone it forever;
+// only then does a gap further on start a new one.
+func (n *Notifier) gapFinal(id int64, page realtime.EventPage) bool {
+	if n.gap == (heldGap{}) || (id > n.gap.through && page.Xmin >= n.gap.xmax) {
+		n.gap = heldGap{through: page.Events[len(page.Events)-1].ID, xmax: page.Xmax}
+	}
+	return id <= n.gap.through && page.Xmin >= n.gap.xmax
+}
+
+func (n *Notifier) publish(ctx context.Context, ev realtime.Event) {
+	if ev.Channel == realtime.LenderMembersChannel {
+		n.revoke(ctx, ev)
+		return
+	}
+	payload, err := encodeEvent(ev)
+	if err != nil {
+		n.logger.Error("ws notifier dropped malformed event", "id", ev.ID, "err", err)
+		return
+	}
+	n.hub.Publish(ev.Channel, ev.ID, payload)
+}
+
+func (n *Notifier) revoke(ctx context.Context, ev realtime.Event) {
+	var removed realtime.LenderMemberRemoved
+	if err := json.Unmarshal(ev.Data, &removed); err != nil {
+		n.logger.Error("ws notifier dropped malformed event", "id", ev.ID, "err", err)
+		return
+	}
+	if n.revoker != nil {
+		n.revoker.RecheckUser(ctx, removed.UserID)
 	}
-	return nil
 }
@@ -0,0 +1,325 @@
+package ws
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/realtime"
+)
+
+type memRealtimeRepo struct {
+	mu       sync.Mutex
+	events   []realtime.Event
+	cursors  map[string]int64
+	cursorAt map[string]time.Time
+	// xmin and xmax bound the snapshot of every read; equal, the default,
+	// means no transaction is running.
+	xmin, xmax int64
+}
+
+// add stores an event, keeping events in id order.
+func (r *memRealtimeRepo) add(id int64, channel, eventType, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := sort.Search(len(r.events), func(i int) bool { return r.events[i].ID > id })
+	r.events = slices.Insert(r.events, i, realtime.Event{ID: id, Channel: channel, Type: eventType, Data: []byte(data)})
+}
+
+func (r *memRealtimeRepo) snapshot(xmin, xmax int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.xmin, r.xmax = xmin, xmax
+}
+
+func (r *memRealtimeRepo) ListRealtimeEventsSince(_ context.Context, afterID int64, limit int32) (realtime.EventPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	page := realtime.EventPage{Xmin: r.xmin, Xmax: r.xmax}
+	for _, ev := range r.events {
+		if ev.ID > afterID && int32(len(page.Events)) < limit {
+			page.Events = append(page.Events, ev)
+		}
+	}
+	return page, nil
+}
+
+func (r *memRealtimeRepo) GetRealtimeCursor(_ context.Context, nodeID string) (int64, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seq, ok := r.cursors[nodeID]
+	return seq, ok, nil
+}
+
+func (r *memRealtimeRepo) SetRealtimeCursor(_ context.Context, nodeID string, seq int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursors[nodeID] = seq
+	if r.cursorAt == nil {
+		r.cursorAt = make(map[string]time.Time)
+	}
+	r.cursorAt[nodeID] = time.Now()
+	return nil
+}
+
+func (r *memRealtimeRepo) LatestRealtimeEventID(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest int64
+	for _, ev := range r.events {
+		if ev.ID > latest {
+			latest = ev.ID
+		}
+	}
+	return latest, nil
+}
+
+func (r *memRealtimeRepo) PruneRealtimeEvents(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.events[:0]
+	for _, ev := range r.events {
+		if !ev.CreatedAt.Before(before) {
+			kept = append(kept, ev)
+		}
+	}
+	pruned := int64(len(r.events) - len(kept))
+	r.events = kept
+	return pruned, nil
+}
+
+func (r *memRealtimeRepo) PruneRealtimeCursors(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pruned int64
+	for nodeID := range r.cursors {
+		if r.cursorAt[nodeID].Before(before) {
+			delete(r.cursors, nodeID)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func (r *memRealtimeRepo) ListChannelEventsSince(_ context.Context, channel string, afterSeq int64, limit int32) ([]realtime.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []realtime.Event
+	for _, ev := range r.events {
+		if ev.Channel == channel && ev.ID > afterSeq && int32(len(out)) < limit {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (r *memRealtimeRepo) OldestRealtimeEventID(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return 0, nil
+	}
+	return r.events[0].ID, nil
+}
+
+type chanListener struct {
+	wake      chan chan<- struct{}
+	connected atomic.Bool
+}
+
+func (l *chanListener) Run(ctx context.Context, wake chan<- struct{}) {
+	l.connected.Store(true)
+	l.wake <- wake
+	<-ctx.Done()
+}
+
+func (l *chanListener) Connected() bool {
+	return l.connected.Load()
+}
+
+func receive(t *testing.T, client *Client) string {
+	t.Helper()
+	select {
+	case msg := <-client.out:
+		return string(msg)
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for message")
+		return ""
+	}
+}
+
+func TestNotifierResumesFromPersistedCursorOnNotification(t *testing.T) {
+	repo := &memRealtimeRepo{cursors: map[string]int64{}}
+	repo.add(1, "pool:repayments:pool-1", "repayment_recorded", `{"loan_id":"loan-old"}`)
+	hub := NewHub()
+	client := NewClient(nil, Identity{})
+	hub.Subscribe("pool:repayments:pool-1", client)
+
+	// A new node starts at the newest event instead of replaying history.
+	listener := &chanListener{wake: make(chan chan<- struct{}, 1)}
+	n := NewNotifier(repo, hub, "node-a", time.Hour, nil)
+	n.SetListener(listener)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = n.Run(ctx)
+		close(done)
+	}()
+	wake := <-listener.wake
+
+	repo.add(2, "pool:repayments:pool-1", "repayment_recorded", `{"loan_id":"loan-new"}`)
+	wake <- struct{}{}
+	if msg := receive(t, client); !strings.Contains(msg, "loan-new") {
+		t.Fatalf("expected the new repayment, got %s", msg)
+	}
+	cancel()
+	<-done
+	if repo.cursors["node-a"] != 2 {
+		t.Fatalf("expected cursor 2 persisted, got %d", repo.cursors["node-a"])
+	}
+
+	// Restarted, the node publishes what was stored while it was down,
+	// without any notification and without repeating event 2.
+	repo.add(3, "pool:repayments:pool-1", "repayment_recorded", `{"loan_id":"loan-missed"}`)
+	restarted := NewNotifier(repo, hub, "node-a", time.Hour, nil)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = restarted.Run(ctx) }()
+	if msg := receive(t, client); !strings.Contains(msg, "loan-missed") {
+		t.Fatalf("expected the missed repayment, got %s", msg)
+	}
+}
+
+func TestNotifierPollsWhileListenerIsDisconnected(t *testing.T) {
+	repo := &memRealtimeRepo{cursors: map[string]int64{"node-b": 0}}
+	hub := NewHub()
+	client := NewClient(nil, Identity{})
+	hub.Subscribe("lender:portfolio:lender-1", client)
+
+	listener := &chanListener{wake: make(chan chan<- struct{}, 1)}
+	n := NewNotifier(repo, hub, "node-b", 10*time.Millisecond, nil)
+	n.SetListener(listener)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = n.Run(ctx) }()
+	<-listener.wake
+	listener.connected.Store(false)
+
+	repo.add(7, "lender:portfolio:lender-1", "portfolio_updated", `{"lender_id":"lender-1"}`)
+	want := `{"event":"portfolio_updated","channel":"lender:portfolio:lender-1","seq":7,"data":{"lender_id":"lender-1"}}`
+	if msg := receive(t, client); msg != want {
+		t.Fatalf("expected portfolio update envelope from polling, got %s", msg)
+	}
+}
+
+func TestNotifierHoldsGapsUntilFinal(t *testing.T) {
+	const channel = "loan:loan-1"
+	repo := &memRealtimeRepo{cursors: map[string]int64{"node-c": 0}}
+	hub := NewHub()
+	client := NewClient(nil, Identity{})
+	hub.Subscribe(channel, client)
+	n := NewNotifier(repo, hub, "node-c", time.Hour, nil)
+	ctx := context.Background()
+
+	// Event 2 commits while transaction 10, which may hold id 1, is still
+	// running: 2 waits.
+	repo.add(2, channel, "loan_status_changed", `{"status":"confirmed"}`)
+	repo.snapshot(10, 12)
+	if err := n.catchUp(ctx); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if got := drain(client); len(got) != 0 {
+		t.Fatalf("expected event 2 held behind the gap, got %v", got)
+	}
+
+	// Id 1 commits: both go out in order.
+	repo.add(1, channel, "loan_status_changed", `{"status":"registered"}`)
+	if err := n.catchUp(ctx); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if got := drain(client); len(got) != 2 || !strings.Contains(got[0], `"seq":1`) || !strings.Contains(got[1], `"seq":2`) {
+		t.Fatalf("expected events 1 and 2, got %v", got)
+	}
+
+	// Id 3 is rolled back. Event 4 waits while transaction 11 runs, even as
+	// newer transactions start, and goes out once it has finished.
+	repo.add(4, channel, "loan_status_changed", `{"status":"repaid"}`)
+	repo.snapshot(11, 13)
+	if err := n.catchUp(ctx); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	repo.snapshot(11, 20)
+	if err := n.catchUp(ctx); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if got := drain(client); len(got) != 0 {
+		t.Fatalf("expected event 4 held behind the gap, got %v", got)
+	}
+	repo.snapshot(13, 20)
+	if err := n.catchUp(ctx); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if got := drain(client); len(got) != 1 || !strings.Contains(got[0], `"seq":4`) {
+		t.Fatalf("expected event 4 after the gap closed, got %v", got)
+	}
+	if repo.cursors["node-c"] != 4 || hub.head.Load() != 4 {
+		t.Fatalf("expected cursor and head at 4, got %d and %d", repo.cursors["node-c"], hub.head.Load())
+	}
+}
+
+func TestNotifierRevokesRemovedMembers(t *testing.T) {
+	const channel = "lender:portfolio:lender-1"
+	access := fakeAccess{members: map[string][]string{"user-1": {"lender-1"}, "user-2": {"lender-1"}}}
+	hub := NewHub()
+	h := NewHandler(hub, access)
+	removed := NewClient(nil, Identity{UserID: "user-1", Role: "lender"})
+	kept := NewClient(nil, Identity{UserID: "user-2", Role: "lender"})
+	hub.Subscribe(channel, removed)
+	hub.Subscribe(channel, kept)
+
+	repo := &memRealtimeRepo{cursors: map[string]int64{"node-d": 0}}
+	n := NewNotifier(repo, hub, "node-d", time.Hour, nil)
+	n.SetRevoker(h)
+
+	// user-1 is removed, then the lender's portfolio changes.
+	access.members["user-1"] = nil
+	repo.add(1, realtime.LenderMembersChannel, realtime.EventLenderMemberRemoved, `{"lender_id":"lender-1","user_id":"user-1"}`)
+	repo.add(2, channel, "portfolio_updated", `{"lender_id":"lender-1"}`)
+	if err := n.catchUp(context.Background()); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+
+	want := `{"event":"unsubscribed","reason":"forbidden","topic":"lender:portfolio:lender-1"}`
+	if got := drain(removed); len(got) != 1 || got[0] != want {
+		t.Fatalf("expected only the revocation, got %v", got)
+	}
+	if topics := removed.listChannels(); len(topics) != 0 {
+		t.Fatalf("expected no subscriptions left, got %v", topics)
+	}
+	if got := drain(kept); len(got) != 1 || !strings.Contains(got[0], `"seq":2`) {
+		t.Fatalf("expected the remaining member to get the update, got %v", got)
+	}
+}
+
+func TestNotifierPrunesStaleCursors(t *testing.T) {
+	now := time.Now()
+	repo := &memRealtimeRepo{
+		cursors:  map[string]int64{"old-host": 3, "node-a": 5},
+		cursorAt: map[string]time.Time{"old-host": now.Add(-2 * time.Hour), "node-a": now},
+	}
+	n := NewNotifier(repo, NewHub(), "node-a", time.Second, nil)
+	if err := n.prune(context.Background(), now.Add(-time.Hour)); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if _, ok := repo.cursors["old-host"]; ok {
+		t.Fatalf("expected the stale cursor pruned")
+	}
+	if repo.cursors["node-a"] != 5 {
+		t.Fatalf("expected the live cursor kept, got %v", repo.cursors)
+	}
+}
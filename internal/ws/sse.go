@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleEventStream serves the hub channels listed in ?channels= as
+// Server-Sent Events, for clients that cannot keep a websocket open. Each
+// event's SSE id is its seq, so a reconnecting EventSource resumes through
+// Last-Event-ID like resume_from. The stream is authorized like websocket
+// subscriptions, but all channels at once: one refused channel refuses the
+// request.
+func (h *Handler) HandleEventStream(c *gin.Context) {
+	subs, ok := parseTopics(c.Query("channels"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_channels"})
+		return
+	}
+	from, resume, ok := lastEventID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_last_event_id"})
+		return
+	}
+	if resume && h.replay == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "resume_unavailable"})
+		return
+	}
+	identity := h.identity(c)
+	ctx := c.Request.Context()
+	for _, sub := range subs {
+		if err := authorize(ctx, h.access, identity, sub); err != nil {
+			if errors.Is(err, errForbidden) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "subscription_check_failed"})
+			return
+		}
+	}
+
+	client := newClient(nil, identity, h.opts.BufferSize, h.opts.DropPolicy)
+	defer func() {
+		h.hub.UnsubscribeAll(client)
+		client.close()
+	}()
+	topics := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		topics = append(topics, sub.topic)
+	}
+	ack, _ := json.Marshal(map[string]any{"event": "subscribed", "topics": topics})
+	client.send(ack)
+	if !resume {
+		for _, topic := range topics {
+			h.hub.Subscribe(topic, client)
+		}
+	} else {
+		// As with resume_from, live events wait until the replay is sent;
+		// the writer below drains it meanwhile.
+		for _, topic := range topics {
+			h.hub.SubscribeFrom(topic, client, from)
+		}
+		go h.resumeStream(ctx, client, topics, from)
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	h.streamWriter(ctx, c.Writer, client)
+}
+
+// resumeStream replays topics after from in one seq-ordered run, so the
+// stream's Last-Event-ID stays a valid resume point for every channel.
+func (h *Handler) resumeStream(ctx context.Context, client *Client, topics []string, from int64) {
+	defer func() {
+		for _, topic := range topics {
+			client.endReplay(topic)
+		}
+	}()
+	err := func() error {
+		oldest, err := h.replayGap(ctx, from)
+		if err != nil {
+			return err
+		}
+		if oldest > 0 {
+			gap, _ := json.Marshal(map[string]any{"event": "resume_gap", "topics": topics, "oldest_seq": oldest})
+			if err := client.sendWait(ctx, gap); err != nil {
+				return err
+			}
+		}
+		last, replayed, err := h.replayEvents(ctx, client, topics, from)
+		if err != nil {
+			return err
+		}
+		done, _ := json.Marshal(map[string]any{"event": "resumed", "topics": topics, "replayed": replayed, "last_seq": last})
+		return client.sendWait(ctx, done)
+	}()
+	if err != nil && ctx.Err() == nil {
+		client.send([]byte(`{"event":"error","message":"resume_failed"}`))
+	}
+}
+
+// streamWriter writes the client's messages until the request ends or the
+// drop policy disconnects it, with a comment line every PingInterval to keep
+// proxies from closing an idle stream.
+func (h *Handler) streamWriter(ctx context.Context, w gin.ResponseWriter, client *Client) {
+	ticker := time.NewTicker(h.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.done:
+			return
+		case payload := <-client.out:
+			if err := encodeStreamEvent(w, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+// encodeStreamEvent writes a websocket message as an SSE event named after
+// its event field. Only realtime events carry an id, so protocol messages
+// leave Last-Event-ID unchanged.
+func encodeStreamEvent(w io.Writer, payload []byte) error {
+	var head struct {
+		Event string `json:"event"`
+		Seq   int64  `json:"seq"`
+	}
+	_ = json.Unmarshal(payload, &head)
+	ev := sse.Event{Event: head.Event, Data: string(payload)}
+	if head.Seq > 0 {
+		ev.Id = strconv.FormatInt(head.Seq, 10)
+	}
+	return sse.Encode(w, ev)
+}
+
+// parseTopics parses a comma separated list of hub topics, ignoring
+// duplicates.
+func parseTopics(raw string) ([]subscription, bool) {
+	seen := map[string]bool{}
+	var subs []subscription
+	for _, part := range strings.Split(raw, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		sub, ok := parseTopic(part)
+		if !ok {
+			return nil, false
+		}
+		if !seen[sub.topic] {
+			seen[sub.topic] = true
+			subs = append(subs, sub)
+		}
+	}
+	return subs, len(subs) > 0
+}
+
+// lastEventID reads the Last-Event-ID header an EventSource sends when it
+// reconnects, or a last_event_id query parameter for the first connection.
+func lastEventID(c *gin.Context) (int64, bool, bool) {
+	raw := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if raw == "" {
+		raw = strings.TrimSpace(c.Query("last_event_id"))
+	}
+	if raw == "" {
+		return 0, false, true
+	}
+	seq, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, false, false
+	}
+	return seq, true, true
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -13,8 +14,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/loangraph/backend/internal/auth"
 	"github.com/loangraph/backend/internal/config"
+	admindomain "github.com/loangraph/backend/internal/domain/admin"
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
 	"github.com/loangraph/backend/internal/http/handlers"
+	"github.com/loangraph/backend/internal/indexer"
 	"github.com/loangraph/backend/internal/server"
 )
 
@@ -28,6 +33,117 @@ func (s *fakeAdminService) UpdateLenderStatus(_ context.Context, _ string, _ str
 	return nil
 }
 
+func (s *fakeAdminService) AddLenderMember(_ context.Context, _, _, _ string) error {
+	return nil
+}
+
+func (s *fakeAdminService) RemoveLenderMember(_ context.Context, _, _, _ string) error {
+	return nil
+}
+
+type fakeOutboxService struct{}
+
+func (s *fakeOutboxService) ListJobs(_ context.Context, f outboxdomain.ListFilter) ([]outboxdomain.Job, error) {
+	return []outboxdomain.Job{{ID: 7, Topic: "register_loan", Status: f.Status, Attempts: 5, LastError: "rpc timeout"}}, nil
+}
+
+func (s *fakeOutboxService) GetJob(_ context.Context, id int64) (*outboxdomain.Job, error) {
+	if id != 7 {
+		return nil, outboxdomain.ErrNotFound
+	}
+	return &outboxdomain.Job{ID: id, Topic: "register_loan", Status: outboxdomain.StatusFailed, Payload: json.RawMessage(`{"loan_id":"loan-1"}`)}, nil
+}
+
+func (s *fakeOutboxService) RetryJob(_ context.Context, _ string, id int64) (*outboxdomain.Job, error) {
+	return &outboxdomain.Job{ID: id, Status: outboxdomain.StatusPending}, nil
+}
+
+func (s *fakeOutboxService) CancelJob(_ context.Context, _ string, _ int64) (*outboxdomain.Job, error) {
+	return nil, outboxdomain.ErrInvalidTransition
+}
+
+func (s *fakeOutboxService) RequeueTopic(_ context.Context, _, _ string) (int64, error) {
+	return 3, nil
+}
+
+func (s *fakeOutboxService) Summary(_ context.Context) (*outboxdomain.Summary, error) {
+	return &outboxdomain.Summary{ByStatus: map[string]int64{"failed": 3}, FailedByTopic: map[string]int64{"register_loan": 3}}, nil
+}
+
+type fakeLedgerService struct{}
+
+func (s *fakeLedgerService) GetAccountStatement(_ context.Context, kind, ownerID, currency string, _, _ int32) (*ledgerdomain.AccountStatement, error) {
+	if !ledgerdomain.IsAccountKind(kind) {
+		return nil, ledgerdomain.ErrInvalidAccount
+	}
+	return &ledgerdomain.AccountStatement{
+		Balance: &ledgerdomain.Balance{Kind: kind, OwnerID: ownerID, CurrencyCode: currency, DebitMinor: 500000, BalanceMinor: 500000},
+		Entries: []ledgerdomain.EntryLine{{EntryID: "entry-1", Kind: ledgerdomain.EntryDisbursement, DebitMinor: 500000}},
+	}, nil
+}
+
+func (s *fakeLedgerService) GetLenderBalances(_ context.Context, lenderID string) ([]ledgerdomain.LenderBalances, error) {
+	if lenderID == "lender-down" {
+		return nil, errors.New("connection refused")
+	}
+	return []ledgerdomain.LenderBalances{{LenderID: lenderID, CurrencyCode: "NGN", PrincipalDisbursedMinor: 500000}}, nil
+}
+
+func (s *fakeLedgerService) GetPoolBalances(_ context.Context, poolID string) ([]ledgerdomain.PoolBalances, error) {
+	if poolID == "-" {
+		return nil, ledgerdomain.ErrMissingPoolID
+	}
+	return []ledgerdomain.PoolBalances{{PoolID: poolID, CurrencyCode: "NGN"}}, nil
+}
+
+func TestAdminLedgerRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := newFakeRepo()
+	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
+	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "did:privy:test-user")
+	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
+	ledgerHandler := handlers.NewLedgerHandler(&fakeLedgerService{})
+
+	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: authHandler, LedgerHandler: ledgerHandler, JWTManager: jwtManager})
+
+	loginReq := httptest.NewRequest(http.MethodPost, "/v1/auth/privy/login", bytes.NewBufferString(`{"privy_access_token":"token"}`))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginW := httptest.NewRecorder()
+	r.ServeHTTP(loginW, loginReq)
+	if loginW.Code != http.StatusOK {
+		t.Fatalf("expected login 200, got %d", loginW.Code)
+	}
+	var accessCookie *http.Cookie
+	for _, c := range loginW.Result().Cookies() {
+		if c.Name == auth.AccessCookieName {
+			accessCookie = c
+			break
+		}
+	}
+	if accessCookie == nil {
+		t.Fatalf("missing access cookie")
+	}
+
+	cases := map[string]int{
+		"/admin/ledger/accounts/borrower_receivable/loan-1?currency=NGN": http.StatusOK,
+		"/admin/ledger/accounts/unknown/loan-1?currency=NGN":             http.StatusBadRequest,
+		"/admin/ledger/lenders/lender-1/balances":                        http.StatusOK,
+		"/admin/ledger/pools/pool-1/balances":                            http.StatusOK,
+		"/admin/ledger/lenders/lender-down/balances":                     http.StatusInternalServerError,
+		"/admin/ledger/pools/-/balances":                                 http.StatusBadRequest,
+	}
+	for path, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(accessCookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("expected %d for %s, got %d", want, path, w.Code)
+		}
+	}
+}
+
 func TestAdminRoutesRequireAdminRoleAndWork(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -36,7 +152,7 @@ func TestAdminRoutesRequireAdminRoleAndWork(t *testing.T) {
 	subject := "did:privy:test-user"
 	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, subject)
 	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
-	adminHandler := handlers.NewAdminHandler(&fakeAdminService{})
+	adminHandler := handlers.NewAdminHandler(&fakeAdminService{}, &fakeOutboxService{})
 
 	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: authHandler, AdminHandler: adminHandler, JWTManager: jwtManager})
 
@@ -83,6 +199,53 @@ func TestAdminRoutesRequireAdminRoleAndWork(t *testing.T) {
 		t.Fatalf("expected 200, got %d", statusW.Code)
 	}
 
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		memberReq := httptest.NewRequest(method, "/admin/lenders/lender-1/members/u-2", nil)
+		memberReq.AddCookie(accessCookie)
+		memberW := httptest.NewRecorder()
+		r.ServeHTTP(memberW, memberReq)
+		if memberW.Code != http.StatusOK {
+			t.Fatalf("expected 200 for %s lender member, got %d", method, memberW.Code)
+		}
+	}
+
+	healthReq := httptest.NewRequest(http.MethodGet, "/admin/system/health", nil)
+	healthReq.AddCookie(accessCookie)
+	healthW := httptest.NewRecorder()
+	r.ServeHTTP(healthW, healthReq)
+	if healthW.Code != http.StatusOK {
+		t.Fatalf("expected 200 for system health, got %d", healthW.Code)
+	}
+	var health struct {
+		Outbox outboxdomain.Summary `json:"outbox"`
+	}
+	if err := json.Unmarshal(healthW.Body.Bytes(), &health); err != nil || health.Outbox.ByStatus["failed"] != 3 {
+		t.Fatalf("expected outbox summary in system health, got %s", healthW.Body.String())
+	}
+
+	outboxCases := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/admin/outbox/jobs?status=failed&topic=register_loan", http.StatusOK},
+		{http.MethodGet, "/admin/outbox/jobs/7", http.StatusOK},
+		{http.MethodGet, "/admin/outbox/jobs/8", http.StatusNotFound},
+		{http.MethodGet, "/admin/outbox/jobs/abc", http.StatusBadRequest},
+		{http.MethodPost, "/admin/outbox/jobs/7/retry", http.StatusOK},
+		{http.MethodPost, "/admin/outbox/jobs/7/cancel", http.StatusConflict},
+		{http.MethodPost, "/admin/outbox/topics/register_loan/requeue", http.StatusOK},
+	}
+	for _, tc := range outboxCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.AddCookie(accessCookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("expected %d for %s %s, got %d", tc.want, tc.method, tc.path, w.Code)
+		}
+	}
+
 	invalidBody, _ := json.Marshal(map[string]any{
 		"name":           "Bad Lender",
 		"country_code":   "N",
@@ -97,3 +260,130 @@ func TestAdminRoutesRequireAdminRoleAndWork(t *testing.T) {
 		t.Fatalf("expected 400 for invalid admin onboard payload, got %d", invalidW.Code)
 	}
 }
+
+type memQuarantineRepo struct {
+	events map[int64]*indexer.QuarantinedEvent
+}
+
+func (r *memQuarantineRepo) ListQuarantined(_ context.Context, _, _ int32) ([]indexer.QuarantinedEvent, error) {
+	out := make([]indexer.QuarantinedEvent, 0)
+	for _, ev := range r.events {
+		if ev.QuarantinedAt != nil && !ev.Processed {
+			out = append(out, *ev)
+		}
+	}
+	return out, nil
+}
+
+func (r *memQuarantineRepo) GetEvent(_ context.Context, id int64) (*indexer.QuarantinedEvent, error) {
+	ev, ok := r.events[id]
+	if !ok {
+		return nil, indexer.ErrEventNotFound
+	}
+	cp := *ev
+	return &cp, nil
+}
+
+func (r *memQuarantineRepo) Requeue(_ context.Context, id int64, rawData json.RawMessage) (*indexer.QuarantinedEvent, error) {
+	ev := r.events[id]
+	if len(rawData) > 0 {
+		ev.RawData = rawData
+	}
+	ev.Attempts, ev.QuarantinedAt = 0, nil
+	return ev, nil
+}
+
+func (r *memQuarantineRepo) Skip(_ context.Context, id int64) (*indexer.QuarantinedEvent, error) {
+	ev := r.events[id]
+	now := time.Now()
+	ev.Processed, ev.SkippedAt = true, &now
+	return ev, nil
+}
+
+type memAuditRepo struct {
+	logs []admindomain.AuditLogInput
+}
+
+func (r *memAuditRepo) Log(_ context.Context, in admindomain.AuditLogInput) error {
+	r.logs = append(r.logs, in)
+	return nil
+}
+
+func TestAdminChainEventQuarantineRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := newFakeRepo()
+	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
+	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "did:privy:test-user")
+	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
+	quarantinedAt := time.Now().Add(-time.Minute)
+	eventRepo := &memQuarantineRepo{events: map[int64]*indexer.QuarantinedEvent{
+		1: {ID: 1, EventName: "RepaymentRecorded", RawData: json.RawMessage(`{"loan_id":"11111111-1111-1111-1111-111111111111"}`), Attempts: 5, LastError: "invalid RepaymentRecorded payload values", QuarantinedAt: &quarantinedAt},
+		2: {ID: 2, EventName: "LoanDefaulted", RawData: json.RawMessage(`{}`), Attempts: 5, QuarantinedAt: &quarantinedAt},
+		3: {ID: 3, EventName: "LoanRegistered", RawData: json.RawMessage(`{}`), Processed: true},
+	}}
+	auditRepo := &memAuditRepo{}
+	chainHandler := handlers.NewChainHandler(indexer.NewQuarantineService(eventRepo, auditRepo), nil)
+
+	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: authHandler, ChainHandler: chainHandler, JWTManager: jwtManager})
+
+	loginReq := httptest.NewRequest(http.MethodPost, "/v1/auth/privy/login", bytes.NewBufferString(`{"privy_access_token":"token"}`))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginW := httptest.NewRecorder()
+	r.ServeHTTP(loginW, loginReq)
+	if loginW.Code != http.StatusOK {
+		t.Fatalf("expected login 200, got %d", loginW.Code)
+	}
+	var accessCookie *http.Cookie
+	for _, c := range loginW.Result().Cookies() {
+		if c.Name == auth.AccessCookieName {
+			accessCookie = c
+			break
+		}
+	}
+	if accessCookie == nil {
+		t.Fatalf("missing access cookie")
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/admin/chain-events/quarantined", "", http.StatusOK},
+		{http.MethodPost, "/admin/chain-events/abc/retry", "", http.StatusBadRequest},
+		{http.MethodPost, "/admin/chain-events/9/retry", "", http.StatusNotFound},
+		{http.MethodPost, "/admin/chain-events/3/skip", "", http.StatusConflict},
+		{http.MethodPost, "/admin/chain-events/1/retry", `{"raw_data":{"loan_id":"11111111-1111-1111-1111-111111111111","amount_minor":"x"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/chain-events/1/retry", `{"raw_data":{"loan_id":"11111111-1111-1111-1111-111111111111","amount_minor":2500}}`, http.StatusOK},
+		{http.MethodPost, "/admin/chain-events/1/retry", "", http.StatusConflict},
+		{http.MethodPost, "/admin/chain-events/2/skip", "", http.StatusOK},
+	}
+	for _, tc := range cases {
+		var body *bytes.Buffer
+		if tc.body != "" {
+			body = bytes.NewBufferString(tc.body)
+		} else {
+			body = &bytes.Buffer{}
+		}
+		req := httptest.NewRequest(tc.method, tc.path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(accessCookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("expected %d for %s %s, got %d: %s", tc.want, tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+
+	if ev := eventRepo.events[1]; ev.QuarantinedAt != nil || ev.Attempts != 0 || !bytes.Contains(ev.RawData, []byte(`2500`)) {
+		t.Fatalf("expected event 1 requeued with fixed payload, got %+v", ev)
+	}
+	if ev := eventRepo.events[2]; !ev.Processed || ev.SkippedAt == nil {
+		t.Fatalf("expected event 2 skipped, got %+v", ev)
+	}
+	if len(auditRepo.logs) != 2 || auditRepo.logs[0].Action != "chain_event_retried" || auditRepo.logs[1].Action != "chain_event_skipped" {
+		t.Fatalf("unexpected audit logs: %+v", auditRepo.logs)
+	}
+}
@@ -100,7 +100,7 @@ func TestAuthLoginSetsCookies(t *testing.T) {
 	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
 	svc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "")
 	h := handlers.NewAuthHandler(svc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
-	adminHandler := handlers.NewAdminHandler(nil)
+	adminHandler := handlers.NewAdminHandler(nil, nil)
 	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: h, AdminHandler: adminHandler, JWTManager: jwtManager})
 
 	body, _ := json.Marshal(map[string]string{"privy_access_token": "token"})
@@ -125,7 +125,7 @@ func TestAdminEndpointRequiresAdminRole(t *testing.T) {
 	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
 	svc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "")
 	h := handlers.NewAuthHandler(svc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
-	adminHandler := handlers.NewAdminHandler(nil)
+	adminHandler := handlers.NewAdminHandler(nil, nil)
 	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: h, AdminHandler: adminHandler, JWTManager: jwtManager})
 
 	loginBody, _ := json.Marshal(map[string]string{"privy_access_token": "token"})
@@ -165,7 +165,7 @@ func TestBootstrapAdminCanAccessAdminEndpoint(t *testing.T) {
 	subject := "did:privy:test-user"
 	svc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, subject)
 	h := handlers.NewAuthHandler(svc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
-	adminHandler := handlers.NewAdminHandler(nil)
+	adminHandler := handlers.NewAdminHandler(nil, nil)
 	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{AuthHandler: h, AdminHandler: adminHandler, JWTManager: jwtManager})
 
 	loginBody, _ := json.Marshal(map[string]string{"privy_access_token": "token"})
@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
+	"github.com/loangraph/backend/test/integration/testutil"
+)
+
+func TestChainFeeRepositoryTotalsPerDay(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	repo := postgresrepo.NewChainFeeRepository(pool)
+	today := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	for _, spend := range []struct {
+		day   time.Time
+		topic string
+		cost  int64
+	}{
+		{today, "register_loan", 100},
+		{today, "register_loan", 50},
+		{today, "multicall", 25},
+		{yesterday, "register_loan", 1000},
+	} {
+		if err := repo.RecordSpend(ctx, spend.day, spend.topic, 21000, big.NewInt(spend.cost)); err != nil {
+			t.Fatalf("record spend: %v", err)
+		}
+	}
+
+	var spent int64
+	if err := pool.QueryRow(ctx, `SELECT SUM(max_cost_wei)::bigint FROM chain_fee_spend WHERE day = $1::date`, today.Format(time.DateOnly)).Scan(&spent); err != nil {
+		t.Fatalf("query spend total: %v", err)
+	}
+	if spent != 175 {
+		t.Fatalf("expected today's spend 175, got %d", spent)
+	}
+
+	var txCount int64
+	var gasTotal string
+	if err := pool.QueryRow(ctx, `SELECT tx_count, gas_total::text FROM chain_fee_spend WHERE day = $1::date AND topic = 'register_loan'`, today.Format(time.DateOnly)).Scan(&txCount, &gasTotal); err != nil {
+		t.Fatalf("query spend row: %v", err)
+	}
+	if txCount != 2 || gasTotal != "42000" {
+		t.Fatalf("expected 2 transactions and 42000 gas, got %d and %s", txCount, gasTotal)
+	}
+}
+
+func TestChainFeeRepositoryReservesWithinBudget(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	repo := postgresrepo.NewChainFeeRepository(pool)
+	today := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	budget := big.NewInt(100)
+
+	for _, step := range []struct {
+		cost int64
+		want bool
+	}{
+		{60, true},
+		{41, false},
+		{40, true},
+		{1, false},
+	} {
+		ok, err := repo.ReserveSpend(ctx, today, big.NewInt(step.cost), budget)
+		if err != nil {
+			t.Fatalf("reserve spend: %v", err)
+		}
+		if ok != step.want {
+			t.Fatalf("reserve %d: expected %v, got %v", step.cost, step.want, ok)
+		}
+	}
+
+	if err := repo.ReleaseSpend(ctx, today, big.NewInt(40)); err != nil {
+		t.Fatalf("release spend: %v", err)
+	}
+	if ok, err := repo.ReserveSpend(ctx, today, big.NewInt(40), budget); err != nil || !ok {
+		t.Fatalf("expected released spend reservable again, got %v (%v)", ok, err)
+	}
+	// A new day starts with the whole budget, and no budget always reserves.
+	if ok, err := repo.ReserveSpend(ctx, today.AddDate(0, 0, 1), big.NewInt(100), budget); err != nil || !ok {
+		t.Fatalf("expected a fresh budget the next day, got %v (%v)", ok, err)
+	}
+	if ok, err := repo.ReserveSpend(ctx, today, big.NewInt(1000), nil); err != nil || !ok {
+		t.Fatalf("expected an unlimited reservation, got %v (%v)", ok, err)
+	}
+}
@@ -2,12 +2,18 @@ package integration
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/loangraph/backend/internal/blockchain"
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
 	"github.com/loangraph/backend/internal/indexer"
 	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
 	"github.com/loangraph/backend/test/integration/testutil"
@@ -78,7 +84,7 @@ VALUES
 		t.Fatalf("insert chain events: %v", err)
 	}
 
-	svc := indexer.NewService(idxRepo, idxRepo)
+	svc := indexer.NewService(idxRepo)
 	if err := svc.RunOnce(ctx, 10); err != nil {
 		t.Fatalf("indexer run once: %v", err)
 	}
@@ -113,3 +119,598 @@ VALUES
 		t.Fatalf("expected passport cache row")
 	}
 }
+
+func TestIndexerReprojectRebuildsLoansAndPassports(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lender, err := postgresrepo.NewLenderRepository(pool).Create(ctx, lenderdomain.CreateInput{
+		Name:          "Reproject Lender",
+		CountryCode:   "NG",
+		WalletAddress: "0x5656565656565656565656565656565656565656",
+		KYCStatus:     "approved",
+		Tier:          "starter",
+	})
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	borrower, err := postgresrepo.NewBorrowerRepository(pool).Create(ctx, borrowerdomain.CreateInput{
+		BorrowerHash: []byte{0x67, 0x78},
+		LenderID:     lender.ID,
+		CountryCode:  "NG",
+	})
+	if err != nil {
+		t.Fatalf("create borrower: %v", err)
+	}
+	loanRepo := postgresrepo.NewLoanRepository(pool)
+	loanItem, err := loanRepo.Create(ctx, loandomain.CreateInput{
+		LoanHash:        []byte{0xa3, 0xb4},
+		LenderID:        lender.ID,
+		BorrowerID:      borrower.ID,
+		PrincipalMinor:  100000,
+		CurrencyCode:    "NGN",
+		InterestRateBPS: 2000,
+		StartDate:       time.Now().UTC(),
+		MaturityDate:    time.Now().UTC().Add(90 * 24 * time.Hour),
+		RiskGrade:       "B",
+		Metadata:        []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+
+	// Inserted out of chain order, and with the repayment projected twice.
+	ins := `
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
+VALUES
+  ($1, 'RepaymentRecorded', '0xdef2', 9, 0, $3::jsonb, TRUE),
+  ($1, 'LoanRegistered', '0xdef1', 8, 4, $2::jsonb, TRUE)
+`
+	if _, err := pool.Exec(ctx, ins,
+		"0x3c20fd0b57711a199776b53c2f24385563d1670f",
+		`{"loan_id":"`+loanItem.ID+`"}`,
+		`{"loan_id":"`+loanItem.ID+`","amount_minor":30000}`,
+	); err != nil {
+		t.Fatalf("insert chain events: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE loans SET amount_repaid_minor = 60000 WHERE id = $1`, loanItem.ID); err != nil {
+		t.Fatalf("skew loan: %v", err)
+	}
+
+	idxRepo := postgresrepo.NewIndexerRepository(pool)
+	report, err := idxRepo.Reproject(ctx, true)
+	if err != nil {
+		t.Fatalf("dry-run reproject: %v", err)
+	}
+	if report.Events != 2 || len(report.Loans) != 1 || report.Loans[0].After.AmountRepaidMinor != 30000 || report.Loans[0].After.OnChainTX != "0xdef1" || report.LedgerEntries != 1 {
+		t.Fatalf("unexpected dry-run report: %+v", report)
+	}
+	if len(report.Passports) != 1 || report.Passports[0].Before != nil || report.Passports[0].After.CumulativeRepaid != 30000 {
+		t.Fatalf("unexpected passport diff: %+v", report.Passports)
+	}
+	unchanged, err := loanRepo.GetByID(ctx, loanItem.ID)
+	if err != nil {
+		t.Fatalf("get loan: %v", err)
+	}
+	if unchanged.AmountRepaid != 60000 {
+		t.Fatalf("dry run must not write, got amount repaid %d", unchanged.AmountRepaid)
+	}
+
+	if _, err := idxRepo.Reproject(ctx, false); err != nil {
+		t.Fatalf("reproject: %v", err)
+	}
+	rebuilt, err := loanRepo.GetByID(ctx, loanItem.ID)
+	if err != nil {
+		t.Fatalf("get loan: %v", err)
+	}
+	if rebuilt.AmountRepaid != 30000 || rebuilt.Status != "active" || rebuilt.OnChainTX != "0xdef1" {
+		t.Fatalf("unexpected rebuilt loan: %+v", rebuilt)
+	}
+	again, err := idxRepo.Reproject(ctx, true)
+	if err != nil {
+		t.Fatalf("second reproject: %v", err)
+	}
+	if len(again.Loans) != 0 || len(again.Passports) != 0 || again.LedgerEntries != 0 {
+		t.Fatalf("expected reprojection to be idempotent, got %+v", again)
+	}
+}
+
+func TestIndexerQuarantinesAndRequeuesEvents(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	ins := `
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
+VALUES
+  ($1, 'RepaymentRecorded', '0xbad1', 1, 0, '{"loan_id":"0xabc","amount_minor":"x"}'::jsonb, FALSE),
+  ($1, 'Transfer', '0xok01', 2, 0, '{}'::jsonb, FALSE)
+`
+	if _, err := pool.Exec(ctx, ins, "0x3c20fd0b57711a199776b53c2f24385563d1670f"); err != nil {
+		t.Fatalf("insert chain events: %v", err)
+	}
+
+	idxRepo := postgresrepo.NewIndexerRepository(pool)
+	svc := indexer.NewService(idxRepo)
+	svc.SetMaxAttempts(2)
+	if err := svc.RunOnce(ctx, 10); err == nil {
+		t.Fatalf("expected first attempt to fail")
+	}
+	if err := svc.RunOnce(ctx, 10); err == nil {
+		t.Fatalf("expected quarantine to be reported")
+	}
+
+	quarantined, err := idxRepo.ListQuarantined(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("list quarantined: %v", err)
+	}
+	if len(quarantined) != 1 || quarantined[0].TXHash != "0xbad1" || quarantined[0].Attempts != 2 || quarantined[0].LastError == "" {
+		t.Fatalf("unexpected quarantined events: %+v", quarantined)
+	}
+	var pending int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM chain_events WHERE processed = FALSE`).Scan(&pending); err != nil {
+		t.Fatalf("count pending: %v", err)
+	}
+	if pending != 1 {
+		t.Fatalf("expected only the quarantined event left unprocessed, got %d", pending)
+	}
+
+	id := quarantined[0].ID
+	requeued, err := idxRepo.Requeue(ctx, id, []byte(`{"loan_id":"0xabc","amount_minor":10}`))
+	if err != nil {
+		t.Fatalf("requeue: %v", err)
+	}
+	if requeued.QuarantinedAt != nil || requeued.Attempts != 0 || !strings.Contains(string(requeued.RawData), `"amount_minor": 10`) {
+		t.Fatalf("unexpected requeued event: %+v", requeued)
+	}
+	if _, err := idxRepo.Skip(ctx, id); !errors.Is(err, indexer.ErrEventNotQuarantined) {
+		t.Fatalf("expected skip of a requeued event to be rejected, got %v", err)
+	}
+	if err := svc.RunOnce(ctx, 10); err != nil {
+		t.Fatalf("run fixed event: %v", err)
+	}
+	if _, err := idxRepo.GetEvent(ctx, 999999); !errors.Is(err, indexer.ErrEventNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestIndexerStoresUndecodableEventsQuarantined(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	idxRepo := postgresrepo.NewIndexerRepository(pool)
+	if err := idxRepo.InsertChainEvent(ctx, indexer.IngestedEvent{
+		ContractAddr: "0x3c20fd0b57711a199776b53c2f24385563d1670f",
+		EventName:    "RepaymentRecorded",
+		TXHash:       "0xbad1",
+		BlockNumber:  1,
+		RawData:      []byte(`{"topics":["0x01"],"data":"0xnothex"}`),
+		DecodeError:  "decode: RepaymentRecorded data is not hex",
+	}); err != nil {
+		t.Fatalf("insert chain event: %v", err)
+	}
+
+	quarantined, err := idxRepo.ListQuarantined(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("list quarantined: %v", err)
+	}
+	if len(quarantined) != 1 || quarantined[0].TXHash != "0xbad1" || quarantined[0].LastError != "decode: RepaymentRecorded data is not hex" {
+		t.Fatalf("expected the undecodable event quarantined, got %+v", quarantined)
+	}
+	// The projection never picks it up.
+	if err := indexer.NewService(idxRepo).RunOnce(ctx, 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+}
+
+func TestIndexerProjectsInChainOrderAndAppliesRepaymentsOnce(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lender, err := postgresrepo.NewLenderRepository(pool).Create(ctx, lenderdomain.CreateInput{
+		Name:          "Ordering Lender",
+		CountryCode:   "NG",
+		WalletAddress: "0x5757575757575757575757575757575757575757",
+		KYCStatus:     "approved",
+		Tier:          "starter",
+	})
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	borrower, err := postgresrepo.NewBorrowerRepository(pool).Create(ctx, borrowerdomain.CreateInput{
+		BorrowerHash: []byte{0x68, 0x79},
+		LenderID:     lender.ID,
+		CountryCode:  "NG",
+	})
+	if err != nil {
+		t.Fatalf("create borrower: %v", err)
+	}
+	loanRepo := postgresrepo.NewLoanRepository(pool)
+	loanItem, err := loanRepo.Create(ctx, loandomain.CreateInput{
+		LoanHash:        []byte{0xa5, 0xb6},
+		LenderID:        lender.ID,
+		BorrowerID:      borrower.ID,
+		PrincipalMinor:  100000,
+		CurrencyCode:    "NGN",
+		InterestRateBPS: 2000,
+		StartDate:       time.Now().UTC(),
+		MaturityDate:    time.Now().UTC().Add(90 * 24 * time.Hour),
+		RiskGrade:       "B",
+		Metadata:        []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+
+	// A backfilled repayment (lower id order) that happened before the default
+	// on chain must still count; inserted after it, it would be ignored.
+	ins := `
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
+VALUES
+  ($1, 'LoanDefaulted', '0xfed2', 12, 0, $2::jsonb, FALSE),
+  ($1, 'RepaymentRecorded', '0xfed1', 11, 3, $3::jsonb, FALSE)
+`
+	if _, err := pool.Exec(ctx, ins,
+		"0x3c20fd0b57711a199776b53c2f24385563d1670f",
+		`{"loan_id":"`+loanItem.ID+`"}`,
+		`{"loan_id":"`+loanItem.ID+`","amount_minor":25000}`,
+	); err != nil {
+		t.Fatalf("insert chain events: %v", err)
+	}
+
+	idxRepo := postgresrepo.NewIndexerRepository(pool)
+	svc := indexer.NewService(idxRepo)
+	if err := svc.RunOnce(ctx, 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	projected, err := loanRepo.GetByID(ctx, loanItem.ID)
+	if err != nil {
+		t.Fatalf("get loan: %v", err)
+	}
+	if projected.AmountRepaid != 25000 || projected.Status != "defaulted" {
+		t.Fatalf("expected repayment applied before default, got repaid=%d status=%s", projected.AmountRepaid, projected.Status)
+	}
+	ledgerRepo := postgresrepo.NewLedgerRepository(pool)
+	balances, err := ledgerRepo.GetLenderBalances(ctx, lender.ID)
+	if err != nil {
+		t.Fatalf("ledger lender balances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].PrincipalRepaidMinor != 25000 || balances[0].WrittenOffMinor != 75000 || balances[0].OutstandingMinor != 0 {
+		t.Fatalf("expected the ledger to follow the projection, got %+v", balances)
+	}
+
+	// Replaying the repayment, as after a crash between apply and commit in
+	// the old code, must not count it twice.
+	if _, err := pool.Exec(ctx, `UPDATE loans SET status = 'active' WHERE id = $1`, loanItem.ID); err != nil {
+		t.Fatalf("reset status: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE chain_events SET processed = FALSE WHERE tx_hash = '0xfed1'`); err != nil {
+		t.Fatalf("reset processed: %v", err)
+	}
+	if err := svc.RunOnce(ctx, 10); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	replayed, err := loanRepo.GetByID(ctx, loanItem.ID)
+	if err != nil {
+		t.Fatalf("get loan: %v", err)
+	}
+	if replayed.AmountRepaid != 25000 {
+		t.Fatalf("expected repayment applied once, got %d", replayed.AmountRepaid)
+	}
+	balances, err = ledgerRepo.GetLenderBalances(ctx, lender.ID)
+	if err != nil {
+		t.Fatalf("ledger lender balances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].PrincipalRepaidMinor != 25000 {
+		t.Fatalf("expected repayment posted once, got %+v", balances)
+	}
+}
+
+type staticRegistryReader map[string]blockchain.OnChainLoan
+
+func (r staticRegistryReader) GetLoans(_ context.Context, loanIDs []string, _ uint64) ([]blockchain.OnChainLoan, error) {
+	out := make([]blockchain.OnChainLoan, 0, len(loanIDs))
+	for _, loanID := range loanIDs {
+		loan, ok := r[loanID]
+		if !ok {
+			loan = blockchain.OnChainLoan{Status: "none"}
+		}
+		out = append(out, loan)
+	}
+	return out, nil
+}
+
+func TestIndexerReconciliationStoresDiscrepanciesAndEnqueuesJobs(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lender, err := postgresrepo.NewLenderRepository(pool).Create(ctx, lenderdomain.CreateInput{
+		Name:          "Reconcile Lender",
+		CountryCode:   "NG",
+		WalletAddress: "0x5858585858585858585858585858585858585858",
+		KYCStatus:     "approved",
+		Tier:          "starter",
+	})
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	borrower, err := postgresrepo.NewBorrowerRepository(pool).Create(ctx, borrowerdomain.CreateInput{
+		BorrowerHash: []byte{0x69, 0x7a},
+		LenderID:     lender.ID,
+		CountryCode:  "NG",
+	})
+	if err != nil {
+		t.Fatalf("create borrower: %v", err)
+	}
+	loanItem, err := postgresrepo.NewLoanRepository(pool).Create(ctx, loandomain.CreateInput{
+		LoanHash:        []byte{0xa6, 0xb7},
+		LenderID:        lender.ID,
+		BorrowerID:      borrower.ID,
+		PrincipalMinor:  100000,
+		CurrencyCode:    "NGN",
+		InterestRateBPS: 2000,
+		StartDate:       time.Now().UTC(),
+		MaturityDate:    time.Now().UTC().Add(90 * 24 * time.Hour),
+		RiskGrade:       "B",
+		Metadata:        []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+	// The DB recorded 5000 more than ever reached the registry.
+	if _, err := pool.Exec(ctx, `UPDATE loans SET amount_repaid_minor = 30000, on_chain_confirmed = TRUE WHERE id = $1`, loanItem.ID); err != nil {
+		t.Fatalf("update loan: %v", err)
+	}
+	ins := `
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
+VALUES
+  ($1, 'LoanRegistered', '0xaba1', 20, 0, $2::jsonb, TRUE),
+  ($1, 'RepaymentRecorded', '0xaba2', 21, 0, $3::jsonb, TRUE)
+`
+	if _, err := pool.Exec(ctx, ins,
+		"0x3c20fd0b57711a199776b53c2f24385563d1670f",
+		`{"loan_id":"`+loanItem.ID+`"}`,
+		`{"loan_id":"`+loanItem.ID+`","amount_minor":25000}`,
+	); err != nil {
+		t.Fatalf("insert chain events: %v", err)
+	}
+
+	idxRepo := postgresrepo.NewIndexerRepository(pool)
+	reader := staticRegistryReader{loanItem.ID: {Registered: true, PrincipalMinor: 100000, TotalRepaidMinor: 25000, Status: "active"}}
+	svc := indexer.NewReconciliationService(idxRepo, reader)
+	report, err := svc.Reconcile(ctx, indexer.ReconcileOptions{BlockNumber: 30, Enqueue: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Run.ID == 0 || report.Run.LoansChecked != 1 || report.Run.JobsEnqueued != 1 {
+		t.Fatalf("unexpected run: %+v", report.Run)
+	}
+
+	run, items, err := svc.ListDiscrepancies(ctx, 0, 50, 0)
+	if err != nil {
+		t.Fatalf("list discrepancies: %v", err)
+	}
+	if run.ID != report.Run.ID || run.BlockNumber != 30 || len(items) != 1 {
+		t.Fatalf("unexpected stored run %+v with %d items", run, len(items))
+	}
+	if d := items[0]; d.LoanID != loanItem.ID || d.Field != "amount_repaid_minor" || d.DBValue != "30000" || d.ChainValue != "25000" || d.EventsValue != "25000" || d.CorrectiveTopic != "record_repayment" {
+		t.Fatalf("unexpected discrepancy: %+v", d)
+	}
+	var topic, amount string
+	if err := pool.QueryRow(ctx, `SELECT topic, payload->>'amount_minor' FROM outbox_jobs WHERE payload->>'loan_id' = $1`, loanItem.ID).Scan(&topic, &amount); err != nil {
+		t.Fatalf("load corrective job: %v", err)
+	}
+	if topic != "record_repayment" || amount != "5000" {
+		t.Fatalf("unexpected corrective job %s %s", topic, amount)
+	}
+
+	// With the correction pending, a second run reports it in flight and
+	// enqueues nothing more.
+	again, err := svc.Reconcile(ctx, indexer.ReconcileOptions{BlockNumber: 31, Enqueue: true})
+	if err != nil {
+		t.Fatalf("reconcile again: %v", err)
+	}
+	if again.Run.JobsEnqueued != 0 || len(again.Items) != 1 || !again.Items[0].InFlight {
+		t.Fatalf("expected in-flight discrepancy without new jobs, got %+v", again)
+	}
+
+	// Once the correction is dead-lettered, runs leave it to the admin: a
+	// retry then sends the repayment exactly once.
+	if _, err := pool.Exec(ctx, `UPDATE outbox_jobs SET status = 'failed' WHERE payload->>'loan_id' = $1`, loanItem.ID); err != nil {
+		t.Fatalf("fail corrective job: %v", err)
+	}
+	afterFailure, err := svc.Reconcile(ctx, indexer.ReconcileOptions{BlockNumber: 32, Enqueue: true})
+	if err != nil {
+		t.Fatalf("reconcile after failure: %v", err)
+	}
+	if afterFailure.Run.JobsEnqueued != 0 || len(afterFailure.Items) != 1 || !afterFailure.Items[0].InFlight {
+		t.Fatalf("expected the failed job to block corrections, got %+v", afterFailure)
+	}
+	var failedID int64
+	if err := pool.QueryRow(ctx, `SELECT id FROM outbox_jobs WHERE payload->>'loan_id' = $1`, loanItem.ID).Scan(&failedID); err != nil {
+		t.Fatalf("load failed job: %v", err)
+	}
+	outboxSvc := outboxdomain.NewService(postgresrepo.NewOutboxRepository(pool), postgresrepo.NewAdminAuditRepository(pool))
+	if _, err := outboxSvc.RetryJob(ctx, uuid.NewString(), failedID); err != nil {
+		t.Fatalf("retry failed job: %v", err)
+	}
+	var statuses []string
+	if err := pool.QueryRow(ctx, `SELECT array_agg(status ORDER BY id) FROM outbox_jobs WHERE payload->>'loan_id' = $1`, loanItem.ID).Scan(&statuses); err != nil {
+		t.Fatalf("load job statuses: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0] != "pending" {
+		t.Fatalf("expected only the retried job, got %v", statuses)
+	}
+	if _, _, err := svc.ListDiscrepancies(ctx, 999, 50, 0); !errors.Is(err, indexer.ErrReconciliationRunNotFound) {
+		t.Fatalf("expected run not found, got %v", err)
+	}
+}
+
+func TestProducersStoreRealtimeEventsAndNotifyListeners(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	lender, err := postgresrepo.NewLenderRepository(pool).Create(ctx, lenderdomain.CreateInput{
+		Name:          "Notify Lender",
+		CountryCode:   "NG",
+		WalletAddress: "0x5959595959595959595959595959595959595959",
+		KYCStatus:     "approved",
+		Tier:          "starter",
+	})
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	borrower, err := postgresrepo.NewBorrowerRepository(pool).Create(ctx, borrowerdomain.CreateInput{
+		BorrowerHash: []byte{0x6a, 0x7b},
+		LenderID:     lender.ID,
+		CountryCode:  "NG",
+	})
+	if err != nil {
+		t.Fatalf("create borrower: %v", err)
+	}
+	loanItem, err := postgresrepo.NewLoanRepository(pool).Create(ctx, loandomain.CreateInput{
+		LoanHash:        []byte{0xa7, 0xb8},
+		LenderID:        lender.ID,
+		BorrowerID:      borrower.ID,
+		PrincipalMinor:  100000,
+		CurrencyCode:    "NGN",
+		InterestRateBPS: 2000,
+		StartDate:       time.Now().UTC(),
+		MaturityDate:    time.Now().UTC().Add(90 * 24 * time.Hour),
+		RiskGrade:       "B",
+		Metadata:        []byte(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+
+	poolRepo := postgresrepo.NewPoolRepository(pool)
+	var poolItem *pooldomain.Entity
+	// Repayments go to the pool holding the loan only, not to the lender's
+	// other pools.
+	for _, name := range []string{"Notify Pool", "Other Pool"} {
+		created, err := poolRepo.Create(ctx, pooldomain.CreateInput{
+			LenderID:     lender.ID,
+			Name:         name,
+			TargetAPYBPS: 1500,
+			CurrencyCode: "NGN",
+			Status:       "active",
+		})
+		if err != nil {
+			t.Fatalf("create pool: %v", err)
+		}
+		if poolItem == nil {
+			poolItem = created
+		}
+	}
+	if _, err := poolRepo.AllocateLoan(ctx, poolItem.ID, loanItem.ID, loanItem.PrincipalMinor); err != nil {
+		t.Fatalf("allocate loan: %v", err)
+	}
+
+	listener := postgresrepo.NewProjectionListener(pool, nil)
+	wake := make(chan struct{}, 1)
+	go listener.Run(ctx, wake)
+	select {
+	case <-wake:
+	case <-ctx.Done():
+		t.Fatalf("listener never connected")
+	}
+	if !listener.Connected() {
+		t.Fatalf("expected listener connected")
+	}
+
+	wsRepo := postgresrepo.NewWSRepository(pool)
+	before, err := wsRepo.LatestRealtimeEventID(ctx)
+	if err != nil {
+		t.Fatalf("latest id: %v", err)
+	}
+
+	// The worker submits the loan to the registry.
+	if err := postgresrepo.NewLoanRepository(pool).SetOnChainSubmission(ctx, loanItem.ID, "0xcdc0", false); err != nil {
+		t.Fatalf("set submission: %v", err)
+	}
+	select {
+	case <-wake:
+	case <-ctx.Done():
+		t.Fatalf("no notification after submission")
+	}
+
+	ins := `
+INSERT INTO chain_events (contract_addr, event_name, tx_hash, block_number, log_index, raw_data, processed)
+VALUES ($1, $2, $3, 40, 0, $4::jsonb, FALSE)
+`
+	for _, ev := range []struct{ name, tx, raw string }{
+		{"RepaymentRecorded", "0xcdc1", `{"loan_id":"` + loanItem.ID + `","amount_minor":1500}`},
+		{"LoanDefaulted", "0xcdc2", `{"loan_id":"` + loanItem.ID + `"}`},
+	} {
+		if _, err := pool.Exec(ctx, ins, "0x3c20fd0b57711a199776b53c2f24385563d1670f", ev.name, ev.tx, ev.raw); err != nil {
+			t.Fatalf("insert chain event: %v", err)
+		}
+	}
+	if err := indexer.NewService(postgresrepo.NewIndexerRepository(pool)).RunOnce(ctx, 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	select {
+	case <-wake:
+	case <-ctx.Done():
+		t.Fatalf("no notification after projection")
+	}
+
+	page, err := wsRepo.ListRealtimeEventsSince(ctx, before, 50)
+	if err != nil {
+		t.Fatalf("list realtime events: %v", err)
+	}
+	events := page.Events
+	var got []string
+	for i, ev := range events {
+		if ev.ID <= before || (i > 0 && ev.ID <= events[i-1].ID) {
+			t.Fatalf("events out of order: %+v", events)
+		}
+		got = append(got, ev.Type+" "+ev.Channel)
+	}
+	want := []string{
+		"loan_status_changed loan:" + loanItem.ID,
+		"repayment_recorded pool:repayments:" + poolItem.ID,
+		"portfolio_updated lender:portfolio:" + lender.ID,
+		"passport_score_changed passport:" + borrower.ID,
+		"loan_status_changed loan:" + loanItem.ID,
+		"loan_defaulted lender:defaults:" + lender.ID,
+		"portfolio_updated lender:portfolio:" + lender.ID,
+		"passport_score_changed passport:" + borrower.ID,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected realtime events:\n%s", strings.Join(got, "\n"))
+	}
+	if !strings.Contains(string(events[0].Data), `"status":"registered"`) || !strings.Contains(string(events[1].Data), `"amount_minor":1500`) || !strings.Contains(string(events[4].Data), `"status":"defaulted"`) {
+		t.Fatalf("unexpected event data: %s %s %s", events[0].Data, events[1].Data, events[4].Data)
+	}
+
+	if _, ok, err := wsRepo.GetRealtimeCursor(ctx, "node-a"); err != nil || ok {
+		t.Fatalf("expected no cursor yet, got ok=%v err=%v", ok, err)
+	}
+	last := events[len(events)-1].ID
+	if err := wsRepo.SetRealtimeCursor(ctx, "node-a", last); err != nil {
+		t.Fatalf("set cursor: %v", err)
+	}
+	if seq, ok, err := wsRepo.GetRealtimeCursor(ctx, "node-a"); err != nil || !ok || seq != last {
+		t.Fatalf("unexpected cursor %d ok=%v err=%v", seq, ok, err)
+	}
+}
@@ -30,8 +30,11 @@ func (s *fakeInvestorService) GetPool(_ context.Context, poolID string) (*pooldo
 	return &pooldomain.Entity{ID: poolID, Name: "Starter Pool"}, nil
 }
 
-func (s *fakeInvestorService) GetPoolPerformance(_ context.Context, _ string, _ int32) ([]loandomain.PerformancePoint, error) {
-	return []loandomain.PerformancePoint{{Date: "2026-02-25", RepaymentCount: 2, RepaidAmountMinor: 5000}}, nil
+func (s *fakeInvestorService) GetPoolPerformance(_ context.Context, poolID string, _ int32) (*investordomain.PoolPerformance, error) {
+	return &investordomain.PoolPerformance{
+		Summary: &pooldomain.PerformanceSummary{PoolID: poolID},
+		Items:   []loandomain.PerformancePoint{{Date: "2026-02-25", RepaymentCount: 2, RepaidAmountMinor: 5000}},
+	}, nil
 }
 
 func (s *fakeInvestorService) GetLenderProfile(_ context.Context, lenderID string) (*investordomain.LenderProfile, error) {
@@ -56,6 +56,16 @@ func TestLoanLifecycleRoutes(t *testing.T) {
 		}
 	})
 
+	t.Run("list loans rejects invalid range filter", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/loans?lender_id=lender-1&maturity_from=yesterday", nil)
+		req.AddCookie(accessCookie)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 got %d", resp.Code)
+		}
+	})
+
 	t.Run("get loan", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/v1/loans/loan-1", nil)
 		req.AddCookie(accessCookie)
@@ -28,8 +28,8 @@ func (s *fakeLoanService) ProcessCSVUpload(_ context.Context, _ string, _ io.Rea
 	return s.result, s.err
 }
 
-func (s *fakeLoanService) ListLoans(_ context.Context, _ loandomain.ListFilter) ([]loandomain.Entity, error) {
-	return []loandomain.Entity{}, nil
+func (s *fakeLoanService) ListLoans(_ context.Context, _ loandomain.ListFilter) (*loandomain.ListPage, error) {
+	return &loandomain.ListPage{Items: []loandomain.Entity{}}, nil
 }
 
 func (s *fakeLoanService) GetLoan(_ context.Context, _ string) (*loandomain.Entity, error) {
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
+	"github.com/loangraph/backend/test/integration/testutil"
+)
+
+func TestNonceRepositoryReservations(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	repo := postgresrepo.NewNonceRepository(pool)
+	addr := "0x1111111111111111111111111111111111111111"
+	staleBefore := time.Now().Add(-time.Hour)
+
+	reserve := func(pending uint64) uint64 {
+		t.Helper()
+		n, err := repo.Reserve(ctx, addr, pending, staleBefore)
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		return n
+	}
+	if n := reserve(3); n != 3 {
+		t.Fatalf("expected first nonce to start at chain pending 3, got %d", n)
+	}
+	if n := reserve(3); n != 4 {
+		t.Fatalf("expected nonce 4, got %d", n)
+	}
+	if err := repo.Release(ctx, addr, 3); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if n := reserve(3); n != 3 {
+		t.Fatalf("expected released nonce 3 to be reused, got %d", n)
+	}
+	// Transactions sent outside the store push the next nonce forward.
+	if n := reserve(10); n != 10 {
+		t.Fatalf("expected nonce to follow chain pending 10, got %d", n)
+	}
+
+	tx := blockchain.PendingTx{To: "0x2222222222222222222222222222222222222222", Data: "0x00", Gas: 21000, GasPriceWei: big.NewInt(1000)}
+	for _, nonce := range []uint64{3, 4} {
+		if err := repo.MarkSubmitted(ctx, addr, nonce, "0xhash", tx, false); err != nil {
+			t.Fatalf("mark submitted: %v", err)
+		}
+	}
+	if err := repo.Confirm(ctx, addr, 4); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	stuck, err := repo.ListStuck(ctx, addr, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("list stuck: %v", err)
+	}
+	if len(stuck) != 1 || stuck[0].Nonce != 4 || stuck[0].Tx.GasPriceWei.Cmp(big.NewInt(1000)) != 0 || stuck[0].Tx.Gas != 21000 {
+		t.Fatalf("unexpected stuck reservations: %+v", stuck)
+	}
+}
@@ -0,0 +1,209 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loangraph/backend/internal/auth"
+	"github.com/loangraph/backend/internal/config"
+	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
+	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
+	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+	"github.com/loangraph/backend/internal/http/handlers"
+	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
+	"github.com/loangraph/backend/internal/server"
+	"github.com/loangraph/backend/test/integration/testutil"
+)
+
+type fakePoolService struct{}
+
+func (s *fakePoolService) CreatePool(_ context.Context, _ pooldomain.Actor, in pooldomain.CreateInput) (*pooldomain.Entity, error) {
+	if in.CurrencyCode != "NGN" {
+		return nil, pooldomain.ErrCurrencyMismatch
+	}
+	return &pooldomain.Entity{ID: "pool-1", LenderID: in.LenderID, Name: in.Name, CurrencyCode: in.CurrencyCode, TargetAPYBPS: in.TargetAPYBPS, Status: pooldomain.StatusOpen}, nil
+}
+
+func (s *fakePoolService) UpdatePool(_ context.Context, _ pooldomain.Actor, poolID string, req pooldomain.UpdateRequest) (*pooldomain.Entity, error) {
+	if req.Status != nil && *req.Status == pooldomain.StatusSettled {
+		return nil, pooldomain.ErrInvalidStatusTransition
+	}
+	return &pooldomain.Entity{ID: poolID, Status: pooldomain.StatusOpen}, nil
+}
+
+func (s *fakePoolService) ClosePool(_ context.Context, _ pooldomain.Actor, poolID string) (*pooldomain.Entity, error) {
+	switch poolID {
+	case "missing":
+		return nil, pooldomain.ErrNotFound
+	case "other-lenders":
+		return nil, pooldomain.ErrForbidden
+	}
+	return &pooldomain.Entity{ID: poolID, Status: pooldomain.StatusClosed}, nil
+}
+
+func (s *fakePoolService) AllocateLoans(_ context.Context, _ pooldomain.Actor, poolID string, loanIDs []string) (*pooldomain.AllocationResult, error) {
+	result := &pooldomain.AllocationResult{Allocated: []pooldomain.Allocation{}, Errors: []pooldomain.AllocationError{}}
+	for _, id := range loanIDs {
+		result.Allocated = append(result.Allocated, pooldomain.Allocation{ID: "alloc-" + id, PoolID: poolID, LoanID: id})
+	}
+	return result, nil
+}
+
+func (s *fakePoolService) ReleaseLoan(_ context.Context, _ pooldomain.Actor, _ string, loanID string) error {
+	if loanID != "loan-1" {
+		return pooldomain.ErrAllocationNotFound
+	}
+	return nil
+}
+
+func (s *fakePoolService) ListAllocations(_ context.Context, _ pooldomain.Actor, poolID string, _, _ int32) ([]pooldomain.Allocation, error) {
+	return []pooldomain.Allocation{{ID: "alloc-1", PoolID: poolID, LoanID: "loan-1"}}, nil
+}
+
+func TestPoolManagementRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := newFakeRepo()
+	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
+	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "")
+	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
+	poolHandler := handlers.NewPoolHandler(&fakePoolService{})
+
+	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{
+		AuthHandler: authHandler,
+		PoolHandler: poolHandler,
+		JWTManager:  jwtManager,
+	})
+
+	loginReq := httptest.NewRequest(http.MethodPost, "/v1/auth/privy/login", bytes.NewBufferString(`{"privy_access_token":"token"}`))
+	loginReq.Header.Set("Content-Type", "application/json")
+	loginW := httptest.NewRecorder()
+	r.ServeHTTP(loginW, loginReq)
+	if loginW.Code != http.StatusOK {
+		t.Fatalf("expected login 200, got %d", loginW.Code)
+	}
+
+	var accessCookie *http.Cookie
+	for _, c := range loginW.Result().Cookies() {
+		if c.Name == auth.AccessCookieName {
+			accessCookie = c
+			break
+		}
+	}
+	if accessCookie == nil {
+		t.Fatalf("missing access cookie")
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/v1/pools", `{"lender_id":"lender-1","name":"Starter Pool","currency":"NGN","target_apybps":1200}`, http.StatusCreated},
+		{http.MethodPost, "/v1/pools", `{"lender_id":"lender-1","name":"Starter Pool","currency":"KES","target_apybps":1200}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/pools", `{"name":"Starter Pool"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/v1/pools/pool-1", `{"target_apybps":1500}`, http.StatusOK},
+		{http.MethodPatch, "/v1/pools/pool-1", `{"status":"settled"}`, http.StatusConflict},
+		{http.MethodPatch, "/v1/pools/pool-1", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/pools/pool-1/close", ``, http.StatusOK},
+		{http.MethodPost, "/v1/pools/missing/close", ``, http.StatusNotFound},
+		{http.MethodPost, "/v1/pools/other-lenders/close", ``, http.StatusForbidden},
+		{http.MethodPost, "/v1/pools/pool-1/loans", `{"loan_ids":["loan-1","loan-2"]}`, http.StatusOK},
+		{http.MethodPost, "/v1/pools/pool-1/loans", `{"loan_ids":[]}`, http.StatusBadRequest},
+		{http.MethodGet, "/v1/pools/pool-1/loans", ``, http.StatusOK},
+		{http.MethodDelete, "/v1/pools/pool-1/loans/loan-1", ``, http.StatusOK},
+		{http.MethodDelete, "/v1/pools/pool-1/loans/loan-9", ``, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(accessCookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d (%s)", tc.method, tc.path, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPoolServiceChecksLenderMembership(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lenderRepo := postgresrepo.NewLenderRepository(pool)
+	lender, err := lenderRepo.Create(ctx, lenderdomain.CreateInput{
+		Name:          "Member Lender",
+		CountryCode:   "NG",
+		WalletAddress: "0x6161616161616161616161616161616161616161",
+		KYCStatus:     "approved",
+		Tier:          "starter",
+	})
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	borrower, err := postgresrepo.NewBorrowerRepository(pool).Create(ctx, borrowerdomain.CreateInput{
+		BorrowerHash: []byte{0x61, 0x62},
+		LenderID:     lender.ID,
+		CountryCode:  "NG",
+	})
+	if err != nil {
+		t.Fatalf("create borrower: %v", err)
+	}
+	if _, err := postgresrepo.NewLoanRepository(pool).Create(ctx, loandomain.CreateInput{
+		LoanHash:        []byte{0x61, 0x63},
+		LenderID:        lender.ID,
+		BorrowerID:      borrower.ID,
+		PrincipalMinor:  100000,
+		CurrencyCode:    "NGN",
+		InterestRateBPS: 2000,
+		StartDate:       time.Now().UTC(),
+		MaturityDate:    time.Now().UTC().Add(90 * 24 * time.Hour),
+		RiskGrade:       "B",
+		Metadata:        []byte(`{}`),
+	}); err != nil {
+		t.Fatalf("create loan: %v", err)
+	}
+	var memberID, outsiderID string
+	for subject, id := range map[string]*string{"did:privy:pool-member": &memberID, "did:privy:pool-outsider": &outsiderID} {
+		if err := pool.QueryRow(ctx, `INSERT INTO users (privy_subject, role) VALUES ($1, 'lender') RETURNING id::text`, subject).Scan(id); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	if err := lenderRepo.AddMember(ctx, lender.ID, memberID); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	svc := pooldomain.NewService(
+		postgresrepo.NewPoolRepository(pool),
+		lenderRepo,
+		postgresrepo.NewLoanRepository(pool),
+		postgresrepo.NewAdminAuditRepository(pool),
+	)
+	in := pooldomain.CreateInput{LenderID: lender.ID, Name: "Member Pool", CurrencyCode: "NGN", TargetAPYBPS: 1200}
+	outsider := pooldomain.Actor{UserID: outsiderID}
+	if _, err := svc.CreatePool(ctx, outsider, in); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected an outsider refused, got %v", err)
+	}
+	created, err := svc.CreatePool(ctx, pooldomain.Actor{UserID: memberID}, in)
+	if err != nil {
+		t.Fatalf("create pool as member: %v", err)
+	}
+	if _, err := svc.ClosePool(ctx, outsider, created.ID); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected an outsider refused closing the pool, got %v", err)
+	}
+	if _, err := svc.ClosePool(ctx, pooldomain.Actor{UserID: outsiderID, Admin: true}, created.ID); err != nil {
+		t.Fatalf("close pool as admin: %v", err)
+	}
+}
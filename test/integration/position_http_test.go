@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loangraph/backend/internal/auth"
+	"github.com/loangraph/backend/internal/config"
+	"github.com/loangraph/backend/internal/db"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
+	"github.com/loangraph/backend/internal/http/handlers"
+	"github.com/loangraph/backend/internal/server"
+)
+
+type fakePositionService struct{}
+
+func (s *fakePositionService) Deposit(_ context.Context, investorUserID, poolID string, amountMinor int64) (*positiondomain.Transaction, error) {
+	if poolID == "missing" {
+		return nil, positiondomain.ErrPoolNotFound
+	}
+	return &positiondomain.Transaction{ID: "tx-1", PoolID: poolID, InvestorUserID: investorUserID, Kind: positiondomain.KindDeposit, AmountMinor: amountMinor, Shares: amountMinor, NAVPerShareMicros: positiondomain.NAVScale}, nil
+}
+
+func (s *fakePositionService) Withdraw(_ context.Context, _, _ string, _ int64) (*positiondomain.Transaction, error) {
+	return nil, positiondomain.ErrInsufficientShares
+}
+
+func (s *fakePositionService) ListPositions(_ context.Context, _ string) ([]positiondomain.PositionView, error) {
+	return []positiondomain.PositionView{{PoolID: "pool-1", Shares: 1000, NAVPerShareMicros: positiondomain.NAVScale, ValueMinor: 1000}}, nil
+}
+
+func (s *fakePositionService) ListHistory(_ context.Context, investorUserID, poolID string, _, _ int32) ([]positiondomain.Transaction, error) {
+	return []positiondomain.Transaction{{ID: "tx-1", PoolID: poolID, InvestorUserID: investorUserID, Kind: positiondomain.KindDeposit}}, nil
+}
+
+func TestPositionRoutesRequireInvestorRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := newFakeRepo()
+	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
+	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "")
+	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
+	positionHandler := handlers.NewPositionHandler(&fakePositionService{})
+
+	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{
+		AuthHandler:     authHandler,
+		PositionHandler: positionHandler,
+		JWTManager:      jwtManager,
+	})
+
+	login := func() *http.Cookie {
+		loginReq := httptest.NewRequest(http.MethodPost, "/v1/auth/privy/login", bytes.NewBufferString(`{"privy_access_token":"token"}`))
+		loginReq.Header.Set("Content-Type", "application/json")
+		loginW := httptest.NewRecorder()
+		r.ServeHTTP(loginW, loginReq)
+		if loginW.Code != http.StatusOK {
+			t.Fatalf("expected login 200, got %d", loginW.Code)
+		}
+		for _, c := range loginW.Result().Cookies() {
+			if c.Name == auth.AccessCookieName {
+				return c
+			}
+		}
+		t.Fatalf("missing access cookie")
+		return nil
+	}
+	do := func(cookie *http.Cookie, method, path, body string) int {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	lenderCookie := login()
+	if code := do(lenderCookie, http.MethodPost, "/v1/pools/pool-1/deposits", `{"amount_minor":1000}`); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for lender deposit, got %d", code)
+	}
+
+	repo.users["did:privy:test-user"] = &db.User{ID: "u-1", PrivySubject: "did:privy:test-user", Role: auth.RoleInvestor}
+	investorCookie := login()
+
+	if code := do(investorCookie, http.MethodPost, "/v1/pools/pool-1/deposits", `{"amount_minor":1000}`); code != http.StatusCreated {
+		t.Fatalf("expected 201 for deposit, got %d", code)
+	}
+	if code := do(investorCookie, http.MethodPost, "/v1/pools/pool-1/deposits", `{"amount_minor":0}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for zero deposit, got %d", code)
+	}
+	if code := do(investorCookie, http.MethodPost, "/v1/pools/missing/deposits", `{"amount_minor":1000}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown pool, got %d", code)
+	}
+	if code := do(investorCookie, http.MethodPost, "/v1/pools/pool-1/withdrawals", `{"amount_minor":1000}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for insufficient shares, got %d", code)
+	}
+	for _, path := range []string{"/v1/investor/positions", "/v1/investor/positions/pool-1/history"} {
+		if code := do(investorCookie, http.MethodGet, path, ""); code != http.StatusOK {
+			t.Fatalf("expected 200 for %s, got %d", path, code)
+		}
+	}
+}
@@ -2,14 +2,19 @@ package integration
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
 	passportdomain "github.com/loangraph/backend/internal/domain/passport"
 	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
+	"github.com/loangraph/backend/internal/realtime"
 	"github.com/loangraph/backend/internal/repository/postgres"
 	"github.com/loangraph/backend/test/integration/testutil"
 )
@@ -112,6 +117,59 @@ func TestPostgresRepositoriesCoreDomainFlow(t *testing.T) {
 		t.Fatalf("pool mismatch")
 	}
 
+	if _, err := poolRepo.AllocateLoan(ctx, poolItem.ID, loanItem.ID, loanItem.PrincipalMinor); err != nil {
+		t.Fatalf("allocate loan: %v", err)
+	}
+	if _, err := poolRepo.AllocateLoan(ctx, poolItem.ID, loanItem.ID, loanItem.PrincipalMinor); !errors.Is(err, pooldomain.ErrLoanAlreadyAllocated) {
+		t.Fatalf("expected duplicate allocation to fail, got %v", err)
+	}
+	if err := loanRepo.RecordRepayment(ctx, loanItem.ID, 100000); err != nil {
+		t.Fatalf("record repayment: %v", err)
+	}
+	summary, err := poolRepo.GetPerformanceSummary(ctx, poolItem.ID)
+	if err != nil {
+		t.Fatalf("pool performance summary: %v", err)
+	}
+	if summary.AllocatedLoans != 1 || summary.DeployedMinor != 500000 || summary.RepaidMinor != 100000 || summary.WeightedAvgInterestBPS != 2200 {
+		t.Fatalf("unexpected pool summary: %+v", summary)
+	}
+	series, err := poolRepo.GetRepaymentTimeSeries(ctx, poolItem.ID, 30)
+	if err != nil {
+		t.Fatalf("pool repayment series: %v", err)
+	}
+	if len(series) != 1 || series[0].RepaidAmountMinor != 100000 {
+		t.Fatalf("unexpected pool repayment series: %+v", series)
+	}
+	if _, err := poolRepo.ReleaseLoan(ctx, poolItem.ID, loanItem.ID); err != nil {
+		t.Fatalf("release loan: %v", err)
+	}
+	released, err := poolRepo.GetByID(ctx, poolItem.ID)
+	if err != nil {
+		t.Fatalf("get pool: %v", err)
+	}
+	if released.TotalDeployed != 0 {
+		t.Fatalf("expected total deployed reset, got %d", released.TotalDeployed)
+	}
+
+	ledgerRepo := postgres.NewLedgerRepository(pool)
+	lenderBalances, err := ledgerRepo.GetLenderBalances(ctx, lender.ID)
+	if err != nil {
+		t.Fatalf("ledger lender balances: %v", err)
+	}
+	if len(lenderBalances) != 1 || lenderBalances[0].PrincipalDisbursedMinor != 500000 || lenderBalances[0].PrincipalRepaidMinor != 100000 || lenderBalances[0].OutstandingMinor != 400000 || lenderBalances[0].PoolFundedMinor != 0 {
+		t.Fatalf("unexpected ledger lender balances: %+v", lenderBalances)
+	}
+	receivable, err := ledgerRepo.GetBalance(ctx, ledgerdomain.Account{Kind: ledgerdomain.AccountBorrowerReceivable, OwnerID: loanItem.ID, CurrencyCode: "NGN"})
+	if err != nil {
+		t.Fatalf("ledger receivable balance: %v", err)
+	}
+	if receivable.BalanceMinor != 400000 {
+		t.Fatalf("expected receivable 400000, got %+v", receivable)
+	}
+	if _, err := pool.Exec(ctx, `UPDATE ledger_postings SET debit_minor = debit_minor + 1`); err == nil {
+		t.Fatalf("expected ledger postings to be immutable")
+	}
+
 	tokenID := int64(1)
 	cache, err := passportRepo.Upsert(ctx, passportdomain.UpsertInput{
 		BorrowerID:         borrower.ID,
@@ -145,3 +203,175 @@ func borrowLenderInput() lenderdomain.CreateInput {
 		Tier:          "starter",
 	}
 }
+
+func TestPositionRepositoryEnqueuesPoolPositionWithTransaction(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lender, err := postgres.NewLenderRepository(pool).Create(ctx, borrowLenderInput())
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	poolItem, err := postgres.NewPoolRepository(pool).Create(ctx, pooldomain.CreateInput{
+		LenderID:      lender.ID,
+		Name:          "Token Pool",
+		PoolTokenAddr: "0x114dac28b091F2d73dF6509E4063D6553eB36fa2",
+		TargetAPYBPS:  1200,
+		CurrencyCode:  "NGN",
+		Status:        pooldomain.StatusOpen,
+	})
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	var investorID string
+	if err := pool.QueryRow(ctx, `INSERT INTO users (privy_subject, role) VALUES ('did:privy:position-investor', 'investor') RETURNING id::text`).Scan(&investorID); err != nil {
+		t.Fatalf("create investor: %v", err)
+	}
+
+	positionRepo := postgres.NewPositionRepository(pool)
+	in := positiondomain.TransactionInput{
+		PoolID:            poolItem.ID,
+		InvestorUserID:    investorID,
+		Kind:              positiondomain.KindDeposit,
+		AmountMinor:       100000,
+		Shares:            100000,
+		NAVPerShareMicros: positiondomain.NAVScale,
+		PoolTokenAddr:     poolItem.PoolTokenAddr,
+	}
+	tx, err := positionRepo.RecordTransaction(ctx, in)
+	if err != nil {
+		t.Fatalf("record deposit: %v", err)
+	}
+	var topic, action string
+	if err := pool.QueryRow(ctx, `SELECT topic, payload->>'action' FROM outbox_jobs WHERE payload->>'transaction_id' = $1`, tx.ID).Scan(&topic, &action); err != nil {
+		t.Fatalf("load pool_position job: %v", err)
+	}
+	if topic != "pool_position" || action != "deposit" {
+		t.Fatalf("unexpected job %s %s", topic, action)
+	}
+
+	// A rejected withdrawal leaves neither a transaction nor a job behind.
+	in.Kind, in.Shares, in.ExpectedTotalShares = positiondomain.KindWithdrawal, 200000, 100000
+	if _, err := positionRepo.RecordTransaction(ctx, in); !errors.Is(err, positiondomain.ErrInsufficientShares) {
+		t.Fatalf("expected insufficient shares, got %v", err)
+	}
+	var jobs int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM outbox_jobs WHERE topic = 'pool_position'`).Scan(&jobs); err != nil {
+		t.Fatalf("count jobs: %v", err)
+	}
+	if jobs != 1 {
+		t.Fatalf("expected one pool_position job, got %d", jobs)
+	}
+}
+
+func TestRealtimeEventsCommitWithoutWaitingForEachOther(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsRepo := postgres.NewWSRepository(pool)
+	before, err := wsRepo.LatestRealtimeEventID(ctx)
+	if err != nil {
+		t.Fatalf("latest realtime event: %v", err)
+	}
+
+	// A producer takes an id and keeps its transaction open, the way
+	// insertRealtimeEvent does it.
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var xid, heldID int64
+	if err := tx.QueryRow(ctx, `SELECT pg_current_xact_id()::text::bigint`).Scan(&xid); err != nil {
+		t.Fatalf("assign xact id: %v", err)
+	}
+	if err := tx.QueryRow(ctx, `INSERT INTO realtime_events (channel, event, data) VALUES ('loan:a', 'loan_status_changed', '{}') RETURNING id`).Scan(&heldID); err != nil {
+		t.Fatalf("insert held event: %v", err)
+	}
+
+	// Another producer commits a later id without waiting.
+	if err := wsRepo.Publish(ctx, "loan:b", "loan_status_changed", map[string]string{"status": "confirmed"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	page, err := wsRepo.ListRealtimeEventsSince(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("list realtime events: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].ID <= heldID {
+		t.Fatalf("expected only the later event visible, got %+v", page.Events)
+	}
+	if page.Xmin > xid || page.Xmax <= xid {
+		t.Fatalf("expected the open producer %d within the snapshot bounds, got [%d, %d)", xid, page.Xmin, page.Xmax)
+	}
+
+	// Once it commits, a later snapshot shows both and starts after the
+	// snapshot that saw the gap.
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	page2, err := wsRepo.ListRealtimeEventsSince(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("list realtime events: %v", err)
+	}
+	if len(page2.Events) != 2 || page2.Events[0].ID != heldID {
+		t.Fatalf("expected both events in id order, got %+v", page2.Events)
+	}
+	if page2.Xmin < page.Xmax {
+		t.Fatalf("expected xmin %d to pass the earlier xmax %d", page2.Xmin, page.Xmax)
+	}
+}
+
+func TestLenderRepositoryRemoveMemberPublishesRevocation(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	lenderRepo := postgres.NewLenderRepository(pool)
+	wsRepo := postgres.NewWSRepository(pool)
+	lender, err := lenderRepo.Create(ctx, borrowLenderInput())
+	if err != nil {
+		t.Fatalf("create lender: %v", err)
+	}
+	var userID string
+	if err := pool.QueryRow(ctx, `INSERT INTO users (privy_subject, role) VALUES ('did:privy:removed-member', 'lender') RETURNING id::text`).Scan(&userID); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := lenderRepo.AddMember(ctx, lender.ID, userID); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	before, err := wsRepo.LatestRealtimeEventID(ctx)
+	if err != nil {
+		t.Fatalf("latest realtime event: %v", err)
+	}
+
+	if err := lenderRepo.RemoveMember(ctx, lender.ID, userID); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	if ok, err := lenderRepo.IsMember(ctx, lender.ID, userID); err != nil || ok {
+		t.Fatalf("expected membership removed, got %v %v", ok, err)
+	}
+	// Removing someone who is no longer a member announces nothing.
+	if err := lenderRepo.RemoveMember(ctx, lender.ID, userID); err != nil {
+		t.Fatalf("remove member again: %v", err)
+	}
+	page, err := wsRepo.ListRealtimeEventsSince(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("list realtime events: %v", err)
+	}
+	if len(page.Events) != 1 {
+		t.Fatalf("expected one revocation event, got %+v", page.Events)
+	}
+	ev := page.Events[0]
+	if ev.Channel != realtime.LenderMembersChannel || ev.Type != realtime.EventLenderMemberRemoved || !strings.Contains(string(ev.Data), userID) {
+		t.Fatalf("unexpected revocation event: %+v", ev)
+	}
+}
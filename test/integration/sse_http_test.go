@@ -0,0 +1,167 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/auth"
+	"github.com/loangraph/backend/internal/config"
+	"github.com/loangraph/backend/internal/http/handlers"
+	"github.com/loangraph/backend/internal/realtime"
+	"github.com/loangraph/backend/internal/server"
+	internalws "github.com/loangraph/backend/internal/ws"
+)
+
+type fakeSSEReplay struct {
+	events []realtime.Event
+}
+
+func (r fakeSSEReplay) ListChannelEventsSince(_ context.Context, channel string, afterSeq int64, limit int32) ([]realtime.Event, error) {
+	var out []realtime.Event
+	for _, ev := range r.events {
+		if ev.Channel == channel && ev.ID > afterSeq && int32(len(out)) < limit {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (r fakeSSEReplay) OldestRealtimeEventID(context.Context) (int64, error) {
+	return 1, nil
+}
+
+type sseMessage struct {
+	id, event, data string
+}
+
+func readSSE(t *testing.T, r *bufio.Reader) sseMessage {
+	t.Helper()
+	var msg sseMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if msg.data != "" {
+				return msg
+			}
+		case strings.HasPrefix(line, "id:"):
+			msg.id = strings.TrimPrefix(line, "id:")
+		case strings.HasPrefix(line, "event:"):
+			msg.event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			msg.data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func TestEventStreamResumesAndStreamsLiveEvents(t *testing.T) {
+	repo := newFakeRepo()
+	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
+	authSvc := auth.NewService(repo, jwtManager, fakeVerifier{}, 15*time.Minute, 24*time.Hour, "")
+	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
+
+	hub := internalws.NewHub()
+	wsHandler := internalws.NewHandler(hub, fakeWSAccess{})
+	wsHandler.SetReplay(fakeSSEReplay{events: []realtime.Event{
+		{ID: 2, Channel: "pool:repayments:pool-1", Type: "repayment_recorded", Data: []byte(`{"loan_id":"loan-1"}`)},
+		{ID: 3, Channel: "pool:repayments:pool-2", Type: "repayment_recorded", Data: []byte(`{"loan_id":"loan-2"}`)},
+		{ID: 4, Channel: "lender:portfolio:lender-1", Type: "portfolio_updated", Data: []byte(`{"lender_id":"lender-1"}`)},
+	}})
+
+	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{
+		AuthHandler: authHandler,
+		WSHandler:   wsHandler,
+		JWTManager:  jwtManager,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	loginReqBody, _ := json.Marshal(map[string]string{"privy_access_token": "token"})
+	loginResp, err := http.Post(ts.URL+"/v1/auth/privy/login", "application/json", bytes.NewReader(loginReqBody))
+	if err != nil {
+		t.Fatalf("login request failed: %v", err)
+	}
+	defer loginResp.Body.Close()
+	var accessCookie *http.Cookie
+	for _, c := range loginResp.Cookies() {
+		if c.Name == auth.AccessCookieName {
+			accessCookie = c
+			break
+		}
+	}
+	if accessCookie == nil {
+		t.Fatalf("missing access cookie")
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(query, lastEventID string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/events/stream?"+query, nil)
+		req.AddCookie(accessCookie)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("stream request failed: %v", err)
+		}
+		return resp
+	}
+
+	for query, want := range map[string]int{
+		"channels=pool:repayments:pool-2":                    http.StatusForbidden,
+		"channels=pool:repayments:pool-1,admin:outbox":       http.StatusForbidden,
+		"channels=pool:repayments":                           http.StatusBadRequest,
+		"channels=":                                          http.StatusBadRequest,
+		"channels=pool:repayments:pool-1&last_event_id=nope": http.StatusBadRequest,
+	} {
+		resp := get(query, "")
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET ?%s = %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+
+	// The notifier has published through seq 4, so that is as far as
+	// replays go.
+	hub.Publish("lender:portfolio:lender-1", 4, []byte(`{}`))
+
+	// The user is a member of lender-1, whose pool-1 and portfolio it
+	// streams; the replay skips pool-2 and runs in seq order.
+	resp := get("channels=pool:repayments:pool-1,lender:portfolio:lender-1", "1")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("expected event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body := bufio.NewReader(resp.Body)
+	if msg := readSSE(t, body); msg.event != "subscribed" || msg.id != "" {
+		t.Fatalf("expected subscribed first, got %+v", msg)
+	}
+	if msg := readSSE(t, body); msg.id != "2" || msg.event != "repayment_recorded" || !strings.Contains(msg.data, `"seq":2`) {
+		t.Fatalf("expected replayed repayment 2, got %+v", msg)
+	}
+	if msg := readSSE(t, body); msg.id != "4" || msg.event != "portfolio_updated" {
+		t.Fatalf("expected replayed portfolio update 4, got %+v", msg)
+	}
+	if msg := readSSE(t, body); msg.event != "resumed" || !strings.Contains(msg.data, `"last_seq":4`) {
+		t.Fatalf("expected resumed, got %+v", msg)
+	}
+
+	// Already replayed, so not sent again.
+	hub.Publish("pool:repayments:pool-1", 2, []byte(`{"event":"repayment_recorded","channel":"pool:repayments:pool-1","seq":2,"data":{}}`))
+	hub.Publish("pool:repayments:pool-1", 5, []byte(`{"event":"repayment_recorded","channel":"pool:repayments:pool-1","seq":5,"data":{}}`))
+	if msg := readSSE(t, body); msg.id != "5" || msg.event != "repayment_recorded" {
+		t.Fatalf("expected live repayment 5, got %+v", msg)
+	}
+}
@@ -67,7 +67,7 @@ func ApplyMigrations(t *testing.T, pool *pgxpool.Pool) {
 		if err != nil {
 			t.Fatalf("read migration %s: %v", file, err)
 		}
-		for _, stmt := range strings.Split(string(content), ";") {
+		for _, stmt := range splitStatements(string(content)) {
 			q := strings.TrimSpace(stmt)
 			if q == "" {
 				continue
@@ -79,6 +79,29 @@ func ApplyMigrations(t *testing.T, pool *pgxpool.Pool) {
 	}
 }
 
+// splitStatements splits a migration on ";" while keeping dollar-quoted
+// function bodies intact.
+func splitStatements(sql string) []string {
+	var out []string
+	var current strings.Builder
+	inDollar := false
+	for i := 0; i < len(sql); i++ {
+		if strings.HasPrefix(sql[i:], "$$") {
+			inDollar = !inDollar
+			current.WriteString("$$")
+			i++
+			continue
+		}
+		if sql[i] == ';' && !inDollar {
+			out = append(out, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteByte(sql[i])
+	}
+	return append(out, current.String())
+}
+
 func ResetTables(t *testing.T, pool *pgxpool.Pool) {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -87,7 +110,25 @@ func ResetTables(t *testing.T, pool *pgxpool.Pool) {
 	q := `
 TRUNCATE TABLE
   admin_audit_logs,
+  ledger_postings,
+  ledger_entries,
+  ledger_accounts,
+  pool_allocations,
+  investor_transactions,
+  investor_positions,
   outbox_jobs,
+  chain_fee_spend,
+  chain_fee_budget,
+  chain_batch_items,
+  chain_batches,
+  chain_nonce_reservations,
+  chain_nonces,
+  lender_members,
+  realtime_events,
+  realtime_cursors,
+  chain_reconciliation_discrepancies,
+  chain_reconciliation_runs,
+  chain_repayment_applications,
   chain_events,
   pools,
   passport_cache,
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
 	"github.com/loangraph/backend/internal/jobs"
 	postgresrepo "github.com/loangraph/backend/internal/repository/postgres"
 	"github.com/loangraph/backend/test/integration/testutil"
@@ -88,3 +90,172 @@ func TestWorkerProcessesRegisterLoanOutboxJob(t *testing.T) {
 		t.Fatalf("expected outbox status done, got %s", status)
 	}
 }
+
+func TestOutboxRepositoryDeadLetterManagement(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	outboxRepo := postgresrepo.NewOutboxRepository(pool)
+	for _, loanID := range []string{"missing-1", "missing-2"} {
+		if err := outboxRepo.Enqueue(ctx, "register_loan", []byte(`{"loan_id":"`+loanID+`"}`)); err != nil {
+			t.Fatalf("enqueue outbox: %v", err)
+		}
+	}
+	claimed, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("claim pending: %v (%d)", err, len(claimed))
+	}
+	for _, job := range claimed {
+		if err := outboxRepo.MarkFailed(ctx, job.ID, "rpc timeout"); err != nil {
+			t.Fatalf("mark failed: %v", err)
+		}
+	}
+
+	failed, err := outboxRepo.List(ctx, outboxdomain.ListFilter{Status: outboxdomain.StatusFailed, Topic: "register_loan"})
+	if err != nil || len(failed) != 2 || failed[0].LastError != "rpc timeout" {
+		t.Fatalf("unexpected failed jobs: %+v (%v)", failed, err)
+	}
+
+	retried, err := outboxRepo.Retry(ctx, claimed[0].ID)
+	if err != nil || retried.Status != outboxdomain.StatusPending || retried.Attempts != 0 {
+		t.Fatalf("unexpected retry result: %+v (%v)", retried, err)
+	}
+	if _, err := outboxRepo.Retry(ctx, claimed[0].ID); !errors.Is(err, outboxdomain.ErrInvalidTransition) {
+		t.Fatalf("expected retry of pending job to be rejected, got %v", err)
+	}
+	if _, err := outboxRepo.Cancel(ctx, claimed[1].ID); err != nil {
+		t.Fatalf("cancel job: %v", err)
+	}
+	if n, err := outboxRepo.RequeueFailed(ctx, "register_loan"); err != nil || n != 0 {
+		t.Fatalf("expected no failed jobs left to requeue, got %d (%v)", n, err)
+	}
+
+	counts, err := outboxRepo.CountByStatus(ctx)
+	if err != nil {
+		t.Fatalf("count by status: %v", err)
+	}
+	byStatus := map[string]int64{}
+	for _, c := range counts {
+		byStatus[c.Status] += c.Count
+	}
+	if byStatus[outboxdomain.StatusPending] != 1 || byStatus[outboxdomain.StatusCancelled] != 1 {
+		t.Fatalf("unexpected status counts: %+v", counts)
+	}
+}
+
+func TestOutboxRepositoryHoldsJobsBehindFailedPredecessors(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	outboxRepo := postgresrepo.NewOutboxRepository(pool)
+	for _, topic := range []string{"register_loan", "record_repayment"} {
+		if err := outboxRepo.Enqueue(ctx, topic, []byte(`{"loan_id":"ordered-1","amount_minor":100}`)); err != nil {
+			t.Fatalf("enqueue outbox: %v", err)
+		}
+	}
+	claimed, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Topic != "register_loan" {
+		t.Fatalf("expected only the registration to be claimable: %+v (%v)", claimed, err)
+	}
+	if err := outboxRepo.MarkFailed(ctx, claimed[0].ID, "rpc timeout"); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	if next, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10); err != nil || len(next) != 0 {
+		t.Fatalf("expected the repayment to wait behind the failed registration: %+v (%v)", next, err)
+	}
+	if _, err := outboxRepo.Cancel(ctx, claimed[0].ID); err != nil {
+		t.Fatalf("cancel job: %v", err)
+	}
+	next, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10)
+	if err != nil || len(next) != 1 || next[0].Topic != "record_repayment" {
+		t.Fatalf("expected the repayment once the registration is cancelled: %+v (%v)", next, err)
+	}
+}
+
+func TestOutboxRepositoryLeases(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	outboxRepo := postgresrepo.NewOutboxRepository(pool)
+	if err := outboxRepo.Enqueue(ctx, "register_loan", []byte(`{"loan_id":"lease-1"}`)); err != nil {
+		t.Fatalf("enqueue outbox: %v", err)
+	}
+	claimed, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("claim pending: %v (%d)", err, len(claimed))
+	}
+	job, err := outboxRepo.GetByID(ctx, claimed[0].ID)
+	if err != nil || job.LockedBy != "worker-1" || job.LockedUntil == nil {
+		t.Fatalf("expected lease on claimed job: %+v (%v)", job, err)
+	}
+	if n, failed, err := outboxRepo.ReapExpiredLeases(ctx, 5, nil); err != nil || n != 0 || failed != 0 {
+		t.Fatalf("expected live lease to survive reaping, got %d/%d (%v)", n, failed, err)
+	}
+
+	// Another worker cannot renew or release a lease it does not hold.
+	if err := outboxRepo.Release(ctx, "worker-2", []int64{job.ID}); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := outboxRepo.RenewLease(ctx, "worker-1", []int64{job.ID}, -time.Minute); err != nil {
+		t.Fatalf("renew lease: %v", err)
+	}
+	if n, failed, err := outboxRepo.ReapExpiredLeases(ctx, 5, nil); err != nil || n != 1 || failed != 0 {
+		t.Fatalf("expected expired lease to be reaped, got %d/%d (%v)", n, failed, err)
+	}
+	job, err = outboxRepo.GetByID(ctx, job.ID)
+	if err != nil || job.Status != "pending" || job.LockedBy != "" || job.LastError != "lease_expired" || job.Attempts != 1 {
+		t.Fatalf("unexpected reaped job: %+v (%v)", job, err)
+	}
+
+	// A job that crashes its worker on the last attempt its topic allows is
+	// failed rather than handed out again.
+	claimed, err = outboxRepo.ClaimPending(ctx, "worker-1", -time.Minute, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Fatalf("claim pending: %+v (%v)", claimed, err)
+	}
+	if n, failed, err := outboxRepo.ReapExpiredLeases(ctx, 5, map[string]int32{"register_loan": 2}); err != nil || n != 0 || failed != 1 {
+		t.Fatalf("expected exhausted job to be failed, got %d/%d (%v)", n, failed, err)
+	}
+	job, err = outboxRepo.GetByID(ctx, job.ID)
+	if err != nil || job.Status != "failed" || job.LockedBy != "" || job.LastError != "lease_expired" {
+		t.Fatalf("unexpected failed job: %+v (%v)", job, err)
+	}
+	if next, err := outboxRepo.ClaimPending(ctx, "worker-1", time.Minute, 10); err != nil || len(next) != 0 {
+		t.Fatalf("expected no claimable jobs, got %+v (%v)", next, err)
+	}
+}
+
+func TestChainBatchRepositoryTracksLoans(t *testing.T) {
+	pool := testutil.NewTestPool(t)
+	defer pool.Close()
+	testutil.ApplyMigrations(t, pool)
+	testutil.ResetTables(t, pool)
+
+	ctx := context.Background()
+	repo := postgresrepo.NewChainBatchRepository(pool)
+	err := repo.RecordBatch(ctx, jobs.ChainBatch{
+		LenderID: "lender-1",
+		TxHash:   "0xbatch",
+		Items: []jobs.ChainBatchItem{
+			{JobID: 1, Action: "register_loan", LoanID: "loan-b"},
+			{JobID: 2, Action: "record_repayment", LoanID: "loan-a"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("record batch: %v", err)
+	}
+	var loanIDs []string
+	q := `SELECT array_agg(i.loan_id ORDER BY i.position) FROM chain_batch_items i JOIN chain_batches b ON b.id = i.batch_id WHERE b.tx_hash = $1`
+	if err := pool.QueryRow(ctx, q, "0xbatch").Scan(&loanIDs); err != nil || len(loanIDs) != 2 || loanIDs[0] != "loan-b" || loanIDs[1] != "loan-a" {
+		t.Fatalf("unexpected batch loans: %v (%v)", loanIDs, err)
+	}
+}
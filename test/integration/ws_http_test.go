@@ -2,6 +2,7 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
@@ -18,6 +19,31 @@ import (
 	"golang.org/x/net/websocket"
 )
 
+type fakeWSAccess struct{}
+
+func (fakeWSAccess) ListLenderIDsByUser(_ context.Context, userID string) ([]string, error) {
+	if userID == "u-1" {
+		return []string{"lender-1"}, nil
+	}
+	return nil, nil
+}
+
+func (fakeWSAccess) GetPoolLenderID(_ context.Context, poolID string) (string, error) {
+	return map[string]string{"pool-1": "lender-1", "pool-2": "lender-2"}[poolID], nil
+}
+
+func (fakeWSAccess) GetLoanLenderID(context.Context, string) (string, error) {
+	return "", nil
+}
+
+func (fakeWSAccess) GetBorrowerLenderID(context.Context, string) (string, error) {
+	return "", nil
+}
+
+func (fakeWSAccess) HasPoolPosition(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
 func TestWebSocketSubscribeAndReceive(t *testing.T) {
 	repo := newFakeRepo()
 	jwtManager := auth.NewJWTManager("issuer", "aud", "super-secret")
@@ -25,7 +51,7 @@ func TestWebSocketSubscribeAndReceive(t *testing.T) {
 	authHandler := handlers.NewAuthHandler(authSvc, auth.CookieConfig{}, 15*time.Minute, 24*time.Hour)
 
 	hub := internalws.NewHub()
-	wsHandler := internalws.NewHandler(hub)
+	wsHandler := internalws.NewHandler(hub, fakeWSAccess{})
 
 	r := server.NewRouter(config.Config{Env: "test"}, slog.Default(), server.Dependencies{
 		AuthHandler: authHandler,
@@ -69,6 +95,27 @@ func TestWebSocketSubscribeAndReceive(t *testing.T) {
 	}
 	defer conn.Close()
 
+	// The user is a member of lender-1 only, so a competitor's streams are
+	// refused.
+	for _, sub := range []string{
+		`{"action":"subscribe","channel":"lender:portfolio","lenderId":"lender-2"}`,
+		`{"action":"subscribe","channel":"pool:repayments","poolId":"pool-2"}`,
+		`{"action":"subscribe","channel":"admin:outbox"}`,
+	} {
+		if err := websocket.Message.Send(conn, sub); err != nil {
+			t.Fatalf("send subscribe: %v", err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+		var nack string
+		if err := websocket.Message.Receive(conn, &nack); err != nil {
+			t.Fatalf("receive ws error: %v", err)
+		}
+		if !strings.Contains(nack, `"message":"forbidden"`) {
+			t.Fatalf("expected forbidden for %s, got %s", sub, nack)
+		}
+	}
+	hub.Publish("pool:repayments:pool-2", 1, []byte(`{"event":"repayment_recorded","data":{"loan_id":"loan-2"}}`))
+
 	sub := `{"action":"subscribe","channel":"pool:repayments","poolId":"pool-1"}`
 	if err := websocket.Message.Send(conn, sub); err != nil {
 		t.Fatalf("send subscribe: %v", err)
@@ -83,7 +130,7 @@ func TestWebSocketSubscribeAndReceive(t *testing.T) {
 	}
 	time.Sleep(50 * time.Millisecond)
 
-	hub.Publish("pool:repayments:pool-1", []byte(`{"event":"repayment_recorded","data":{"loan_id":"loan-1"}}`))
+	hub.Publish("pool:repayments:pool-1", 2, []byte(`{"event":"repayment_recorded","data":{"loan_id":"loan-1"}}`))
 
 	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
 	var msg string
@@ -93,4 +140,23 @@ func TestWebSocketSubscribeAndReceive(t *testing.T) {
 	if !strings.Contains(msg, "repayment_recorded") {
 		t.Fatalf("unexpected ws payload: %s", msg)
 	}
+
+	for _, step := range []struct{ send, want string }{
+		{`{"action":"list"}`, `{"event":"subscriptions","topics":["pool:repayments:pool-1"]}`},
+		{`{"action":"unsubscribe","channel":"pool:repayments","poolId":"pool-1"}`, `{"event":"unsubscribed","topic":"pool:repayments:pool-1"}`},
+		{`{"action":"unsubscribe","channel":"pool:repayments","poolId":"pool-1"}`, `{"event":"error","message":"not_subscribed","topic":"pool:repayments:pool-1"}`},
+		{`{"action":"ping"}`, `{"event":"pong"}`},
+	} {
+		if err := websocket.Message.Send(conn, step.send); err != nil {
+			t.Fatalf("send %s: %v", step.send, err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+		var reply string
+		if err := websocket.Message.Receive(conn, &reply); err != nil {
+			t.Fatalf("receive reply to %s: %v", step.send, err)
+		}
+		if reply != step.want {
+			t.Fatalf("reply to %s = %s, want %s", step.send, reply, step.want)
+		}
+	}
 }
@@ -9,7 +9,8 @@ import (
 )
 
 type adminLenderRepoMock struct {
-	items map[string]*lenderdomain.Entity
+	items   map[string]*lenderdomain.Entity
+	members map[string]bool
 }
 
 func (m *adminLenderRepoMock) Create(_ context.Context, in lenderdomain.CreateInput) (*lenderdomain.Entity, error) {
@@ -37,6 +38,19 @@ func (m *adminLenderRepoMock) UpdateKYCStatus(_ context.Context, lenderID, statu
 	return context.Canceled
 }
 
+func (m *adminLenderRepoMock) AddMember(_ context.Context, lenderID, userID string) error {
+	if m.members == nil {
+		m.members = map[string]bool{}
+	}
+	m.members[lenderID+"/"+userID] = true
+	return nil
+}
+
+func (m *adminLenderRepoMock) RemoveMember(_ context.Context, lenderID, userID string) error {
+	delete(m.members, lenderID+"/"+userID)
+	return nil
+}
+
 type adminAuditRepoMock struct {
 	logs []admindomain.AuditLogInput
 }
@@ -73,3 +87,31 @@ func TestAdminServiceOnboardAndUpdateStatus(t *testing.T) {
 		t.Fatalf("expected 2 audit logs, got %d", len(auditRepo.logs))
 	}
 }
+
+func TestAdminServiceManagesLenderMembers(t *testing.T) {
+	lenderRepo := &adminLenderRepoMock{items: map[string]*lenderdomain.Entity{"lender-1": {ID: "lender-1"}}}
+	auditRepo := &adminAuditRepoMock{}
+	svc := admindomain.NewService(lenderRepo, auditRepo)
+
+	if err := svc.AddLenderMember(context.Background(), "admin-1", "lender-1", "user-1"); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if !lenderRepo.members["lender-1/user-1"] {
+		t.Fatalf("expected user-1 to be a member of lender-1")
+	}
+	if err := svc.AddLenderMember(context.Background(), "admin-1", "lender-missing", "user-1"); err == nil {
+		t.Fatalf("expected error for unknown lender")
+	}
+	if err := svc.AddLenderMember(context.Background(), "admin-1", "lender-1", " "); err == nil {
+		t.Fatalf("expected error for missing user")
+	}
+	if err := svc.RemoveLenderMember(context.Background(), "admin-1", "lender-1", "user-1"); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	if lenderRepo.members["lender-1/user-1"] {
+		t.Fatalf("expected membership removed")
+	}
+	if len(auditRepo.logs) != 2 || auditRepo.logs[0].Action != "lender_member_added" || auditRepo.logs[1].Action != "lender_member_removed" {
+		t.Fatalf("unexpected audit logs: %+v", auditRepo.logs)
+	}
+}
@@ -0,0 +1,249 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+type memFeeStore struct {
+	mu       sync.Mutex
+	reserved map[string]*big.Int
+	spent    map[string]*big.Int
+	gas      map[string]uint64
+}
+
+func newMemFeeStore() *memFeeStore {
+	return &memFeeStore{reserved: make(map[string]*big.Int), spent: make(map[string]*big.Int), gas: make(map[string]uint64)}
+}
+
+func (s *memFeeStore) ReserveSpend(_ context.Context, day time.Time, costWei, budgetWei *big.Int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := day.Format(time.DateOnly)
+	total := new(big.Int).Set(costWei)
+	if s.reserved[key] != nil {
+		total.Add(total, s.reserved[key])
+	}
+	if budgetWei != nil && total.Cmp(budgetWei) > 0 {
+		return false, nil
+	}
+	s.reserved[key] = total
+	return true, nil
+}
+
+func (s *memFeeStore) ReleaseSpend(_ context.Context, day time.Time, costWei *big.Int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := day.Format(time.DateOnly)
+	if s.reserved[key] != nil {
+		s.reserved[key].Sub(s.reserved[key], costWei)
+	}
+	return nil
+}
+
+func (s *memFeeStore) RecordSpend(_ context.Context, day time.Time, topic string, gas uint64, maxCostWei *big.Int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := day.Format(time.DateOnly) + "/" + topic
+	if s.spent[key] == nil {
+		s.spent[key] = new(big.Int)
+	}
+	s.spent[key].Add(s.spent[key], maxCostWei)
+	s.gas[key] += gas
+	return nil
+}
+
+// feeRPCServer answers the pricing calls and records sent transactions.
+func feeRPCServer(t *testing.T, estimateErr map[string]any, sent *[]map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": 1}
+		switch req.Method {
+		case "eth_estimateGas":
+			if estimateErr != nil {
+				resp["error"] = estimateErr
+			} else {
+				resp["result"] = "0x5208"
+			}
+		case "eth_feeHistory":
+			resp["result"] = map[string]any{
+				"baseFeePerGas": []string{"0x3b9aca00", "0x3b9aca00"},
+				"reward":        [][]string{{"0x77359400"}},
+			}
+		case "eth_sendTransaction":
+			var txObj map[string]any
+			_ = json.Unmarshal(req.Params[0], &txObj)
+			*sent = append(*sent, txObj)
+			resp["result"] = "0xabc"
+		default:
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestFeePolicyPadsGasAndCapsFees(t *testing.T) {
+	policy := blockchain.FeePolicy{
+		GasLimitCap:          30000,
+		GasMultiplierPercent: 120,
+		MaxFeePerGasWei:      big.NewInt(2_500_000_000),
+		MaxPriorityFeeWei:    big.NewInt(1_000_000_000),
+	}
+	if got := policy.PaddedGas(21000); got != 25200 {
+		t.Fatalf("expected padded gas 25200, got %d", got)
+	}
+	if got := policy.PaddedGas(100000); got != 30000 {
+		t.Fatalf("expected gas capped at 30000, got %d", got)
+	}
+
+	maxFee, priority := policy.DynamicFees(big.NewInt(1_000_000_000), []*big.Int{big.NewInt(3_000_000_000), big.NewInt(2_000_000_000), nil})
+	if priority.Int64() != 1_000_000_000 {
+		t.Fatalf("expected capped priority fee, got %s", priority)
+	}
+	if maxFee.Int64() != 2_500_000_000 {
+		t.Fatalf("expected capped max fee, got %s", maxFee)
+	}
+
+	capped := policy.CapFees(blockchain.PendingTx{MaxFeePerGasWei: big.NewInt(4_000_000_000), MaxPriorityFeeWei: big.NewInt(3_000_000_000)})
+	if capped.MaxFeePerGasWei.Int64() != 2_500_000_000 || capped.MaxPriorityFeeWei.Int64() != 1_000_000_000 {
+		t.Fatalf("expected fees capped, got %+v", capped)
+	}
+	if capped := policy.CapFees(blockchain.PendingTx{GasPriceWei: big.NewInt(4_000_000_000)}); capped.GasPriceWei.Int64() != 2_500_000_000 {
+		t.Fatalf("expected legacy gas price capped like a max fee, got %s", capped.GasPriceWei)
+	}
+
+	if policy.HasBudget() {
+		t.Fatalf("expected no budget without DailyBudgetWei")
+	}
+	next := blockchain.NextBudgetDay(time.Date(2026, 3, 2, 23, 59, 0, 0, time.FixedZone("x", -3*3600)))
+	if !next.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the budget to reset at the next UTC midnight, got %s", next)
+	}
+}
+
+func TestRPCWriterEstimatesGasAndSendsDynamicFees(t *testing.T) {
+	var sent []map[string]any
+	srv := feeRPCServer(t, nil, &sent)
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	w.SetFeePolicy(blockchain.FeePolicy{GasMultiplierPercent: 120, MaxPriorityFeeWei: big.NewInt(1_500_000_000)})
+	store := newMemFeeStore()
+	w.SetFeeStore(store, nil)
+
+	if _, err := w.RegisterLoan(context.Background(), "loan-1"); err != nil {
+		t.Fatalf("register loan: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected one transaction, got %d", len(sent))
+	}
+	tx := sent[0]
+	// 21000 * 1.2, 2 * 1 gwei base fee + 1.5 gwei capped tip.
+	if tx["gas"] != "0x6270" || tx["type"] != "0x2" || tx["maxPriorityFeePerGas"] != "0x59682f00" || tx["maxFeePerGas"] != "0xd09dc300" {
+		t.Fatalf("unexpected priced transaction: %+v", tx)
+	}
+	if _, ok := tx["gasPrice"]; ok {
+		t.Fatalf("expected no legacy gas price on a dynamic fee transaction")
+	}
+	key := time.Now().UTC().Format(time.DateOnly) + "/register_loan"
+	if store.gas[key] != 25200 || store.spent[key].String() != "88200000000000" {
+		t.Fatalf("unexpected recorded spend: gas=%d cost=%v", store.gas[key], store.spent[key])
+	}
+}
+
+func TestRPCWriterSkipsSendWhenEstimateReverts(t *testing.T) {
+	var sent []map[string]any
+	srv := feeRPCServer(t, map[string]any{"code": 3, "message": "execution reverted: unknown loan"}, &sent)
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	w.SetFeePolicy(blockchain.FeePolicy{GasMultiplierPercent: 120})
+
+	_, err = w.RegisterLoan(context.Background(), "loan-1")
+	if blockchain.KindOf(err) != blockchain.KindReverted {
+		t.Fatalf("expected reverted error, got %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("expected no transaction sent, got %d", len(sent))
+	}
+}
+
+func TestRPCWriterEnforcesDailyFeeBudget(t *testing.T) {
+	var sent []map[string]any
+	srv := feeRPCServer(t, nil, &sent)
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	// Each transaction may cost up to 25200 gas * 4 gwei.
+	w.SetFeePolicy(blockchain.FeePolicy{GasMultiplierPercent: 120, DailyBudgetWei: big.NewInt(150_000_000_000_000)})
+	w.SetFeeStore(newMemFeeStore(), nil)
+
+	if _, err := w.RegisterLoan(context.Background(), "loan-1"); err != nil {
+		t.Fatalf("first register loan: %v", err)
+	}
+	_, err = w.RegisterLoan(context.Background(), "loan-2")
+	if !errors.Is(err, blockchain.ErrFeeBudgetExceeded) || blockchain.KindOf(err) != blockchain.KindFeeBudgetExceeded {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected only the first transaction sent, got %d", len(sent))
+	}
+}
+
+func TestRPCWriterRecordsMulticallSpendPerTopic(t *testing.T) {
+	var sent []map[string]any
+	srv := feeRPCServer(t, nil, &sent)
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	w.SetFeePolicy(blockchain.FeePolicy{GasMultiplierPercent: 120, MaxPriorityFeeWei: big.NewInt(1_500_000_000)})
+	store := newMemFeeStore()
+	w.SetFeeStore(store, nil)
+
+	if _, err := w.SubmitBatch(context.Background(), "lender-1", []blockchain.BatchCall{
+		{Action: blockchain.BatchActionRegisterLoan, LoanID: "loan-1"},
+		{Action: blockchain.BatchActionRecordRepayment, LoanID: "loan-1", AmountMinor: 100, Currency: "USD"},
+		{Action: blockchain.BatchActionRecordRepayment, LoanID: "loan-2", AmountMinor: 100, Currency: "USD"},
+	}); err != nil {
+		t.Fatalf("submit batch: %v", err)
+	}
+	// 25200 gas and 88200000000000 wei, a third to registration and the
+	// rest to the two repayments.
+	day := time.Now().UTC().Format(time.DateOnly)
+	if store.gas[day+"/register_loan"] != 8400 || store.spent[day+"/register_loan"].String() != "29400000000000" {
+		t.Fatalf("unexpected register_loan spend: gas=%d cost=%v", store.gas[day+"/register_loan"], store.spent[day+"/register_loan"])
+	}
+	if store.gas[day+"/record_repayment"] != 16800 || store.spent[day+"/record_repayment"].String() != "58800000000000" {
+		t.Fatalf("unexpected record_repayment spend: gas=%d cost=%v", store.gas[day+"/record_repayment"], store.spent[day+"/record_repayment"])
+	}
+	if _, ok := store.spent[day+"/multicall"]; ok {
+		t.Fatalf("expected no spend keyed by the marker action")
+	}
+}
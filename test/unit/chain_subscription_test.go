@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+	"golang.org/x/net/websocket"
+)
+
+func TestHeadSubscriberWakesAndReconnects(t *testing.T) {
+	var sessions, logSubs int32
+	srv := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		session := atomic.AddInt32(&sessions, 1)
+		for i := 0; i < 2; i++ {
+			var req struct {
+				ID     int   `json:"id"`
+				Params []any `json:"params"`
+			}
+			if err := websocket.JSON.Receive(conn, &req); err != nil {
+				return
+			}
+			if req.Params[0] == "logs" {
+				atomic.AddInt32(&logSubs, 1)
+			}
+			_ = websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0xsub"})
+		}
+		_ = websocket.JSON.Send(conn, map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "eth_subscription",
+			"params":  map[string]any{"subscription": "0xsub", "result": map[string]any{"number": "0x10"}},
+		})
+		if session == 1 {
+			// Drop the first connection to force a reconnect.
+			return
+		}
+		var ignored any
+		_ = websocket.JSON.Receive(conn, &ignored)
+	}))
+	defer srv.Close()
+
+	sub, err := blockchain.NewHeadSubscriber("ws"+strings.TrimPrefix(srv.URL, "http"), blockchain.LogFilter{
+		Addresses: []string{"0x3c20Fd0B57711a199776B53C2F24385563d1670F"},
+		Topics:    []string{"0xtopic"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("new subscriber: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wake := make(chan struct{}, 1)
+	go sub.Run(ctx, wake)
+
+	for atomic.LoadInt32(&sessions) < 2 || !sub.Connected() {
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			t.Fatalf("expected reconnect after the connection dropped, got %d sessions", atomic.LoadInt32(&sessions))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	// The resubscription wakes the caller so it backfills missed blocks.
+	select {
+	case <-wake:
+	case <-ctx.Done():
+		t.Fatalf("expected wake after resubscribing")
+	}
+	if atomic.LoadInt32(&logSubs) != 2 {
+		t.Fatalf("expected logs subscribed on each connection, got %d", logSubs)
+	}
+	cancel()
+}
+
+func TestHeadSubscriberRejectsNonWebSocketURL(t *testing.T) {
+	if _, err := blockchain.NewHeadSubscriber("http://node:8545", blockchain.LogFilter{}, nil); err == nil {
+		t.Fatalf("expected invalid url error")
+	}
+}
@@ -0,0 +1,155 @@
+package unit
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/loangraph/backend/internal/blockchain"
+	"github.com/loangraph/backend/internal/indexer"
+)
+
+func abiWord(v uint64) string {
+	return zeroPaddedHex(fmt.Sprintf("%x", v), 64)
+}
+
+func abiString(s string) string {
+	padded := hex.EncodeToString([]byte(s))
+	if rem := len(padded) % 64; rem != 0 {
+		padded += strings.Repeat("0", 64-rem)
+	}
+	return abiWord(uint64(len(s))) + padded
+}
+
+func parseEvent(t *testing.T, abiJSON string) indexer.ABIEvent {
+	t.Helper()
+	events, err := indexer.ParseABIEvents([]byte(abiJSON))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("parse abi: %v (%d events)", err, len(events))
+	}
+	return events[0]
+}
+
+func TestIngestionDecodesLoanRegisteredCurrencyAndBorrower(t *testing.T) {
+	loanUUID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	borrower := "0x" + strings.Repeat("ab", 20)
+	repo := &fakeIngestionRepo{}
+	rpc := &fakeLogRPC{
+		blockNumber: 105,
+		logs: []blockchain.LogEntry{{
+			Address: "0x3c20Fd0B57711a199776B53C2F24385563d1670F",
+			Topics: []string{
+				eventTopic("LoanRegistered(bytes32,bytes32,address,uint256,uint256,string)"),
+				bytes32TopicFromUUID(loanUUID),
+				"0x" + zeroPaddedHex("cd", 64),
+				"0x" + zeroPaddedHex(strings.TrimPrefix(borrower, "0x"), 64),
+			},
+			// principal, maturity, offset of currency, then the string tail.
+			Data:            "0x" + abiWord(250000) + abiWord(1767225600) + abiWord(96) + abiString("NGN"),
+			BlockNumber:     101,
+			TransactionHash: "0xdef",
+		}},
+	}
+	svc := indexer.NewIngestionService(repo, rpc, "0x3c20Fd0B57711a199776B53C2F24385563d1670F", 100, 10, 2)
+	if err := svc.RunOnce(context.Background()); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(repo.events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(repo.events))
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(repo.events[0].RawData, &raw); err != nil {
+		t.Fatalf("unmarshal raw data: %v", err)
+	}
+	if raw["loan_id"] != loanUUID.String() || raw["currency_code"] != "NGN" || raw["borrower_address"] != borrower {
+		t.Fatalf("unexpected LoanRegistered raw data: %+v", raw)
+	}
+	if raw["principal_minor"] != float64(250000) || raw["maturity_ts"] != float64(1767225600) {
+		t.Fatalf("unexpected amounts: %+v", raw)
+	}
+}
+
+func TestABIEventDecodesDynamicTypes(t *testing.T) {
+	ev := parseEvent(t, `[{"type":"event","name":"Batch","inputs":[
+		{"name":"tag","type":"string","indexed":true},
+		{"name":"amounts","type":"uint256[]"},
+		{"name":"pair","type":"uint8[2]"},
+		{"name":"memo","type":"bytes"},
+		{"name":"names","type":"string[]"},
+		{"name":"flag","type":"bool"},
+		{"name":"delta","type":"int256"}
+	]}]`)
+	if ev.Signature() != "Batch(string,uint256[],uint8[2],bytes,string[],bool,int256)" {
+		t.Fatalf("unexpected signature %s", ev.Signature())
+	}
+
+	// Head: amounts offset, pair[0], pair[1], memo offset, names offset, flag, delta.
+	const head = 7 * 32
+	amounts := abiWord(2) + abiWord(5) + abiWord(7)
+	memo := abiWord(2) + "beef" + strings.Repeat("0", 60)
+	names := abiWord(2) + abiWord(64) + abiWord(128) + abiString("a") + abiString("bc")
+	data := abiWord(head) + abiWord(1) + abiWord(2) + abiWord(uint64(head+len(amounts)/2)) +
+		abiWord(uint64(head+len(amounts)/2+len(memo)/2)) + abiWord(1) + strings.Repeat("f", 64) +
+		amounts + memo + names
+
+	tagHash := "0x" + strings.Repeat("12", 32)
+	out, err := ev.Decode(blockchain.LogEntry{Topics: []string{ev.Topic(), tagHash}, Data: "0x" + data})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	encoded, _ := json.Marshal(out)
+	want := `{"amounts":[5,7],"delta":-1,"flag":true,"memo":"0xbeef","names":["a","bc"],"pair":[1,2],"tag_hash":"` + tagHash + `"}`
+	if string(encoded) != want {
+		t.Fatalf("unexpected decode:\n got %s\nwant %s", encoded, want)
+	}
+}
+
+func TestABIEventRejectsOverflowAndDirtyValues(t *testing.T) {
+	ev := parseEvent(t, `[{"type":"event","name":"Small","inputs":[{"name":"v","type":"uint64"}]}]`)
+	if _, err := ev.Decode(blockchain.LogEntry{Topics: []string{ev.Topic()}, Data: "0x" + zeroPaddedHex("10000000000000000", 64)}); err == nil || !strings.Contains(err.Error(), "overflows uint64") {
+		t.Fatalf("expected uint64 overflow error, got %v", err)
+	}
+
+	ev = parseEvent(t, `[{"type":"event","name":"Owner","inputs":[{"name":"who","type":"address"}]}]`)
+	if _, err := ev.Decode(blockchain.LogEntry{Topics: []string{ev.Topic()}, Data: "0x" + strings.Repeat("1", 64)}); err == nil {
+		t.Fatalf("expected error for address with dirty high bytes")
+	}
+
+	ev = parseEvent(t, `[{"type":"event","name":"Named","inputs":[{"name":"s","type":"string"}]}]`)
+	if _, err := ev.Decode(blockchain.LogEntry{Topics: []string{ev.Topic()}, Data: "0x" + abiWord(32) + abiWord(1000)}); err == nil {
+		t.Fatalf("expected error for string length past the data")
+	}
+}
+
+func TestIndexerRejectsRepaymentAmountOverflowingInt64(t *testing.T) {
+	ev := parseEvent(t, `[{"type":"event","name":"RepaymentRecorded","inputs":[
+		{"name":"loan_id","type":"bytes32","indexed":true},
+		{"name":"borrower_id","type":"bytes32","indexed":true},
+		{"name":"amount_minor","type":"uint256"},
+		{"name":"total_repaid_minor","type":"uint256"},
+		{"name":"timestamp","type":"uint256"}
+	]}]`)
+	loanUUID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	huge := zeroPaddedHex("1"+strings.Repeat("0", 20), 64)
+	out, err := ev.Decode(blockchain.LogEntry{
+		Topics: []string{ev.Topic(), bytes32TopicFromUUID(loanUUID), "0x" + zeroPaddedHex("ab", 64)},
+		Data:   "0x" + huge + huge + abiWord(1),
+	})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	raw, _ := json.Marshal(out)
+	if !strings.Contains(string(raw), `"amount_minor":1208925819614629174706176`) {
+		t.Fatalf("expected exact uint256 in raw data, got %s", raw)
+	}
+
+	projRepo := &fakeProjectionRepo{}
+	svc := indexer.NewService(&fakeEventRepo{events: []indexer.ChainEvent{{ID: 1, EventName: "RepaymentRecorded", TXHash: "0x1", RawData: raw}}, proj: projRepo})
+	if err := svc.RunOnce(context.Background(), 10); err == nil {
+		t.Fatalf("expected overflowing amount to fail projection instead of applying 0")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -99,6 +100,41 @@ func TestIngestionRunOnceIngestsAndAdvancesCursor(t *testing.T) {
 	}
 }
 
+func TestIngestionQuarantinesUndecodableLogs(t *testing.T) {
+	repo := &fakeIngestionRepo{}
+	rpc := &fakeLogRPC{
+		blockNumber: 105,
+		logs: []blockchain.LogEntry{
+			{
+				Address:         "0x3c20Fd0B57711a199776B53C2F24385563d1670F",
+				Topics:          []string{eventTopic("RepaymentRecorded(bytes32,bytes32,uint256,uint256,uint256)")},
+				Data:            "0xnothex",
+				BlockNumber:     102,
+				TransactionHash: "0xabc123",
+				LogIndex:        1,
+			},
+		},
+	}
+	svc := indexer.NewIngestionService(repo, rpc, "0x3c20Fd0B57711a199776B53C2F24385563d1670F", 100, 10, 2)
+
+	if err := svc.RunOnce(context.Background()); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if repo.setCursor != 103 {
+		t.Fatalf("expected the cursor to move past the bad log, got %d", repo.setCursor)
+	}
+	if len(repo.events) != 1 || repo.events[0].EventName != "RepaymentRecorded" || !strings.HasPrefix(repo.events[0].DecodeError, "decode: ") {
+		t.Fatalf("expected the log stored with its decode error, got %+v", repo.events)
+	}
+	var raw struct {
+		Topics []string `json:"topics"`
+		Data   string   `json:"data"`
+	}
+	if err := json.Unmarshal(repo.events[0].RawData, &raw); err != nil || len(raw.Topics) != 1 || raw.Data != "0xnothex" {
+		t.Fatalf("expected the raw log kept, got %s (%v)", repo.events[0].RawData, err)
+	}
+}
+
 func TestIngestionRunOnceNoopWhenCursorAheadOfSafeHead(t *testing.T) {
 	repo := &fakeIngestionRepo{hasCursor: true, cursor: 200}
 	rpc := &fakeLogRPC{blockNumber: 201}
@@ -115,6 +151,25 @@ func TestIngestionRunOnceNoopWhenCursorAheadOfSafeHead(t *testing.T) {
 	}
 }
 
+func TestIngestionCatchUpBackfillsFromCursor(t *testing.T) {
+	repo := &fakeIngestionRepo{hasCursor: true, cursor: 100}
+	rpc := &fakeLogRPC{blockNumber: 137}
+	svc := indexer.NewIngestionService(repo, rpc, "0x3c20Fd0B57711a199776B53C2F24385563d1670F", 0, 10, 2)
+
+	if err := svc.CatchUp(context.Background(), 2); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if repo.cursor != 120 {
+		t.Fatalf("expected two ranges up to block 120, got %d", repo.cursor)
+	}
+	if err := svc.CatchUp(context.Background(), 10); err != nil {
+		t.Fatalf("catch up: %v", err)
+	}
+	if repo.cursor != 135 || rpc.filter.FromBlock != 131 || rpc.filter.ToBlock != 135 {
+		t.Fatalf("expected catch up to stop at safe head 135, got cursor %d range %d-%d", repo.cursor, rpc.filter.FromBlock, rpc.filter.ToBlock)
+	}
+}
+
 func eventTopic(signature string) string {
 	hash := sha3.NewLegacyKeccak256()
 	_, _ = hash.Write([]byte(signature))
@@ -0,0 +1,285 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/loangraph/backend/internal/blockchain"
+	"github.com/loangraph/backend/internal/indexer"
+)
+
+type fakeReconciliationRepo struct {
+	loans  []indexer.ReconcileLoan
+	events []indexer.ChainEvent
+	saved  *indexer.ReconciliationRun
+	items  []indexer.Discrepancy
+	jobs   []indexer.CorrectiveJob
+}
+
+func (r *fakeReconciliationRepo) ReconciliationSnapshot(context.Context) ([]indexer.ReconcileLoan, []indexer.ChainEvent, error) {
+	return r.loans, r.events, nil
+}
+
+func (r *fakeReconciliationRepo) SaveReconciliation(_ context.Context, run indexer.ReconciliationRun, items []indexer.Discrepancy, jobs []indexer.CorrectiveJob) (*indexer.ReconciliationRun, error) {
+	run.ID = 1
+	r.saved, r.items, r.jobs = &run, items, jobs
+	return &run, nil
+}
+
+func (r *fakeReconciliationRepo) GetReconciliationRun(context.Context, int64) (*indexer.ReconciliationRun, error) {
+	if r.saved == nil {
+		return nil, indexer.ErrReconciliationRunNotFound
+	}
+	return r.saved, nil
+}
+
+func (r *fakeReconciliationRepo) ListDiscrepancies(context.Context, int64, int32, int32) ([]indexer.Discrepancy, error) {
+	return r.items, nil
+}
+
+type fakeRegistryReader struct {
+	loans map[string]blockchain.OnChainLoan
+	block uint64
+}
+
+func (r *fakeRegistryReader) GetLoans(_ context.Context, loanIDs []string, blockNumber uint64) ([]blockchain.OnChainLoan, error) {
+	r.block = blockNumber
+	out := make([]blockchain.OnChainLoan, 0, len(loanIDs))
+	for _, loanID := range loanIDs {
+		loan, ok := r.loans[loanID]
+		if !ok {
+			loan = blockchain.OnChainLoan{Status: "none"}
+		}
+		out = append(out, loan)
+	}
+	return out, nil
+}
+
+func TestReconciliationReportsDiscrepanciesAndEnqueuesCorrections(t *testing.T) {
+	const inSync = "11111111-1111-1111-1111-111111111111"
+	const behind = "22222222-2222-2222-2222-222222222222"
+	const ahead = "33333333-3333-3333-3333-333333333333"
+	const pending = "44444444-4444-4444-4444-444444444444"
+	loan := func(id string, repaid int64, status string, confirmed bool, openJobs int) indexer.ReconcileLoan {
+		return indexer.ReconcileLoan{
+			LoanState:    indexer.LoanState{LoanID: id, PrincipalMinor: 1000, AmountRepaidMinor: repaid, Status: status, OnChainConfirmed: confirmed},
+			LenderID:     "lender-1",
+			CurrencyCode: "NGN",
+			OpenJobs:     openJobs,
+		}
+	}
+	repo := &fakeReconciliationRepo{
+		loans: []indexer.ReconcileLoan{
+			loan(inSync, 400, "active", true, 0),
+			// The DB recorded a repayment and a default the registry lacks.
+			loan(behind, 700, "defaulted", true, 0),
+			// The registry has a repayment the DB and the events missed.
+			loan(ahead, 0, "active", true, 0),
+			// Still being written: reported, never corrected.
+			loan(pending, 300, "active", false, 1),
+		},
+		events: []indexer.ChainEvent{
+			{ID: 1, EventName: "LoanRegistered", RawData: []byte(`{"loan_id":"` + inSync + `"}`)},
+			{ID: 2, EventName: "RepaymentRecorded", RawData: []byte(`{"loan_id":"` + inSync + `","amount_minor":400}`)},
+			{ID: 3, EventName: "LoanRegistered", RawData: []byte(`{"loan_id":"` + behind + `"}`)},
+			{ID: 4, EventName: "RepaymentRecorded", RawData: []byte(`{"loan_id":"` + behind + `","amount_minor":200}`)},
+			{ID: 5, EventName: "LoanRegistered", RawData: []byte(`{"loan_id":"` + ahead + `"}`)},
+		},
+	}
+	reader := &fakeRegistryReader{loans: map[string]blockchain.OnChainLoan{
+		inSync: {Registered: true, PrincipalMinor: 1000, TotalRepaidMinor: 400, Status: "active"},
+		behind: {Registered: true, PrincipalMinor: 1000, TotalRepaidMinor: 200, Status: "active"},
+		ahead:  {Registered: true, PrincipalMinor: 1000, TotalRepaidMinor: 250, Status: "active"},
+	}}
+	svc := indexer.NewReconciliationService(repo, reader)
+
+	report, err := svc.Reconcile(context.Background(), indexer.ReconcileOptions{BlockNumber: 90, Enqueue: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if reader.block != 90 {
+		t.Fatalf("expected registry read at block 90, got %d", reader.block)
+	}
+
+	got := make(map[string]indexer.Discrepancy)
+	for _, item := range report.Items {
+		got[item.LoanID+" "+item.Field] = item
+	}
+	if len(got) != 4 || len(report.Items) != 4 {
+		t.Fatalf("unexpected discrepancies: %+v", report.Items)
+	}
+	if d := got[behind+" amount_repaid_minor"]; d.DBValue != "700" || d.ChainValue != "200" || d.EventsValue != "200" || d.CorrectiveTopic != "record_repayment" {
+		t.Fatalf("unexpected repaid discrepancy: %+v", d)
+	}
+	if d := got[behind+" status"]; d.DBValue != "defaulted" || d.ChainValue != "active" || d.CorrectiveTopic != "mark_default" {
+		t.Fatalf("unexpected status discrepancy: %+v", d)
+	}
+	if d := got[ahead+" amount_repaid_minor"]; d.ChainValue != "250" || d.EventsValue != "0" || d.CorrectiveTopic != "" {
+		t.Fatalf("expected chain-ahead discrepancy without correction, got %+v", d)
+	}
+	if d := got[pending+" amount_repaid_minor"]; !d.InFlight || d.DBValue != "300" || d.CorrectiveTopic != "" {
+		t.Fatalf("expected in-flight discrepancy without correction, got %+v", d)
+	}
+
+	if len(repo.jobs) != 2 || repo.jobs[0].Topic != "record_repayment" || repo.jobs[1].Topic != "mark_default" {
+		t.Fatalf("unexpected corrective jobs: %+v", repo.jobs)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(repo.jobs[0].Payload, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if payload["loan_id"] != behind || payload["amount_minor"] != float64(500) || payload["currency"] != "NGN" || payload["lender_id"] != "lender-1" {
+		t.Fatalf("unexpected repayment payload: %+v", payload)
+	}
+	if repo.saved == nil || repo.saved.LoansChecked != 4 || repo.saved.Discrepancies != 4 || repo.saved.JobsEnqueued != 2 {
+		t.Fatalf("unexpected saved run: %+v", repo.saved)
+	}
+
+	run, items, err := svc.ListDiscrepancies(context.Background(), 0, 0, 0)
+	if err != nil || run.ID != 1 || len(items) != 4 {
+		t.Fatalf("unexpected listing: %+v %d %v", run, len(items), err)
+	}
+}
+
+func TestReconciliationResendsUnsentRegistrations(t *testing.T) {
+	const unsent = "11111111-1111-1111-1111-111111111111"
+	const submitted = "22222222-2222-2222-2222-222222222222"
+	const deadLettered = "33333333-3333-3333-3333-333333333333"
+	repo := &fakeReconciliationRepo{loans: []indexer.ReconcileLoan{
+		// The register_loan job was cancelled before any transaction went
+		// out; the loan was repaid in part and defaulted since.
+		{LoanState: indexer.LoanState{LoanID: unsent, PrincipalMinor: 1000, AmountRepaidMinor: 300, Status: "defaulted"}, CurrencyCode: "NGN"},
+		// Sent but not mined yet: it may still land, so it is left alone.
+		{LoanState: indexer.LoanState{LoanID: submitted, PrincipalMinor: 1000, Status: "active", OnChainTX: "0xabc"}},
+		// Dead-lettered: left to an admin retry, which a resend would race.
+		{LoanState: indexer.LoanState{LoanID: deadLettered, PrincipalMinor: 1000, Status: "active"}, OpenJobs: 1},
+	}}
+	svc := indexer.NewReconciliationService(repo, &fakeRegistryReader{})
+
+	report, err := svc.Reconcile(context.Background(), indexer.ReconcileOptions{Enqueue: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(report.Jobs) != 3 || report.Jobs[0].Topic != "register_loan" || report.Jobs[1].Topic != "record_repayment" || report.Jobs[2].Topic != "mark_default" {
+		t.Fatalf("expected registration and the writes after it, got %+v", report.Jobs)
+	}
+	for _, job := range report.Jobs {
+		if job.LoanID != unsent {
+			t.Fatalf("expected only the unsent loan corrected, got %+v", job)
+		}
+	}
+	got := make(map[string]indexer.Discrepancy)
+	for _, item := range report.Items {
+		got[item.LoanID+" "+item.Field] = item
+	}
+	if d := got[unsent+" registered"]; d.DBValue != "unsent" || d.ChainValue != "false" || d.CorrectiveTopic != "register_loan" {
+		t.Fatalf("unexpected registered discrepancy: %+v", d)
+	}
+	if d := got[unsent+" status"]; d.DBValue != "defaulted" || d.ChainValue != "none" || d.CorrectiveTopic != "mark_default" {
+		t.Fatalf("unexpected status discrepancy: %+v", d)
+	}
+	if _, ok := got[submitted+" registered"]; ok {
+		t.Fatalf("expected the submitted registration not reported")
+	}
+	if _, ok := got[deadLettered+" registered"]; ok {
+		t.Fatalf("expected the dead-lettered registration not reported")
+	}
+}
+
+func TestRegistryReaderBatchesCalls(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var reqs []struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			t.Fatalf("expected a batch request: %v", err)
+		}
+		resps := make([]map[string]any, 0, len(reqs))
+		for _, req := range reqs {
+			if req.Method != "eth_call" {
+				t.Fatalf("unexpected method: %s", req.Method)
+			}
+			resps = append(resps, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0x" + abiWord(1) + abiWord(1000) + abiWord(uint64(req.ID)) + abiWord(1)})
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	transport, err := blockchain.NewRPCTransport([]string{srv.URL}, time.Second, 0)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	reader, err := blockchain.NewRPCRegistryReader(transport, "0x3c20fd0b57711a199776b53c2f24385563d1670f")
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	ids := make([]string, 150)
+	for i := range ids {
+		ids[i] = uuid.New().String()
+	}
+	loans, err := reader.GetLoans(context.Background(), ids, 90)
+	if err != nil {
+		t.Fatalf("get loans: %v", err)
+	}
+	if requests != 2 || len(loans) != 150 || !loans[149].Registered || loans[149].Status != "active" {
+		t.Fatalf("expected 150 loans in two batch requests, got %d loans in %d requests", len(loans), requests)
+	}
+}
+
+func TestReconciliationDryRunStoresNothing(t *testing.T) {
+	const loanID = "11111111-1111-1111-1111-111111111111"
+	repo := &fakeReconciliationRepo{loans: []indexer.ReconcileLoan{{
+		LoanState: indexer.LoanState{LoanID: loanID, PrincipalMinor: 1000, Status: "active", OnChainConfirmed: true},
+	}}}
+	svc := indexer.NewReconciliationService(repo, &fakeRegistryReader{})
+
+	report, err := svc.Reconcile(context.Background(), indexer.ReconcileOptions{Enqueue: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(report.Jobs) != 1 || report.Jobs[0].Topic != "register_loan" {
+		t.Fatalf("expected register_loan correction in report, got %+v", report.Jobs)
+	}
+	if repo.saved != nil {
+		t.Fatalf("dry run saved a run: %+v", repo.saved)
+	}
+	if _, _, err := svc.ListDiscrepancies(context.Background(), 0, 50, 0); err != indexer.ErrReconciliationRunNotFound {
+		t.Fatalf("expected run not found, got %v", err)
+	}
+}
+
+func TestRegistryGetLoanEncoding(t *testing.T) {
+	data, err := blockchain.EncodeGetLoanCall("11111111-1111-1111-1111-111111111111")
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if len(data) != 2+8+64 || !strings.HasSuffix(data, "11111111111111111111111111111111"+strings.Repeat("0", 32)) {
+		t.Fatalf("unexpected call data %s", data)
+	}
+	if _, err := blockchain.EncodeGetLoanCall("not-a-uuid"); err == nil {
+		t.Fatalf("expected error for invalid loan id")
+	}
+
+	loan, err := blockchain.DecodeGetLoanResult("0x" + abiWord(1) + abiWord(1000) + abiWord(250) + abiWord(3))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !loan.Registered || loan.PrincipalMinor != 1000 || loan.TotalRepaidMinor != 250 || loan.Status != "defaulted" {
+		t.Fatalf("unexpected loan %+v", loan)
+	}
+	if _, err := blockchain.DecodeGetLoanResult("0x" + abiWord(1) + abiWord(1000) + abiWord(250) + abiWord(9)); err == nil {
+		t.Fatalf("expected error for unknown status")
+	}
+	if _, err := blockchain.DecodeGetLoanResult("0x" + abiWord(1)); err == nil {
+		t.Fatalf("expected error for short result")
+	}
+}
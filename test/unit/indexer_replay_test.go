@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/loangraph/backend/internal/indexer"
+)
+
+func TestReplayLoansRebuildsFromChainOrder(t *testing.T) {
+	const loanA = "11111111-1111-1111-1111-111111111111"
+	const loanB = "22222222-2222-2222-2222-222222222222"
+	before := map[string]indexer.LoanState{
+		// Double-applied repayment the replay must undo.
+		loanA: {LoanID: loanA, BorrowerID: "b1", PrincipalMinor: 1000, AmountRepaidMinor: 1200, Status: "repaid", OnChainTX: "0xa0"},
+		loanB: {LoanID: loanB, BorrowerID: "b1", PrincipalMinor: 500, Status: "active"},
+	}
+	events := []indexer.ChainEvent{
+		{ID: 1, EventName: "LoanRegistered", TXHash: "0xa1", RawData: []byte(`{"loan_id":"` + loanA + `"}`)},
+		{ID: 2, EventName: "RepaymentRecorded", TXHash: "0xa2", RawData: []byte(`{"loan_id":"` + loanA + `","amount_minor":600}`)},
+		{ID: 5, EventName: "LoanDefaulted", TXHash: "0xb1", RawData: []byte(`{"loan_id":"` + loanB + `"}`)},
+		// Repayment after default is ignored, like the incremental projection.
+		{ID: 3, EventName: "RepaymentRecorded", TXHash: "0xb2", RawData: []byte(`{"loan_id":"` + loanB + `","amount_minor":500}`)},
+		{ID: 4, EventName: "RepaymentRecorded", TXHash: "0xc1", RawData: []byte(`{"loan_id":"33333333-3333-3333-3333-333333333333","amount_minor":1}`)},
+		{ID: 6, EventName: "Transfer", RawData: []byte(`{}`)},
+	}
+
+	rebuilt, err := indexer.ReplayLoans(events, before)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(rebuilt) != 2 || rebuilt[0].LoanID != loanA {
+		t.Fatalf("unexpected rebuilt loans: %+v", rebuilt)
+	}
+	a, b := rebuilt[0], rebuilt[1]
+	if a.AmountRepaidMinor != 600 || a.Status != "active" || a.OnChainTX != "0xa1" || !a.OnChainConfirmed {
+		t.Fatalf("unexpected loan A: %+v", a)
+	}
+	if b.AmountRepaidMinor != 0 || b.Status != "defaulted" {
+		t.Fatalf("unexpected loan B: %+v", b)
+	}
+
+	diffs := indexer.DiffLoans(before, rebuilt)
+	if len(diffs) != 2 || diffs[0].Before.AmountRepaidMinor != 1200 || diffs[0].After.AmountRepaidMinor != 600 {
+		t.Fatalf("unexpected diff: %+v", diffs)
+	}
+	if again := indexer.DiffLoans(toLoanMap(rebuilt), rebuilt); len(again) != 0 {
+		t.Fatalf("expected replay to be stable, got %+v", again)
+	}
+
+	ledger, err := indexer.ReplayLedger(events, before)
+	if err != nil {
+		t.Fatalf("replay ledger: %v", err)
+	}
+	if len(ledger) != 2 || ledger[0].TXHash != "0xa2" || ledger[0].AmountMinor != 600 || ledger[1].LoanID != loanB || ledger[1].AmountMinor != 0 {
+		t.Fatalf("unexpected ledger events: %+v", ledger)
+	}
+
+	bad := append(events, indexer.ChainEvent{ID: 7, EventName: "RepaymentRecorded", RawData: []byte(`{"loan_id":"` + loanA + `"}`)})
+	if _, err := indexer.ReplayLoans(bad, before); err == nil {
+		t.Fatalf("expected error for invalid event payload")
+	}
+}
+
+func toLoanMap(loans []indexer.LoanState) map[string]indexer.LoanState {
+	out := make(map[string]indexer.LoanState, len(loans))
+	for _, loan := range loans {
+		out[loan.LoanID] = loan
+	}
+	return out
+}
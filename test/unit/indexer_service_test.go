@@ -2,6 +2,8 @@ package unit
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/loangraph/backend/internal/indexer"
@@ -9,18 +11,36 @@ import (
 
 type fakeEventRepo struct {
 	events      []indexer.ChainEvent
+	proj        indexer.ProjectionRepository
 	processedID []int64
+	attempts    map[int64]int32
+	quarantined []int64
 }
 
 func (r *fakeEventRepo) ListUnprocessed(_ context.Context, _ int32) ([]indexer.ChainEvent, error) {
 	return r.events, nil
 }
 
-func (r *fakeEventRepo) MarkProcessed(_ context.Context, eventID int64) error {
+func (r *fakeEventRepo) Project(_ context.Context, eventID int64, apply func(indexer.ProjectionRepository) error) error {
+	if err := apply(r.proj); err != nil {
+		return err
+	}
 	r.processedID = append(r.processedID, eventID)
 	return nil
 }
 
+func (r *fakeEventRepo) RecordFailure(_ context.Context, eventID int64, _ string, maxAttempts int32) (bool, error) {
+	if r.attempts == nil {
+		r.attempts = map[int64]int32{}
+	}
+	r.attempts[eventID]++
+	if r.attempts[eventID] < maxAttempts {
+		return false, nil
+	}
+	r.quarantined = append(r.quarantined, eventID)
+	return true, nil
+}
+
 type fakeProjectionRepo struct {
 	registered []string
 	repayments []string
@@ -33,7 +53,7 @@ func (r *fakeProjectionRepo) ApplyLoanRegistered(_ context.Context, loanID, _ st
 	return nil
 }
 
-func (r *fakeProjectionRepo) ApplyRepayment(_ context.Context, loanID string, _ int64) error {
+func (r *fakeProjectionRepo) ApplyRepayment(_ context.Context, loanID string, _ int64, _ string, _ uint64) error {
 	r.repayments = append(r.repayments, loanID)
 	return nil
 }
@@ -55,7 +75,8 @@ func TestIndexerRunOnceProcessesSupportedEvents(t *testing.T) {
 		{ID: 3, EventName: "LoanDefaulted", TXHash: "0x3", RawData: []byte(`{"loan_id":"33333333-3333-3333-3333-333333333333"}`)},
 	}}
 	proj := &fakeProjectionRepo{}
-	svc := indexer.NewService(evRepo, proj)
+	evRepo.proj = proj
+	svc := indexer.NewService(evRepo)
 
 	if err := svc.RunOnce(context.Background(), 10); err != nil {
 		t.Fatalf("run once: %v", err)
@@ -80,7 +101,8 @@ func TestIndexerRunOnceProcessesSupportedEvents(t *testing.T) {
 func TestIndexerRunOnceIgnoresUnknownEvent(t *testing.T) {
 	evRepo := &fakeEventRepo{events: []indexer.ChainEvent{{ID: 9, EventName: "UnknownEvent", RawData: []byte(`{}`)}}}
 	proj := &fakeProjectionRepo{}
-	svc := indexer.NewService(evRepo, proj)
+	evRepo.proj = proj
+	svc := indexer.NewService(evRepo)
 
 	if err := svc.RunOnce(context.Background(), 10); err != nil {
 		t.Fatalf("run once: %v", err)
@@ -97,7 +119,8 @@ func TestIndexerRunOnceSkipsProjectionForNonUUIDLoanID(t *testing.T) {
 		{ID: 12, EventName: "LoanDefaulted", TXHash: "0x12", RawData: []byte(`{"loan_id":"0xabc123"}`)},
 	}}
 	proj := &fakeProjectionRepo{}
-	svc := indexer.NewService(evRepo, proj)
+	evRepo.proj = proj
+	svc := indexer.NewService(evRepo)
 
 	if err := svc.RunOnce(context.Background(), 10); err != nil {
 		t.Fatalf("run once: %v", err)
@@ -109,3 +132,59 @@ func TestIndexerRunOnceSkipsProjectionForNonUUIDLoanID(t *testing.T) {
 		t.Fatalf("expected no projections for non-uuid loan ids")
 	}
 }
+
+func TestIndexerQuarantinesPoisonEventAfterMaxAttempts(t *testing.T) {
+	evRepo := &fakeEventRepo{events: []indexer.ChainEvent{
+		{ID: 20, EventName: "RepaymentRecorded", TXHash: "0x20", RawData: []byte(`{"loan_id":"11111111-1111-1111-1111-111111111111","amount_minor":"oops"}`)},
+		{ID: 21, EventName: "LoanDefaulted", TXHash: "0x21", RawData: []byte(`{"loan_id":"33333333-3333-3333-3333-333333333333"}`)},
+	}}
+	proj := &fakeProjectionRepo{}
+	evRepo.proj = proj
+	svc := indexer.NewService(evRepo)
+	svc.SetMaxAttempts(3)
+
+	for i := 0; i < 2; i++ {
+		if err := svc.RunOnce(context.Background(), 10); err == nil {
+			t.Fatalf("expected attempt %d to fail", i+1)
+		}
+		if len(evRepo.processedID) != 0 || len(proj.defaults) != 0 {
+			t.Fatalf("later events must wait while the failure may be transient")
+		}
+	}
+
+	err := svc.RunOnce(context.Background(), 10)
+	if err == nil || !strings.Contains(err.Error(), "chain event 20 quarantined after 3 attempts") {
+		t.Fatalf("expected quarantine to be reported, got %v", err)
+	}
+	if len(evRepo.quarantined) != 1 || evRepo.quarantined[0] != 20 {
+		t.Fatalf("expected event 20 quarantined, got %v", evRepo.quarantined)
+	}
+	if len(evRepo.processedID) != 1 || evRepo.processedID[0] != 21 || len(proj.defaults) != 1 {
+		t.Fatalf("expected the batch to move past the quarantined event")
+	}
+}
+
+type failingRefreshProjectionRepo struct {
+	fakeProjectionRepo
+}
+
+func (r *failingRefreshProjectionRepo) RefreshPassportCacheByLoan(_ context.Context, _ string) error {
+	return errors.New("passport refresh failed")
+}
+
+func TestIndexerLeavesEventUnprocessedWhenProjectionFails(t *testing.T) {
+	evRepo := &fakeEventRepo{
+		events: []indexer.ChainEvent{
+			{ID: 30, EventName: "RepaymentRecorded", TXHash: "0x30", LogIndex: 2, RawData: []byte(`{"loan_id":"22222222-2222-2222-2222-222222222222","amount_minor":5000}`)},
+		},
+		proj: &failingRefreshProjectionRepo{},
+	}
+	svc := indexer.NewService(evRepo)
+
+	if err := svc.RunOnce(context.Background(), 10); err == nil {
+		t.Fatalf("expected projection failure")
+	}
+	if len(evRepo.processedID) != 0 || evRepo.attempts[30] != 1 {
+		t.Fatalf("expected event left unprocessed with one failed attempt, got processed=%v attempts=%v", evRepo.processedID, evRepo.attempts)
+	}
+}
@@ -0,0 +1,205 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loangraph/backend/internal/blockchain"
+	"github.com/loangraph/backend/internal/indexer"
+)
+
+const (
+	registryAddr = "0x3c20fd0b57711a199776b53c2f24385563d1670f"
+	passportAddr = "0x4444444444444444444444444444444444444444"
+)
+
+type addressLogRPC struct {
+	blockNumber uint64
+	logs        []blockchain.LogEntry
+	filters     []blockchain.LogFilter
+}
+
+func (f *addressLogRPC) BlockNumber(_ context.Context) (uint64, error) {
+	return f.blockNumber, nil
+}
+
+func (f *addressLogRPC) GetLogs(_ context.Context, filter blockchain.LogFilter) ([]blockchain.LogEntry, error) {
+	f.filters = append(f.filters, filter)
+	out := make([]blockchain.LogEntry, 0)
+	for _, lg := range f.logs {
+		if lg.BlockNumber < filter.FromBlock || lg.BlockNumber > filter.ToBlock {
+			continue
+		}
+		for _, addr := range filter.Addresses {
+			if strings.EqualFold(addr, lg.Address) {
+				out = append(out, lg)
+			}
+		}
+	}
+	return out, nil
+}
+
+type keyedIngestionRepo struct {
+	cursors map[string]uint64
+	events  []indexer.IngestedEvent
+}
+
+func (r *keyedIngestionRepo) GetIngestionCursor(_ context.Context, key string) (uint64, bool, error) {
+	v, ok := r.cursors[key]
+	return v, ok, nil
+}
+
+func (r *keyedIngestionRepo) SetIngestionCursor(_ context.Context, key string, blockNumber uint64) error {
+	r.cursors[key] = blockNumber
+	return nil
+}
+
+func (r *keyedIngestionRepo) InsertChainEvent(_ context.Context, ev indexer.IngestedEvent) error {
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func writeSourcesFile(t *testing.T, sources any) string {
+	t.Helper()
+	raw, err := json.Marshal(sources)
+	if err != nil {
+		t.Fatalf("marshal sources: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "sources.json")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatalf("write sources: %v", err)
+	}
+	return path
+}
+
+func TestLoadSourcesFromConfigFile(t *testing.T) {
+	path := writeSourcesFile(t, []map[string]any{
+		{"name": "passport_nft", "address": passportAddr, "start_block": 50, "abi_builtin": "erc721"},
+		{
+			"name":      "pool_tokens",
+			"addresses": []string{"0x5555555555555555555555555555555555555555", "0x6666666666666666666666666666666666666666"},
+			"abi": []map[string]any{
+				{"type": "function", "name": "transfer", "inputs": []any{}},
+				{"type": "event", "name": "Approval", "inputs": []map[string]any{{"name": "owner", "type": "address", "indexed": true}, {"name": "spender", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}}},
+				{"type": "event", "name": "Transfer", "inputs": []map[string]any{{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}}},
+			},
+			"events": []string{"Transfer"},
+		},
+	})
+	sources, err := indexer.LoadSources(path)
+	if err != nil {
+		t.Fatalf("load sources: %v", err)
+	}
+	if len(sources) != 2 || sources[0].CursorKey() != "indexer.passport_nft.last_block" || sources[0].StartBlock != 50 {
+		t.Fatalf("unexpected sources: %+v", sources)
+	}
+	if len(sources[1].Addresses) != 2 || len(sources[1].Events) != 1 || sources[1].Events[0].Signature() != "Transfer(address,address,uint256)" {
+		t.Fatalf("unexpected pool token source: %+v", sources[1])
+	}
+
+	bad := writeSourcesFile(t, []map[string]any{{"name": "x", "address": passportAddr, "abi_builtin": "erc721", "events": []string{"Mint"}}})
+	if _, err := indexer.LoadSources(bad); err == nil {
+		t.Fatalf("expected error for unknown event")
+	}
+}
+
+func TestIngestionTracksSourcesIndependently(t *testing.T) {
+	path := writeSourcesFile(t, []map[string]any{
+		{"name": "passport_nft", "address": passportAddr, "start_block": 95, "abi_builtin": "erc721"},
+	})
+	extra, err := indexer.LoadSources(path)
+	if err != nil {
+		t.Fatalf("load sources: %v", err)
+	}
+	sources := indexer.MergeSources([]indexer.Source{indexer.LoanRegistrySource(registryAddr, 100)}, extra)
+
+	transferTopic := eventTopic("Transfer(address,address,uint256)")
+	rpc := &addressLogRPC{
+		blockNumber: 110,
+		logs: []blockchain.LogEntry{
+			{
+				Address:         passportAddr,
+				Topics:          []string{transferTopic, "0x" + zeroPaddedHex("0", 64), "0x" + zeroPaddedHex("abcdef", 64), "0x" + zeroPaddedHex("7", 64)},
+				Data:            "0x",
+				BlockNumber:     96,
+				TransactionHash: "0xNFT",
+				LogIndex:        0,
+			},
+		},
+	}
+	repo := &keyedIngestionRepo{cursors: map[string]uint64{}}
+	svc := indexer.NewMultiSourceIngestionService(repo, rpc, sources, 10, 0)
+
+	if err := svc.RunOnce(context.Background()); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if repo.cursors["indexer.loan_registry.last_block"] != 109 || repo.cursors["indexer.passport_nft.last_block"] != 104 {
+		t.Fatalf("expected independent cursors, got %+v", repo.cursors)
+	}
+	if len(repo.events) != 1 || repo.events[0].EventName != "Transfer" || repo.events[0].TXHash != "0xnft" {
+		t.Fatalf("unexpected events: %+v", repo.events)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(repo.events[0].RawData, &raw); err != nil {
+		t.Fatalf("unmarshal raw data: %v", err)
+	}
+	if raw["to"] != "0x0000000000000000000000000000000000abcdef" || raw["token_id"] != float64(7) {
+		t.Fatalf("unexpected decoded transfer: %+v", raw)
+	}
+
+	filter := svc.Filter()
+	if len(filter.Addresses) != 2 || len(filter.Topics) != 4 {
+		t.Fatalf("expected union filter of both sources, got %+v", filter)
+	}
+}
+
+func TestIngestionBackfillLeavesCursorsAlone(t *testing.T) {
+	path := writeSourcesFile(t, []map[string]any{
+		{"name": "passport_nft", "address": passportAddr, "start_block": 95, "abi_builtin": "erc721"},
+	})
+	extra, err := indexer.LoadSources(path)
+	if err != nil {
+		t.Fatalf("load sources: %v", err)
+	}
+	sources := indexer.MergeSources([]indexer.Source{indexer.LoanRegistrySource(registryAddr, 100)}, extra)
+
+	transferTopic := eventTopic("Transfer(address,address,uint256)")
+	var logs []blockchain.LogEntry
+	for _, block := range []uint64{10, 24, 31} {
+		logs = append(logs, blockchain.LogEntry{
+			Address:         passportAddr,
+			Topics:          []string{transferTopic, "0x" + zeroPaddedHex("0", 64), "0x" + zeroPaddedHex("abcdef", 64), "0x" + zeroPaddedHex("7", 64)},
+			Data:            "0x",
+			BlockNumber:     block,
+			TransactionHash: "0xnft",
+		})
+	}
+	rpc := &addressLogRPC{blockNumber: 40, logs: logs}
+	repo := &keyedIngestionRepo{cursors: map[string]uint64{"indexer.passport_nft.last_block": 200}}
+	svc := indexer.NewMultiSourceIngestionService(repo, rpc, sources, 10, 5)
+
+	n, err := svc.Backfill(context.Background(), 5, 30, []string{"passport_nft"})
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if n != 2 || len(repo.events) != 2 {
+		t.Fatalf("expected 2 events in range, got %d (%d stored)", n, len(repo.events))
+	}
+	if len(rpc.filters) != 3 || rpc.filters[0].FromBlock != 5 || rpc.filters[2].ToBlock != 30 {
+		t.Fatalf("expected range read in batches, got %+v", rpc.filters)
+	}
+	if len(repo.cursors) != 1 || repo.cursors["indexer.passport_nft.last_block"] != 200 {
+		t.Fatalf("expected cursors untouched, got %+v", repo.cursors)
+	}
+
+	if _, err := svc.Backfill(context.Background(), 30, 36, nil); err == nil {
+		t.Fatalf("expected error for range past the safe head")
+	}
+	if _, err := svc.Backfill(context.Background(), 1, 2, []string{"missing"}); err == nil {
+		t.Fatalf("expected error for unknown source")
+	}
+}
@@ -2,7 +2,9 @@ package unit
 
 import (
 	"context"
+	"math"
 	"testing"
+	"time"
 
 	investordomain "github.com/loangraph/backend/internal/domain/investor"
 	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
@@ -11,7 +13,9 @@ import (
 )
 
 type investorPoolRepoMock struct {
-	pools []pooldomain.Entity
+	pools   []pooldomain.Entity
+	summary *pooldomain.PerformanceSummary
+	series  []loandomain.PerformancePoint
 }
 
 func (m *investorPoolRepoMock) List(_ context.Context, _, _, _ string, _, _ int32) ([]pooldomain.Entity, error) {
@@ -28,6 +32,15 @@ func (m *investorPoolRepoMock) GetByID(_ context.Context, id string) (*pooldomai
 	return nil, context.Canceled
 }
 
+func (m *investorPoolRepoMock) GetPerformanceSummary(_ context.Context, _ string) (*pooldomain.PerformanceSummary, error) {
+	cp := *m.summary
+	return &cp, nil
+}
+
+func (m *investorPoolRepoMock) GetRepaymentTimeSeries(_ context.Context, _ string, _ int32) ([]loandomain.PerformancePoint, error) {
+	return m.series, nil
+}
+
 type investorLenderRepoMock struct {
 	lender *lenderdomain.Entity
 }
@@ -37,15 +50,10 @@ func (m *investorLenderRepoMock) GetByID(_ context.Context, _ string) (*lenderdo
 }
 
 type investorLoanRepoMock struct {
-	series    []loandomain.PerformancePoint
 	analytics *loandomain.PortfolioAnalytics
 	health    *loandomain.PortfolioHealth
 }
 
-func (m *investorLoanRepoMock) GetRepaymentTimeSeriesByLender(_ context.Context, _ string, _ int32) ([]loandomain.PerformancePoint, error) {
-	return m.series, nil
-}
-
 func (m *investorLoanRepoMock) GetPortfolioAnalytics(_ context.Context, _ string) (*loandomain.PortfolioAnalytics, error) {
 	return m.analytics, nil
 }
@@ -55,23 +63,42 @@ func (m *investorLoanRepoMock) GetPortfolioHealth(_ context.Context, _ string) (
 }
 
 func TestInvestorServiceGetPoolPerformanceAndLenderProfile(t *testing.T) {
+	firstAllocatedAt := time.Now().UTC().Add(-365 * 24 * time.Hour)
 	svc := investordomain.NewService(
-		&investorPoolRepoMock{pools: []pooldomain.Entity{{ID: "pool-1", LenderID: "lender-1", Name: "Starter Pool"}}},
+		&investorPoolRepoMock{
+			pools: []pooldomain.Entity{{ID: "pool-1", LenderID: "lender-1", Name: "Starter Pool"}},
+			summary: &pooldomain.PerformanceSummary{
+				PoolID:                 "pool-1",
+				TargetAPYBPS:           1200,
+				AllocatedLoans:         4,
+				DefaultedLoans:         1,
+				DeployedMinor:          100000,
+				InterestCollectedMinor: 12000,
+				DefaultLossMinor:       2000,
+				FirstAllocatedAt:       &firstAllocatedAt,
+			},
+			series: []loandomain.PerformancePoint{{Date: "2026-02-25", RepaymentCount: 3, RepaidAmountMinor: 10000}},
+		},
 		&investorLenderRepoMock{lender: &lenderdomain.Entity{ID: "lender-1", Name: "Lender A"}},
 		&investorLoanRepoMock{
-			series:    []loandomain.PerformancePoint{{Date: "2026-02-25", RepaymentCount: 3, RepaidAmountMinor: 10000}},
 			analytics: &loandomain.PortfolioAnalytics{LenderID: "lender-1", TotalLoans: 10},
 			health:    &loandomain.PortfolioHealth{LenderID: "lender-1", UniqueBorrowers: 5},
 		},
 	)
 
-	points, err := svc.GetPoolPerformance(context.Background(), "pool-1", 30)
+	perf, err := svc.GetPoolPerformance(context.Background(), "pool-1", 30)
 	if err != nil {
 		t.Fatalf("pool performance error: %v", err)
 	}
-	if len(points) != 1 || points[0].RepaymentCount != 3 {
+	if len(perf.Items) != 1 || perf.Items[0].RepaymentCount != 3 {
 		t.Fatalf("unexpected performance points")
 	}
+	if perf.Summary.DefaultRatePercent != 25 {
+		t.Fatalf("expected 25%% default rate, got %v", perf.Summary.DefaultRatePercent)
+	}
+	if math.Abs(perf.Summary.RealizedYieldBPS-1000) > 1 || math.Abs(perf.Summary.YieldVsTargetBPS+200) > 1 {
+		t.Fatalf("unexpected realized yield: %+v", perf.Summary)
+	}
 
 	profile, err := svc.GetLenderProfile(context.Background(), "lender-1")
 	if err != nil {
@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	ledgerdomain "github.com/loangraph/backend/internal/domain/ledger"
+)
+
+func TestLedgerRepaymentSplitsPrincipalAndInterest(t *testing.T) {
+	entry := ledgerdomain.Repayment("rep-1", "loan-1", "lender-1", "NGN", 150000, 100000)
+	if err := entry.Validate(); err != nil {
+		t.Fatalf("expected balanced entry, got %v", err)
+	}
+	var principal, interest int64
+	for _, p := range entry.Postings {
+		switch p.Account.Kind {
+		case ledgerdomain.AccountBorrowerReceivable:
+			principal += p.CreditMinor
+		case ledgerdomain.AccountInterestIncome:
+			interest += p.CreditMinor
+		}
+	}
+	if principal != 100000 || interest != 50000 {
+		t.Fatalf("expected 100000 principal and 50000 interest, got %d/%d", principal, interest)
+	}
+
+	partial := ledgerdomain.Repayment("rep-2", "loan-1", "lender-1", "NGN", 40000, 100000)
+	if len(partial.Postings) != 2 || partial.Postings[1].CreditMinor != 40000 {
+		t.Fatalf("expected principal-only repayment, got %+v", partial.Postings)
+	}
+	if partial.IdempotencyKey == entry.IdempotencyKey {
+		t.Fatalf("expected distinct idempotency keys per repayment")
+	}
+}
+
+func TestLedgerEntriesAreBalanced(t *testing.T) {
+	entries := []ledgerdomain.EntryInput{
+		ledgerdomain.Disbursement("loan-1", "lender-1", "NGN", 500000),
+		ledgerdomain.WriteOff("loan-1", "lender-1", "NGN", 400000),
+		ledgerdomain.PoolAllocation("alloc-1", "pool-1", "lender-1", "NGN", 500000),
+		ledgerdomain.PoolRelease("alloc-1", "pool-1", "lender-1", "NGN", 500000),
+		ledgerdomain.InvestorDeposit("tx-1", "pool-1", "user-1", "NGN", 1000),
+		ledgerdomain.InvestorWithdrawal("tx-2", "pool-1", "user-1", "NGN", 1000),
+	}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			t.Fatalf("expected %s entry to validate, got %v", entry.Kind, err)
+		}
+	}
+
+	unbalanced := ledgerdomain.Disbursement("loan-1", "lender-1", "NGN", 500000)
+	unbalanced.Postings[1].CreditMinor = 499999
+	if err := unbalanced.Validate(); !errors.Is(err, ledgerdomain.ErrUnbalancedEntry) {
+		t.Fatalf("expected unbalanced entry error, got %v", err)
+	}
+	twoSided := ledgerdomain.Disbursement("loan-1", "lender-1", "NGN", 500000)
+	twoSided.Postings[0].CreditMinor = 1
+	if err := twoSided.Validate(); !errors.Is(err, ledgerdomain.ErrInvalidEntry) {
+		t.Fatalf("expected invalid entry error, got %v", err)
+	}
+
+	if got := ledgerdomain.NormalBalance(ledgerdomain.AccountInvestorEquity, 0, 1000); got != 1000 {
+		t.Fatalf("expected credit-normal balance 1000, got %d", got)
+	}
+	if got := ledgerdomain.NormalBalance(ledgerdomain.AccountBorrowerReceivable, 500000, 100000); got != 400000 {
+		t.Fatalf("expected debit-normal balance 400000, got %d", got)
+	}
+}
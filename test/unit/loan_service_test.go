@@ -2,12 +2,14 @@ package unit
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	borrowerdomain "github.com/loangraph/backend/internal/domain/borrower"
 	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	"github.com/loangraph/backend/internal/realtime"
 )
 
 type borrowerRepoMock struct {
@@ -31,6 +33,7 @@ func (m *borrowerRepoMock) Create(_ context.Context, in borrowerdomain.CreateInp
 
 type loanRepoMock struct {
 	items             []loandomain.Entity
+	lastFilter        loandomain.ListFilter
 	recordRepaymentID string
 	recordAmount      int64
 	defaultLoanID     string
@@ -63,7 +66,11 @@ func (m *loanRepoMock) GetByHash(_ context.Context, loanHash []byte) (*loandomai
 	return nil, context.Canceled
 }
 
-func (m *loanRepoMock) List(_ context.Context, _ loandomain.ListFilter) ([]loandomain.Entity, error) {
+func (m *loanRepoMock) List(_ context.Context, f loandomain.ListFilter) ([]loandomain.Entity, error) {
+	m.lastFilter = f
+	if f.Limit > 0 && int(f.Limit) < len(m.items) {
+		return m.items[:f.Limit], nil
+	}
 	return m.items, nil
 }
 
@@ -155,6 +162,40 @@ func TestProcessCSVUploadValidationError(t *testing.T) {
 	}
 }
 
+type publisherMock struct {
+	channels []string
+	events   []string
+	data     []any
+}
+
+func (p *publisherMock) Publish(_ context.Context, channel, event string, data any) error {
+	p.channels = append(p.channels, channel)
+	p.events = append(p.events, event)
+	p.data = append(p.data, data)
+	return nil
+}
+
+func TestProcessCSVUploadPublishesCompletion(t *testing.T) {
+	publisher := &publisherMock{}
+	svc := loandomain.NewService(&borrowerRepoMock{byHash: map[string]*borrowerdomain.Entity{}}, &loanRepoMock{}, &outboxRepoMock{})
+	svc.SetRealtimePublisher(publisher)
+	csvInput := strings.NewReader("borrower_kyc_id,gov_id_hash,principal_minor,currency,interest_rate_bps,maturity_date,loan_reference\n" +
+		"smile:NG-BVN:1,abc123,500000,NGN,2200,2030-12-31T00:00:00Z,LOAN-001\n" +
+		"smile:NG-BVN:2,def456,-5,NGN,2200,2030-12-31T00:00:00Z,LOAN-002\n")
+
+	result, err := svc.ProcessCSVUpload(context.Background(), "lender-1", csvInput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(publisher.events) != 1 || publisher.channels[0] != "lender:uploads:lender-1" || publisher.events[0] != "upload_completed" {
+		t.Fatalf("unexpected published events: %+v %+v", publisher.channels, publisher.events)
+	}
+	ev, ok := publisher.data[0].(realtime.UploadCompleted)
+	if !ok || ev.Processed != 1 || ev.Failed != 1 || len(ev.LoanIDs) != 1 || ev.LoanIDs[0] != result.LoanIDs[0] {
+		t.Fatalf("unexpected upload event: %+v", publisher.data[0])
+	}
+}
+
 func TestRecordRepaymentQueuesOutbox(t *testing.T) {
 	borrowerRepo := &borrowerRepoMock{byHash: map[string]*borrowerdomain.Entity{}}
 	loanRepo := &loanRepoMock{}
@@ -192,3 +233,48 @@ func TestMarkDefaultQueuesOutbox(t *testing.T) {
 		t.Fatalf("expected mark_default outbox topic")
 	}
 }
+
+func TestListLoansReturnsNextCursor(t *testing.T) {
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	loanRepo := &loanRepoMock{items: []loandomain.Entity{
+		{ID: "11111111-1111-1111-1111-111111111111", PrincipalMinor: 5000, AmountRepaid: 1000, CreatedAt: created},
+		{ID: "22222222-2222-2222-2222-222222222222", PrincipalMinor: 4000, CreatedAt: created.Add(-time.Hour)},
+		{ID: "33333333-3333-3333-3333-333333333333", PrincipalMinor: 3000, CreatedAt: created.Add(-2 * time.Hour)},
+	}}
+	svc := loandomain.NewService(&borrowerRepoMock{byHash: map[string]*borrowerdomain.Entity{}}, loanRepo, &outboxRepoMock{})
+
+	page, err := svc.ListLoans(context.Background(), loandomain.ListFilter{SortBy: "outstanding", Limit: 2})
+	if err != nil {
+		t.Fatalf("list loans: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 items and a next cursor, got %d items cursor=%q", len(page.Items), page.NextCursor)
+	}
+	if loanRepo.lastFilter.Limit != 3 || loanRepo.lastFilter.SortOrder != loandomain.SortDesc {
+		t.Fatalf("unexpected repository filter: %+v", loanRepo.lastFilter)
+	}
+
+	cursor, err := loandomain.DecodeCursor(page.NextCursor)
+	if err != nil {
+		t.Fatalf("decode cursor: %v", err)
+	}
+	if cursor.ID != "22222222-2222-2222-2222-222222222222" || cursor.Value != "4000" {
+		t.Fatalf("unexpected cursor: %+v", cursor)
+	}
+
+	if _, err := svc.ListLoans(context.Background(), loandomain.ListFilter{SortBy: "outstanding", Cursor: page.NextCursor, Limit: 2}); err != nil {
+		t.Fatalf("list second page: %v", err)
+	}
+	if loanRepo.lastFilter.After == nil || loanRepo.lastFilter.After.ID != cursor.ID {
+		t.Fatalf("expected decoded cursor to reach repository")
+	}
+
+	_, err = svc.ListLoans(context.Background(), loandomain.ListFilter{SortBy: "maturity", Cursor: page.NextCursor})
+	if !errors.Is(err, loandomain.ErrInvalidCursor) {
+		t.Fatalf("expected invalid cursor for mismatched sort, got %v", err)
+	}
+	_, err = svc.ListLoans(context.Background(), loandomain.ListFilter{SortBy: "borrower"})
+	if !errors.Is(err, loandomain.ErrInvalidSort) {
+		t.Fatalf("expected invalid sort, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+// memNonceStore mirrors the Postgres reservation rules closely enough to
+// exercise NonceManager.
+type memNonceStore struct {
+	next         uint64
+	reservations map[uint64]*blockchain.NonceReservation
+}
+
+func newMemNonceStore() *memNonceStore {
+	return &memNonceStore{reservations: map[uint64]*blockchain.NonceReservation{}}
+}
+
+func (s *memNonceStore) Reserve(_ context.Context, address string, chainPending uint64, _ time.Time) (uint64, error) {
+	if chainPending > s.next {
+		s.next = chainPending
+	}
+	var gap *blockchain.NonceReservation
+	for _, res := range s.reservations {
+		if res.Status == blockchain.NonceStatusReleased && res.Nonce >= chainPending && (gap == nil || res.Nonce < gap.Nonce) {
+			gap = res
+		}
+	}
+	if gap != nil {
+		gap.Status = blockchain.NonceStatusReserved
+		return gap.Nonce, nil
+	}
+	nonce := s.next
+	s.next++
+	s.reservations[nonce] = &blockchain.NonceReservation{Address: address, Nonce: nonce, Status: blockchain.NonceStatusReserved}
+	return nonce, nil
+}
+
+func (s *memNonceStore) MarkSubmitted(_ context.Context, _ string, nonce uint64, txHash string, tx blockchain.PendingTx, replacement bool) error {
+	res := s.reservations[nonce]
+	res.Status = blockchain.NonceStatusSubmitted
+	res.TxHash = txHash
+	res.Tx = tx
+	if replacement {
+		res.Replacements++
+	}
+	return nil
+}
+
+func (s *memNonceStore) MarkUsed(_ context.Context, _ string, nonce uint64) error {
+	s.reservations[nonce].Status = blockchain.NonceStatusUsed
+	return nil
+}
+
+func (s *memNonceStore) Release(_ context.Context, _ string, nonce uint64) error {
+	s.reservations[nonce].Status = blockchain.NonceStatusReleased
+	return nil
+}
+
+func (s *memNonceStore) Confirm(_ context.Context, _ string, chainLatest uint64) error {
+	for _, res := range s.reservations {
+		if res.Nonce < chainLatest && res.Status == blockchain.NonceStatusSubmitted {
+			res.Status = blockchain.NonceStatusUsed
+		}
+	}
+	return nil
+}
+
+func (s *memNonceStore) ListStuck(_ context.Context, _ string, _ time.Time) ([]blockchain.NonceReservation, error) {
+	out := []blockchain.NonceReservation{}
+	for n := uint64(0); n < s.next; n++ {
+		if res, ok := s.reservations[n]; ok && res.Status == blockchain.NonceStatusSubmitted {
+			out = append(out, *res)
+		}
+	}
+	return out, nil
+}
+
+func TestNonceManagerSendAssignsSequentialNonces(t *testing.T) {
+	store := newMemNonceStore()
+	m := blockchain.NewNonceManager(store, time.Minute, 20)
+	sent := []uint64{}
+	send := func(_ context.Context, nonce uint64, _ blockchain.PendingTx) (string, error) {
+		sent = append(sent, nonce)
+		return "0xhash", nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := m.Send(context.Background(), "0xABC", 7, blockchain.PendingTx{GasPriceWei: big.NewInt(100)}, send); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	if len(sent) != 3 || sent[0] != 7 || sent[1] != 8 || sent[2] != 9 {
+		t.Fatalf("expected nonces 7,8,9, got %v", sent)
+	}
+}
+
+func TestNonceManagerSendHandlesRejections(t *testing.T) {
+	store := newMemNonceStore()
+	m := blockchain.NewNonceManager(store, time.Minute, 20)
+	ctx := context.Background()
+
+	// A send that never reached the node leaves a gap that is reused.
+	_, err := m.Send(ctx, "0xabc", 0, blockchain.PendingTx{}, func(context.Context, uint64, blockchain.PendingTx) (string, error) {
+		return "", blockchain.Transient(errors.New("connection refused"))
+	})
+	if err == nil || store.reservations[0].Status != blockchain.NonceStatusReleased {
+		t.Fatalf("expected nonce 0 released, got %+v (%v)", store.reservations[0], err)
+	}
+	var reused uint64 = 99
+	if _, err := m.Send(ctx, "0xabc", 0, blockchain.PendingTx{}, func(_ context.Context, nonce uint64, _ blockchain.PendingTx) (string, error) {
+		reused = nonce
+		return "0x1", nil
+	}); err != nil || reused != 0 {
+		t.Fatalf("expected released nonce 0 to be reused, got %d (%v)", reused, err)
+	}
+
+	// A nonce the node reports as taken is never handed out again.
+	_, err = m.Send(ctx, "0xabc", 0, blockchain.PendingTx{}, func(context.Context, uint64, blockchain.PendingTx) (string, error) {
+		return "", blockchain.NonceTooLow(errors.New("nonce too low"))
+	})
+	if blockchain.KindOf(err) != blockchain.KindNonceTooLow || store.reservations[1].Status != blockchain.NonceStatusUsed {
+		t.Fatalf("expected nonce 1 marked used, got %+v (%v)", store.reservations[1], err)
+	}
+	var next uint64
+	if _, err := m.Send(ctx, "0xabc", 0, blockchain.PendingTx{}, func(_ context.Context, nonce uint64, _ blockchain.PendingTx) (string, error) {
+		next = nonce
+		return "0x2", nil
+	}); err != nil || next != 2 {
+		t.Fatalf("expected nonce 2 after a taken nonce, got %d (%v)", next, err)
+	}
+}
+
+func TestNonceManagerReplaceStuckBumpsFee(t *testing.T) {
+	store := newMemNonceStore()
+	m := blockchain.NewNonceManager(store, time.Minute, 20)
+	ctx := context.Background()
+	send := func(context.Context, uint64, blockchain.PendingTx) (string, error) { return "0xorig", nil }
+	for i := 0; i < 2; i++ {
+		if _, err := m.Send(ctx, "0xabc", 0, blockchain.PendingTx{To: "0xto", GasPriceWei: big.NewInt(1000)}, send); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	var resent []blockchain.PendingTx
+	replaced, err := m.ReplaceStuck(ctx, "0xabc", 1, big.NewInt(500), nil, func(_ context.Context, nonce uint64, _, tx blockchain.PendingTx) (string, error) {
+		if nonce != 1 {
+			t.Fatalf("expected only nonce 1 to be stuck, got %d", nonce)
+		}
+		resent = append(resent, tx)
+		return "0xreplacement", nil
+	})
+	if err != nil || replaced != 1 {
+		t.Fatalf("expected one replacement, got %d (%v)", replaced, err)
+	}
+	if resent[0].To != "0xto" || resent[0].GasPriceWei.Cmp(big.NewInt(1200)) != 0 {
+		t.Fatalf("expected same tx with 20%% bumped fee, got %+v", resent[0])
+	}
+	if store.reservations[1].TxHash != "0xreplacement" || store.reservations[1].Replacements != 1 {
+		t.Fatalf("expected replacement recorded, got %+v", store.reservations[1])
+	}
+
+	// A current gas price above the bumped fee wins.
+	if _, err := m.ReplaceStuck(ctx, "0xabc", 1, big.NewInt(5000), nil, func(_ context.Context, _ uint64, _, tx blockchain.PendingTx) (string, error) {
+		if tx.GasPriceWei.Cmp(big.NewInt(5000)) != 0 {
+			t.Fatalf("expected current gas price 5000, got %s", tx.GasPriceWei)
+		}
+		return "0xreplacement2", nil
+	}); err != nil {
+		t.Fatalf("replace stuck: %v", err)
+	}
+}
+
+func TestNonceManagerReplaceStuckBumpsDynamicFees(t *testing.T) {
+	store := newMemNonceStore()
+	m := blockchain.NewNonceManager(store, time.Minute, 20)
+	ctx := context.Background()
+	tx := blockchain.PendingTx{To: "0xto", MaxFeePerGasWei: big.NewInt(3000), MaxPriorityFeeWei: big.NewInt(1000)}
+	if _, err := m.Send(ctx, "0xabc", 0, tx, func(context.Context, uint64, blockchain.PendingTx) (string, error) { return "0xorig", nil }); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	var resent blockchain.PendingTx
+	if _, err := m.ReplaceStuck(ctx, "0xabc", 0, big.NewInt(500), nil, func(_ context.Context, _ uint64, _, tx blockchain.PendingTx) (string, error) {
+		resent = tx
+		return "0xreplacement", nil
+	}); err != nil {
+		t.Fatalf("replace stuck: %v", err)
+	}
+	if resent.GasPriceWei != nil || resent.MaxFeePerGasWei.Cmp(big.NewInt(3600)) != 0 || resent.MaxPriorityFeeWei.Cmp(big.NewInt(1200)) != 0 {
+		t.Fatalf("expected both dynamic fees bumped by 20%%, got %+v", resent)
+	}
+}
+
+func TestNonceManagerReplaceStuckKeepsWithinFeeCaps(t *testing.T) {
+	store := newMemNonceStore()
+	m := blockchain.NewNonceManager(store, time.Minute, 20)
+	ctx := context.Background()
+	tx := blockchain.PendingTx{To: "0xto", MaxFeePerGasWei: big.NewInt(3000), MaxPriorityFeeWei: big.NewInt(1000)}
+	if _, err := m.Send(ctx, "0xabc", 0, tx, func(context.Context, uint64, blockchain.PendingTx) (string, error) { return "0xorig", nil }); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	var resent []blockchain.PendingTx
+	send := func(_ context.Context, _ uint64, _, tx blockchain.PendingTx) (string, error) {
+		resent = append(resent, tx)
+		return "0xreplacement", nil
+	}
+	// A cap too low to outbid the stuck transaction leaves it waiting.
+	policy := &blockchain.FeePolicy{MaxFeePerGasWei: big.NewInt(3200)}
+	if replaced, err := m.ReplaceStuck(ctx, "0xabc", 0, big.NewInt(5000), policy, send); err != nil || replaced != 0 || len(resent) != 0 {
+		t.Fatalf("expected no replacement under the cap, got %d (%v)", replaced, err)
+	}
+
+	policy = &blockchain.FeePolicy{MaxFeePerGasWei: big.NewInt(3400), MaxPriorityFeeWei: big.NewInt(1100)}
+	if replaced, err := m.ReplaceStuck(ctx, "0xabc", 0, big.NewInt(5000), policy, send); err != nil || replaced != 1 {
+		t.Fatalf("expected one replacement, got %d (%v)", replaced, err)
+	}
+	if resent[0].MaxFeePerGasWei.Int64() != 3400 || resent[0].MaxPriorityFeeWei.Int64() != 1100 {
+		t.Fatalf("expected fees capped, got %+v", resent[0])
+	}
+
+	// Over the fee budget, the replacement waits for the next day.
+	over := func(context.Context, uint64, blockchain.PendingTx, blockchain.PendingTx) (string, error) {
+		return "", blockchain.ErrFeeBudgetExceeded
+	}
+	if replaced, err := m.ReplaceStuck(ctx, "0xabc", 0, big.NewInt(5000), nil, over); err != nil || replaced != 0 {
+		t.Fatalf("expected the over-budget replacement skipped, got %d (%v)", replaced, err)
+	}
+}
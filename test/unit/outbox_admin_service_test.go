@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	outboxdomain "github.com/loangraph/backend/internal/domain/outbox"
+)
+
+type outboxAdminRepoMock struct {
+	jobs   map[int64]*outboxdomain.Job
+	getErr error
+}
+
+func (m *outboxAdminRepoMock) List(_ context.Context, f outboxdomain.ListFilter) ([]outboxdomain.Job, error) {
+	out := []outboxdomain.Job{}
+	for _, j := range m.jobs {
+		if (f.Status == "" || j.Status == f.Status) && (f.Topic == "" || j.Topic == f.Topic) {
+			out = append(out, *j)
+		}
+	}
+	return out, nil
+}
+
+func (m *outboxAdminRepoMock) GetByID(_ context.Context, id int64) (*outboxdomain.Job, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	if j, ok := m.jobs[id]; ok {
+		cp := *j
+		return &cp, nil
+	}
+	return nil, outboxdomain.ErrNotFound
+}
+
+func (m *outboxAdminRepoMock) Retry(_ context.Context, id int64) (*outboxdomain.Job, error) {
+	j := m.jobs[id]
+	j.Status = outboxdomain.StatusPending
+	j.Attempts = 0
+	cp := *j
+	return &cp, nil
+}
+
+func (m *outboxAdminRepoMock) Cancel(_ context.Context, id int64) (*outboxdomain.Job, error) {
+	j := m.jobs[id]
+	j.Status = outboxdomain.StatusCancelled
+	cp := *j
+	return &cp, nil
+}
+
+func (m *outboxAdminRepoMock) RequeueFailed(_ context.Context, topic string) (int64, error) {
+	var n int64
+	for _, j := range m.jobs {
+		if j.Topic == topic && j.Status == outboxdomain.StatusFailed {
+			j.Status = outboxdomain.StatusPending
+			j.Attempts = 0
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *outboxAdminRepoMock) CountByStatus(_ context.Context) ([]outboxdomain.StatusCount, error) {
+	counts := map[[2]string]int64{}
+	for _, j := range m.jobs {
+		counts[[2]string{j.Topic, j.Status}]++
+	}
+	out := []outboxdomain.StatusCount{}
+	for k, v := range counts {
+		out = append(out, outboxdomain.StatusCount{Topic: k[0], Status: k[1], Count: v})
+	}
+	return out, nil
+}
+
+func TestOutboxAdminServiceRetryCancelAndRequeue(t *testing.T) {
+	repo := &outboxAdminRepoMock{jobs: map[int64]*outboxdomain.Job{
+		1: {ID: 1, Topic: "register_loan", Status: outboxdomain.StatusFailed, Attempts: 5, LastError: "rpc timeout"},
+		2: {ID: 2, Topic: "register_loan", Status: outboxdomain.StatusFailed, Attempts: 5},
+		3: {ID: 3, Topic: "mark_default", Status: outboxdomain.StatusDone, Attempts: 1},
+		4: {ID: 4, Topic: "mark_default", Status: outboxdomain.StatusPending},
+	}}
+	audit := &adminAuditRepoMock{}
+	svc := outboxdomain.NewService(repo, audit)
+	ctx := context.Background()
+
+	summary, err := svc.Summary(ctx)
+	if err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	if summary.ByStatus[outboxdomain.StatusFailed] != 2 || summary.FailedByTopic["register_loan"] != 2 || summary.ByStatus[outboxdomain.StatusCancelled] != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	if _, err := svc.ListJobs(ctx, outboxdomain.ListFilter{Status: "stuck"}); !errors.Is(err, outboxdomain.ErrInvalidStatus) {
+		t.Fatalf("expected invalid status error, got %v", err)
+	}
+
+	retried, err := svc.RetryJob(ctx, "admin-1", 1)
+	if err != nil {
+		t.Fatalf("retry error: %v", err)
+	}
+	if retried.Status != outboxdomain.StatusPending || retried.Attempts != 0 {
+		t.Fatalf("expected job reset to pending, got %+v", retried)
+	}
+	if _, err := svc.RetryJob(ctx, "admin-1", 3); !errors.Is(err, outboxdomain.ErrInvalidTransition) {
+		t.Fatalf("expected done job retry to fail, got %v", err)
+	}
+	if _, err := svc.RetryJob(ctx, "admin-1", 99); !errors.Is(err, outboxdomain.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	repo.getErr = errors.New("connection reset")
+	if _, err := svc.CancelJob(ctx, "admin-1", 4); !errors.Is(err, repo.getErr) || errors.Is(err, outboxdomain.ErrNotFound) {
+		t.Fatalf("expected lookup error propagated, got %v", err)
+	}
+	repo.getErr = nil
+
+	if _, err := svc.CancelJob(ctx, "admin-1", 4); err != nil {
+		t.Fatalf("cancel error: %v", err)
+	}
+	if _, err := svc.CancelJob(ctx, "admin-1", 3); !errors.Is(err, outboxdomain.ErrInvalidTransition) {
+		t.Fatalf("expected done job cancel to fail, got %v", err)
+	}
+
+	count, err := svc.RequeueTopic(ctx, "admin-1", "register_loan")
+	if err != nil || count != 1 {
+		t.Fatalf("expected one requeued job, got %d (%v)", count, err)
+	}
+
+	actions := []string{}
+	for _, l := range audit.logs {
+		actions = append(actions, l.Action)
+	}
+	want := []string{"outbox_job_retried", "outbox_job_cancelled", "outbox_topic_requeued"}
+	if len(actions) != len(want) {
+		t.Fatalf("unexpected audit actions: %v", actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("unexpected audit actions: %v", actions)
+		}
+	}
+}
@@ -3,43 +3,89 @@ package unit
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/loangraph/backend/internal/blockchain"
 	"github.com/loangraph/backend/internal/jobs"
 )
 
 type fakeOutboxRepo struct {
-	jobs      []jobs.OutboxJob
-	doneIDs   []int64
-	retryIDs  []int64
-	failedIDs []int64
+	mu          sync.Mutex
+	jobs        []jobs.OutboxJob
+	doneIDs     []int64
+	retryIDs    []int64
+	retryAt     []time.Time
+	failedIDs   []int64
+	releasedIDs []int64
+	deferredIDs []int64
+	deferredTo  []time.Time
+	renewals    int
+	markErr     map[int64]error
 }
 
-func (r *fakeOutboxRepo) ClaimPending(_ context.Context, _ int32) ([]jobs.OutboxJob, error) {
+func (r *fakeOutboxRepo) ClaimPending(_ context.Context, _ string, _ time.Duration, _ int32) ([]jobs.OutboxJob, error) {
 	return r.jobs, nil
 }
 
+func (r *fakeOutboxRepo) RenewLease(_ context.Context, _ string, _ []int64, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.renewals++
+	return nil
+}
+
 func (r *fakeOutboxRepo) MarkDone(_ context.Context, jobID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.markErr[jobID]; err != nil {
+		return err
+	}
 	r.doneIDs = append(r.doneIDs, jobID)
 	return nil
 }
 
-func (r *fakeOutboxRepo) MarkRetry(_ context.Context, jobID int64, _ time.Time, _ string) error {
+func (r *fakeOutboxRepo) MarkRetry(_ context.Context, jobID int64, next time.Time, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.retryIDs = append(r.retryIDs, jobID)
+	r.retryAt = append(r.retryAt, next)
 	return nil
 }
 
 func (r *fakeOutboxRepo) MarkFailed(_ context.Context, jobID int64, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.failedIDs = append(r.failedIDs, jobID)
 	return nil
 }
 
+func (r *fakeOutboxRepo) Defer(_ context.Context, jobID int64, until time.Time, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deferredIDs = append(r.deferredIDs, jobID)
+	r.deferredTo = append(r.deferredTo, until)
+	return nil
+}
+
+func (r *fakeOutboxRepo) Release(_ context.Context, _ string, jobIDs []int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releasedIDs = append(r.releasedIDs, jobIDs...)
+	return nil
+}
+
 type fakeLoanRepo struct {
+	mu      sync.Mutex
 	updated map[string]string
 }
 
 func (r *fakeLoanRepo) SetOnChainSubmission(_ context.Context, loanID, txHash string, _ bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.updated == nil {
 		r.updated = map[string]string{}
 	}
@@ -140,3 +186,426 @@ func TestWorkerRunOnceDefaultTopic(t *testing.T) {
 		t.Fatalf("expected default job marked done")
 	}
 }
+
+type fakePoolTokenWriter struct {
+	fakeWriter
+	actions []blockchain.PoolPositionAction
+}
+
+func (w *fakePoolTokenWriter) MirrorPoolPosition(_ context.Context, in blockchain.PoolPositionAction) (string, error) {
+	w.actions = append(w.actions, in)
+	return w.txHash, nil
+}
+
+type fakePositionRepo struct {
+	submitted map[string]string
+}
+
+func (r *fakePositionRepo) SetTransactionSubmission(_ context.Context, transactionID, txHash string) error {
+	if r.submitted == nil {
+		r.submitted = map[string]string{}
+	}
+	r.submitted[transactionID] = txHash
+	return nil
+}
+
+func TestWorkerRunOncePoolPositionTopic(t *testing.T) {
+	payload := []byte(`{"transaction_id":"tx-1","pool_id":"pool-1","pool_token_addr":"0x114dac28b091F2d73dF6509E4063D6553eB36fa2","investor_user_id":"user-1","action":"deposit","amount_minor":5000,"shares":5000}`)
+
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 5, Topic: "pool_position", Attempts: 1, Payload: payload}}}
+	writer := &fakePoolTokenWriter{fakeWriter: fakeWriter{txHash: "0xpooltx"}}
+	positionRepo := &fakePositionRepo{}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, writer)
+	worker.SetPositionRepository(positionRepo)
+
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(outbox.doneIDs) != 1 || outbox.doneIDs[0] != 5 {
+		t.Fatalf("expected pool position job marked done")
+	}
+	if len(writer.actions) != 1 || writer.actions[0].Shares != 5000 || writer.actions[0].Action != "deposit" {
+		t.Fatalf("unexpected pool token actions: %+v", writer.actions)
+	}
+	if positionRepo.submitted["tx-1"] != "0xpooltx" {
+		t.Fatalf("expected tx hash recorded on investor transaction")
+	}
+
+	unsupported := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 6, Topic: "pool_position", Attempts: 1, Payload: payload}}}
+	worker = jobs.NewWorker(unsupported, &fakeLoanRepo{}, &fakeWriter{txHash: "0xtx"})
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(unsupported.failedIDs) != 1 || unsupported.failedIDs[0] != 6 {
+		t.Fatalf("expected pool position job failed when writer has no pool token support")
+	}
+}
+
+func TestWorkerFailsPermanentErrorsImmediately(t *testing.T) {
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{
+		{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{}`)},
+		{ID: 2, Topic: "register_loan", Attempts: 1, Payload: []byte(`not-json`)},
+		{ID: 3, Topic: "unknown_topic", Attempts: 1, Payload: []byte(`{}`)},
+	}}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, &fakeWriter{txHash: "0xtx"})
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(outbox.failedIDs) != 3 || len(outbox.retryIDs) != 0 {
+		t.Fatalf("expected all jobs failed without retry, failed=%v retry=%v", outbox.failedIDs, outbox.retryIDs)
+	}
+
+	writerErr := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 4, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-1"}`)}}}
+	worker = jobs.NewWorker(writerErr, &fakeLoanRepo{}, &fakeWriter{err: blockchain.Permanent(errors.New("invalid args"))})
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(writerErr.failedIDs) != 1 || writerErr.failedIDs[0] != 4 {
+		t.Fatalf("expected permanent writer error to fail job")
+	}
+
+	known := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 5, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-1"}`)}}}
+	worker = jobs.NewWorker(known, &fakeLoanRepo{}, &fakeWriter{err: blockchain.AlreadyKnown(errors.New("already known"))})
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(known.failedIDs) != 1 || len(known.retryIDs) != 0 {
+		t.Fatalf("expected an already known transaction not to be resent, failed=%v retry=%v", known.failedIDs, known.retryIDs)
+	}
+}
+
+func TestWorkerDefersJobsOverFeeBudget(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Even a job on its last attempt waits for the budget instead of failing.
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 1, Topic: "register_loan", Attempts: 5, Payload: []byte(`{"loan_id":"loan-1"}`)}}}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, &fakeWriter{err: blockchain.ErrFeeBudgetExceeded})
+	worker.SetClock(func() time.Time { return now }, func() float64 { return 0.5 })
+	worker.SetRetryPolicy(jobs.RetryPolicy{MaxAttempts: 5, BaseDelay: 15 * time.Second, MaxDelay: 90 * time.Second})
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(outbox.failedIDs) != 0 || len(outbox.retryIDs) != 0 || len(outbox.deferredIDs) != 1 {
+		t.Fatalf("expected the job deferred, failed=%v retry=%v deferred=%v", outbox.failedIDs, outbox.retryIDs, outbox.deferredIDs)
+	}
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC); !outbox.deferredTo[0].Equal(want) {
+		t.Fatalf("expected the job deferred to %s, got %s", want, outbox.deferredTo[0])
+	}
+}
+
+func TestWorkerRetrySchedule(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		topic    string
+		attempts int32
+		err      error
+		want     time.Duration
+	}{
+		{name: "first attempt", topic: "register_loan", attempts: 1, err: errors.New("rpc down"), want: 15 * time.Second},
+		{name: "exponential", topic: "register_loan", attempts: 3, err: errors.New("rpc down"), want: time.Minute},
+		{name: "capped", topic: "register_loan", attempts: 4, err: errors.New("rpc down"), want: 90 * time.Second},
+		{name: "topic override", topic: "mark_default", attempts: 2, err: errors.New("rpc down"), want: 2 * time.Second},
+		{name: "nonce too low", topic: "register_loan", attempts: 4, err: blockchain.NonceTooLow(errors.New("nonce too low")), want: 2 * time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := []byte(`{"loan_id":"loan-1"}`)
+			outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 1, Topic: tc.topic, Attempts: tc.attempts, Payload: payload}}}
+			worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, &fakeWriter{err: tc.err})
+			worker.SetClock(func() time.Time { return now }, func() float64 { return 0.5 })
+			worker.SetRetryPolicy(jobs.RetryPolicy{MaxAttempts: 5, BaseDelay: 15 * time.Second, MaxDelay: 90 * time.Second, Jitter: 0.2})
+			worker.SetTopicRetryPolicy("mark_default", jobs.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Minute})
+
+			if err := worker.RunOnce(context.Background(), 10); err != nil {
+				t.Fatalf("run once: %v", err)
+			}
+			if len(outbox.retryAt) != 1 {
+				t.Fatalf("expected one retry, failed=%v", outbox.failedIDs)
+			}
+			if got := outbox.retryAt[0].Sub(now); got != tc.want {
+				t.Fatalf("expected delay %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := jobs.RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: time.Minute, Jitter: 0.2}
+	if got := policy.Backoff(2, 0); got != 16*time.Second {
+		t.Fatalf("expected lower jitter bound 16s, got %s", got)
+	}
+	if got := policy.Backoff(2, 1); got != 24*time.Second {
+		t.Fatalf("expected upper jitter bound 24s, got %s", got)
+	}
+	if got := policy.Backoff(10, 0.5); got != time.Minute {
+		t.Fatalf("expected capped delay 1m, got %s", got)
+	}
+}
+
+func TestParseRetryPolicies(t *testing.T) {
+	fallback := jobs.DefaultRetryPolicy()
+	policies, err := jobs.ParseRetryPolicies("register_loan=8/30s/30m, mark_default=3", fallback)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	reg := policies["register_loan"]
+	if reg.MaxAttempts != 8 || reg.BaseDelay != 30*time.Second || reg.MaxDelay != 30*time.Minute {
+		t.Fatalf("unexpected register_loan policy: %+v", reg)
+	}
+	def := policies["mark_default"]
+	if def.MaxAttempts != 3 || def.BaseDelay != fallback.BaseDelay || def.MaxDelay != fallback.MaxDelay {
+		t.Fatalf("unexpected mark_default policy: %+v", def)
+	}
+	for _, spec := range []string{"register_loan", "register_loan=0", "register_loan=3/soon", "register_loan=3/1m/10s"} {
+		if _, err := jobs.ParseRetryPolicies(spec, fallback); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+// orderedWriter records the order calls arrive per loan and blocks each call
+// until released so tests can observe concurrency.
+type orderedWriter struct {
+	mu       sync.Mutex
+	calls    map[string][]string
+	inFlight int
+	peak     int
+	gate     chan struct{}
+}
+
+func (w *orderedWriter) record(loanID, action string) (string, error) {
+	w.mu.Lock()
+	if w.calls == nil {
+		w.calls = map[string][]string{}
+	}
+	w.calls[loanID] = append(w.calls[loanID], action)
+	w.inFlight++
+	w.peak = max(w.peak, w.inFlight)
+	w.mu.Unlock()
+	<-w.gate
+	w.mu.Lock()
+	w.inFlight--
+	w.mu.Unlock()
+	return "0x" + action, nil
+}
+
+func (w *orderedWriter) RegisterLoan(_ context.Context, loanID string) (string, error) {
+	return w.record(loanID, "register")
+}
+
+func (w *orderedWriter) RecordRepayment(_ context.Context, loanID string, _ int64, _ string) (string, error) {
+	return w.record(loanID, "repay")
+}
+
+func (w *orderedWriter) MarkDefault(_ context.Context, loanID string, _ string) (string, error) {
+	return w.record(loanID, "default")
+}
+
+func TestWorkerRunOnceConcurrentPreservesLoanOrder(t *testing.T) {
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{
+		{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a"}`)},
+		{ID: 2, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-b"}`)},
+		{ID: 3, Topic: "record_repayment", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a","amount_minor":100,"currency":"NGN"}`)},
+		{ID: 4, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-c"}`)},
+		{ID: 5, Topic: "mark_default", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a","reason":"late"}`)},
+	}}
+	writer := &orderedWriter{gate: make(chan struct{})}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, writer)
+	worker.SetConcurrency(2)
+
+	done := make(chan error, 1)
+	go func() { done <- worker.RunOnce(context.Background(), 10) }()
+	for i := 0; i < 5; i++ {
+		writer.gate <- struct{}{}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+
+	if len(outbox.doneIDs) != 5 {
+		t.Fatalf("expected 5 done jobs, got %v", outbox.doneIDs)
+	}
+	got := writer.calls["loan-a"]
+	if len(got) != 3 || got[0] != "register" || got[1] != "repay" || got[2] != "default" {
+		t.Fatalf("expected loan-a jobs in order, got %v", got)
+	}
+	if writer.peak > 2 {
+		t.Fatalf("expected at most 2 concurrent calls, got %d", writer.peak)
+	}
+}
+
+func TestWorkerRunOnceReleasesUnfinishedJobs(t *testing.T) {
+	outbox := &fakeOutboxRepo{
+		jobs: []jobs.OutboxJob{
+			{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a"}`)},
+			{ID: 2, Topic: "record_repayment", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a","amount_minor":100,"currency":"NGN"}`)},
+			{ID: 3, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-b"}`)},
+		},
+		markErr: map[int64]error{1: errors.New("db down")},
+	}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, &fakeWriter{txHash: "0xtx"})
+
+	err := worker.RunOnce(context.Background(), 10)
+	if err == nil {
+		t.Fatalf("expected db error to be returned")
+	}
+	sort.Slice(outbox.releasedIDs, func(i, j int) bool { return outbox.releasedIDs[i] < outbox.releasedIDs[j] })
+	if len(outbox.releasedIDs) != 2 || outbox.releasedIDs[0] != 1 || outbox.releasedIDs[1] != 2 {
+		t.Fatalf("expected jobs 1 and 2 released, got %v", outbox.releasedIDs)
+	}
+	if len(outbox.doneIDs) != 1 || outbox.doneIDs[0] != 3 {
+		t.Fatalf("expected other loans to keep processing, got %v", outbox.doneIDs)
+	}
+
+	cancelled := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 7, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a"}`)}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	worker = jobs.NewWorker(cancelled, &fakeLoanRepo{}, &fakeWriter{txHash: "0xtx"})
+	if err := worker.RunOnce(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if len(cancelled.releasedIDs) != 1 || cancelled.releasedIDs[0] != 7 || len(cancelled.doneIDs) != 0 {
+		t.Fatalf("expected unstarted job released on shutdown, released=%v done=%v", cancelled.releasedIDs, cancelled.doneIDs)
+	}
+}
+
+func TestWorkerRenewsLeaseWhileProcessing(t *testing.T) {
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-a"}`)}}}
+	writer := &orderedWriter{gate: make(chan struct{})}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, writer)
+	worker.SetLease("worker-1", 30*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- worker.RunOnce(context.Background(), 10) }()
+	time.Sleep(100 * time.Millisecond)
+	writer.gate <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+
+	outbox.mu.Lock()
+	renewals := outbox.renewals
+	outbox.mu.Unlock()
+	if renewals == 0 {
+		t.Fatalf("expected lease to be renewed during a slow job")
+	}
+	time.Sleep(30 * time.Millisecond)
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	if outbox.renewals != renewals {
+		t.Fatalf("expected renewals to stop after the batch finished")
+	}
+}
+
+type fakeBatchWriter struct {
+	fakeWriter
+	mu         sync.Mutex
+	revertLoan string
+	batches    [][]string
+}
+
+func (w *fakeBatchWriter) SubmitBatch(_ context.Context, lenderID string, calls []blockchain.BatchCall) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	loans := make([]string, 0, len(calls))
+	for _, call := range calls {
+		if call.LoanID == w.revertLoan {
+			return "", blockchain.Reverted(errors.New("execution reverted"))
+		}
+		loans = append(loans, call.LoanID)
+	}
+	w.batches = append(w.batches, loans)
+	return "0xbatch-" + lenderID, nil
+}
+
+type fakeBatchRepo struct {
+	mu      sync.Mutex
+	err     error
+	batches []jobs.ChainBatch
+}
+
+func (r *fakeBatchRepo) RecordBatch(_ context.Context, batch jobs.ChainBatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func TestWorkerBatchesJobsPerLender(t *testing.T) {
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{
+		{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-1","lender_id":"lender-a"}`)},
+		{ID: 2, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-2","lender_id":"lender-a"}`)},
+		{ID: 3, Topic: "record_repayment", Attempts: 1, Payload: []byte(`{"loan_id":"loan-3","lender_id":"lender-a","amount_minor":500,"currency":"NGN"}`)},
+		{ID: 4, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-4","lender_id":"lender-b"}`)},
+		{ID: 5, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-5","lender_id":"lender-b"}`)},
+		{ID: 6, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-6"}`)},
+	}}
+	loanRepo := &fakeLoanRepo{}
+	writer := &fakeBatchWriter{fakeWriter: fakeWriter{txHash: "0xsingle"}}
+	batchRepo := &fakeBatchRepo{}
+	worker := jobs.NewWorker(outbox, loanRepo, writer)
+	worker.SetBatching(3, batchRepo)
+
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if len(outbox.doneIDs) != 6 {
+		t.Fatalf("expected all jobs done, got %v", outbox.doneIDs)
+	}
+	if len(writer.batches) != 2 || len(batchRepo.batches) != 2 {
+		t.Fatalf("expected one batch per lender, got %v", writer.batches)
+	}
+	if loanRepo.updated["loan-1"] != "0xbatch-lender-a" || loanRepo.updated["loan-5"] != "0xbatch-lender-b" {
+		t.Fatalf("expected batch tx recorded on loans, got %v", loanRepo.updated)
+	}
+	if loanRepo.updated["loan-6"] != "0xsingle" {
+		t.Fatalf("expected job without lender to be sent on its own, got %v", loanRepo.updated["loan-6"])
+	}
+}
+
+func TestWorkerSplitsRevertedBatch(t *testing.T) {
+	payloads := []string{"loan-1", "loan-2", "loan-3", "loan-4"}
+	claimed := make([]jobs.OutboxJob, 0, len(payloads))
+	for i, loanID := range payloads {
+		claimed = append(claimed, jobs.OutboxJob{ID: int64(i + 1), Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"` + loanID + `","lender_id":"lender-a"}`)})
+	}
+	outbox := &fakeOutboxRepo{jobs: claimed}
+	writer := &fakeBatchWriter{revertLoan: "loan-3"}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, writer)
+	worker.SetBatching(10, nil)
+
+	if err := worker.RunOnce(context.Background(), 10); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	sort.Slice(outbox.doneIDs, func(i, j int) bool { return outbox.doneIDs[i] < outbox.doneIDs[j] })
+	if len(outbox.doneIDs) != 3 || outbox.doneIDs[0] != 1 || outbox.doneIDs[2] != 4 {
+		t.Fatalf("expected non-reverting jobs done, got %v", outbox.doneIDs)
+	}
+	if len(outbox.retryIDs) != 1 || outbox.retryIDs[0] != 3 {
+		t.Fatalf("expected reverting job isolated for retry, got retry=%v failed=%v", outbox.retryIDs, outbox.failedIDs)
+	}
+}
+
+func TestWorkerNeverResendsSubmittedBatch(t *testing.T) {
+	outbox := &fakeOutboxRepo{jobs: []jobs.OutboxJob{
+		{ID: 1, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-1","lender_id":"lender-a"}`)},
+		{ID: 2, Topic: "register_loan", Attempts: 1, Payload: []byte(`{"loan_id":"loan-2","lender_id":"lender-a"}`)},
+	}}
+	writer := &fakeBatchWriter{}
+	worker := jobs.NewWorker(outbox, &fakeLoanRepo{}, writer)
+	worker.SetBatching(10, &fakeBatchRepo{err: errors.New("db down")})
+
+	err := worker.RunOnce(context.Background(), 10)
+	if err == nil || !strings.Contains(err.Error(), "0xbatch-lender-a") {
+		t.Fatalf("expected the recording failure reported with the batch tx, got %v", err)
+	}
+	if len(outbox.doneIDs) != 2 || len(outbox.retryIDs) != 0 || len(outbox.failedIDs) != 0 {
+		t.Fatalf("expected submitted jobs done without retry, done=%v retry=%v failed=%v", outbox.doneIDs, outbox.retryIDs, outbox.failedIDs)
+	}
+	if len(writer.batches) != 1 {
+		t.Fatalf("expected one submission, got %v", writer.batches)
+	}
+}
@@ -0,0 +1,321 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	lenderdomain "github.com/loangraph/backend/internal/domain/lender"
+	loandomain "github.com/loangraph/backend/internal/domain/loan"
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+)
+
+type poolRepoMock struct {
+	items       map[string]*pooldomain.Entity
+	allocations map[string]pooldomain.Allocation
+	getErr      error
+}
+
+func (m *poolRepoMock) Create(_ context.Context, in pooldomain.CreateInput) (*pooldomain.Entity, error) {
+	e := &pooldomain.Entity{ID: "pool-1", LenderID: in.LenderID, Name: in.Name, PoolTokenAddr: in.PoolTokenAddr, TargetAPYBPS: in.TargetAPYBPS, CurrencyCode: in.CurrencyCode, Status: in.Status}
+	m.items[e.ID] = e
+	return e, nil
+}
+
+func (m *poolRepoMock) GetByID(_ context.Context, id string) (*pooldomain.Entity, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	if e, ok := m.items[id]; ok {
+		cp := *e
+		return &cp, nil
+	}
+	return nil, pooldomain.ErrNotFound
+}
+
+func (m *poolRepoMock) ListByLender(_ context.Context, _ string) ([]pooldomain.Entity, error) {
+	return nil, nil
+}
+
+func (m *poolRepoMock) List(_ context.Context, _, _, _ string, _, _ int32) ([]pooldomain.Entity, error) {
+	return nil, nil
+}
+
+func (m *poolRepoMock) Update(_ context.Context, id string, in pooldomain.UpdateInput) (*pooldomain.Entity, error) {
+	e, ok := m.items[id]
+	if !ok {
+		return nil, context.Canceled
+	}
+	e.Name, e.TargetAPYBPS, e.Status = in.Name, in.TargetAPYBPS, in.Status
+	cp := *e
+	return &cp, nil
+}
+
+func (m *poolRepoMock) AllocateLoan(_ context.Context, poolID, loanID string, amountMinor int64) (*pooldomain.Allocation, error) {
+	if _, ok := m.allocations[loanID]; ok {
+		return nil, pooldomain.ErrLoanAlreadyAllocated
+	}
+	a := pooldomain.Allocation{ID: "alloc-" + loanID, PoolID: poolID, LoanID: loanID, AmountMinor: amountMinor}
+	m.allocations[loanID] = a
+	m.items[poolID].TotalDeployed += amountMinor
+	return &a, nil
+}
+
+func (m *poolRepoMock) ReleaseLoan(_ context.Context, poolID, loanID string) (*pooldomain.Allocation, error) {
+	a, ok := m.allocations[loanID]
+	if !ok || a.PoolID != poolID {
+		return nil, pooldomain.ErrAllocationNotFound
+	}
+	delete(m.allocations, loanID)
+	m.items[poolID].TotalDeployed -= a.AmountMinor
+	return &a, nil
+}
+
+func (m *poolRepoMock) ListAllocations(_ context.Context, _ string, _, _ int32) ([]pooldomain.Allocation, error) {
+	return nil, nil
+}
+
+func (m *poolRepoMock) GetPerformanceSummary(_ context.Context, _ string) (*pooldomain.PerformanceSummary, error) {
+	return &pooldomain.PerformanceSummary{}, nil
+}
+
+func (m *poolRepoMock) GetRepaymentTimeSeries(_ context.Context, _ string, _ int32) ([]loandomain.PerformancePoint, error) {
+	return nil, nil
+}
+
+type poolLenderRepoMock struct {
+	lenderID string
+	members  []string
+}
+
+func (m *poolLenderRepoMock) GetByID(_ context.Context, id string) (*lenderdomain.Entity, error) {
+	if id != m.lenderID {
+		return nil, lenderdomain.ErrNotFound
+	}
+	return &lenderdomain.Entity{ID: id}, nil
+}
+
+func (m *poolLenderRepoMock) IsMember(_ context.Context, lenderID, userID string) (bool, error) {
+	return lenderID == m.lenderID && slices.Contains(m.members, userID), nil
+}
+
+type poolLoanRepoMock struct {
+	currencies map[string]bool
+	loans      map[string]*loandomain.Entity
+	getErr     error
+}
+
+func (m *poolLoanRepoMock) GetByID(_ context.Context, id string) (*loandomain.Entity, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	if l, ok := m.loans[id]; ok {
+		return l, nil
+	}
+	return nil, loandomain.ErrNotFound
+}
+
+func (m *poolLoanRepoMock) List(_ context.Context, f loandomain.ListFilter) ([]loandomain.Entity, error) {
+	if m.currencies[f.CurrencyCode] {
+		return []loandomain.Entity{{ID: "loan-1", LenderID: f.LenderID, CurrencyCode: f.CurrencyCode}}, nil
+	}
+	return []loandomain.Entity{}, nil
+}
+
+func TestPoolServiceCreateUpdateAndClose(t *testing.T) {
+	poolRepo := &poolRepoMock{items: map[string]*pooldomain.Entity{}, allocations: map[string]pooldomain.Allocation{}}
+	auditRepo := &adminAuditRepoMock{}
+	svc := pooldomain.NewService(
+		poolRepo,
+		&poolLenderRepoMock{lenderID: "lender-1", members: []string{"user-1"}},
+		&poolLoanRepoMock{currencies: map[string]bool{"NGN": true}},
+		auditRepo,
+	)
+	ctx := context.Background()
+	member := pooldomain.Actor{UserID: "user-1"}
+
+	if _, err := svc.CreatePool(ctx, member, pooldomain.CreateInput{LenderID: "lender-1", Name: "KES Pool", CurrencyCode: "KES", TargetAPYBPS: 1200}); !errors.Is(err, pooldomain.ErrCurrencyMismatch) {
+		t.Fatalf("expected currency mismatch, got %v", err)
+	}
+	if _, err := svc.CreatePool(ctx, member, pooldomain.CreateInput{LenderID: "lender-1", Name: "NGN Pool", CurrencyCode: "ngn", TargetAPYBPS: 0}); !errors.Is(err, pooldomain.ErrInvalidTargetAPY) {
+		t.Fatalf("expected invalid target apy, got %v", err)
+	}
+
+	created, err := svc.CreatePool(ctx, member, pooldomain.CreateInput{LenderID: "lender-1", Name: "NGN Pool", CurrencyCode: "ngn", TargetAPYBPS: 1200})
+	if err != nil {
+		t.Fatalf("create pool error: %v", err)
+	}
+	if created.Status != pooldomain.StatusOpen || created.CurrencyCode != "NGN" {
+		t.Fatalf("unexpected created pool: %+v", created)
+	}
+
+	apy := int32(1500)
+	updated, err := svc.UpdatePool(ctx, member, created.ID, pooldomain.UpdateRequest{TargetAPYBPS: &apy})
+	if err != nil {
+		t.Fatalf("update pool error: %v", err)
+	}
+	if updated.TargetAPYBPS != 1500 || updated.Name != "NGN Pool" {
+		t.Fatalf("unexpected updated pool: %+v", updated)
+	}
+
+	settled := pooldomain.StatusSettled
+	if _, err := svc.UpdatePool(ctx, member, created.ID, pooldomain.UpdateRequest{Status: &settled}); !errors.Is(err, pooldomain.ErrInvalidStatusTransition) {
+		t.Fatalf("expected open->settled to be rejected, got %v", err)
+	}
+	if _, err := svc.ClosePool(ctx, member, created.ID); err != nil {
+		t.Fatalf("close pool error: %v", err)
+	}
+	apy = 1800
+	if _, err := svc.UpdatePool(ctx, member, created.ID, pooldomain.UpdateRequest{TargetAPYBPS: &apy}); !errors.Is(err, pooldomain.ErrPoolNotOpen) {
+		t.Fatalf("expected apy change on closed pool to be rejected, got %v", err)
+	}
+	if _, err := svc.UpdatePool(ctx, member, created.ID, pooldomain.UpdateRequest{Status: &settled}); err != nil {
+		t.Fatalf("settle pool error: %v", err)
+	}
+	if _, err := svc.ClosePool(ctx, member, "missing"); !errors.Is(err, pooldomain.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	poolRepo.getErr = errors.New("connection reset")
+	if _, err := svc.ClosePool(ctx, member, created.ID); !errors.Is(err, poolRepo.getErr) {
+		t.Fatalf("expected lookup error propagated, got %v", err)
+	}
+	poolRepo.getErr = nil
+
+	actions := []string{}
+	for _, l := range auditRepo.logs {
+		actions = append(actions, l.Action)
+	}
+	want := []string{"pool_created", "pool_updated", "pool_status_updated", "pool_status_updated"}
+	if len(actions) != len(want) {
+		t.Fatalf("expected audit actions %v, got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("expected audit actions %v, got %v", want, actions)
+		}
+	}
+}
+
+func TestPoolServiceAllocateAndReleaseLoans(t *testing.T) {
+	poolRepo := &poolRepoMock{
+		items:       map[string]*pooldomain.Entity{"pool-1": {ID: "pool-1", LenderID: "lender-1", CurrencyCode: "NGN", Status: pooldomain.StatusOpen}},
+		allocations: map[string]pooldomain.Allocation{},
+	}
+	auditRepo := &adminAuditRepoMock{}
+	loanRepo := &poolLoanRepoMock{loans: map[string]*loandomain.Entity{
+		"loan-1": {ID: "loan-1", LenderID: "lender-1", CurrencyCode: "NGN", Status: "active", PrincipalMinor: 100000},
+		"loan-2": {ID: "loan-2", LenderID: "lender-1", CurrencyCode: "KES", Status: "active", PrincipalMinor: 50000},
+		"loan-3": {ID: "loan-3", LenderID: "lender-2", CurrencyCode: "NGN", Status: "active", PrincipalMinor: 50000},
+		"loan-4": {ID: "loan-4", LenderID: "lender-1", CurrencyCode: "NGN", Status: "defaulted", PrincipalMinor: 50000},
+	}}
+	svc := pooldomain.NewService(
+		poolRepo,
+		&poolLenderRepoMock{lenderID: "lender-1", members: []string{"user-1"}},
+		loanRepo,
+		auditRepo,
+	)
+	ctx := context.Background()
+	member := pooldomain.Actor{UserID: "user-1"}
+
+	result, err := svc.AllocateLoans(ctx, member, "pool-1", []string{"loan-1", "loan-2", "loan-3", "loan-4", "loan-1"})
+	if err != nil {
+		t.Fatalf("allocate loans error: %v", err)
+	}
+	if len(result.Allocated) != 1 || result.Allocated[0].LoanID != "loan-1" {
+		t.Fatalf("expected only loan-1 allocated, got %+v", result.Allocated)
+	}
+	wantErrors := map[string]string{"loan-2": "currency_mismatch", "loan-3": "loan_not_eligible", "loan-4": "loan_not_eligible"}
+	if len(result.Errors) != 4 {
+		t.Fatalf("expected 4 allocation errors, got %+v", result.Errors)
+	}
+	for _, e := range result.Errors[:3] {
+		if wantErrors[e.LoanID] != e.Error {
+			t.Fatalf("unexpected allocation error %+v", e)
+		}
+	}
+	if result.Errors[3].Error != "loan_already_allocated" {
+		t.Fatalf("expected duplicate allocation error, got %+v", result.Errors[3])
+	}
+	if poolRepo.items["pool-1"].TotalDeployed != 100000 {
+		t.Fatalf("expected total deployed 100000, got %d", poolRepo.items["pool-1"].TotalDeployed)
+	}
+
+	if err := svc.ReleaseLoan(ctx, member, "pool-1", "loan-1"); err != nil {
+		t.Fatalf("release loan error: %v", err)
+	}
+	if poolRepo.items["pool-1"].TotalDeployed != 0 {
+		t.Fatalf("expected total deployed 0 after release")
+	}
+	if err := svc.ReleaseLoan(ctx, member, "pool-1", "loan-1"); !errors.Is(err, pooldomain.ErrAllocationNotFound) {
+		t.Fatalf("expected allocation not found, got %v", err)
+	}
+
+	// An unknown loan is reported per loan; a failed lookup fails the call.
+	result, err = svc.AllocateLoans(ctx, member, "pool-1", []string{"loan-missing"})
+	if err != nil || len(result.Errors) != 1 || result.Errors[0].Error != "loan_not_eligible" {
+		t.Fatalf("expected unknown loan not eligible, got %+v %v", result, err)
+	}
+	loanRepo.getErr = errors.New("connection reset")
+	if _, err := svc.AllocateLoans(ctx, member, "pool-1", []string{"loan-1"}); !errors.Is(err, loanRepo.getErr) {
+		t.Fatalf("expected lookup error propagated, got %v", err)
+	}
+	loanRepo.getErr = nil
+
+	poolRepo.items["pool-1"].Status = pooldomain.StatusClosed
+	if _, err := svc.AllocateLoans(ctx, member, "pool-1", []string{"loan-1"}); !errors.Is(err, pooldomain.ErrPoolNotOpen) {
+		t.Fatalf("expected allocation into closed pool to fail, got %v", err)
+	}
+	if len(auditRepo.logs) != 2 {
+		t.Fatalf("expected 2 audit logs, got %d", len(auditRepo.logs))
+	}
+}
+
+func TestPoolServiceRefusesOtherLendersPools(t *testing.T) {
+	poolRepo := &poolRepoMock{
+		items:       map[string]*pooldomain.Entity{"pool-1": {ID: "pool-1", LenderID: "lender-1", CurrencyCode: "NGN", Status: pooldomain.StatusOpen}},
+		allocations: map[string]pooldomain.Allocation{"loan-1": {ID: "alloc-loan-1", PoolID: "pool-1", LoanID: "loan-1", AmountMinor: 100000}},
+	}
+	auditRepo := &adminAuditRepoMock{}
+	svc := pooldomain.NewService(
+		poolRepo,
+		&poolLenderRepoMock{lenderID: "lender-1", members: []string{"user-1"}},
+		&poolLoanRepoMock{
+			currencies: map[string]bool{"NGN": true},
+			loans:      map[string]*loandomain.Entity{"loan-2": {ID: "loan-2", LenderID: "lender-1", CurrencyCode: "NGN", Status: "active", PrincipalMinor: 50000}},
+		},
+		auditRepo,
+	)
+	ctx := context.Background()
+	// user-2 acts for another lender.
+	outsider := pooldomain.Actor{UserID: "user-2"}
+	closed := pooldomain.StatusClosed
+
+	if _, err := svc.CreatePool(ctx, outsider, pooldomain.CreateInput{LenderID: "lender-1", Name: "NGN Pool", CurrencyCode: "NGN", TargetAPYBPS: 1200}); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected create forbidden, got %v", err)
+	}
+	if _, err := svc.UpdatePool(ctx, outsider, "pool-1", pooldomain.UpdateRequest{Status: &closed}); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected update forbidden, got %v", err)
+	}
+	if _, err := svc.ClosePool(ctx, outsider, "pool-1"); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected close forbidden, got %v", err)
+	}
+	if _, err := svc.AllocateLoans(ctx, outsider, "pool-1", []string{"loan-2"}); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected allocation forbidden, got %v", err)
+	}
+	if err := svc.ReleaseLoan(ctx, outsider, "pool-1", "loan-1"); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected release forbidden, got %v", err)
+	}
+	if _, err := svc.ListAllocations(ctx, outsider, "pool-1", 50, 0); !errors.Is(err, pooldomain.ErrForbidden) {
+		t.Fatalf("expected listing forbidden, got %v", err)
+	}
+	if poolRepo.items["pool-1"].Status != pooldomain.StatusOpen || len(poolRepo.allocations) != 1 || len(auditRepo.logs) != 0 {
+		t.Fatalf("expected nothing changed or audited, got %+v %+v %d", poolRepo.items["pool-1"], poolRepo.allocations, len(auditRepo.logs))
+	}
+
+	// Admins act for every lender.
+	admin := pooldomain.Actor{UserID: "admin-1", Admin: true}
+	if _, err := svc.ClosePool(ctx, admin, "pool-1"); err != nil {
+		t.Fatalf("expected admin close to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pooldomain "github.com/loangraph/backend/internal/domain/pool"
+	positiondomain "github.com/loangraph/backend/internal/domain/position"
+)
+
+type positionRepoMock struct {
+	state        positiondomain.PoolState
+	positions    map[string]*positiondomain.Position
+	transactions []positiondomain.Transaction
+	tokenAddrs   []string
+	conflicts    int
+}
+
+func (m *positionRepoMock) RecordTransaction(_ context.Context, in positiondomain.TransactionInput) (*positiondomain.Transaction, error) {
+	if m.conflicts > 0 {
+		m.conflicts--
+		return nil, positiondomain.ErrConcurrentUpdate
+	}
+	if in.ExpectedTotalShares != m.state.TotalShares {
+		return nil, positiondomain.ErrConcurrentUpdate
+	}
+	p, ok := m.positions[in.InvestorUserID]
+	if !ok {
+		p = &positiondomain.Position{PoolID: in.PoolID, InvestorUserID: in.InvestorUserID}
+		m.positions[in.InvestorUserID] = p
+	}
+	switch in.Kind {
+	case positiondomain.KindDeposit:
+		p.Shares += in.Shares
+		p.DepositedMinor += in.AmountMinor
+		m.state.TotalShares += in.Shares
+		m.state.DepositedMinor += in.AmountMinor
+	case positiondomain.KindWithdrawal:
+		if p.Shares < in.Shares {
+			return nil, positiondomain.ErrInsufficientShares
+		}
+		p.Shares -= in.Shares
+		p.WithdrawnMinor += in.AmountMinor
+		m.state.TotalShares -= in.Shares
+		m.state.WithdrawnMinor += in.AmountMinor
+	}
+	tx := positiondomain.Transaction{ID: "tx-" + in.Kind, PoolID: in.PoolID, InvestorUserID: in.InvestorUserID, Kind: in.Kind, AmountMinor: in.AmountMinor, Shares: in.Shares, NAVPerShareMicros: in.NAVPerShareMicros}
+	m.transactions = append(m.transactions, tx)
+	m.tokenAddrs = append(m.tokenAddrs, in.PoolTokenAddr)
+	return &tx, nil
+}
+
+func (m *positionRepoMock) GetPoolState(_ context.Context, _ string) (*positiondomain.PoolState, error) {
+	cp := m.state
+	return &cp, nil
+}
+
+func (m *positionRepoMock) ListPositions(_ context.Context, investorUserID string) ([]positiondomain.Position, error) {
+	out := []positiondomain.Position{}
+	if p, ok := m.positions[investorUserID]; ok {
+		out = append(out, *p)
+	}
+	return out, nil
+}
+
+func (m *positionRepoMock) ListTransactions(_ context.Context, _, _ string, _, _ int32) ([]positiondomain.Transaction, error) {
+	return m.transactions, nil
+}
+
+func (m *positionRepoMock) SetTransactionSubmission(_ context.Context, _, _ string) error {
+	return nil
+}
+
+type positionPoolRepoMock struct {
+	pool *pooldomain.Entity
+}
+
+func (m *positionPoolRepoMock) GetByID(_ context.Context, id string) (*pooldomain.Entity, error) {
+	if m.pool.ID != id {
+		return nil, pooldomain.ErrNotFound
+	}
+	cp := *m.pool
+	return &cp, nil
+}
+
+func TestPositionServiceDepositWithdrawAndNAV(t *testing.T) {
+	repo := &positionRepoMock{positions: map[string]*positiondomain.Position{}}
+	poolRepo := &positionPoolRepoMock{pool: &pooldomain.Entity{ID: "pool-1", Name: "NGN Pool", CurrencyCode: "NGN", Status: pooldomain.StatusOpen, PoolTokenAddr: "0x114dac28b091F2d73dF6509E4063D6553eB36fa2"}}
+	svc := positiondomain.NewService(repo, poolRepo)
+	ctx := context.Background()
+
+	dep, err := svc.Deposit(ctx, "user-1", "pool-1", 100000)
+	if err != nil {
+		t.Fatalf("deposit error: %v", err)
+	}
+	if dep.Shares != 100000 || dep.NAVPerShareMicros != positiondomain.NAVScale {
+		t.Fatalf("expected first deposit at par, got %+v", dep)
+	}
+	if len(repo.tokenAddrs) != 1 || repo.tokenAddrs[0] != poolRepo.pool.PoolTokenAddr {
+		t.Fatalf("expected the pool_position job recorded with the deposit, got %v", repo.tokenAddrs)
+	}
+	var payload map[string]any
+	_ = json.Unmarshal(positiondomain.PoolPositionPayload(dep, poolRepo.pool.PoolTokenAddr), &payload)
+	if payload["pool_token_addr"] != poolRepo.pool.PoolTokenAddr || payload["action"] != "deposit" || payload["transaction_id"] != dep.ID {
+		t.Fatalf("unexpected outbox payload: %v", payload)
+	}
+
+	// 10% interest collected on allocated loans lifts NAV for existing holders.
+	repo.state.InterestCollectedMinor = 10000
+	views, err := svc.ListPositions(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("list positions error: %v", err)
+	}
+	if len(views) != 1 || views[0].NAVPerShareMicros != 1_100_000 || views[0].ValueMinor != 110000 || views[0].ReturnMinor != 10000 {
+		t.Fatalf("unexpected position view: %+v", views)
+	}
+
+	repo.conflicts = 1
+	second, err := svc.Deposit(ctx, "user-1", "pool-1", 11000)
+	if err != nil {
+		t.Fatalf("second deposit error: %v", err)
+	}
+	if second.Shares != 10000 {
+		t.Fatalf("expected 10000 shares at NAV 1.1, got %d", second.Shares)
+	}
+
+	if _, err := svc.Withdraw(ctx, "user-1", "pool-1", 1_000_000); !errors.Is(err, positiondomain.ErrInsufficientShares) {
+		t.Fatalf("expected insufficient shares, got %v", err)
+	}
+	wd, err := svc.Withdraw(ctx, "user-1", "pool-1", 55000)
+	if err != nil {
+		t.Fatalf("withdraw error: %v", err)
+	}
+	if wd.Shares != 50000 {
+		t.Fatalf("expected 50000 shares burned, got %d", wd.Shares)
+	}
+
+	poolRepo.pool.Status = pooldomain.StatusClosed
+	if _, err := svc.Deposit(ctx, "user-1", "pool-1", 1000); !errors.Is(err, positiondomain.ErrPoolNotOpen) {
+		t.Fatalf("expected deposit into closed pool to fail, got %v", err)
+	}
+	if _, err := svc.Withdraw(ctx, "user-1", "pool-1", 1000); err != nil {
+		t.Fatalf("expected withdrawal from closed pool to succeed, got %v", err)
+	}
+}
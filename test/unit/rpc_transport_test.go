@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loangraph/backend/internal/blockchain"
+)
+
+func rpcResultServer(hits *int32, status int, result any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+}
+
+func TestRPCTransportFailsOverReads(t *testing.T) {
+	var badHits, goodHits int32
+	bad := rpcResultServer(&badHits, http.StatusBadGateway, nil)
+	defer bad.Close()
+	good := rpcResultServer(&goodHits, http.StatusOK, "0x10")
+	defer good.Close()
+
+	transport, err := blockchain.NewRPCTransport([]string{bad.URL, good.URL}, time.Second, 2)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	client := blockchain.NewJSONRPCLogClientWithTransport(transport)
+	for i := 0; i < 3; i++ {
+		n, err := client.BlockNumber(context.Background())
+		if err != nil || n != 16 {
+			t.Fatalf("expected block 16 via failover, got %d (%v)", n, err)
+		}
+	}
+	// After its first failure the bad endpoint ranks last and is not retried
+	// while the good one keeps answering.
+	if badHits != 1 || goodHits != 3 {
+		t.Fatalf("expected 1 bad and 3 good hits, got %d and %d", badHits, goodHits)
+	}
+	health := transport.Health()
+	if health[0].URL != good.URL || health[1].Failures != 1 || health[1].ErrorRate <= 0 {
+		t.Fatalf("unexpected health ranking: %+v", health)
+	}
+}
+
+func TestRPCTransportMarksEndpointDown(t *testing.T) {
+	var badHits, goodHits int32
+	bad := rpcResultServer(&badHits, http.StatusServiceUnavailable, nil)
+	defer bad.Close()
+	good := rpcResultServer(&goodHits, http.StatusServiceUnavailable, nil)
+	defer good.Close()
+
+	transport, err := blockchain.NewRPCTransport([]string{bad.URL, good.URL}, time.Second, 5)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	var out string
+	if err := transport.Call(context.Background(), "eth_blockNumber", nil, &out); blockchain.KindOf(err) != blockchain.KindTransient {
+		t.Fatalf("expected transient error once all endpoints fail, got %v", err)
+	}
+	for _, h := range transport.Health() {
+		if h.DownUntil == nil || h.ConsecutiveFailures != 3 {
+			t.Fatalf("expected endpoint down after 3 failures, got %+v", h)
+		}
+	}
+
+	// Once the cooldown is over, a single further failure takes the
+	// endpoints down again.
+	now := time.Now().Add(time.Minute)
+	transport.SetClock(func() time.Time { return now })
+	if err := transport.Call(context.Background(), "eth_blockNumber", nil, &out); err == nil {
+		t.Fatalf("expected the call to fail again")
+	}
+	for _, h := range transport.Health() {
+		if h.DownUntil == nil || !h.DownUntil.After(now) {
+			t.Fatalf("expected endpoint down again after its cooldown, got %+v", h)
+		}
+	}
+}
+
+func TestRPCTransportDoesNotResendWrites(t *testing.T) {
+	var firstHits, secondHits int32
+	first := rpcResultServer(&firstHits, http.StatusBadGateway, nil)
+	defer first.Close()
+	second := rpcResultServer(&secondHits, http.StatusOK, "0xabc")
+	defer second.Close()
+
+	transport, err := blockchain.NewRPCTransport([]string{first.URL, second.URL}, time.Second, 2)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	var hash string
+	if err := transport.Call(context.Background(), "eth_sendTransaction", []any{map[string]string{}}, &hash); err == nil {
+		t.Fatalf("expected the ambiguous write failure to be returned")
+	}
+	if firstHits != 1 || secondHits != 0 {
+		t.Fatalf("expected the write to be sent once, got %d and %d", firstHits, secondHits)
+	}
+
+	// An endpoint that refuses connections never saw the write, so it moves on.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	transport, err = blockchain.NewRPCTransport([]string{down.URL, second.URL}, time.Second, 0)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	if err := transport.Call(context.Background(), "eth_sendTransaction", []any{map[string]string{}}, &hash); err != nil || hash != "0xabc" {
+		t.Fatalf("expected write to fail over past a refused connection, got %q (%v)", hash, err)
+	}
+}
+
+func TestRPCTransportBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			t.Fatalf("expected batch request: %v", err)
+		}
+		resps := make([]map[string]any, 0, len(reqs))
+		// Servers may answer a batch in any order.
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resp := map[string]any{"jsonrpc": "2.0", "id": reqs[i].ID}
+			switch reqs[i].Method {
+			case "eth_blockNumber":
+				resp["result"] = "0x10"
+			case "eth_gasPrice":
+				resp["result"] = "0x3b9aca00"
+			default:
+				resp["error"] = map[string]any{"code": -32601, "message": "method not found"}
+			}
+			resps = append(resps, resp)
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+
+	transport, err := blockchain.NewRPCTransport([]string{srv.URL}, time.Second, 0)
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	var block, price, other string
+	calls := []blockchain.RPCCall{
+		{Method: "eth_blockNumber", Out: &block},
+		{Method: "eth_gasPrice", Out: &price},
+		{Method: "eth_unknown", Out: &other},
+	}
+	if err := transport.Batch(context.Background(), calls); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if block != "0x10" || price != "0x3b9aca00" || calls[0].Err != nil || calls[1].Err != nil {
+		t.Fatalf("unexpected batch results: %q %q %+v", block, price, calls)
+	}
+	if blockchain.KindOf(calls[2].Err) != blockchain.KindPermanent {
+		t.Fatalf("expected permanent error for unknown method, got %v", calls[2].Err)
+	}
+}
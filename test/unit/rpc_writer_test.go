@@ -3,9 +3,11 @@ package unit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/loangraph/backend/internal/blockchain"
 )
@@ -41,3 +43,132 @@ func TestRPCWriterSendTransaction(t *testing.T) {
 		t.Fatalf("unexpected tx hash: %s", tx)
 	}
 }
+
+func TestRPCWriterClassifiesErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		rpcErr  map[string]any
+		want    blockchain.ErrorKind
+		loanID  string
+		noServe bool
+	}{
+		{name: "nonce too low", status: http.StatusOK, rpcErr: map[string]any{"code": -32000, "message": "nonce too low"}, want: blockchain.KindNonceTooLow, loanID: "loan-1"},
+		{name: "already known", status: http.StatusOK, rpcErr: map[string]any{"code": -32000, "message": "already known"}, want: blockchain.KindAlreadyKnown, loanID: "loan-1"},
+		{name: "reverted", status: http.StatusOK, rpcErr: map[string]any{"code": 3, "message": "execution reverted: unknown loan"}, want: blockchain.KindReverted, loanID: "loan-1"},
+		{name: "invalid params", status: http.StatusOK, rpcErr: map[string]any{"code": -32602, "message": "invalid argument 0"}, want: blockchain.KindPermanent, loanID: "loan-1"},
+		{name: "node busy", status: http.StatusOK, rpcErr: map[string]any{"code": -32000, "message": "txpool is full"}, want: blockchain.KindTransient, loanID: "loan-1"},
+		{name: "gateway error", status: http.StatusBadGateway, want: blockchain.KindTransient, loanID: "loan-1"},
+		{name: "validation", status: http.StatusOK, want: blockchain.KindPermanent, loanID: " ", noServe: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.noServe {
+					t.Fatalf("unexpected rpc call")
+				}
+				w.WriteHeader(tc.status)
+				_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "error": tc.rpcErr})
+			}))
+			defer srv.Close()
+
+			w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+			if err != nil {
+				t.Fatalf("new rpc writer: %v", err)
+			}
+			_, err = w.RegisterLoan(context.Background(), tc.loanID)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			var chainErr *blockchain.Error
+			if !errors.As(err, &chainErr) || chainErr.Kind != tc.want {
+				t.Fatalf("expected %s error, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRPCWriterUsesManagedNonces(t *testing.T) {
+	var sent []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		var result any
+		switch req.Method {
+		case "eth_getTransactionCount":
+			result = "0x5"
+		case "eth_gasPrice":
+			result = "0x3b9aca00"
+		case "eth_sendTransaction":
+			var txObj map[string]any
+			_ = json.Unmarshal(req.Params[0], &txObj)
+			sent = append(sent, txObj)
+			result = "0xabc"
+		default:
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	w.SetNonceManager(blockchain.NewNonceManager(newMemNonceStore(), time.Minute, 20))
+	for _, loanID := range []string{"loan-1", "loan-2"} {
+		if _, err := w.RegisterLoan(context.Background(), loanID); err != nil {
+			t.Fatalf("register loan: %v", err)
+		}
+	}
+	if len(sent) != 2 || sent[0]["nonce"] != "0x5" || sent[1]["nonce"] != "0x6" {
+		t.Fatalf("expected nonces 0x5 and 0x6, got %+v", sent)
+	}
+	if sent[0]["gasPrice"] != "0x3b9aca00" {
+		t.Fatalf("expected explicit gas price, got %v", sent[0]["gasPrice"])
+	}
+}
+
+func TestRPCWriterResolvesAlreadyKnownTransactions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": 1}
+		switch req.Method {
+		case "eth_getTransactionCount":
+			resp["result"] = "0x5"
+		case "eth_gasPrice":
+			resp["result"] = "0x3b9aca00"
+		case "eth_sendTransaction":
+			resp["error"] = map[string]any{"code": -32000, "message": "already known"}
+		case "eth_signTransaction":
+			resp["result"] = map[string]any{"raw": "0xf86b", "tx": map[string]any{"hash": "0xknown"}}
+		default:
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	w, err := blockchain.NewRPCWriter(srv.URL, "0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", 300000)
+	if err != nil {
+		t.Fatalf("new rpc writer: %v", err)
+	}
+	w.SetNonceManager(blockchain.NewNonceManager(newMemNonceStore(), time.Minute, 20))
+	txHash, err := w.RegisterLoan(context.Background(), "loan-1")
+	if err != nil {
+		t.Fatalf("register loan: %v", err)
+	}
+	if txHash != "0xknown" {
+		t.Fatalf("expected the pending transaction's hash, got %s", txHash)
+	}
+}